@@ -1,11 +1,19 @@
 package main
 
 import (
+	"crontab_go/internal/application/audit"
+	"crontab_go/internal/application/channel"
+	"crontab_go/internal/application/digest"
+	"crontab_go/internal/application/statistics"
 	"crontab_go/internal/application/system"
 	"crontab_go/internal/application/template"
 	"crontab_go/internal/domain/service"
+	"crontab_go/internal/infrastructure/config"
+	"crontab_go/internal/infrastructure/metrics"
 	"crontab_go/internal/infrastructure/persistence"
 	"crontab_go/internal/interfaces/http"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -17,25 +25,6 @@ func main() {
 	// 检查命令行参数
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
-		case "--help", "-h":
-			fmt.Println("Crontab Go - 现代化定时任务管理系统")
-			fmt.Println("")
-			fmt.Println("用法:")
-			fmt.Println("  crontab_go [选项]")
-			fmt.Println("")
-			fmt.Println("选项:")
-			fmt.Println("  --help, -h     显示帮助信息")
-			fmt.Println("  --version, -v  显示版本信息")
-			fmt.Println("")
-			fmt.Println("环境变量:")
-			fmt.Println("  DB_PATH        数据库文件路径 (默认: crontab.db)")
-			fmt.Println("  JWT_SECRET     JWT 密钥")
-			fmt.Println("  PORT           服务端口 (默认: 8080)")
-			fmt.Println("  GIN_MODE       运行模式 (debug/release)")
-			fmt.Println("")
-			fmt.Println("访问 http://localhost:8080 开始使用")
-			fmt.Println("默认账户: admin/admin123")
-			return
 		case "--version", "-v":
 			fmt.Println("Crontab Go v1.0.0")
 			fmt.Println("Build with Go", runtime.Version())
@@ -43,8 +32,18 @@ func main() {
 		}
 	}
 
+	// 加载配置：默认值 < 配置文件 < 环境变量 < 命令行参数
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage()
+		return
+	}
+	if err != nil {
+		log.Fatal("Failed to load config: ", err)
+	}
+
 	// 初始化数据库
-	db, err := persistence.NewSQLiteDB("crontab.db")
+	db, err := persistence.NewSQLiteDB(cfg.Database.Path)
 	if err != nil {
 		log.Fatal("Failed to connect database:", err)
 	}
@@ -52,18 +51,52 @@ func main() {
 	// 初始化任务执行器
 	taskRepo := persistence.NewTaskRepository(db.Client)
 	taskLogRepo := persistence.NewTaskLogRepository(db.Client)
-	executor := service.NewTaskExecutor(taskRepo, taskLogRepo)
+	channelRepo := persistence.NewNotificationChannelRepository(db.Client)
+	templateRepo := persistence.NewTaskTemplateRepository(db.Client)
+	digestRepo := persistence.NewReportDigestRepository(db.Client)
+	deliveryRepo := persistence.NewNotificationDeliveryRepository(db.Client)
+	alertRepo := persistence.NewTaskAlertRepository(db.Client)
+	policyRepo := persistence.NewEscalationPolicyRepository(db.Client)
+	heartbeatRepo := persistence.NewTaskHeartbeatRepository(db.Client)
+	projectRepo := persistence.NewProjectRepository(db.Client)
+
+	// 将任务、模板和报表摘要中的内联通知配置迁移为通知渠道
+	if err := channel.NewService(channelRepo).MigrateInlineConfigs(taskRepo, templateRepo, digestRepo); err != nil {
+		log.Printf("Failed to migrate notification configs: %v", err)
+	}
+
+	// 将任务执行和通知发送记录为 Prometheus 指标
+	service.SetObserver(metrics.Observer{})
+
+	// 启动通知分发器，异步发送任务执行通知并在失败时重试
+	dispatcher := service.NewNotificationDispatcher(deliveryRepo, channelRepo, cfg.Notifier.Workers, cfg.Retention.Deliveries.Std())
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	executor := service.NewTaskExecutor(taskRepo, taskLogRepo, channelRepo, deliveryRepo, alertRepo, policyRepo, projectRepo)
 	executor.Start()
 	defer executor.Stop()
 
+	// 启动告警升级检查，按升级策略通知未确认的告警
+	escalationEvaluator := service.NewEscalationEvaluator(alertRepo, taskRepo, taskLogRepo, channelRepo, policyRepo, deliveryRepo)
+	escalationEvaluator.Start()
+	defer escalationEvaluator.Stop()
+
+	// 启动心跳检查，心跳任务错过上报时记录失败日志并通知
+	heartbeatMonitor := service.NewHeartbeatMonitor(taskRepo, taskLogRepo, channelRepo, deliveryRepo, alertRepo, policyRepo, heartbeatRepo)
+	heartbeatMonitor.Start()
+	defer heartbeatMonitor.Stop()
+
+	metrics.RegisterSchedulerEntries(executor.EntryCount)
+
 	// 初始化系统服务
 	systemRepo := persistence.NewSystemRepository(db.Client)
 	systemService := system.NewService(systemRepo)
+	metrics.RegisterSystemStats(systemService.GetLatestStats)
 
 	// 初始化模板服务并创建默认数据
-	templateRepo := persistence.NewTaskTemplateRepository(db.Client)
 	categoryRepo := persistence.NewTaskTemplateCategoryRepository(db.Client)
-	templateService := template.NewService(templateRepo, categoryRepo, taskRepo)
+	templateService := template.NewService(templateRepo, categoryRepo, taskRepo, channelRepo)
 
 	// 初始化默认分类和模板
 	if err := templateService.InitializeDefaultCategories(); err != nil {
@@ -76,25 +109,67 @@ func main() {
 	// 启动系统监控数据收集
 	go func() {
 		for {
-			// 每10秒收集一次系统监控数据
 			if err := systemService.CollectAndSaveStats(); err != nil {
 				log.Printf("Failed to collect system stats: %v", err)
 			}
-			time.Sleep(10 * time.Second)
+			time.Sleep(cfg.Collector.SystemStatsInterval.Std())
 		}
 	}()
 
-	// 启动数据清理任务，每分钟清理一次旧数据
+	// 启动数据清理任务，定期清理旧数据
+	auditService := audit.NewService(persistence.NewAuditLogRepository(db.Client))
 	go func() {
 		for {
-			time.Sleep(1 * time.Minute)
-			if err := systemService.CleanOldStats(); err != nil {
+			time.Sleep(cfg.Collector.CleanupInterval.Std())
+			if err := systemService.CleanOldStats(cfg.Retention.SystemStats); err != nil {
 				log.Printf("Failed to clean old stats: %v", err)
 			}
+			auditService.Cleanup(cfg.Retention.AuditLogs.Std())
 		}
 	}()
 
+	// 启动报表摘要发送任务，定期检查到期的摘要
+	digestService := digest.NewService(digestRepo, statistics.NewService(taskRepo, taskLogRepo), channelRepo)
+	go func() {
+		for {
+			if err := digestService.SendDueDigests(time.Now()); err != nil {
+				log.Printf("Failed to send report digests: %v", err)
+			}
+			time.Sleep(cfg.Collector.DigestInterval.Std())
+		}
+	}()
+
+	// 启动 LDAP 同步任务，定期同步目录中用户的启用状态和角色
+	if cfg.Auth.LDAP.Enabled() {
+		directoryService := http.NewDirectoryService(cfg.Auth.LDAP, persistence.NewUserRepository(db.Client), persistence.NewSessionRepository(db.Client))
+		go func() {
+			for {
+				if err := directoryService.Sync(); err != nil {
+					log.Printf("Failed to sync LDAP users: %v", err)
+				}
+				time.Sleep(cfg.Auth.LDAP.SyncInterval.Std())
+			}
+		}()
+	}
+
 	// 启动HTTP服务器
-	server := http.NewServer(db.Client)
+	server := http.NewServer(db.Client, cfg)
 	server.Start()
 }
+
+// printUsage 输出帮助信息
+func printUsage() {
+	fmt.Println("Crontab Go - 现代化定时任务管理系统")
+	fmt.Println("")
+	fmt.Println("用法:")
+	fmt.Println("  crontab_go [选项]")
+	fmt.Println("")
+	fmt.Println("选项:")
+	fmt.Println("  -h, --help     显示帮助信息")
+	fmt.Println("  --version, -v  显示版本信息")
+	config.PrintUsage(os.Stdout)
+	fmt.Println("")
+	fmt.Println("配置优先级: 命令行参数 > 环境变量 > 配置文件 > 默认值，配置文件示例见 config.example.yaml")
+	fmt.Println("访问 http://localhost:8080 开始使用")
+	fmt.Println("默认账户: admin/admin123")
+}
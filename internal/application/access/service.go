@@ -0,0 +1,428 @@
+package access
+
+import (
+	"errors"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTaskNotFound 任务不存在或当前用户无权查看
+	ErrTaskNotFound = errors.New("Task not found")
+	// ErrForbidden 当前用户没有所需的权限
+	ErrForbidden = errors.New("没有操作权限")
+	// ErrProjectNotFound 项目不存在或当前用户不是项目成员
+	ErrProjectNotFound = errors.New("Project not found")
+	// ErrInvalidGrant 授权参数无效
+	ErrInvalidGrant = errors.New("授权需要指定 user_id 或 group_id 之一，权限可选值: view, execute, edit")
+)
+
+// Service 权限服务：根据用户角色、项目成员角色、任务所有者和任务授权计算用户对任务的权限
+type Service struct {
+	taskRepo    repository.TaskRepository
+	grantRepo   repository.TaskGrantRepository
+	groupRepo   repository.UserGroupRepository
+	userRepo    repository.UserRepository
+	projectRepo repository.ProjectRepository
+}
+
+func NewService(taskRepo repository.TaskRepository, grantRepo repository.TaskGrantRepository, groupRepo repository.UserGroupRepository, userRepo repository.UserRepository, projectRepo repository.ProjectRepository) *Service {
+	return &Service{
+		taskRepo:    taskRepo,
+		grantRepo:   grantRepo,
+		groupRepo:   groupRepo,
+		userRepo:    userRepo,
+		projectRepo: projectRepo,
+	}
+}
+
+// Memberships 获取用户在各个项目中的角色，键为项目ID
+func (s *Service) Memberships(user *entity.User) (map[uint]string, error) {
+	members, err := s.projectRepo.FindMembershipsByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[uint]string, len(members))
+	for _, member := range members {
+		roles[member.ProjectID] = member.Role
+	}
+	return roles, nil
+}
+
+// ProjectRole 获取用户在项目中的角色，管理员在所有项目中都是项目管理员；不是项目成员时返回空字符串
+func (s *Service) ProjectRole(user *entity.User, projectID uint) (string, error) {
+	if user.IsAdmin() {
+		return entity.RoleAdmin, nil
+	}
+	roles, err := s.Memberships(user)
+	if err != nil {
+		return "", err
+	}
+	return roles[projectID], nil
+}
+
+// AuthorizeProject 检查用户在项目中的角色是否不低于 role。项目不存在或用户不是项目成员时返回
+// ErrProjectNotFound，角色不足时返回 ErrForbidden
+func (s *Service) AuthorizeProject(user *entity.User, projectID uint, role string) (*entity.Project, error) {
+	project, err := s.projectRepo.FindByID(projectID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+
+	project.Role, err = s.ProjectRole(user, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if project.Role == "" {
+		return nil, ErrProjectNotFound
+	}
+	if !entity.RoleAtLeast(project.Role, role) {
+		return nil, ErrForbidden
+	}
+	return project, nil
+}
+
+// AuthorizeCreate 检查用户是否可以在项目中创建任务、模板和通知渠道：不属于项目时需要操作员角色，
+// 属于项目时需要项目操作员角色
+func (s *Service) AuthorizeCreate(user *entity.User, projectID *uint) error {
+	if projectID == nil {
+		if !user.HasRole(entity.RoleOperator) {
+			return ErrForbidden
+		}
+		return nil
+	}
+	_, err := s.AuthorizeProject(user, *projectID, entity.RoleOperator)
+	return err
+}
+
+// TaskPermission 计算用户对任务的权限：管理员和任务所有者拥有 manage 权限，其他用户取本人和所在用户组
+// 授权以及项目角色中最高的权限，结果不超过用户角色或项目角色允许的最高权限。没有任何权限时返回空字符串
+func (s *Service) TaskPermission(user *entity.User, task *entity.Task) (string, error) {
+	if user == nil {
+		return "", nil
+	}
+	if user.IsAdmin() {
+		return entity.PermissionManage, nil
+	}
+
+	var projects map[uint]string
+	if task.ProjectID != nil {
+		var err error
+		if projects, err = s.Memberships(user); err != nil {
+			return "", err
+		}
+	}
+
+	permission := ""
+	if task.IsOwnedBy(user.ID) {
+		permission = entity.PermissionManage
+	} else {
+		grants, err := s.grantRepo.FindByTaskID(task.ID)
+		if err != nil {
+			return "", err
+		}
+		var groupIDs map[uint]bool
+		for _, grant := range grants {
+			if grant.GroupID != nil && groupIDs == nil {
+				if groupIDs, err = s.groupSet(user.ID); err != nil {
+					return "", err
+				}
+			}
+			if (grant.UserID != nil && *grant.UserID == user.ID) || (grant.GroupID != nil && groupIDs[*grant.GroupID]) {
+				permission = entity.MaxPermission(permission, grant.Permission)
+			}
+		}
+	}
+	return limitPermission(user, projects, task, permission), nil
+}
+
+// limitPermission 合并项目角色的权限，并将权限限制在用户角色或项目角色允许的最高权限内
+func limitPermission(user *entity.User, projects map[uint]string, task *entity.Task, permission string) string {
+	limit := user.MaxTaskPermission()
+	if task.ProjectID != nil {
+		if role := projects[*task.ProjectID]; role != "" {
+			permission = entity.MaxPermission(permission, entity.ProjectRolePermission(role))
+			limit = entity.MaxPermission(limit, entity.RoleMaxTaskPermission(role))
+		}
+	}
+	if permission == "" {
+		return ""
+	}
+	return entity.MinPermission(permission, limit)
+}
+
+// AuthorizeTask 检查用户对任务是否拥有 required 权限。任务不存在或用户无权查看时返回 ErrTaskNotFound，
+// 可以查看但权限不足时返回 ErrForbidden
+func (s *Service) AuthorizeTask(user *entity.User, taskID int, required string) (*entity.Task, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, err
+	}
+
+	permission, err := s.TaskPermission(user, task)
+	if err != nil {
+		return nil, err
+	}
+	if !entity.PermissionAllows(permission, entity.PermissionView) {
+		return nil, ErrTaskNotFound
+	}
+	if !entity.PermissionAllows(permission, required) {
+		return nil, ErrForbidden
+	}
+	task.Permission = permission
+	return task, nil
+}
+
+// Scope 获取用户可访问的任务范围，projectID 不为空时只包含该项目中的任务。
+// 管理员未选择项目时返回 nil 表示不限制
+func (s *Service) Scope(user *entity.User, projectID *uint) (*entity.TaskScope, error) {
+	if user.IsAdmin() {
+		if projectID == nil {
+			return nil, nil
+		}
+		return &entity.TaskScope{Unrestricted: true, ProjectID: projectID}, nil
+	}
+
+	groupIDs, err := s.groupRepo.FindGroupIDsByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.grantRepo.FindBySubject(user.ID, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := s.Memberships(user)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := &entity.TaskScope{OwnerID: user.ID, ProjectID: projectID}
+	for id := range projects {
+		scope.ProjectIDs = append(scope.ProjectIDs, id)
+	}
+	seen := make(map[int]bool, len(grants))
+	for _, grant := range grants {
+		if !seen[grant.TaskID] {
+			seen[grant.TaskID] = true
+			scope.TaskIDs = append(scope.TaskIDs, grant.TaskID)
+		}
+	}
+	return scope, nil
+}
+
+// VisibleTaskIDs 获取用户可以查看的任务ID，projectID 不为空时只包含该项目中的任务。
+// 管理员未选择项目时返回 nil 表示不限制；没有可查看的任务时返回空列表
+func (s *Service) VisibleTaskIDs(user *entity.User, projectID *uint) ([]int, error) {
+	scope, err := s.Scope(user, projectID)
+	if err != nil || scope == nil {
+		return nil, err
+	}
+
+	tasks, err := s.taskRepo.FindByScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids, nil
+}
+
+// AnnotateTasks 为任务列表填充当前用户的权限
+func (s *Service) AnnotateTasks(user *entity.User, tasks []*entity.Task) error {
+	if user.IsAdmin() {
+		for _, task := range tasks {
+			task.Permission = entity.PermissionManage
+		}
+		return nil
+	}
+
+	groupIDs, err := s.groupRepo.FindGroupIDsByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	grants, err := s.grantRepo.FindBySubject(user.ID, groupIDs)
+	if err != nil {
+		return err
+	}
+	granted := make(map[int]string, len(grants))
+	for _, grant := range grants {
+		granted[grant.TaskID] = entity.MaxPermission(granted[grant.TaskID], grant.Permission)
+	}
+	projects, err := s.Memberships(user)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		permission := granted[task.ID]
+		if task.IsOwnedBy(user.ID) {
+			permission = entity.PermissionManage
+		}
+		task.Permission = limitPermission(user, projects, task, permission)
+	}
+	return nil
+}
+
+// CanManageTemplate 用户是否可以修改或删除模板：管理员、模板所属项目的管理员，或模板创建者（需为操作员或项目操作员）。
+// projects 为用户在各项目中的角色
+func CanManageTemplate(user *entity.User, projects map[uint]string, template *entity.TaskTemplate) bool {
+	if user.IsAdmin() {
+		return true
+	}
+	role := user.Role
+	if template.ProjectID != nil {
+		role = projects[*template.ProjectID]
+		if role == entity.RoleAdmin {
+			return true
+		}
+	}
+	return entity.RoleAtLeast(role, entity.RoleOperator) && template.CreatedBy == int(user.ID)
+}
+
+// CanViewTemplate 用户是否可以查看模板：公共模板、自己创建的模板和所属项目中的模板，管理员可以查看所有模板
+func CanViewTemplate(user *entity.User, projects map[uint]string, template *entity.TaskTemplate) bool {
+	if template.IsPublic || user.IsAdmin() || template.CreatedBy == int(user.ID) {
+		return true
+	}
+	return template.ProjectID != nil && projects[*template.ProjectID] != ""
+}
+
+// CanViewChannel 用户是否可以查看通知渠道：全局渠道对所有用户可见，项目渠道只对项目成员可见
+func CanViewChannel(user *entity.User, projects map[uint]string, channel *entity.NotificationChannel) bool {
+	return channel.ProjectID == nil || user.IsAdmin() || projects[*channel.ProjectID] != ""
+}
+
+// CanManageChannel 用户是否可以修改通知渠道：全局渠道需要操作员角色，项目渠道需要项目操作员角色
+func CanManageChannel(user *entity.User, projects map[uint]string, channel *entity.NotificationChannel) bool {
+	if channel.ProjectID == nil || user.IsAdmin() {
+		return user.HasRole(entity.RoleOperator)
+	}
+	return entity.RoleAtLeast(projects[*channel.ProjectID], entity.RoleOperator)
+}
+
+// CanViewEscalationPolicy 用户是否可以查看升级策略：全局策略对所有用户可见，项目策略只对项目成员可见
+func CanViewEscalationPolicy(user *entity.User, projects map[uint]string, policy *entity.EscalationPolicy) bool {
+	return policy.ProjectID == nil || user.IsAdmin() || projects[*policy.ProjectID] != ""
+}
+
+// CanManageEscalationPolicy 用户是否可以修改升级策略：全局策略需要操作员角色，项目策略需要项目操作员角色
+func CanManageEscalationPolicy(user *entity.User, projects map[uint]string, policy *entity.EscalationPolicy) bool {
+	if policy.ProjectID == nil || user.IsAdmin() {
+		return user.HasRole(entity.RoleOperator)
+	}
+	return entity.RoleAtLeast(projects[*policy.ProjectID], entity.RoleOperator)
+}
+
+// ListTaskGrants 获取任务的授权
+func (s *Service) ListTaskGrants(taskID int) ([]*entity.TaskGrant, error) {
+	return s.grantRepo.FindByTaskID(taskID)
+}
+
+// GrantTask 授予用户或用户组对任务的权限
+func (s *Service) GrantTask(grant *entity.TaskGrant) error {
+	if (grant.UserID == nil) == (grant.GroupID == nil) || !entity.ValidGrantPermission(grant.Permission) {
+		return ErrInvalidGrant
+	}
+	if grant.UserID != nil {
+		if _, err := s.userRepo.FindByID(*grant.UserID); err != nil {
+			return errors.New("授权的用户不存在")
+		}
+	}
+	if grant.GroupID != nil {
+		if _, err := s.groupRepo.FindByID(*grant.GroupID); err != nil {
+			return errors.New("授权的用户组不存在")
+		}
+	}
+	grant.ID = 0
+	return s.grantRepo.Create(grant)
+}
+
+// RevokeTaskGrant 撤销任务的一项授权
+func (s *Service) RevokeTaskGrant(taskID int, grantID uint) error {
+	grant, err := s.grantRepo.FindByID(grantID)
+	if err != nil || grant.TaskID != taskID {
+		return gorm.ErrRecordNotFound
+	}
+	return s.grantRepo.Delete(grantID)
+}
+
+// ListGroups 获取所有用户组
+func (s *Service) ListGroups() ([]*entity.UserGroup, error) {
+	return s.groupRepo.FindAll()
+}
+
+// GetGroup 获取用户组
+func (s *Service) GetGroup(id uint) (*entity.UserGroup, error) {
+	return s.groupRepo.FindByID(id)
+}
+
+// CreateGroup 创建用户组
+func (s *Service) CreateGroup(group *entity.UserGroup) error {
+	if group.Name == "" {
+		return errors.New("用户组名称不能为空")
+	}
+	group.ID = 0
+	group.Members = nil
+	return s.groupRepo.Create(group)
+}
+
+// UpdateGroup 更新用户组的名称和描述
+func (s *Service) UpdateGroup(group *entity.UserGroup) error {
+	if group.Name == "" {
+		return errors.New("用户组名称不能为空")
+	}
+	existing, err := s.groupRepo.FindByID(group.ID)
+	if err != nil {
+		return err
+	}
+	group.CreatedAt = existing.CreatedAt
+	group.Members = nil
+	return s.groupRepo.Update(group)
+}
+
+// DeleteGroup 删除用户组及其授权
+func (s *Service) DeleteGroup(id uint) error {
+	if err := s.grantRepo.DeleteByGroupID(id); err != nil {
+		return err
+	}
+	return s.groupRepo.Delete(id)
+}
+
+// AddGroupMember 将用户加入用户组
+func (s *Service) AddGroupMember(groupID, userID uint) error {
+	if _, err := s.groupRepo.FindByID(groupID); err != nil {
+		return err
+	}
+	if _, err := s.userRepo.FindByID(userID); err != nil {
+		return errors.New("用户不存在")
+	}
+	return s.groupRepo.AddMember(groupID, userID)
+}
+
+// RemoveGroupMember 将用户移出用户组
+func (s *Service) RemoveGroupMember(groupID, userID uint) error {
+	return s.groupRepo.RemoveMember(groupID, userID)
+}
+
+func (s *Service) groupSet(userID uint) (map[uint]bool, error) {
+	groupIDs, err := s.groupRepo.FindGroupIDsByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[uint]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		set[id] = true
+	}
+	return set, nil
+}
@@ -0,0 +1,216 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/infrastructure/persistence"
+
+	"gorm.io/gorm"
+)
+
+// accessFixture 权限测试使用的数据库和服务
+type accessFixture struct {
+	db      *gorm.DB
+	service *Service
+	seq     int
+}
+
+func newAccessFixture(t *testing.T) *accessFixture {
+	t.Helper()
+
+	db, err := persistence.NewSQLiteDB(filepath.Join(t.TempDir(), "crontab.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	service := NewService(
+		persistence.NewTaskRepository(db.Client),
+		persistence.NewTaskGrantRepository(db.Client),
+		persistence.NewUserGroupRepository(db.Client),
+		persistence.NewUserRepository(db.Client),
+		persistence.NewProjectRepository(db.Client),
+	)
+	return &accessFixture{db: db.Client, service: service}
+}
+
+// name 生成唯一名称
+func (f *accessFixture) name(prefix string) string {
+	f.seq++
+	return fmt.Sprintf("%s-%d", prefix, f.seq)
+}
+
+func (f *accessFixture) createUser(t *testing.T, role string) *entity.User {
+	t.Helper()
+
+	name := f.name("user")
+	user := &entity.User{Username: name, Email: name + "@example.com", Role: role, IsActive: true, AuthProvider: entity.AuthProviderLocal}
+	if err := f.db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func (f *accessFixture) createTask(t *testing.T, ownerID *uint, projectID *uint) *entity.Task {
+	t.Helper()
+
+	task := &entity.Task{Name: f.name("task"), Schedule: "* * * * *", Command: "true", OwnerID: ownerID, ProjectID: projectID}
+	if err := f.db.Create(task).Error; err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return task
+}
+
+func TestTaskPermissionMatrix(t *testing.T) {
+	f := newAccessFixture(t)
+
+	const (
+		grantNone  = ""
+		grantUser  = "user"
+		grantGroup = "group"
+		grantOwner = "owner"
+	)
+	tests := []struct {
+		name        string
+		role        string
+		grant       string // 授权方式
+		permission  string // 授权的权限
+		projectRole string // 任务属于项目时用户在项目中的角色，为空时任务不属于项目
+		want        string
+	}{
+		{"admin without grant", entity.RoleAdmin, grantNone, "", "", entity.PermissionManage},
+		{"operator without grant", entity.RoleOperator, grantNone, "", "", ""},
+		{"viewer without grant", entity.RoleViewer, grantNone, "", "", ""},
+		{"operator owner", entity.RoleOperator, grantOwner, "", "", entity.PermissionManage},
+		{"viewer owner capped", entity.RoleViewer, grantOwner, "", "", entity.PermissionView},
+
+		{"operator user view", entity.RoleOperator, grantUser, entity.PermissionView, "", entity.PermissionView},
+		{"operator user execute", entity.RoleOperator, grantUser, entity.PermissionExecute, "", entity.PermissionExecute},
+		{"operator user edit", entity.RoleOperator, grantUser, entity.PermissionEdit, "", entity.PermissionEdit},
+		{"operator group view", entity.RoleOperator, grantGroup, entity.PermissionView, "", entity.PermissionView},
+		{"operator group execute", entity.RoleOperator, grantGroup, entity.PermissionExecute, "", entity.PermissionExecute},
+		{"operator group edit", entity.RoleOperator, grantGroup, entity.PermissionEdit, "", entity.PermissionEdit},
+
+		{"viewer user view", entity.RoleViewer, grantUser, entity.PermissionView, "", entity.PermissionView},
+		{"viewer user execute capped", entity.RoleViewer, grantUser, entity.PermissionExecute, "", entity.PermissionView},
+		{"viewer user edit capped", entity.RoleViewer, grantUser, entity.PermissionEdit, "", entity.PermissionView},
+		{"viewer group view", entity.RoleViewer, grantGroup, entity.PermissionView, "", entity.PermissionView},
+		{"viewer group execute capped", entity.RoleViewer, grantGroup, entity.PermissionExecute, "", entity.PermissionView},
+		{"viewer group edit capped", entity.RoleViewer, grantGroup, entity.PermissionEdit, "", entity.PermissionView},
+
+		{"project viewer", entity.RoleViewer, grantNone, "", entity.RoleViewer, entity.PermissionView},
+		{"project operator", entity.RoleViewer, grantNone, "", entity.RoleOperator, entity.PermissionEdit},
+		{"project admin", entity.RoleViewer, grantNone, "", entity.RoleAdmin, entity.PermissionManage},
+		{"project viewer with user execute", entity.RoleOperator, grantUser, entity.PermissionExecute, entity.RoleViewer, entity.PermissionExecute},
+		{"project operator lifts viewer cap", entity.RoleViewer, grantGroup, entity.PermissionExecute, entity.RoleOperator, entity.PermissionEdit},
+		{"project viewer keeps viewer cap", entity.RoleViewer, grantUser, entity.PermissionEdit, entity.RoleViewer, entity.PermissionView},
+		{"project viewer owner", entity.RoleViewer, grantOwner, "", entity.RoleViewer, entity.PermissionView},
+	}
+	required := []string{entity.PermissionView, entity.PermissionExecute, entity.PermissionEdit, entity.PermissionManage}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := f.createUser(t, tt.role)
+
+			var projectID *uint
+			if tt.projectRole != "" {
+				project := &entity.Project{Name: f.name("project")}
+				if err := f.db.Create(project).Error; err != nil {
+					t.Fatalf("create project: %v", err)
+				}
+				member := &entity.ProjectMember{ProjectID: project.ID, UserID: user.ID, Role: tt.projectRole}
+				if err := f.db.Create(member).Error; err != nil {
+					t.Fatalf("create project member: %v", err)
+				}
+				projectID = &project.ID
+			}
+
+			// 其他用户拥有的任务，避免用户通过所有者身份获得权限
+			other := f.createUser(t, entity.RoleOperator)
+			ownerID := &other.ID
+			if tt.grant == grantOwner {
+				ownerID = &user.ID
+			}
+			task := f.createTask(t, ownerID, projectID)
+
+			switch tt.grant {
+			case grantUser:
+				grant := &entity.TaskGrant{TaskID: task.ID, UserID: &user.ID, Permission: tt.permission}
+				if err := f.db.Create(grant).Error; err != nil {
+					t.Fatalf("create grant: %v", err)
+				}
+			case grantGroup:
+				group := &entity.UserGroup{Name: f.name("group")}
+				if err := f.db.Create(group).Error; err != nil {
+					t.Fatalf("create group: %v", err)
+				}
+				if err := f.db.Model(group).Association("Members").Append(user); err != nil {
+					t.Fatalf("add group member: %v", err)
+				}
+				grant := &entity.TaskGrant{TaskID: task.ID, GroupID: &group.ID, Permission: tt.permission}
+				if err := f.db.Create(grant).Error; err != nil {
+					t.Fatalf("create grant: %v", err)
+				}
+			}
+
+			got, err := f.service.TaskPermission(user, task)
+			if err != nil {
+				t.Fatalf("TaskPermission: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("TaskPermission = %q, want %q", got, tt.want)
+			}
+
+			// 任务列表中的权限与单个任务的权限一致
+			annotated := []*entity.Task{{ID: task.ID, OwnerID: task.OwnerID, ProjectID: task.ProjectID}}
+			if err := f.service.AnnotateTasks(user, annotated); err != nil {
+				t.Fatalf("AnnotateTasks: %v", err)
+			}
+			if annotated[0].Permission != tt.want {
+				t.Errorf("AnnotateTasks permission = %q, want %q", annotated[0].Permission, tt.want)
+			}
+
+			visible, err := f.service.VisibleTaskIDs(user, nil)
+			if err != nil {
+				t.Fatalf("VisibleTaskIDs: %v", err)
+			}
+			if contains := visible == nil || containsTaskID(visible, task.ID); contains != (tt.want != "") {
+				t.Errorf("VisibleTaskIDs contains task = %v, want %v", contains, tt.want != "")
+			}
+
+			for _, permission := range required {
+				_, err := f.service.AuthorizeTask(user, task.ID, permission)
+				var want error
+				switch {
+				case tt.want == "":
+					want = ErrTaskNotFound
+				case !entity.PermissionAllows(tt.want, permission):
+					want = ErrForbidden
+				}
+				if !errors.Is(err, want) || (want == nil && err != nil) {
+					t.Errorf("AuthorizeTask(%s) error = %v, want %v", permission, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizeTaskNotFound(t *testing.T) {
+	f := newAccessFixture(t)
+	admin := f.createUser(t, entity.RoleAdmin)
+
+	if _, err := f.service.AuthorizeTask(admin, 9999, entity.PermissionView); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("AuthorizeTask error = %v, want %v", err, ErrTaskNotFound)
+	}
+}
+
+func containsTaskID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,353 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"crontab_go/internal/application/auth"
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+var (
+	// ErrModifySelf 不能禁用、删除自己或修改自己的角色
+	ErrModifySelf = errors.New("不能对自己执行该操作")
+	// ErrLastAdmin 系统中至少需要保留一个已启用的管理员
+	ErrLastAdmin = errors.New("至少需要保留一个已启用的管理员")
+	// ErrExternalAdmin 管理员账号不能切换为外部认证，避免通过身份提供方的邮箱接管管理员账号
+	ErrExternalAdmin = errors.New("管理员账号不能切换为外部认证，请先修改角色")
+	// ErrExternalLinked 账号已绑定外部身份
+	ErrExternalLinked = errors.New("账号已绑定外部身份，不能修改认证来源")
+)
+
+// temporaryPasswordLength 重置密码时生成的临时密码字节数
+const temporaryPasswordLength = 9
+
+// Service 用户管理服务：管理员创建、审核、禁用、删除用户，分配角色，重置密码和管理注册邀请码
+type Service struct {
+	userRepo    repository.UserRepository
+	inviteRepo  repository.InviteCodeRepository
+	taskRepo    repository.TaskRepository
+	grantRepo   repository.TaskGrantRepository
+	groupRepo   repository.UserGroupRepository
+	projectRepo repository.ProjectRepository
+	tokenRepo   repository.APITokenRepository
+	sessionRepo repository.SessionRepository
+}
+
+func NewService(userRepo repository.UserRepository, inviteRepo repository.InviteCodeRepository, taskRepo repository.TaskRepository, grantRepo repository.TaskGrantRepository, groupRepo repository.UserGroupRepository, projectRepo repository.ProjectRepository, tokenRepo repository.APITokenRepository, sessionRepo repository.SessionRepository) *Service {
+	return &Service{
+		userRepo:    userRepo,
+		inviteRepo:  inviteRepo,
+		taskRepo:    taskRepo,
+		grantRepo:   grantRepo,
+		groupRepo:   groupRepo,
+		projectRepo: projectRepo,
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+	}
+}
+
+// ListUsers 获取所有用户
+func (s *Service) ListUsers() ([]*entity.User, error) {
+	return s.userRepo.FindAll()
+}
+
+// GetUser 获取用户
+func (s *Service) GetUser(id uint) (*entity.User, error) {
+	return s.userRepo.FindByID(id)
+}
+
+// CreateUser 管理员创建用户，创建的用户无需审核
+func (s *Service) CreateUser(req *entity.CreateUserRequest) (*entity.User, error) {
+	if req.Role == "" {
+		req.Role = entity.RoleViewer
+	}
+	if !entity.ValidRole(req.Role) {
+		return nil, errors.New("无效的角色，可选值: admin, operator, viewer")
+	}
+	if _, err := s.userRepo.FindByUsername(req.Username); err == nil {
+		return nil, errors.New("用户名已存在")
+	}
+
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+	user := &entity.User{
+		Username:           req.Username,
+		Password:           hashedPassword,
+		Email:              req.Email,
+		Role:               req.Role,
+		IsActive:           true,
+		MustChangePassword: req.MustChangePassword,
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, errors.New("创建用户失败，用户名或邮箱可能已被使用")
+	}
+	return user, nil
+}
+
+// SetRole 修改用户角色。不能修改自己的角色，避免管理员误操作后失去管理权限
+func (s *Service) SetRole(operator *entity.User, userID uint, role string) (*entity.User, error) {
+	if !entity.ValidRole(role) {
+		return nil, errors.New("无效的角色，可选值: admin, operator, viewer")
+	}
+	if operator.ID == userID {
+		return nil, ErrModifySelf
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if role != entity.RoleAdmin {
+		if err := s.checkNotLastAdmin(user); err != nil {
+			return nil, err
+		}
+	}
+
+	user.Role = role
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetAuthProvider 修改未绑定外部身份的用户的认证来源。切换为 oidc 或 ldap 后用户不能再使用本地密码登录，
+// 该认证来源首次登录且邮箱已验证时绑定到该账号；改回 local 用于撤销尚未完成的关联
+func (s *Service) SetAuthProvider(operator *entity.User, userID uint, provider string) (*entity.User, error) {
+	switch provider {
+	case entity.AuthProviderLocal, entity.AuthProviderOIDC, entity.AuthProviderLDAP:
+	default:
+		return nil, errors.New("无效的认证来源，可选值: local, oidc, ldap")
+	}
+	if operator.ID == userID {
+		return nil, ErrModifySelf
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.ExternalID != "" {
+		return nil, ErrExternalLinked
+	}
+	if provider != entity.AuthProviderLocal && user.IsAdmin() {
+		return nil, ErrExternalAdmin
+	}
+
+	user.AuthProvider = provider
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetActive 启用或禁用用户，禁用的用户不能登录，已登录的会话全部注销
+func (s *Service) SetActive(operator *entity.User, userID uint, active bool) (*entity.User, error) {
+	if operator.ID == userID {
+		return nil, ErrModifySelf
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		if err := s.checkNotLastAdmin(user); err != nil {
+			return nil, err
+		}
+	}
+
+	user.IsActive = active
+	// 管理员手动设置后不再由 LDAP 同步自动启用
+	user.DirectoryDisabled = false
+	if active {
+		user.PendingApproval = false
+	}
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if !active {
+		if err := s.sessionRepo.RevokeByUser(user.ID, 0, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// Approve 审核通过注册的用户
+func (s *Service) Approve(userID uint) (*entity.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.PendingApproval {
+		return nil, errors.New("该用户不需要审核")
+	}
+
+	user.PendingApproval = false
+	user.IsActive = true
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ResetPassword 重置用户密码，password 为空时生成临时密码。用户已登录的会话全部注销，下次登录后必须修改密码。
+// 返回设置的密码
+func (s *Service) ResetPassword(userID uint, password string) (string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if !user.IsLocal() {
+		return "", auth.ErrExternalAccount
+	}
+
+	if password == "" {
+		if password, err = temporaryPassword(); err != nil {
+			return "", err
+		}
+	} else if len(password) < auth.MinPasswordLength {
+		return "", auth.ErrPasswordTooShort
+	}
+
+	if user.Password, err = auth.HashPassword(password); err != nil {
+		return "", err
+	}
+	user.MustChangePassword = true
+	if err := s.userRepo.Update(user); err != nil {
+		return "", err
+	}
+	if err := s.sessionRepo.RevokeByUser(user.ID, 0, time.Now()); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// ResetTwoFactor 停用用户的两步验证，用于用户丢失验证器设备和恢复码的情况。用户已登录的会话全部注销
+func (s *Service) ResetTwoFactor(userID uint) (*entity.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("该用户未启用两步验证")
+	}
+
+	auth.ClearTwoFactor(user)
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.RevokeByUser(user.ID, 0, time.Now()); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// DeleteUser 删除用户，同时注销其会话、删除其个人访问令牌，撤销授予该用户的任务权限、移出用户组和项目。
+// 用户拥有的任务不会删除，所有者被清空后只有管理员和被授权的用户可以访问
+func (s *Service) DeleteUser(operator *entity.User, userID uint) error {
+	if operator.ID == userID {
+		return ErrModifySelf
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkNotLastAdmin(user); err != nil {
+		return err
+	}
+
+	if err := s.sessionRepo.RevokeByUser(userID, 0, time.Now()); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteByUser(userID); err != nil {
+		return err
+	}
+	if err := s.grantRepo.DeleteByUserID(userID); err != nil {
+		return err
+	}
+	if err := s.groupRepo.DeleteMembersByUser(userID); err != nil {
+		return err
+	}
+	if err := s.projectRepo.DeleteMembersByUser(userID); err != nil {
+		return err
+	}
+	if err := s.taskRepo.ClearOwner(userID); err != nil {
+		return err
+	}
+	return s.userRepo.Delete(userID)
+}
+
+// checkNotLastAdmin 用户是已启用的管理员且是最后一个时返回 ErrLastAdmin
+func (s *Service) checkNotLastAdmin(user *entity.User) error {
+	if !user.IsAdmin() || !user.IsActive {
+		return nil
+	}
+	count, err := s.userRepo.CountActiveAdmins()
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// ListInvites 获取所有邀请码
+func (s *Service) ListInvites() ([]*entity.InviteCode, error) {
+	return s.inviteRepo.FindAll()
+}
+
+// CreateInvite 创建邀请码，expiresIn 为 0 时不过期
+func (s *Service) CreateInvite(creator *entity.User, role, note string, expiresIn time.Duration) (*entity.InviteCode, error) {
+	if role == "" {
+		role = entity.RoleViewer
+	}
+	if !entity.ValidRole(role) {
+		return nil, errors.New("无效的角色，可选值: admin, operator, viewer")
+	}
+	if expiresIn < 0 {
+		return nil, errors.New("有效期不能小于 0")
+	}
+
+	code, err := randomString(12)
+	if err != nil {
+		return nil, err
+	}
+	invite := &entity.InviteCode{
+		Code:      code,
+		Role:      role,
+		Note:      note,
+		CreatedBy: creator.ID,
+	}
+	if expiresIn > 0 {
+		expiresAt := time.Now().Add(expiresIn)
+		invite.ExpiresAt = &expiresAt
+	}
+	if err := s.inviteRepo.Create(invite); err != nil {
+		return nil, err
+	}
+	return invite, nil
+}
+
+// DeleteInvite 删除邀请码
+func (s *Service) DeleteInvite(id uint) error {
+	return s.inviteRepo.Delete(id)
+}
+
+func temporaryPassword() (string, error) {
+	return randomString(temporaryPasswordLength)
+}
+
+// randomString 生成 URL 安全的随机字符串，n 为随机字节数
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(base64.RawURLEncoding.EncodeToString(buf), "="), nil
+}
@@ -0,0 +1,124 @@
+package apitoken
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidToken 令牌不存在、已撤销、已过期或所属用户已被禁用
+	ErrInvalidToken = errors.New("无效的访问令牌")
+	// ErrTokenNotFound 令牌不存在或不属于当前用户
+	ErrTokenNotFound = errors.New("访问令牌不存在")
+)
+
+// touchInterval 最近使用时间的更新间隔，避免每个请求都写数据库
+const touchInterval = time.Minute
+
+// Service 个人访问令牌服务
+type Service struct {
+	tokenRepo repository.APITokenRepository
+	userRepo  repository.UserRepository
+}
+
+func NewService(tokenRepo repository.APITokenRepository, userRepo repository.UserRepository) *Service {
+	return &Service{
+		tokenRepo: tokenRepo,
+		userRepo:  userRepo,
+	}
+}
+
+// Create 为用户创建个人访问令牌，返回的令牌明文不会保存，只能在创建时获取
+func (s *Service) Create(user *entity.User, req *entity.CreateAPITokenRequest) (*entity.CreateAPITokenResponse, error) {
+	if !entity.ValidTokenScope(req.Scope) {
+		return nil, errors.New("无效的令牌权限范围，可选值: read, execute, admin")
+	}
+	if req.ExpiresIn < 0 {
+		return nil, errors.New("有效期不能小于 0")
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	raw := entity.APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	token := &entity.APIToken{
+		UserID:    user.ID,
+		Name:      req.Name,
+		Prefix:    raw[:len(entity.APITokenPrefix)+6],
+		TokenHash: hashToken(raw),
+		Scope:     req.Scope,
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresIn)
+		token.ExpiresAt = &expiresAt
+	}
+	if err := s.tokenRepo.Create(token); err != nil {
+		return nil, err
+	}
+	return &entity.CreateAPITokenResponse{Token: raw, APIToken: token}, nil
+}
+
+// List 获取用户的个人访问令牌
+func (s *Service) List(user *entity.User) ([]*entity.APIToken, error) {
+	return s.tokenRepo.FindByUser(user.ID)
+}
+
+// ListAll 获取所有用户的个人访问令牌
+func (s *Service) ListAll() ([]*entity.APIToken, error) {
+	return s.tokenRepo.FindAll()
+}
+
+// Revoke 撤销令牌。用户只能撤销自己的令牌，管理员可以撤销任何令牌
+func (s *Service) Revoke(user *entity.User, id uint) error {
+	token, err := s.tokenRepo.FindByID(id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrTokenNotFound
+		}
+		return err
+	}
+	if token.UserID != user.ID && !user.IsAdmin() {
+		return ErrTokenNotFound
+	}
+	return s.tokenRepo.Revoke(token.ID, time.Now())
+}
+
+// Authenticate 验证令牌明文，返回令牌所属的用户和令牌，并记录最近使用时间
+func (s *Service) Authenticate(raw, ip string) (*entity.User, *entity.APIToken, error) {
+	token, err := s.tokenRepo.FindByHash(hashToken(raw))
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+	now := time.Now()
+	if !token.Usable(now) {
+		return nil, nil, ErrInvalidToken
+	}
+	user, err := s.userRepo.FindByID(token.UserID)
+	if err != nil || !user.IsActive {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= touchInterval || token.LastUsedIP != ip {
+		if err := s.tokenRepo.Touch(token.ID, now, ip); err == nil {
+			token.LastUsedAt = &now
+			token.LastUsedIP = ip
+		}
+	}
+	return user, token, nil
+}
+
+// hashToken 令牌本身是高熵随机值，使用 SHA-256 即可安全保存并按哈希查找
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// ignoredFields 不记录变化的字段
+var ignoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"permission": true,
+}
+
+// secretFields 可能包含令牌等敏感信息的字段，只记录是否修改，不记录值
+var secretFields = map[string]bool{
+	"ping_token":          true,
+	"headers":             true,
+	"notification_config": true,
+}
+
+// Service 审计日志服务
+type Service struct {
+	repo repository.AuditLogRepository
+}
+
+func NewService(repo repository.AuditLogRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record 记录审计日志。写入失败只记录到日志，不影响正在进行的操作
+func (s *Service) Record(entry *entity.AuditLog) {
+	if err := s.repo.Create(entry); err != nil {
+		log.Printf("Failed to record audit log %s: %v", entry.Action, err)
+	}
+}
+
+// List 分页查询审计日志
+func (s *Service) List(filter *entity.AuditLogFilter, pagination *entity.PaginationRequest) (*entity.PaginationResponse, error) {
+	logs, total, err := s.repo.Find(filter, pagination.GetOffset(), pagination.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	return entity.NewPaginationResponse(pagination.Page, pagination.PageSize, total, logs), nil
+}
+
+// Cleanup 删除超过保留时间的审计日志
+func (s *Service) Cleanup(retention time.Duration) {
+	deleted, err := s.repo.DeleteBefore(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("Failed to clean old audit logs: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Cleaned %d old audit logs", deleted)
+	}
+}
+
+// Diff 按 JSON 字段比较修改前后的对象，返回有变化的字段，JSON格式。
+// 创建时 before 为 nil，删除时 after 为 nil，没有变化时返回空字符串
+func Diff(before, after interface{}) string {
+	old, current := fields(before), fields(after)
+	changes := make(map[string]entity.AuditChange)
+	for _, values := range []map[string]interface{}{old, current} {
+		for name := range values {
+			if ignoredFields[name] {
+				continue
+			}
+			previous, value := old[name], current[name]
+			if reflect.DeepEqual(previous, value) || (isEmpty(previous) && isEmpty(value)) {
+				continue
+			}
+			if secretFields[name] {
+				previous, value = redact(previous), redact(value)
+			}
+			changes[name] = entity.AuditChange{Before: previous, After: value}
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// fields 将对象转换为 JSON 字段，nil 返回空
+func fields(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var values map[string]interface{}
+	_ = json.Unmarshal(data, &values)
+	return values
+}
+
+// isEmpty 是否为零值，创建和删除时不记录零值字段
+func isEmpty(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	}
+	return false
+}
+
+func redact(v interface{}) interface{} {
+	if isEmpty(v) {
+		return v
+	}
+	return entity.MaskedValue
+}
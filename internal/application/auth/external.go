@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// ErrExternalUserNotFound 外部身份提供方的用户在系统中不存在，且未开启自动创建
+var ErrExternalUserNotFound = errors.New("账号不存在，请联系管理员开通")
+
+// ErrExternalEmailInUse 外部身份的邮箱已被不能自动关联的本地账号使用
+var ErrExternalEmailInUse = errors.New("邮箱已被本地账号使用，请联系管理员关联账号")
+
+// ExternalLogin 外部身份提供方认证通过后登录，创建会话并返回令牌
+func (s *Service) ExternalLogin(identity *entity.ExternalIdentity, ip, userAgent string) (*entity.LoginResponse, error) {
+	user, err := s.resolveExternalUser(identity)
+	if err != nil {
+		return nil, err
+	}
+	return s.createSession(user, ip, userAgent)
+}
+
+// resolveExternalUser 返回外部身份对应的已启用用户。
+// 按认证来源和外部标识查找用户；找不到时关联邮箱已验证且相同的本地账号；仍找不到时按需自动创建用户
+func (s *Service) resolveExternalUser(identity *entity.ExternalIdentity) (*entity.User, error) {
+	user, err := s.findExternalUser(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		if user, err = s.provisionExternalUser(identity); err != nil {
+			return nil, err
+		}
+	} else {
+		changed := false
+		if identity.Email != "" && user.Email != identity.Email {
+			user.Email = identity.Email
+			changed = true
+		}
+		if identity.SyncRole && identity.Role != "" && user.Role != identity.Role {
+			log.Printf("Updating role of %s user %s from %s to %s", identity.Provider, user.Username, user.Role, identity.Role)
+			user.Role = identity.Role
+			changed = true
+		}
+		if changed {
+			if err := s.userRepo.Update(user); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if user.PendingApproval {
+		return nil, errors.New("账号等待管理员审核")
+	}
+	if !user.IsActive {
+		return nil, errors.New("用户已被禁用")
+	}
+	return user, nil
+}
+
+// findExternalUser 查找外部身份对应的用户。首次登录且邮箱已验证时，关联管理员已切换到该认证来源、尚未绑定的同邮箱账号；
+// identity.LinkLocal 为 true 时也关联同邮箱的本地账号。管理员账号不会自动关联，避免控制了身份提供方邮箱的用户接管管理员账号
+func (s *Service) findExternalUser(identity *entity.ExternalIdentity) (*entity.User, error) {
+	if user, err := s.userRepo.FindByExternalID(identity.Provider, identity.Subject); err == nil {
+		return user, nil
+	}
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, nil
+	}
+
+	user, err := s.userRepo.FindByEmail(identity.Email)
+	if err != nil {
+		return nil, nil
+	}
+	pendingLink := user.AuthProvider == identity.Provider && user.ExternalID == ""
+	if !pendingLink && !(identity.LinkLocal && user.IsLocal()) {
+		return nil, nil
+	}
+	if user.IsAdmin() {
+		log.Printf("Refusing to link admin user %s to %s identity %s by email", user.Username, identity.Provider, identity.Subject)
+		return nil, ErrExternalEmailInUse
+	}
+	// 关联后该账号只能通过身份提供方登录
+	log.Printf("Linking user %s to %s identity %s", user.Username, identity.Provider, identity.Subject)
+	user.AuthProvider = identity.Provider
+	user.ExternalID = identity.Subject
+	user.MustChangePassword = false
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// provisionExternalUser 首次登录时创建用户，用户没有可用的本地密码
+func (s *Service) provisionExternalUser(identity *entity.ExternalIdentity) (*entity.User, error) {
+	if !identity.AutoCreate {
+		return nil, ErrExternalUserNotFound
+	}
+	if identity.Username == "" {
+		return nil, errors.New("身份提供方没有返回用户名")
+	}
+	if _, err := s.userRepo.FindByUsername(identity.Username); err == nil {
+		return nil, fmt.Errorf("用户名 %s 已被其他账号使用，请联系管理员", identity.Username)
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	password, err := HashPassword(base64.RawURLEncoding.EncodeToString(buf))
+	if err != nil {
+		return nil, err
+	}
+
+	role := identity.Role
+	if role == "" {
+		role = entity.RoleViewer
+	}
+	user := &entity.User{
+		Username:     identity.Username,
+		Password:     password,
+		Email:        identity.Email,
+		Role:         role,
+		IsActive:     true,
+		AuthProvider: identity.Provider,
+		ExternalID:   identity.Subject,
+	}
+	if user.Email == "" {
+		// email 有唯一索引，身份提供方没有返回邮箱时使用占位地址
+		user.Email = fmt.Sprintf("%s@%s.invalid", identity.Subject, identity.Provider)
+	}
+	if err := s.userRepo.Create(user); err != nil {
+		return nil, errors.New("创建用户失败，邮箱可能已被其他账号使用")
+	}
+	log.Printf("Created %s user %s with role %s", identity.Provider, user.Username, user.Role)
+	return user, nil
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockedError 登录失败次数过多，在 Until 之前拒绝登录
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	minutes := int(time.Until(e.Until).Minutes()) + 1
+	return fmt.Sprintf("登录失败次数过多，请 %d 分钟后再试", minutes)
+}
+
+// loginCounter 一个 IP 或账号在当前窗口内的失败次数
+type loginCounter struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// LoginLimiter 按 IP 和账号统计登录失败次数，在窗口期内失败次数达到上限后临时锁定。
+// 计数只保存在内存中，重启后清零
+type LoginLimiter struct {
+	maxPerIP      int // 为 0 时不按 IP 限制
+	maxPerAccount int // 为 0 时不按账号限制
+	window        time.Duration
+	lockout       time.Duration
+
+	mu        sync.Mutex
+	counters  map[string]*loginCounter
+	lastPrune time.Time
+}
+
+func NewLoginLimiter(maxPerIP, maxPerAccount int, window, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		maxPerIP:      maxPerIP,
+		maxPerAccount: maxPerAccount,
+		window:        window,
+		lockout:       lockout,
+		counters:      make(map[string]*loginCounter),
+	}
+}
+
+// Check IP 或账号被锁定时返回 LockedError
+func (l *LoginLimiter) Check(ip, username string, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var until time.Time
+	for _, key := range l.keys(ip, username) {
+		if counter, ok := l.counters[key]; ok && counter.lockedUntil.After(until) {
+			until = counter.lockedUntil
+		}
+	}
+	if until.After(now) {
+		return &LockedError{Until: until}
+	}
+	return nil
+}
+
+// Fail 记录一次登录失败，返回因本次失败新锁定的 IP 或账号，如 "ip 10.0.0.1"
+func (l *LoginLimiter) Fail(ip, username string, now time.Time) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+	var locked []string
+	for _, key := range l.keys(ip, username) {
+		counter, ok := l.counters[key]
+		if !ok {
+			counter = &loginCounter{windowStart: now}
+			l.counters[key] = counter
+		}
+		if now.Sub(counter.windowStart) > l.window {
+			counter.failures = 0
+			counter.windowStart = now
+		}
+		counter.failures++
+		if counter.failures >= l.limit(key) {
+			counter.lockedUntil = now.Add(l.lockout)
+			counter.failures = 0
+			counter.windowStart = now
+			locked = append(locked, strings.Replace(key, ":", " ", 1))
+		}
+	}
+	return locked
+}
+
+// Succeed 登录成功后清零账号的失败次数。IP 的计数不清零，避免用一个可登录的账号重置对其他账号的猜测次数
+func (l *LoginLimiter) Succeed(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.counters, accountKey(username))
+}
+
+// keys 返回启用了限制的计数键
+func (l *LoginLimiter) keys(ip, username string) []string {
+	keys := make([]string, 0, 2)
+	if l.maxPerIP > 0 && ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	if l.maxPerAccount > 0 && username != "" {
+		keys = append(keys, accountKey(username))
+	}
+	return keys
+}
+
+func (l *LoginLimiter) limit(key string) int {
+	if strings.HasPrefix(key, "ip:") {
+		return l.maxPerIP
+	}
+	return l.maxPerAccount
+}
+
+// prune 每分钟最多一次删除窗口和锁定都已过期的计数，调用方需要持有锁
+func (l *LoginLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for key, counter := range l.counters {
+		if now.Sub(counter.windowStart) > l.window && !counter.lockedUntil.After(now) {
+			delete(l.counters, key)
+		}
+	}
+}
+
+// accountKey 账号的计数键，用户名不区分大小写，避免通过改变大小写绕过限制
+func accountKey(username string) string {
+	return "account:" + strings.ToLower(username)
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiterLocksIP(t *testing.T) {
+	l := NewLoginLimiter(3, 0, 10*time.Minute, 15*time.Minute)
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// 同一 IP 猜测不同账号
+	for i, username := range []string{"alice", "bob"} {
+		if locked := l.Fail("10.0.0.1", username, now); len(locked) != 0 {
+			t.Fatalf("failure %d locked %v", i+1, locked)
+		}
+	}
+	if locked := l.Fail("10.0.0.1", "carol", now); !reflect.DeepEqual(locked, []string{"ip 10.0.0.1"}) {
+		t.Fatalf("third failure locked %v, want [ip 10.0.0.1]", locked)
+	}
+
+	var lockedErr *LockedError
+	if err := l.Check("10.0.0.1", "dave", now.Add(time.Minute)); !errors.As(err, &lockedErr) {
+		t.Fatalf("Check locked IP error = %v, want LockedError", err)
+	}
+	if !lockedErr.Until.Equal(now.Add(15 * time.Minute)) {
+		t.Errorf("locked until %v, want %v", lockedErr.Until, now.Add(15*time.Minute))
+	}
+	if err := l.Check("10.0.0.2", "alice", now.Add(time.Minute)); err != nil {
+		t.Errorf("Check other IP: %v", err)
+	}
+	if err := l.Check("10.0.0.1", "dave", now.Add(15*time.Minute)); err != nil {
+		t.Errorf("Check after lockout: %v", err)
+	}
+
+	// 登录成功不清零 IP 的计数
+	l.Fail("10.0.0.3", "alice", now)
+	l.Fail("10.0.0.3", "bob", now)
+	l.Succeed("mallory")
+	if locked := l.Fail("10.0.0.3", "carol", now); len(locked) != 1 {
+		t.Errorf("failure after another account's success locked %v, want IP locked", locked)
+	}
+}
+
+func TestLoginLimiterLocksAccount(t *testing.T) {
+	l := NewLoginLimiter(0, 3, 10*time.Minute, 15*time.Minute)
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// 从不同 IP 猜测同一账号，用户名不区分大小写
+	l.Fail("10.0.0.1", "alice", now)
+	l.Fail("10.0.0.2", "Alice", now)
+	if locked := l.Fail("10.0.0.3", "ALICE", now); !reflect.DeepEqual(locked, []string{"account alice"}) {
+		t.Fatalf("third failure locked %v, want [account alice]", locked)
+	}
+	if err := l.Check("10.0.0.4", "alice", now); err == nil {
+		t.Fatal("Check allowed a locked account")
+	}
+	if err := l.Check("10.0.0.4", "bob", now); err != nil {
+		t.Errorf("Check other account: %v", err)
+	}
+
+	// 登录成功后清零账号的失败次数
+	l.Fail("10.0.0.1", "bob", now)
+	l.Fail("10.0.0.1", "bob", now)
+	l.Succeed("BOB")
+	if locked := l.Fail("10.0.0.1", "bob", now); len(locked) != 0 {
+		t.Errorf("failure after success locked %v", locked)
+	}
+}
+
+func TestLoginLimiterWindow(t *testing.T) {
+	l := NewLoginLimiter(2, 2, 10*time.Minute, 15*time.Minute)
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	// 超过窗口期的失败不累计
+	l.Fail("10.0.0.1", "alice", now)
+	if locked := l.Fail("10.0.0.1", "alice", now.Add(11*time.Minute)); len(locked) != 0 {
+		t.Errorf("failures in different windows locked %v", locked)
+	}
+	if locked := l.Fail("10.0.0.1", "alice", now.Add(12*time.Minute)); !reflect.DeepEqual(locked, []string{"ip 10.0.0.1", "account alice"}) {
+		t.Errorf("failures in the same window locked %v, want IP and account", locked)
+	}
+}
+
+func TestLoginLimiterDisabled(t *testing.T) {
+	l := NewLoginLimiter(0, 0, time.Minute, time.Minute)
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if locked := l.Fail("10.0.0.1", "alice", now); len(locked) != 0 {
+			t.Fatalf("disabled limiter locked %v", locked)
+		}
+	}
+	if err := l.Check("10.0.0.1", "alice", now); err != nil {
+		t.Errorf("Check: %v", err)
+	}
+}
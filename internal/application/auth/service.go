@@ -1,118 +1,345 @@
 package auth
 
 import (
+	"crontab_go/internal/application/audit"
 	"crontab_go/internal/domain/entity"
 	"crontab_go/internal/domain/repository"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte("crontab-go-secret-key-2024") // 在生产环境中应该从环境变量读取
+// MinPasswordLength 密码最小长度
+const MinPasswordLength = 6
+
+var (
+	// ErrInvalidCredentials 用户名或密码错误
+	ErrInvalidCredentials = errors.New("用户名或密码错误")
+	// ErrRegistrationDisabled 已关闭自助注册
+	ErrRegistrationDisabled = errors.New("已关闭注册，请联系管理员创建账号")
+	// ErrInvalidInviteCode 邀请码无效、已使用或已过期
+	ErrInvalidInviteCode = errors.New("邀请码无效或已过期")
+	// ErrPasswordTooShort 密码长度不足
+	ErrPasswordTooShort = errors.New("密码长度不能少于 6 位")
+	// ErrLocalLoginDisabled 已禁用本地账号密码登录
+	ErrLocalLoginDisabled = errors.New("已禁用本地账号登录，请使用单点登录或 LDAP 账号登录")
+	// ErrExternalAccount 外部身份提供方的用户不能使用本地密码
+	ErrExternalAccount = errors.New("该账号由单点登录或 LDAP 管理，不能使用本地密码")
+)
+
+// PasswordAuthenticator 外部账号密码认证方式，如 LDAP。本地不存在或属于该认证来源的用户登录时由其校验密码
+type PasswordAuthenticator interface {
+	// Name 认证来源，对应用户的 AuthProvider
+	Name() string
+	// Authenticate 校验用户名和密码，返回认证通过的身份，用户名或密码错误时返回 nil
+	Authenticate(username, password string) (*entity.ExternalIdentity, error)
+}
 
 type Service struct {
-	userRepo repository.UserRepository
+	userRepo          repository.UserRepository
+	inviteRepo        repository.InviteCodeRepository
+	sessionRepo       repository.SessionRepository
+	jwtSecret         []byte
+	jwtExpiration     time.Duration // 访问令牌有效期
+	refreshExpiration time.Duration // 刷新令牌有效期，即会话在不活动后保持登录的时间
+	registrationMode  string
+	localLoginEnabled bool
+	authenticator     PasswordAuthenticator // 未配置外部账号密码认证时为 nil
+	limiter           *LoginLimiter
+	auditService      *audit.Service
+}
+
+func NewService(userRepo repository.UserRepository, inviteRepo repository.InviteCodeRepository, sessionRepo repository.SessionRepository, jwtSecret string, jwtExpiration, refreshExpiration time.Duration, registrationMode string, localLoginEnabled bool, authenticator PasswordAuthenticator, limiter *LoginLimiter, auditService *audit.Service) *Service {
+	if registrationMode == "" {
+		registrationMode = entity.RegistrationOpen
+	}
+	return &Service{
+		userRepo:          userRepo,
+		inviteRepo:        inviteRepo,
+		sessionRepo:       sessionRepo,
+		jwtSecret:         []byte(jwtSecret),
+		jwtExpiration:     jwtExpiration,
+		refreshExpiration: refreshExpiration,
+		registrationMode:  registrationMode,
+		localLoginEnabled: localLoginEnabled,
+		authenticator:     authenticator,
+		limiter:           limiter,
+		auditService:      auditService,
+	}
+}
+
+// RegistrationMode 返回注册方式：open、approval、invite 或 disabled。禁用本地登录时不能注册
+func (s *Service) RegistrationMode() string {
+	if !s.localLoginEnabled {
+		return entity.RegistrationDisabled
+	}
+	return s.registrationMode
+}
+
+// LocalLoginEnabled 是否允许本地账号密码登录
+func (s *Service) LocalLoginEnabled() bool {
+	return s.localLoginEnabled
+}
+
+// HashPassword 使用 bcrypt 加密密码
+func HashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.New("密码加密失败")
+	}
+	return string(hashed), nil
 }
 
-func NewService(userRepo repository.UserRepository) *Service {
-	return &Service{userRepo: userRepo}
+// PasswordProvider 外部账号密码认证来源，如 ldap，未配置时返回空字符串
+func (s *Service) PasswordProvider() string {
+	if s.authenticator == nil {
+		return ""
+	}
+	return s.authenticator.Name()
 }
 
-// Login 用户登录
-func (s *Service) Login(req *entity.LoginRequest) (*entity.LoginResponse, error) {
+// Login 用户登录，创建新的会话并返回访问令牌和刷新令牌。ip 和 userAgent 用于在会话列表中识别设备。
+// 同一 IP 或账号登录失败次数过多时临时锁定，锁定期间返回 LockedError。失败的登录记录到审计日志
+func (s *Service) Login(req *entity.LoginRequest, ip, userAgent string) (*entity.LoginResponse, error) {
+	now := time.Now()
+	if err := s.limiter.Check(ip, req.Username, now); err != nil {
+		return nil, err
+	}
+
+	response, err := s.login(req, ip, userAgent)
+	if err == nil {
+		s.limiter.Succeed(req.Username)
+		return response, nil
+	}
+	if err == ErrTwoFactorRequired {
+		// 密码正确，等待用户输入验证码
+		return nil, err
+	}
+
+	s.auditService.Record(&entity.AuditLog{
+		Username:  req.Username,
+		Action:    entity.AuditLoginFailed,
+		Detail:    err.Error(),
+		IP:        ip,
+		UserAgent: userAgent,
+	})
+	if err == ErrInvalidCredentials || err == ErrInvalidTwoFactorCode {
+		if locked := s.limiter.Fail(ip, req.Username, now); len(locked) > 0 {
+			s.auditService.Record(&entity.AuditLog{
+				Username:  req.Username,
+				Action:    entity.AuditLoginLocked,
+				Detail:    "临时锁定 " + strings.Join(locked, ", "),
+				IP:        ip,
+				UserAgent: userAgent,
+			})
+		}
+	}
+	return nil, err
+}
+
+// login 校验账号密码和两步验证码。本地账号校验本地密码；
+// 配置了外部认证方式时，本地不存在或属于该认证来源的用户交由其校验
+func (s *Service) login(req *entity.LoginRequest, ip, userAgent string) (*entity.LoginResponse, error) {
 	// 查找用户
 	user, err := s.userRepo.FindByUsername(req.Username)
 	if err != nil {
-		return nil, errors.New("用户名或密码错误")
+		user = nil
+	}
+	if s.authenticator != nil && (user == nil || user.AuthProvider == s.authenticator.Name()) {
+		return s.externalPasswordLogin(req, ip, userAgent)
+	}
+
+	if !s.localLoginEnabled {
+		return nil, ErrLocalLoginDisabled
+	}
+	if user == nil {
+		return nil, ErrInvalidCredentials
 	}
 
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("用户名或密码错误")
+		return nil, ErrInvalidCredentials
+	}
+	if !user.IsLocal() {
+		return nil, ErrExternalAccount
 	}
 
 	// 检查用户是否激活
+	if user.PendingApproval {
+		return nil, errors.New("账号等待管理员审核")
+	}
 	if !user.IsActive {
 		return nil, errors.New("用户已被禁用")
 	}
+	if user.TOTPEnabled {
+		if err := s.verifySecondFactor(user, req.OTPCode); err != nil {
+			return nil, err
+		}
+	}
 
-	// 生成JWT token
-	token, err := s.generateToken(user)
+	return s.createSession(user, ip, userAgent)
+}
+
+// externalPasswordLogin 由外部认证方式校验密码，认证通过后按外部身份登录
+func (s *Service) externalPasswordLogin(req *entity.LoginRequest, ip, userAgent string) (*entity.LoginResponse, error) {
+	identity, err := s.authenticator.Authenticate(req.Username, req.Password)
 	if err != nil {
-		return nil, errors.New("生成token失败")
+		return nil, err
+	}
+	if identity == nil {
+		return nil, ErrInvalidCredentials
 	}
 
-	return &entity.LoginResponse{
-		Token: token,
-		User:  user,
-	}, nil
+	user, err := s.resolveExternalUser(identity)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		if err := s.verifySecondFactor(user, req.OTPCode); err != nil {
+			return nil, err
+		}
+	}
+	return s.createSession(user, ip, userAgent)
 }
 
-// Register 用户注册
+// Register 用户注册。注册方式为 invite 时必须提供邀请码；为 approval 时没有邀请码的用户需要管理员审核后才能登录
 func (s *Service) Register(req *entity.RegisterRequest) (*entity.User, error) {
+	if s.RegistrationMode() == entity.RegistrationDisabled {
+		return nil, ErrRegistrationDisabled
+	}
+
 	// 检查用户名是否已存在
 	if _, err := s.userRepo.FindByUsername(req.Username); err == nil {
 		return nil, errors.New("用户名已存在")
 	}
 
+	var invite *entity.InviteCode
+	if req.InviteCode != "" || s.registrationMode == entity.RegistrationInvite {
+		found, err := s.inviteRepo.FindByCode(req.InviteCode)
+		if err != nil || !found.Usable(time.Now()) {
+			return nil, ErrInvalidInviteCode
+		}
+		invite = found
+	}
+
 	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := HashPassword(req.Password)
 	if err != nil {
-		return nil, errors.New("密码加密失败")
+		return nil, err
 	}
 
 	// 创建用户
 	user := &entity.User{
 		Username: req.Username,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
 		Email:    req.Email,
-		Role:     "user",
+		Role:     entity.RoleViewer, // 新注册的用户只读，由管理员分配角色和任务权限
 		IsActive: true,
 	}
+	if invite != nil {
+		user.Role = invite.Role
+	} else if s.registrationMode == entity.RegistrationApproval {
+		user.IsActive = false
+		user.PendingApproval = true
+	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, errors.New("创建用户失败")
 	}
 
+	if invite != nil {
+		// 并发注册时只有一个用户能使用邀请码，其余的回滚
+		used, err := s.inviteRepo.MarkUsed(invite.ID, user.ID)
+		if err != nil || !used {
+			_ = s.userRepo.Delete(user.ID)
+			return nil, ErrInvalidInviteCode
+		}
+	}
+
 	return user, nil
 }
 
-// ValidateToken 验证JWT token
-func (s *Service) ValidateToken(tokenString string) (*entity.User, error) {
+// ChangePassword 用户修改自己的密码，修改后不再要求强制修改密码，并注销除 sessionID 外的其他会话
+func (s *Service) ChangePassword(user *entity.User, sessionID uint, oldPassword, newPassword string) error {
+	if !user.IsLocal() {
+		return ErrExternalAccount
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(oldPassword)); err != nil {
+		return errors.New("原密码错误")
+	}
+	if len(newPassword) < MinPasswordLength {
+		return ErrPasswordTooShort
+	}
+	if oldPassword == newPassword {
+		return errors.New("新密码不能与原密码相同")
+	}
+
+	hashedPassword, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hashedPassword
+	user.MustChangePassword = false
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+	return s.sessionRepo.RevokeByUser(user.ID, sessionID, time.Now())
+}
+
+// ValidateToken 验证访问令牌，返回令牌所属的用户和会话 ID。
+// 会话已注销、用户已被禁用或令牌不属于任何会话（旧版本签发）时验证失败
+func (s *Service) ValidateToken(tokenString string) (*entity.User, uint, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("无效的签名方法")
 		}
-		return jwtSecret, nil
+		return s.jwtSecret, nil
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userID := uint(claims["user_id"].(float64))
-		user, err := s.userRepo.FindByID(userID)
-		if err != nil {
-			return nil, errors.New("用户不存在")
-		}
-		return user, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, 0, errors.New("无效的token")
+	}
+	userID, ok1 := claims["user_id"].(float64)
+	sessionID, ok2 := claims["sid"].(float64)
+	if !ok1 || !ok2 {
+		return nil, 0, errors.New("无效的token")
 	}
 
-	return nil, errors.New("无效的token")
+	session, err := s.sessionRepo.FindByID(uint(sessionID))
+	if err != nil || session.UserID != uint(userID) || !session.Active(time.Now()) {
+		return nil, 0, errors.New("会话已失效，请重新登录")
+	}
+
+	user, err := s.userRepo.FindByID(uint(userID))
+	if err != nil {
+		return nil, 0, errors.New("用户不存在")
+	}
+	if !user.IsActive {
+		return nil, 0, errors.New("用户已被禁用")
+	}
+	return user, session.ID, nil
 }
 
-// generateToken 生成JWT token
-func (s *Service) generateToken(user *entity.User) (string, error) {
+// generateToken 生成访问令牌，sid 为所属会话 ID
+func (s *Service) generateToken(user *entity.User, sessionID uint) (string, error) {
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"role":     user.Role,
-		"exp":      time.Now().Add(time.Hour * 24 * 7).Unix(), // 7天过期
+		"sid":      sessionID,
+		"iat":      now.Unix(),
+		"exp":      now.Add(s.jwtExpiration).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
\ No newline at end of file
+	return token.SignedString(s.jwtSecret)
+}
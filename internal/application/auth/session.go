@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRefreshToken 刷新令牌无效、已过期或会话已注销
+	ErrInvalidRefreshToken = errors.New("登录已过期，请重新登录")
+	// ErrSessionNotFound 会话不存在或不属于当前用户
+	ErrSessionNotFound = errors.New("会话不存在")
+)
+
+// createSession 为用户创建会话并签发访问令牌和刷新令牌
+func (s *Service) createSession(user *entity.User, ip, userAgent string) (*entity.LoginResponse, error) {
+	now := time.Now()
+	// 顺便清理刷新令牌已过期的会话
+	if _, err := s.sessionRepo.DeleteExpired(now); err != nil {
+		log.Printf("Failed to delete expired sessions: %v", err)
+	}
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		return nil, errors.New("生成token失败")
+	}
+	session := &entity.Session{
+		UserID:           user.ID,
+		RefreshTokenHash: hashRefreshToken(refreshToken),
+		UserAgent:        userAgent,
+		IP:               ip,
+		ExpiresAt:        now.Add(s.refreshExpiration),
+		LastUsedAt:       now,
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+	return s.tokenResponse(user, session.ID, refreshToken)
+}
+
+// Refresh 使用刷新令牌获取新的访问令牌，同时更换刷新令牌。
+// 已被更换的刷新令牌再次使用说明令牌可能已泄露，整个会话会被注销
+func (s *Service) Refresh(refreshToken, ip string) (*entity.LoginResponse, error) {
+	hash := hashRefreshToken(refreshToken)
+	now := time.Now()
+
+	session, err := s.sessionRepo.FindByRefreshHash(hash)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			if reused, findErr := s.sessionRepo.FindByPreviousHash(hash); findErr == nil && reused.RevokedAt == nil {
+				log.Printf("Refresh token reused for session %d of user %d, revoking session", reused.ID, reused.UserID)
+				_ = s.sessionRepo.Revoke(reused.ID, now)
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !session.Active(now) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.FindByID(session.UserID)
+	if err != nil || !user.IsActive {
+		_ = s.sessionRepo.Revoke(session.ID, now)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	newToken, err := newRefreshToken()
+	if err != nil {
+		return nil, errors.New("生成token失败")
+	}
+	// 并发使用同一个刷新令牌时只有一个请求能更换成功
+	rotated, err := s.sessionRepo.Rotate(session.ID, hash, hashRefreshToken(newToken), now.Add(s.refreshExpiration), now, ip)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, ErrInvalidRefreshToken
+	}
+	return s.tokenResponse(user, session.ID, newToken)
+}
+
+func (s *Service) tokenResponse(user *entity.User, sessionID uint, refreshToken string) (*entity.LoginResponse, error) {
+	token, err := s.generateToken(user, sessionID)
+	if err != nil {
+		return nil, errors.New("生成token失败")
+	}
+	return &entity.LoginResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.jwtExpiration / time.Second),
+		User:         user,
+	}, nil
+}
+
+// Logout 注销会话，会话的访问令牌和刷新令牌立即失效
+func (s *Service) Logout(sessionID uint) error {
+	return s.sessionRepo.Revoke(sessionID, time.Now())
+}
+
+// LogoutAll 注销用户的所有会话
+func (s *Service) LogoutAll(userID uint) error {
+	return s.sessionRepo.RevokeByUser(userID, 0, time.Now())
+}
+
+// ListSessions 获取用户的有效会话，currentID 为当前请求使用的会话
+func (s *Service) ListSessions(userID, currentID uint) ([]*entity.Session, error) {
+	sessions, err := s.sessionRepo.FindActiveByUser(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession 注销用户自己的某个会话
+func (s *Service) RevokeSession(userID, sessionID uint) error {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return s.sessionRepo.Revoke(session.ID, time.Now())
+}
+
+func newRefreshToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashRefreshToken 刷新令牌是高熵随机值，使用 SHA-256 保存即可按哈希查找
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
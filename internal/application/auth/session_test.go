@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// sessionID 从访问令牌中获取会话ID
+func sessionID(t *testing.T, s *Service, resp *entity.LoginResponse) uint {
+	t.Helper()
+
+	_, id, err := s.ValidateToken(resp.Token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	return id
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s, userRepo, sessionRepo := newTestAuthService(t)
+	user := createTestUser(t, userRepo, "alice")
+
+	login, err := s.createSession(user, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("createSession: %v", err)
+	}
+	id := sessionID(t, s, login)
+	before, err := sessionRepo.FindByID(id)
+	if err != nil {
+		t.Fatalf("find session: %v", err)
+	}
+
+	refreshed, err := s.Refresh(login.RefreshToken, "10.0.0.2")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Error("Refresh did not rotate the refresh token")
+	}
+	if got := sessionID(t, s, refreshed); got != id {
+		t.Errorf("refreshed session = %d, want %d", got, id)
+	}
+
+	after, err := sessionRepo.FindByID(id)
+	if err != nil {
+		t.Fatalf("find session: %v", err)
+	}
+	if after.RefreshTokenHash != hashRefreshToken(refreshed.RefreshToken) {
+		t.Error("session does not store the new refresh token hash")
+	}
+	if after.IP != "10.0.0.2" {
+		t.Errorf("session IP = %q, want 10.0.0.2", after.IP)
+	}
+	if after.ExpiresAt.Before(before.ExpiresAt) {
+		t.Errorf("session expiry moved back from %v to %v", before.ExpiresAt, after.ExpiresAt)
+	}
+
+	// 新的刷新令牌可以继续使用
+	if _, err := s.Refresh(refreshed.RefreshToken, "10.0.0.2"); err != nil {
+		t.Fatalf("Refresh with rotated token: %v", err)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	s, userRepo, _ := newTestAuthService(t)
+	user := createTestUser(t, userRepo, "alice")
+
+	login, err := s.createSession(user, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("createSession: %v", err)
+	}
+	other, err := s.createSession(user, "10.0.0.3", "other device")
+	if err != nil {
+		t.Fatalf("createSession: %v", err)
+	}
+	refreshed, err := s.Refresh(login.RefreshToken, "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// 已被更换的刷新令牌再次使用，整个会话被注销
+	if _, err := s.Refresh(login.RefreshToken, "10.0.0.9"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("Refresh with reused token error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := s.Refresh(refreshed.RefreshToken, "10.0.0.1"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh with current token after reuse error = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, _, err := s.ValidateToken(refreshed.Token); err == nil {
+		t.Error("access token of the revoked session is still valid")
+	}
+
+	// 同一用户的其他会话不受影响
+	if _, err := s.Refresh(other.RefreshToken, "10.0.0.3"); err != nil {
+		t.Errorf("Refresh other session: %v", err)
+	}
+}
+
+func TestRefreshConcurrentUseRotatesOnce(t *testing.T) {
+	s, userRepo, _ := newTestAuthService(t)
+	user := createTestUser(t, userRepo, "alice")
+
+	login, err := s.createSession(user, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("createSession: %v", err)
+	}
+
+	const attempts = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Refresh(login.RefreshToken, "10.0.0.1"); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want 1", succeeded)
+	}
+}
+
+func TestRefreshRejectsInactiveSessions(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, s *Service, user *entity.User, login *entity.LoginResponse) string
+	}{
+		{"unknown token", func(t *testing.T, s *Service, user *entity.User, login *entity.LoginResponse) string {
+			return "unknown"
+		}},
+		{"expired session", func(t *testing.T, s *Service, user *entity.User, login *entity.LoginResponse) string {
+			s.refreshExpiration = -time.Minute
+			expired, err := s.createSession(user, "10.0.0.1", "test")
+			if err != nil {
+				t.Fatalf("createSession: %v", err)
+			}
+			return expired.RefreshToken
+		}},
+		{"logged out", func(t *testing.T, s *Service, user *entity.User, login *entity.LoginResponse) string {
+			if err := s.Logout(sessionID(t, s, login)); err != nil {
+				t.Fatalf("Logout: %v", err)
+			}
+			return login.RefreshToken
+		}},
+		{"logged out everywhere", func(t *testing.T, s *Service, user *entity.User, login *entity.LoginResponse) string {
+			if err := s.LogoutAll(user.ID); err != nil {
+				t.Fatalf("LogoutAll: %v", err)
+			}
+			return login.RefreshToken
+		}},
+		{"disabled user", func(t *testing.T, s *Service, user *entity.User, login *entity.LoginResponse) string {
+			user.IsActive = false
+			if err := s.userRepo.Update(user); err != nil {
+				t.Fatalf("disable user: %v", err)
+			}
+			return login.RefreshToken
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, userRepo, _ := newTestAuthService(t)
+			user := createTestUser(t, userRepo, "alice")
+			login, err := s.createSession(user, "10.0.0.1", "test")
+			if err != nil {
+				t.Fatalf("createSession: %v", err)
+			}
+
+			token := tt.setup(t, s, user, login)
+			if _, err := s.Refresh(token, "10.0.0.1"); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("Refresh error = %v, want %v", err, ErrInvalidRefreshToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"crontab_go/internal/application/audit"
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/infrastructure/persistence"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA1 测试向量使用的密钥 "12345678901234567890"
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// RFC 6238 附录 B 的 8 位验证码，6 位验证码为其后 6 位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	key := []byte("12345678901234567890")
+	for _, tt := range tests {
+		want := tt.want[len(tt.want)-totpDigits:]
+		if got := totpCode(key, tt.unix/totpPeriod); got != want {
+			t.Errorf("totpCode(T=%d) = %s, want %s", tt.unix, got, want)
+		}
+
+		step, ok := verifyTOTP(rfc6238Secret, want, time.Unix(tt.unix, 0), 0)
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("verifyTOTP(T=%d) = %d, %v, want step %d", tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestVerifyTOTPSkewWindow(t *testing.T) {
+	key := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name   string
+		offset int64
+		wantOK bool
+	}{
+		{"current step", 0, true},
+		{"previous step", -1, true},
+		{"next step", 1, true},
+		{"two steps behind", -2, false},
+		{"two steps ahead", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := verifyTOTP(rfc6238Secret, totpCode(key, current+tt.offset), now, 0)
+			if ok != tt.wantOK {
+				t.Fatalf("verifyTOTP ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && step != current+tt.offset {
+				t.Errorf("step = %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := verifyTOTP(rfc6238Secret, code, now, 0); ok {
+			t.Errorf("verifyTOTP accepted %q", code)
+		}
+	}
+	if _, ok := verifyTOTP("not base32!", totpCode(key, current), now, 0); ok {
+		t.Error("verifyTOTP accepted an invalid secret")
+	}
+}
+
+func TestVerifyTOTPRejectsUsedSteps(t *testing.T) {
+	key := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+	current := now.Unix() / totpPeriod
+
+	// 同一验证码不能重复使用
+	if _, ok := verifyTOTP(rfc6238Secret, totpCode(key, current), now, current); ok {
+		t.Error("verifyTOTP accepted the code of the last used step")
+	}
+	// 使用过较新的验证码后，窗口内较早的验证码也失效
+	if _, ok := verifyTOTP(rfc6238Secret, totpCode(key, current-1), now, current); ok {
+		t.Error("verifyTOTP accepted an older code after a newer one was used")
+	}
+	if _, ok := verifyTOTP(rfc6238Secret, totpCode(key, current+1), now, current); !ok {
+		t.Error("verifyTOTP rejected the next step")
+	}
+}
+
+// newTestAuthService 创建使用临时 SQLite 数据库的认证服务
+func newTestAuthService(t *testing.T) (*Service, repository.UserRepository, repository.SessionRepository) {
+	t.Helper()
+
+	// 并发测试中多个连接同时写入，等待锁而不是立即返回 database is locked
+	db, err := persistence.NewSQLiteDB(filepath.Join(t.TempDir(), "crontab.db") + "?_busy_timeout=5000")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	userRepo := persistence.NewUserRepository(db.Client)
+	sessionRepo := persistence.NewSessionRepository(db.Client)
+	s := NewService(
+		userRepo,
+		persistence.NewInviteCodeRepository(db.Client),
+		sessionRepo,
+		"test-secret", time.Hour, 24*time.Hour, entity.RegistrationDisabled, true, nil,
+		NewLoginLimiter(0, 0, time.Minute, time.Minute),
+		audit.NewService(persistence.NewAuditLogRepository(db.Client)),
+	)
+	return s, userRepo, sessionRepo
+}
+
+// createTestUser 创建本地账号，密码为 password123
+func createTestUser(t *testing.T, userRepo repository.UserRepository, username string) *entity.User {
+	t.Helper()
+
+	password, err := HashPassword("password123")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	user := &entity.User{
+		Username:     username,
+		Password:     password,
+		Email:        username + "@example.com",
+		Role:         entity.RoleOperator,
+		IsActive:     true,
+		AuthProvider: entity.AuthProviderLocal,
+	}
+	if err := userRepo.Create(user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// concurrentLogins 同时使用同一个验证码登录，返回成功的次数
+func concurrentLogins(t *testing.T, s *Service, username, code string, n int) int {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Login(&entity.LoginRequest{Username: username, Password: "password123", OTPCode: code}, "127.0.0.1", "test")
+			if err != nil && !errors.Is(err, ErrInvalidTwoFactorCode) {
+				t.Errorf("Login: %v", err)
+			}
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return succeeded
+}
+
+func TestLoginTwoFactorCodeCanBeUsedOnce(t *testing.T) {
+	s, userRepo, _ := newTestAuthService(t)
+	user := createTestUser(t, userRepo, "alice")
+
+	setup, err := s.SetupTOTP(user)
+	if err != nil {
+		t.Fatalf("SetupTOTP: %v", err)
+	}
+	key, _ := totpEncoding.DecodeString(setup.Secret)
+	current := time.Now().Unix() / totpPeriod
+	recoveryCodes, err := s.EnableTOTP(user, totpCode(key, current-1))
+	if err != nil {
+		t.Fatalf("EnableTOTP: %v", err)
+	}
+
+	if _, err := s.Login(&entity.LoginRequest{Username: "alice", Password: "password123"}, "127.0.0.1", "test"); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("Login without code error = %v, want ErrTwoFactorRequired", err)
+	}
+
+	// 同时提交同一个验证码的登录只有一个成功
+	if n := concurrentLogins(t, s, "alice", totpCode(key, current), 8); n != 1 {
+		t.Fatalf("%d concurrent logins with the same TOTP code succeeded, want 1", n)
+	}
+	if _, err := s.Login(&entity.LoginRequest{Username: "alice", Password: "password123", OTPCode: totpCode(key, current)}, "127.0.0.1", "test"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Fatalf("replayed TOTP code error = %v, want ErrInvalidTwoFactorCode", err)
+	}
+
+	// 恢复码同样只能使用一次
+	if n := concurrentLogins(t, s, "alice", recoveryCodes[0], 8); n != 1 {
+		t.Fatalf("%d concurrent logins with the same recovery code succeeded, want 1", n)
+	}
+	stored, err := userRepo.FindByID(user.ID)
+	if err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	if remaining := RecoveryCodesRemaining(stored); remaining != recoveryCodeCount-1 {
+		t.Errorf("recovery codes remaining = %d, want %d", remaining, recoveryCodeCount-1)
+	}
+	if _, err := s.Login(&entity.LoginRequest{Username: "alice", Password: "password123", OTPCode: recoveryCodes[1]}, "127.0.0.1", "test"); err != nil {
+		t.Fatalf("Login with another recovery code: %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// recoveryCodeCount 每次生成的恢复码数量
+const recoveryCodeCount = 10
+
+var (
+	// ErrTwoFactorRequired 账号已启用两步验证，需要提供验证码
+	ErrTwoFactorRequired = errors.New("请输入两步验证码")
+	// ErrInvalidTwoFactorCode 验证码或恢复码错误
+	ErrInvalidTwoFactorCode = errors.New("两步验证码错误")
+	// ErrTwoFactorUnavailable 单点登录的账号由身份提供方负责两步验证
+	ErrTwoFactorUnavailable = errors.New("单点登录的账号请在身份提供方设置两步验证")
+)
+
+// SetupTOTP 生成新的 TOTP 密钥，用户在验证器应用中添加后调用 EnableTOTP 完成启用。已启用时需要先停用
+func (s *Service) SetupTOTP(user *entity.User) (*entity.TOTPSetupResponse, error) {
+	if !s.passwordLogin(user) {
+		return nil, ErrTwoFactorUnavailable
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("已启用两步验证，请先停用后重新绑定")
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPSecret = secret
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return &entity.TOTPSetupResponse{Secret: secret, URL: totpURL(secret, user.Username)}, nil
+}
+
+// EnableTOTP 校验验证器应用生成的验证码后启用两步验证，返回恢复码。恢复码只在此时返回一次
+func (s *Service) EnableTOTP(user *entity.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, errors.New("已启用两步验证")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("请先获取两步验证密钥")
+	}
+	step, ok := verifyTOTP(user.TOTPSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP 使用验证码或恢复码确认后停用两步验证
+func (s *Service) DisableTOTP(user *entity.User, code string) error {
+	if !user.TOTPEnabled {
+		return errors.New("未启用两步验证")
+	}
+	if err := s.verifySecondFactor(user, code); err != nil {
+		return err
+	}
+	ClearTwoFactor(user)
+	return s.userRepo.Update(user)
+}
+
+// RegenerateRecoveryCodes 使用验证码确认后重新生成恢复码，之前的恢复码全部失效
+func (s *Service) RegenerateRecoveryCodes(user *entity.User, code string) ([]string, error) {
+	if !user.TOTPEnabled {
+		return nil, errors.New("未启用两步验证")
+	}
+	if err := s.verifySecondFactor(user, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	user.RecoveryCodes = hashes
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RecoveryCodesRemaining 未使用的恢复码数量
+func RecoveryCodesRemaining(user *entity.User) int {
+	return len(recoveryHashes(user))
+}
+
+// ClearTwoFactor 清除用户的两步验证设置，调用方负责保存
+func ClearTwoFactor(user *entity.User) {
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = ""
+}
+
+// verifySecondFactor 校验动态验证码或恢复码，验证码和恢复码都只能使用一次。
+// 使用条件更新记录，同时使用同一个验证码的请求只有一个能通过
+func (s *Service) verifySecondFactor(user *entity.User, code string) error {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return ErrTwoFactorRequired
+	}
+
+	if step, ok := verifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		used, err := s.userRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidTwoFactorCode
+		}
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	hashes := recoveryHashes(user)
+	hash := hashRecoveryCode(code)
+	for i, candidate := range hashes {
+		if candidate == hash {
+			remaining, _ := json.Marshal(append(hashes[:i:i], hashes[i+1:]...))
+			replaced, err := s.userRepo.ReplaceRecoveryCodes(user.ID, user.RecoveryCodes, string(remaining))
+			if err != nil {
+				return err
+			}
+			if !replaced {
+				return ErrInvalidTwoFactorCode
+			}
+			user.RecoveryCodes = string(remaining)
+			return nil
+		}
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// passwordLogin 用户是否使用账号密码登录（本地账号或外部账号密码认证），只有这些账号可以设置两步验证
+func (s *Service) passwordLogin(user *entity.User) bool {
+	return user.IsLocal() || (s.authenticator != nil && user.AuthProvider == s.authenticator.Name())
+}
+
+// newRecoveryCodes 生成恢复码，返回明文和保存到用户的哈希 JSON
+func newRecoveryCodes() ([]string, string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(buf))
+		codes[i] = raw[:4] + "-" + raw[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	data, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(data), nil
+}
+
+// hashRecoveryCode 恢复码不区分大小写，忽略分隔符
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func recoveryHashes(user *entity.User) []string {
+	var hashes []string
+	if user.RecoveryCodes != "" {
+		_ = json.Unmarshal([]byte(user.RecoveryCodes), &hashes)
+	}
+	return hashes
+}
@@ -0,0 +1,412 @@
+package channel
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/domain/service"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// ErrChannelInUse 通知渠道仍被任务、模板、报表摘要或升级策略引用
+var ErrChannelInUse = errors.New("通知渠道正在被使用，无法删除")
+
+type Service struct {
+	channelRepo         repository.NotificationChannelRepository
+	notificationService *service.NotificationService
+}
+
+func NewService(channelRepo repository.NotificationChannelRepository) *Service {
+	return &Service{
+		channelRepo:         channelRepo,
+		notificationService: service.NewNotificationService(),
+	}
+}
+
+// CreateChannel 创建通知渠道，返回的渠道配置中敏感字段已掩码
+func (s *Service) CreateChannel(channel *entity.NotificationChannel) error {
+	if err := s.normalizeChannel(channel); err != nil {
+		return err
+	}
+	if err := s.channelRepo.Create(channel); err != nil {
+		return err
+	}
+	s.mask(channel)
+	return nil
+}
+
+// UpdateChannel 更新通知渠道，所属项目不变。类型和连接配置未修改时，配置中仍为掩码的敏感字段保留原值，返回的渠道配置中敏感字段已掩码
+func (s *Service) UpdateChannel(channel *entity.NotificationChannel) error {
+	existing, err := s.channelRepo.FindByID(channel.ID)
+	if err != nil {
+		return err
+	}
+
+	channel.Config, err = s.notificationService.MergeMaskedConfig(channel.Type, channel.Config, existing.Type, existing.Config)
+	if err != nil {
+		return err
+	}
+	if err := s.normalizeChannel(channel); err != nil {
+		return err
+	}
+
+	channel.CreatedAt = existing.CreatedAt
+	channel.ProjectID = existing.ProjectID
+	if err := s.channelRepo.Update(channel); err != nil {
+		return err
+	}
+	s.mask(channel)
+	return nil
+}
+
+// DeleteChannel 删除通知渠道，仍被引用的渠道不允许删除
+func (s *Service) DeleteChannel(id int) error {
+	count, err := s.channelRepo.CountReferences(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrChannelInUse
+	}
+	return s.channelRepo.Delete(id)
+}
+
+// GetChannel 获取通知渠道，敏感字段已掩码
+func (s *Service) GetChannel(id int) (*entity.NotificationChannel, error) {
+	channel, err := s.channelRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mask(channel)
+	return channel, nil
+}
+
+// ListChannels 获取所有通知渠道，敏感字段已掩码
+func (s *Service) ListChannels() ([]*entity.NotificationChannel, error) {
+	channels, err := s.channelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		s.mask(channel)
+	}
+	return channels, nil
+}
+
+// TestChannel 向通知渠道发送一条测试通知
+func (s *Service) TestChannel(id int) error {
+	channel, err := s.channelRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	return s.notificationService.TestChannel(channel)
+}
+
+// ValidateMessageTemplate 校验任务或渠道的消息模板
+func (s *Service) ValidateMessageTemplate(value string) error {
+	return s.notificationService.ValidateMessageTemplate(value)
+}
+
+// PreviewMessage 使用示例数据预览消息模板。指定 channelID 时使用该渠道的类型、语言和模板，
+// tmpl 优先于渠道模板，可用于编辑任务或渠道模板时预览未保存的内容
+func (s *Service) PreviewMessage(channelID int, channelType, language string, tmpl entity.MessageTemplate, success bool) (*entity.MessagePreview, error) {
+	templates := []entity.MessageTemplate{tmpl}
+	if channelID > 0 {
+		channel, err := s.channelRepo.FindByID(channelID)
+		if err != nil {
+			return nil, err
+		}
+		channelTemplate, err := entity.ParseMessageTemplate(channel.MessageTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("消息模板格式错误: %v", err)
+		}
+		templates = append(templates, channelTemplate)
+		channelType = channel.Type
+		if language == "" {
+			language = channel.Language
+		}
+	}
+	if channelType == "" {
+		return nil, errors.New("请指定渠道类型或渠道ID")
+	}
+	return s.notificationService.PreviewMessage(channelType, language, success, templates...)
+}
+
+// FindChannels 根据 JSON 格式存储的渠道ID列表获取通知渠道，返回完整配置，仅供发送通知使用
+func (s *Service) FindChannels(channelIDs string) ([]*entity.NotificationChannel, error) {
+	ids, err := entity.ParseChannelIDs(channelIDs)
+	if err != nil {
+		return nil, fmt.Errorf("通知渠道格式错误: %v", err)
+	}
+	return s.channelRepo.FindByIDs(ids)
+}
+
+// ResolveChannelIDs 校验引用的通知渠道是否存在，并将请求中的内联通知配置
+// （notification_types + notification_config）转换为通知渠道，返回合并后的渠道ID列表
+func (s *Service) ResolveChannelIDs(owner, notificationTypes, notificationConfig, channelIDs string) (string, error) {
+	ids, err := entity.ParseChannelIDs(channelIDs)
+	if err != nil {
+		return "", fmt.Errorf("通知渠道格式错误: %v", err)
+	}
+
+	channels, err := s.channelRepo.FindByIDs(ids)
+	if err != nil {
+		return "", err
+	}
+	if len(channels) != len(uniqueIDs(ids)) {
+		return "", errors.New("引用的通知渠道不存在")
+	}
+
+	inlineIDs, err := s.convertInlineConfig(owner, notificationTypes, notificationConfig, true)
+	if err != nil {
+		return "", err
+	}
+	return entity.FormatChannelIDs(uniqueIDs(append(ids, inlineIDs...))), nil
+}
+
+// MigrateInlineConfigs 将任务、模板和报表摘要中的内联通知配置迁移为通知渠道，迁移后清空内联配置。
+// 配置相同的渠道只创建一次，配置校验失败的渠道同样会被创建，以便在渠道管理中修正
+func (s *Service) MigrateInlineConfigs(
+	taskRepo repository.TaskRepository,
+	templateRepo repository.TaskTemplateRepository,
+	digestRepo repository.ReportDigestRepository,
+) error {
+	tasks, err := taskRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.NotificationTypes == "" && task.NotificationConfig == "" {
+			continue
+		}
+		channelIDs, err := s.migrate("任务 "+task.Name, task.NotificationTypes, task.NotificationConfig, task.NotificationChannelIDs)
+		if err != nil {
+			log.Printf("Failed to migrate notification config for task %s: %v", task.Name, err)
+			continue
+		}
+		task.NotificationChannelIDs, task.NotificationTypes, task.NotificationConfig = channelIDs, "", ""
+		if err := taskRepo.Update(task); err != nil {
+			return err
+		}
+	}
+
+	templates, err := templateRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, template := range templates {
+		if template.NotificationTypes == "" && template.NotificationConfig == "" {
+			continue
+		}
+		channelIDs, err := s.migrate("模板 "+template.Name, template.NotificationTypes, template.NotificationConfig, template.NotificationChannelIDs)
+		if err != nil {
+			log.Printf("Failed to migrate notification config for template %s: %v", template.Name, err)
+			continue
+		}
+		template.NotificationChannelIDs, template.NotificationTypes, template.NotificationConfig = channelIDs, "", ""
+		if err := templateRepo.Update(template); err != nil {
+			return err
+		}
+	}
+
+	digests, err := digestRepo.FindAll()
+	if err != nil {
+		return err
+	}
+	for _, digest := range digests {
+		if digest.NotificationTypes == "" && digest.NotificationConfig == "" {
+			continue
+		}
+		channelIDs, err := s.migrate("报表摘要 "+digest.Name, digest.NotificationTypes, digest.NotificationConfig, digest.NotificationChannelIDs)
+		if err != nil {
+			log.Printf("Failed to migrate notification config for digest %s: %v", digest.Name, err)
+			continue
+		}
+		digest.NotificationChannelIDs, digest.NotificationTypes, digest.NotificationConfig = channelIDs, "", ""
+		if err := digestRepo.Update(digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrate 迁移单条内联通知配置，返回合并后的渠道ID列表
+func (s *Service) migrate(owner, notificationTypes, notificationConfig, channelIDs string) (string, error) {
+	ids, err := entity.ParseChannelIDs(channelIDs)
+	if err != nil {
+		return "", err
+	}
+	inlineIDs, err := s.convertInlineConfig(owner, notificationTypes, notificationConfig, false)
+	if err != nil {
+		return "", err
+	}
+	if len(inlineIDs) > 0 {
+		log.Printf("Migrated inline notification config of %s to channels %v", owner, inlineIDs)
+	}
+	return entity.FormatChannelIDs(uniqueIDs(append(ids, inlineIDs...))), nil
+}
+
+// convertInlineConfig 为内联通知配置中的每种通知类型查找或创建通知渠道。
+// strict 为 true 时配置校验失败返回错误，否则跳过未注册或缺少配置的类型，仅记录校验失败
+func (s *Service) convertInlineConfig(owner, notificationTypes, notificationConfig string, strict bool) ([]int, error) {
+	if notificationTypes == "" {
+		return nil, nil
+	}
+
+	var types []string
+	if err := json.Unmarshal([]byte(notificationTypes), &types); err != nil {
+		return nil, fmt.Errorf("通知类型格式错误: %v", err)
+	}
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var config entity.NotificationConfig
+	if notificationConfig != "" {
+		if err := json.Unmarshal([]byte(notificationConfig), &config); err != nil {
+			return nil, fmt.Errorf("通知配置格式错误: %v", err)
+		}
+	}
+
+	if strict {
+		if err := s.notificationService.ValidateConfig(config, types); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := s.channelRepo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []int
+	for _, channelType := range types {
+		channelConfig := strings.TrimSpace(string(config[channelType]))
+		if channelConfig == "" || channelConfig == "null" {
+			log.Printf("Skip notification type %s of %s: config is missing", channelType, owner)
+			continue
+		}
+
+		channel := &entity.NotificationChannel{Type: channelType, Config: channelConfig}
+		if err := s.notificationService.ValidateChannel(channel); err != nil {
+			if !s.notificationService.HasNotifier(channelType) {
+				log.Printf("Skip notification type %s of %s: %v", channelType, owner, err)
+				continue
+			}
+			log.Printf("Notification config %s of %s is invalid: %v", channelType, owner, err)
+		}
+
+		if found := findSameChannel(existing, channel); found != nil {
+			ids = append(ids, found.ID)
+			continue
+		}
+
+		channel.Name = uniqueName(existing, fmt.Sprintf("%s - %s", owner, channelType))
+		channel.Description = fmt.Sprintf("由%s的通知配置迁移生成", owner)
+		channel.CreatedAt = time.Now()
+		channel.UpdatedAt = time.Now()
+		if err := s.channelRepo.Create(channel); err != nil {
+			return nil, err
+		}
+		existing = append(existing, channel)
+		ids = append(ids, channel.ID)
+	}
+
+	return ids, nil
+}
+
+// normalizeChannel 校验通知渠道名称、类型和配置
+func (s *Service) normalizeChannel(channel *entity.NotificationChannel) error {
+	channel.Name = strings.TrimSpace(channel.Name)
+	if channel.Name == "" {
+		return errors.New("渠道名称不能为空")
+	}
+
+	if existing, err := s.channelRepo.FindByName(channel.Name); err == nil && existing.ID != channel.ID {
+		return fmt.Errorf("渠道名称已存在: %s", channel.Name)
+	}
+
+	switch channel.Language {
+	case "":
+		channel.Language = entity.LanguageZh
+	case entity.LanguageZh, entity.LanguageEn:
+	default:
+		return fmt.Errorf("不支持的语言: %s", channel.Language)
+	}
+	if err := s.notificationService.ValidateMessageTemplate(channel.MessageTemplate); err != nil {
+		return err
+	}
+
+	if channel.Timeout == 0 {
+		channel.Timeout = 10
+	}
+	if channel.Timeout < 1 || channel.Timeout > 300 {
+		return errors.New("发送超时时间必须在 1-300 秒之间")
+	}
+	if channel.MaxAttempts == 0 {
+		channel.MaxAttempts = 4
+	}
+	if channel.MaxAttempts < 1 || channel.MaxAttempts > 10 {
+		return errors.New("最多尝试次数必须在 1-10 之间")
+	}
+
+	return s.notificationService.ValidateChannel(channel)
+}
+
+// mask 掩码渠道配置中的敏感字段
+func (s *Service) mask(channel *entity.NotificationChannel) {
+	channel.Config = s.notificationService.MaskConfig(channel.Type, channel.Config)
+}
+
+// findSameChannel 查找类型和配置都相同的渠道
+func findSameChannel(channels []*entity.NotificationChannel, channel *entity.NotificationChannel) *entity.NotificationChannel {
+	config := canonicalConfig(channel.Config)
+	for _, existing := range channels {
+		if existing.Type == channel.Type && canonicalConfig(existing.Config) == config {
+			return existing
+		}
+	}
+	return nil
+}
+
+// canonicalConfig 将 JSON 配置格式化为统一形式，以便比较
+func canonicalConfig(config string) string {
+	var value interface{}
+	if err := json.Unmarshal([]byte(config), &value); err != nil {
+		return config
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// uniqueName 生成不与现有渠道重复的名称
+func uniqueName(channels []*entity.NotificationChannel, name string) string {
+	names := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		names[channel.Name] = true
+	}
+
+	candidate := name
+	for i := 2; names[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)", name, i)
+	}
+	return candidate
+}
+
+// uniqueIDs 去除重复的渠道ID，保持原有顺序
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var result []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,299 @@
+package digest
+
+import (
+	"crontab_go/internal/application/channel"
+	"crontab_go/internal/application/statistics"
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/domain/service"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// defaultTopN 失败最多、最慢任务默认列出的数量
+const defaultTopN = 5
+
+type Service struct {
+	digestRepo          repository.ReportDigestRepository
+	statisticsService   *statistics.Service
+	channelService      *channel.Service
+	notificationService *service.NotificationService
+}
+
+func NewService(digestRepo repository.ReportDigestRepository, statisticsService *statistics.Service, channelRepo repository.NotificationChannelRepository) *Service {
+	return &Service{
+		digestRepo:          digestRepo,
+		statisticsService:   statisticsService,
+		channelService:      channel.NewService(channelRepo),
+		notificationService: service.NewNotificationService(),
+	}
+}
+
+// CreateDigest 创建报表摘要
+func (s *Service) CreateDigest(digest *entity.ReportDigest) error {
+	if err := s.normalizeDigest(digest); err != nil {
+		return err
+	}
+	digest.LastSentAt = nil
+	return s.digestRepo.Create(digest)
+}
+
+// UpdateDigest 更新报表摘要，保留原有的创建时间和最后发送时间
+func (s *Service) UpdateDigest(digest *entity.ReportDigest) error {
+	existing, err := s.digestRepo.FindByID(digest.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.normalizeDigest(digest); err != nil {
+		return err
+	}
+
+	digest.CreatedAt = existing.CreatedAt
+	digest.LastSentAt = existing.LastSentAt
+	return s.digestRepo.Update(digest)
+}
+
+// DeleteDigest 删除报表摘要
+func (s *Service) DeleteDigest(id int) error {
+	return s.digestRepo.Delete(id)
+}
+
+// GetDigest 获取报表摘要
+func (s *Service) GetDigest(id int) (*entity.ReportDigest, error) {
+	return s.digestRepo.FindByID(id)
+}
+
+// ListDigests 获取所有报表摘要
+func (s *Service) ListDigests() ([]*entity.ReportDigest, error) {
+	return s.digestRepo.FindAll()
+}
+
+// PreviewDigest 生成截至当前的摘要内容，不发送
+func (s *Service) PreviewDigest(id int) (*entity.DigestReport, error) {
+	digest, err := s.digestRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.BuildReport(digest, time.Now())
+}
+
+// SendDigest 立即生成并发送截至当前的摘要
+func (s *Service) SendDigest(id int) (*entity.DigestReport, error) {
+	digest, err := s.digestRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.send(digest, time.Now())
+}
+
+// SendDueDigests 发送所有已到发送时间的摘要。按最后发送时间（未发送过时为创建时间）计算下一次发送时间，
+// 因此只需定期调用，服务停止期间错过的发送会在恢复后补发一次
+func (s *Service) SendDueDigests(now time.Time) error {
+	digests, err := s.digestRepo.FindEnabled()
+	if err != nil {
+		return err
+	}
+
+	for _, digest := range digests {
+		scheduledAt, due := dueTime(digest, now)
+		if !due {
+			continue
+		}
+		if _, err := s.send(digest, scheduledAt); err != nil {
+			log.Printf("Failed to send digest %s: %v", digest.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// BuildReport 生成截至 end 的摘要内容：本期与上期的执行概览、失败最多和最慢的任务以及本期每日趋势
+func (s *Service) BuildReport(digest *entity.ReportDigest, end time.Time) (*entity.DigestReport, error) {
+	period := digest.PeriodDuration()
+	currentStart := end.Add(-period)
+	previousStart := currentStart.Add(-period)
+
+	current := periodRequest(currentStart, end)
+	report, err := s.statisticsService.GetTaskExecutionReport(current)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.statisticsService.GetTaskStatistics(current)
+	if err != nil {
+		return nil, err
+	}
+	previousReport, err := s.statisticsService.GetTaskExecutionReport(periodRequest(previousStart, currentStart))
+	if err != nil {
+		return nil, err
+	}
+
+	digestReport := &entity.DigestReport{
+		Name:       digest.Name,
+		Period:     digest.Period,
+		TotalTasks: report.TotalTasks,
+		Current: entity.DigestPeriodSummary{
+			StartTime:       currentStart,
+			EndTime:         end,
+			ActiveTasks:     report.ActiveTasks,
+			TotalExecutions: report.TotalExecutions,
+			SuccessRate:     report.SuccessRate,
+		},
+		Previous: entity.DigestPeriodSummary{
+			StartTime:         previousStart,
+			EndTime:           currentStart,
+			ActiveTasks:       previousReport.ActiveTasks,
+			TotalExecutions:   previousReport.TotalExecutions,
+			FailureExecutions: failureCount(previousReport),
+			SuccessRate:       previousReport.SuccessRate,
+		},
+		Trends: report.RecentTrends,
+	}
+	digestReport.Current.FailureExecutions = failureCount(report)
+
+	if previous := digestReport.Previous.TotalExecutions; previous > 0 {
+		change := float64(digestReport.Current.TotalExecutions-previous) / float64(previous) * 100
+		digestReport.ExecutionsChange = &change
+		if digestReport.Current.TotalExecutions > 0 {
+			diff := digestReport.Current.SuccessRate - digestReport.Previous.SuccessRate
+			digestReport.SuccessRateChange = &diff
+		}
+	}
+
+	topN := digest.TopN
+	if topN <= 0 {
+		topN = defaultTopN
+	}
+	digestReport.TopFailures = topTasks(stats, topN,
+		func(stat *entity.TaskStatistics) bool { return stat.FailureExecutions > 0 },
+		func(a, b *entity.TaskStatistics) bool {
+			if a.FailureExecutions != b.FailureExecutions {
+				return a.FailureExecutions > b.FailureExecutions
+			}
+			return a.SuccessRate < b.SuccessRate
+		})
+	digestReport.SlowestTasks = topTasks(stats, topN,
+		func(stat *entity.TaskStatistics) bool { return stat.TotalExecutions > 0 },
+		func(a, b *entity.TaskStatistics) bool { return a.AverageExecutionTime > b.AverageExecutionTime })
+
+	return digestReport, nil
+}
+
+// send 生成截至 end 的摘要并通过配置的通知渠道发送，发送后记录发送时间
+func (s *Service) send(digest *entity.ReportDigest, end time.Time) (*entity.DigestReport, error) {
+	report, err := s.BuildReport(digest, end)
+	if err != nil {
+		return nil, err
+	}
+
+	channels, err := s.channelService.FindChannels(digest.NotificationChannelIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(channels) == 0 {
+		return nil, errors.New("未配置通知渠道")
+	}
+
+	sendErr := s.notificationService.SendDigestToChannels(channels, report)
+
+	// 部分渠道失败时同样记录发送时间，避免每次检查都重复发送到已成功的渠道
+	now := time.Now()
+	digest.LastSentAt = &now
+	if err := s.digestRepo.Update(digest); err != nil {
+		log.Printf("Failed to update last sent time for digest %s: %v", digest.Name, err)
+	}
+
+	return report, sendErr
+}
+
+// normalizeDigest 校验摘要配置并填充默认值
+func (s *Service) normalizeDigest(digest *entity.ReportDigest) error {
+	if digest.Name == "" {
+		return errors.New("摘要名称不能为空")
+	}
+
+	if digest.Period == "" {
+		digest.Period = entity.DigestPeriodDaily
+	}
+	if digest.Period != entity.DigestPeriodDaily && digest.Period != entity.DigestPeriodWeekly {
+		return fmt.Errorf("不支持的统计周期: %s", digest.Period)
+	}
+
+	if digest.Schedule == "" {
+		digest.Schedule = digest.DefaultSchedule()
+	}
+	if _, err := cron.ParseStandard(digest.Schedule); err != nil {
+		return fmt.Errorf("无效的Cron表达式: %v", err)
+	}
+
+	if digest.TopN <= 0 {
+		digest.TopN = defaultTopN
+	}
+
+	channelIDs, err := s.channelService.ResolveChannelIDs("报表摘要 "+digest.Name, digest.NotificationTypes, digest.NotificationConfig, digest.NotificationChannelIDs)
+	if err != nil {
+		return err
+	}
+	if channelIDs == "" {
+		return errors.New("至少需要配置一个通知渠道")
+	}
+	digest.NotificationChannelIDs, digest.NotificationTypes, digest.NotificationConfig = channelIDs, "", ""
+	return nil
+}
+
+// dueTime 计算摘要在 now 之前最近一次应发送的时间
+func dueTime(digest *entity.ReportDigest, now time.Time) (time.Time, bool) {
+	schedule, err := cron.ParseStandard(digest.Schedule)
+	if err != nil {
+		log.Printf("Invalid schedule for digest %s: %v", digest.Name, err)
+		return time.Time{}, false
+	}
+
+	last := digest.CreatedAt
+	if digest.LastSentAt != nil {
+		last = *digest.LastSentAt
+	}
+
+	var scheduledAt time.Time
+	for next := schedule.Next(last); !next.After(now); next = schedule.Next(next) {
+		scheduledAt = next
+	}
+	return scheduledAt, !scheduledAt.IsZero()
+}
+
+// periodRequest 创建 [start, end) 区间的统计请求
+func periodRequest(start, end time.Time) *entity.StatisticsRequest {
+	req := entity.NewStatisticsRequest()
+	endDate := end.Add(-time.Nanosecond)
+	req.StartDate = &start
+	req.EndDate = &endDate
+	return req
+}
+
+// failureCount 报表中的失败执行次数，由总执行次数和成功率换算，与报表统计口径一致
+func failureCount(report *entity.TaskExecutionReport) int64 {
+	return int64(math.Round(float64(report.TotalExecutions) * (100 - report.SuccessRate) / 100))
+}
+
+// topTasks 按条件筛选并排序，返回前 n 个任务统计
+func topTasks(stats []entity.TaskStatistics, n int, keep func(*entity.TaskStatistics) bool, less func(a, b *entity.TaskStatistics) bool) []entity.TaskStatistics {
+	result := []entity.TaskStatistics{}
+	for i := range stats {
+		if keep(&stats[i]) {
+			result = append(result, stats[i])
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return less(&result[i], &result[j])
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
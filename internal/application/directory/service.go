@@ -0,0 +1,147 @@
+package directory
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// ErrUnavailable 无法连接用户目录
+var ErrUnavailable = errors.New("LDAP 服务暂时不可用，请稍后重试")
+
+// Directory 用户目录，如 LDAP / Active Directory
+type Directory interface {
+	// Authenticate 校验用户名和密码，用户不存在或密码错误时返回 nil
+	Authenticate(username, password string) (*entity.DirectoryEntry, error)
+	// Lookup 查找用户，用户不存在时返回 nil
+	Lookup(username string) (*entity.DirectoryEntry, error)
+}
+
+// Mapping 目录用户到本地用户的映射
+type Mapping struct {
+	RoleMapping map[string]string // 用户组到角色，用户属于多个组时取最高的角色
+	DefaultRole string
+	AutoCreate  bool
+	LinkByEmail bool // 目录中的邮箱视为已验证，用于关联同邮箱的本地账号
+}
+
+// Service LDAP 认证和同步服务：作为 auth.Service 的账号密码认证方式，并定期将目录中用户的启用状态和角色同步到本地
+type Service struct {
+	directory   Directory
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	mapping     Mapping
+}
+
+func NewService(directory Directory, userRepo repository.UserRepository, sessionRepo repository.SessionRepository, mapping Mapping) *Service {
+	return &Service{
+		directory:   directory,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		mapping:     mapping,
+	}
+}
+
+// Name 认证来源
+func (s *Service) Name() string {
+	return entity.AuthProviderLDAP
+}
+
+// Authenticate 使用目录校验用户名和密码，返回认证通过的身份。用户名或密码错误时返回 nil，目录中已禁用的账号返回错误
+func (s *Service) Authenticate(username, password string) (*entity.ExternalIdentity, error) {
+	entry, err := s.directory.Authenticate(username, password)
+	if err != nil {
+		log.Printf("LDAP authentication of %s failed: %v", username, err)
+		return nil, ErrUnavailable
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	if entry.Disabled {
+		return nil, errors.New("账号已在目录中禁用")
+	}
+
+	return &entity.ExternalIdentity{
+		Provider: entity.AuthProviderLDAP,
+		Subject:  entry.Username,
+		Username: entry.Username,
+		Email:    entry.Email,
+		// 目录中的邮箱可能由用户自行修改，默认不视为已验证，配置 link_by_email 后才用于关联同邮箱的本地账号
+		EmailVerified: s.mapping.LinkByEmail && entry.Email != "",
+		LinkLocal:     s.mapping.LinkByEmail,
+		Role:          s.mapRole(entry),
+		SyncRole:      len(s.mapping.RoleMapping) > 0,
+		AutoCreate:    s.mapping.AutoCreate,
+	}, nil
+}
+
+// Sync 同步目录用户的启用状态和角色。目录中已删除或已禁用的用户在本地禁用并注销会话；
+// 因此被禁用的用户在目录中恢复后重新启用，管理员手动禁用的用户不会被启用。
+// 连接目录失败时中止同步，避免误禁用所有用户；单个用户更新失败时记录日志后继续
+func (s *Service) Sync() error {
+	users, err := s.userRepo.FindByAuthProvider(entity.AuthProviderLDAP)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		// 管理员已切换为 LDAP、尚未首次登录绑定的账号
+		if user.ExternalID == "" {
+			continue
+		}
+		entry, err := s.directory.Lookup(user.ExternalID)
+		if err != nil {
+			return err
+		}
+		if err := s.syncUser(user, entry); err != nil {
+			log.Printf("Failed to sync LDAP user %s: %v", user.Username, err)
+		}
+	}
+	return nil
+}
+
+// syncUser 按目录中的用户更新本地用户，entry 为 nil 表示用户已从目录删除
+func (s *Service) syncUser(user *entity.User, entry *entity.DirectoryEntry) error {
+	if entry == nil || entry.Disabled {
+		if !user.IsActive {
+			return nil
+		}
+		log.Printf("Disabling LDAP user %s: removed or disabled in directory", user.Username)
+		user.IsActive = false
+		user.DirectoryDisabled = true
+		if err := s.userRepo.Update(user); err != nil {
+			return err
+		}
+		return s.sessionRepo.RevokeByUser(user.ID, 0, time.Now())
+	}
+
+	changed := false
+	if !user.IsActive && user.DirectoryDisabled {
+		log.Printf("Enabling LDAP user %s: enabled in directory again", user.Username)
+		user.IsActive = true
+		user.DirectoryDisabled = false
+		changed = true
+	}
+	if len(s.mapping.RoleMapping) > 0 {
+		if role := s.mapRole(entry); user.Role != role {
+			log.Printf("Updating role of LDAP user %s from %s to %s", user.Username, user.Role, role)
+			user.Role = role
+			changed = true
+		}
+	}
+	if entry.Email != "" && user.Email != entry.Email {
+		user.Email = entry.Email
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.userRepo.Update(user)
+}
+
+func (s *Service) mapRole(entry *entity.DirectoryEntry) string {
+	return entity.MapGroupRole(entry.Groups, s.mapping.RoleMapping, s.mapping.DefaultRole)
+}
@@ -0,0 +1,132 @@
+package escalation
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrPolicyInUse 升级策略仍被任务引用
+var ErrPolicyInUse = errors.New("升级策略正在被任务使用，无法删除")
+
+// maxStepDelay 升级步骤延迟上限（分钟）
+const maxStepDelay = 7 * 24 * 60
+
+type Service struct {
+	policyRepo  repository.EscalationPolicyRepository
+	channelRepo repository.NotificationChannelRepository
+}
+
+func NewService(policyRepo repository.EscalationPolicyRepository, channelRepo repository.NotificationChannelRepository) *Service {
+	return &Service{
+		policyRepo:  policyRepo,
+		channelRepo: channelRepo,
+	}
+}
+
+// CreatePolicy 创建升级策略
+func (s *Service) CreatePolicy(policy *entity.EscalationPolicy) error {
+	if err := s.normalizePolicy(policy); err != nil {
+		return err
+	}
+	return s.policyRepo.Create(policy)
+}
+
+// UpdatePolicy 更新升级策略，保留原有的创建时间和所属项目
+func (s *Service) UpdatePolicy(policy *entity.EscalationPolicy) error {
+	existing, err := s.policyRepo.FindByID(policy.ID)
+	if err != nil {
+		return err
+	}
+	policy.ProjectID = existing.ProjectID
+	if err := s.normalizePolicy(policy); err != nil {
+		return err
+	}
+	policy.CreatedAt = existing.CreatedAt
+	return s.policyRepo.Update(policy)
+}
+
+// DeletePolicy 删除升级策略，仍被任务引用时返回 ErrPolicyInUse
+func (s *Service) DeletePolicy(id int) error {
+	count, err := s.policyRepo.CountReferences(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrPolicyInUse
+	}
+	return s.policyRepo.Delete(id)
+}
+
+// GetPolicy 获取升级策略
+func (s *Service) GetPolicy(id int) (*entity.EscalationPolicy, error) {
+	return s.policyRepo.FindByID(id)
+}
+
+// ListPolicies 获取所有升级策略
+func (s *Service) ListPolicies() ([]*entity.EscalationPolicy, error) {
+	return s.policyRepo.FindAll()
+}
+
+// normalizePolicy 校验升级策略：至少一个步骤，延迟不递减，引用的渠道必须存在，且为全局渠道或策略所属项目的渠道
+func (s *Service) normalizePolicy(policy *entity.EscalationPolicy) error {
+	if policy.Name == "" {
+		return errors.New("升级策略名称不能为空")
+	}
+
+	steps, err := policy.ParseSteps()
+	if err != nil {
+		return fmt.Errorf("升级步骤格式错误: %v", err)
+	}
+	if len(steps) == 0 {
+		return errors.New("至少需要配置一个升级步骤")
+	}
+
+	for i, step := range steps {
+		if step.Delay < 0 || step.Delay > maxStepDelay {
+			return fmt.Errorf("第 %d 步的延迟需在 0-%d 分钟之间", i+1, maxStepDelay)
+		}
+		if i > 0 && step.Delay < steps[i-1].Delay {
+			return fmt.Errorf("第 %d 步的延迟不能小于上一步", i+1)
+		}
+		if len(step.ChannelIDs) == 0 {
+			return fmt.Errorf("第 %d 步至少需要一个通知渠道", i+1)
+		}
+
+		steps[i].ChannelIDs = uniqueIDs(step.ChannelIDs)
+		channels, err := s.channelRepo.FindByIDs(steps[i].ChannelIDs)
+		if err != nil {
+			return err
+		}
+		if len(channels) != len(steps[i].ChannelIDs) {
+			return fmt.Errorf("第 %d 步引用了不存在的通知渠道", i+1)
+		}
+		for _, ch := range channels {
+			if ch.ProjectID != nil && (policy.ProjectID == nil || *ch.ProjectID != *policy.ProjectID) {
+				return fmt.Errorf("第 %d 步的通知渠道 %s 属于其他项目，不能在该升级策略中使用", i+1, ch.Name)
+			}
+		}
+	}
+
+	data, err := json.Marshal(steps)
+	if err != nil {
+		return err
+	}
+	policy.Steps = string(data)
+	return nil
+}
+
+// uniqueIDs 去除重复的ID
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	var result []int
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,159 @@
+package export
+
+import (
+	"crontab_go/internal/application/statistics"
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"io"
+)
+
+// exportBatchSize 导出日志时每批读取的条数
+const exportBatchSize = 500
+
+var statisticsColumns = []string{
+	"task_id", "task_name", "total_executions", "success_executions", "failure_executions",
+	"success_rate", "average_execution_time", "last_execution_time", "last_execution_status",
+}
+
+type Service struct {
+	taskLogRepo       repository.TaskLogRepository
+	statisticsService *statistics.Service
+}
+
+func NewService(taskLogRepo repository.TaskLogRepository, statisticsService *statistics.Service) *Service {
+	return &Service{
+		taskLogRepo:       taskLogRepo,
+		statisticsService: statisticsService,
+	}
+}
+
+// ExportLogs 导出符合条件的任务日志。按游标分批读取，内存占用与日志总量无关。
+// 第一批查询成功后才开始写出，查询失败时调用方仍可以返回错误响应
+func (s *Service) ExportLogs(w io.Writer, format string, req *entity.TaskLogSearchRequest) error {
+	batch := *req
+	batch.Page = 1
+	batch.PageSize = exportBatchSize
+	batch.Cursor = nil
+
+	logs, next, err := s.taskLogRepo.SearchLogs(&batch)
+	if err != nil {
+		return err
+	}
+
+	writer, err := NewTableWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.BeginTable("", []string{
+		"id", "task_id", "task_name", "trigger_source", "start_time", "end_time",
+		"duration", "success", "output", "error",
+	}); err != nil {
+		return err
+	}
+
+	for {
+		for _, log := range logs {
+			if err := writer.WriteRow([]interface{}{
+				log.ID, log.TaskID, log.TaskName, log.TriggerSource, log.StartTime, log.EndTime,
+				log.EndTime.Sub(log.StartTime).Seconds(), log.Success, log.Output, log.Error,
+			}); err != nil {
+				return err
+			}
+		}
+
+		if next == nil {
+			break
+		}
+		batch.Cursor = next
+		if logs, next, err = s.taskLogRepo.SearchLogs(&batch); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// ExportTaskStatistics 导出任务统计信息
+func (s *Service) ExportTaskStatistics(w io.Writer, format string, req *entity.StatisticsRequest) error {
+	stats, err := s.statisticsService.GetTaskStatistics(req)
+	if err != nil {
+		return err
+	}
+
+	writer, err := NewTableWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	if err := writeStatisticsTable(writer, "", stats); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// ExportReport 导出任务执行报表，包含概览、热门任务和每日趋势三张表
+func (s *Service) ExportReport(w io.Writer, format string, req *entity.StatisticsRequest) error {
+	report, err := s.statisticsService.GetTaskExecutionReport(req)
+	if err != nil {
+		return err
+	}
+
+	writer, err := NewTableWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	if err := writer.BeginTable("summary", []string{
+		"report_date", "total_tasks", "active_tasks", "total_executions", "success_rate",
+	}); err != nil {
+		return err
+	}
+	if err := writer.WriteRow([]interface{}{
+		report.ReportDate, report.TotalTasks, report.ActiveTasks, report.TotalExecutions, report.SuccessRate,
+	}); err != nil {
+		return err
+	}
+
+	if err := writeStatisticsTable(writer, "top_tasks", report.TopTasks); err != nil {
+		return err
+	}
+
+	if err := writer.BeginTable("trends", []string{
+		"date", "total_executions", "success_count", "failure_count", "success_rate",
+	}); err != nil {
+		return err
+	}
+	for _, trend := range report.RecentTrends {
+		if err := writer.WriteRow([]interface{}{
+			trend.Date, trend.TotalExecutions, trend.SuccessCount, trend.FailureCount, trend.SuccessRate,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// writeStatisticsTable 写入任务统计表
+func writeStatisticsTable(writer TableWriter, name string, stats []entity.TaskStatistics) error {
+	if err := writer.BeginTable(name, statisticsColumns); err != nil {
+		return err
+	}
+
+	for _, stat := range stats {
+		var lastStatus interface{}
+		if stat.LastExecutionTime != nil {
+			lastStatus = stat.LastExecutionStatus
+		}
+		if err := writer.WriteRow([]interface{}{
+			stat.TaskID, stat.TaskName, stat.TotalExecutions, stat.SuccessExecutions, stat.FailureExecutions,
+			stat.SuccessRate, stat.AverageExecutionTime, stat.LastExecutionTime, lastStatus,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
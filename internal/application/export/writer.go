@@ -0,0 +1,224 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// 导出格式
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+// timeLayout 导出文件中的时间格式
+const timeLayout = "2006-01-02 15:04:05"
+
+// TableWriter 表格写入器，按表逐行写出数据，不在内存中保留已写出的行
+type TableWriter interface {
+	// BeginTable 开始一张新表，name 为空表示只有一张表
+	BeginTable(name string, columns []string) error
+	// WriteRow 写入一行，values 与 columns 一一对应
+	WriteRow(values []interface{}) error
+	// Close 结束写入并刷新缓冲
+	Close() error
+}
+
+// NewTableWriter 根据格式创建表格写入器
+func NewTableWriter(format string, w io.Writer) (TableWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w), nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
+
+// ContentType 获取导出格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// ValidFormat 检查导出格式是否支持
+func ValidFormat(format string) bool {
+	return format == FormatCSV || format == FormatJSONL || format == FormatXLSX
+}
+
+// formatValue 将值格式化为文本
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(timeLayout)
+	case *time.Time:
+		if v == nil || v.IsZero() {
+			return ""
+		}
+		return v.Format(timeLayout)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvWriter CSV 写入器，多张表之间空一行并以表名作为标题行。
+// 输出先写入缓冲区，查询在写出第一批数据前失败时调用方仍可以返回错误响应
+type csvWriter struct {
+	buf    *bufio.Writer
+	w      *csv.Writer
+	tables int
+	rows   int
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	buf := bufio.NewWriter(w)
+	return &csvWriter{buf: buf, w: csv.NewWriter(buf)}
+}
+
+func (cw *csvWriter) BeginTable(name string, columns []string) error {
+	if cw.tables == 0 {
+		// 写入 UTF-8 BOM，便于 Excel 正确识别中文
+		if _, err := cw.buf.WriteString("\xEF\xBB\xBF"); err != nil {
+			return err
+		}
+	} else if err := cw.w.Write(nil); err != nil {
+		return err
+	}
+	cw.tables++
+	if name != "" {
+		if err := cw.w.Write([]string{name}); err != nil {
+			return err
+		}
+	}
+	return cw.w.Write(columns)
+}
+
+func (cw *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatValue(value)
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+
+	// 定期刷新，避免大量数据堆积在缓冲区
+	cw.rows++
+	if cw.rows%500 == 0 {
+		return cw.flush()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	return cw.flush()
+}
+
+func (cw *csvWriter) flush() error {
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return err
+	}
+	return cw.buf.Flush()
+}
+
+// jsonlWriter JSON Lines 写入器，每行一个对象；有表名时额外写入 table 字段
+type jsonlWriter struct {
+	w       *bufio.Writer
+	table   string
+	columns []string
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{w: bufio.NewWriter(w)}
+}
+
+func (jw *jsonlWriter) BeginTable(name string, columns []string) error {
+	jw.table = name
+	jw.columns = columns
+	return nil
+}
+
+func (jw *jsonlWriter) WriteRow(values []interface{}) error {
+	// 手动拼接对象以保持列顺序
+	jw.w.WriteByte('{')
+	if jw.table != "" {
+		jw.w.WriteString(`"table":`)
+		if err := jw.writeJSON(jw.table); err != nil {
+			return err
+		}
+	}
+	for i, value := range values {
+		if i >= len(jw.columns) {
+			break
+		}
+		if i > 0 || jw.table != "" {
+			jw.w.WriteByte(',')
+		}
+		if err := jw.writeJSON(jw.columns[i]); err != nil {
+			return err
+		}
+		jw.w.WriteByte(':')
+
+		switch v := value.(type) {
+		case time.Time:
+			value = formatValue(v)
+		case *time.Time:
+			if v == nil {
+				value = nil
+			} else {
+				value = formatValue(v)
+			}
+		}
+		if err := jw.writeJSON(value); err != nil {
+			return err
+		}
+	}
+	jw.w.WriteByte('}')
+	return jw.w.WriteByte('\n')
+}
+
+// writeJSON 写入单个 JSON 值，不转义 HTML 字符以保持输出原样
+func (jw *jsonlWriter) writeJSON(value interface{}) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return err
+	}
+	_, err := jw.w.Write(bytes.TrimRight(buf.Bytes(), "\n"))
+	return err
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.w.Flush()
+}
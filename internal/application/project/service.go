@@ -0,0 +1,190 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/domain/service"
+)
+
+var (
+	// ErrTaskQuotaExceeded 项目中的任务数已达上限
+	ErrTaskQuotaExceeded = errors.New("项目中的任务数已达上限")
+	// ErrProjectNotEmpty 项目中还有任务，不能删除
+	ErrProjectNotEmpty = errors.New("项目中还有任务，请先删除或移出任务")
+)
+
+// Service 项目服务：管理项目、项目成员和项目配额
+type Service struct {
+	projectRepo repository.ProjectRepository
+	taskRepo    repository.TaskRepository
+	userRepo    repository.UserRepository
+}
+
+func NewService(projectRepo repository.ProjectRepository, taskRepo repository.TaskRepository, userRepo repository.UserRepository) *Service {
+	return &Service{
+		projectRepo: projectRepo,
+		taskRepo:    taskRepo,
+		userRepo:    userRepo,
+	}
+}
+
+// ListProjects 获取用户可见的项目：管理员可以看到所有项目，其他用户只能看到自己加入的项目
+func (s *Service) ListProjects(user *entity.User) ([]*entity.Project, error) {
+	var (
+		projects []*entity.Project
+		roles    map[uint]string
+		err      error
+	)
+	if user.IsAdmin() {
+		projects, err = s.projectRepo.FindAll()
+	} else {
+		var members []*entity.ProjectMember
+		if members, err = s.projectRepo.FindMembershipsByUser(user.ID); err != nil {
+			return nil, err
+		}
+		roles = make(map[uint]string, len(members))
+		ids := make([]uint, 0, len(members))
+		for _, member := range members {
+			roles[member.ProjectID] = member.Role
+			ids = append(ids, member.ProjectID)
+		}
+		projects, err = s.projectRepo.FindByIDs(ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, project := range projects {
+		project.Role = entity.RoleAdmin
+		if roles != nil {
+			project.Role = roles[project.ID]
+		}
+		if err := s.fillUsage(project); err != nil {
+			return nil, err
+		}
+	}
+	return projects, nil
+}
+
+// GetProject 获取项目及其任务数和正在执行的任务数
+func (s *Service) GetProject(id uint) (*entity.Project, error) {
+	project, err := s.projectRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillUsage(project); err != nil {
+		return nil, err
+	}
+	return project, nil
+}
+
+// fillUsage 填充项目的任务数和正在执行的任务数
+func (s *Service) fillUsage(project *entity.Project) error {
+	count, err := s.taskRepo.CountByProject(project.ID)
+	if err != nil {
+		return err
+	}
+	project.TaskCount = count
+	project.RunningTasks = service.RunningProjectTasks(project.ID)
+	return nil
+}
+
+// CreateProject 创建项目
+func (s *Service) CreateProject(project *entity.Project) error {
+	if err := validateProject(project); err != nil {
+		return err
+	}
+	project.ID = 0
+	return s.projectRepo.Create(project)
+}
+
+// UpdateProject 更新项目名称、描述和配额
+func (s *Service) UpdateProject(project *entity.Project) error {
+	if err := validateProject(project); err != nil {
+		return err
+	}
+	existing, err := s.projectRepo.FindByID(project.ID)
+	if err != nil {
+		return err
+	}
+	project.CreatedAt = existing.CreatedAt
+	return s.projectRepo.Update(project)
+}
+
+func validateProject(project *entity.Project) error {
+	if project.Name == "" {
+		return errors.New("项目名称不能为空")
+	}
+	if project.MaxTasks < 0 || project.MaxConcurrentRuns < 0 {
+		return errors.New("项目配额不能小于 0，0 表示不限制")
+	}
+	return nil
+}
+
+// DeleteProject 删除项目及其成员。项目中还有任务时不能删除
+func (s *Service) DeleteProject(id uint) error {
+	if _, err := s.projectRepo.FindByID(id); err != nil {
+		return err
+	}
+	count, err := s.taskRepo.CountByProject(id)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrProjectNotEmpty
+	}
+	return s.projectRepo.Delete(id)
+}
+
+// ListMembers 获取项目成员
+func (s *Service) ListMembers(projectID uint) ([]*entity.ProjectMember, error) {
+	return s.projectRepo.FindMembers(projectID)
+}
+
+// SaveMember 添加项目成员或修改成员的项目角色
+func (s *Service) SaveMember(projectID, userID uint, role string) (*entity.ProjectMember, error) {
+	if !entity.ValidRole(role) {
+		return nil, errors.New("无效的项目角色，可选值: admin, operator, viewer")
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, errors.New("用户不存在")
+	}
+
+	member := &entity.ProjectMember{ProjectID: projectID, UserID: userID, Role: role}
+	if err := s.projectRepo.SaveMember(member); err != nil {
+		return nil, err
+	}
+	member.User = user
+	return member, nil
+}
+
+// RemoveMember 将用户移出项目
+func (s *Service) RemoveMember(projectID, userID uint) error {
+	return s.projectRepo.RemoveMember(projectID, userID)
+}
+
+// CheckTaskQuota 检查是否可以在项目中再创建一个任务，projectID 为空时不限制
+func (s *Service) CheckTaskQuota(projectID *uint) error {
+	if projectID == nil {
+		return nil
+	}
+	project, err := s.projectRepo.FindByID(*projectID)
+	if err != nil {
+		return err
+	}
+	if project.MaxTasks == 0 {
+		return nil
+	}
+	count, err := s.taskRepo.CountByProject(project.ID)
+	if err != nil {
+		return err
+	}
+	if count >= int64(project.MaxTasks) {
+		return fmt.Errorf("%w (%d)", ErrTaskQuotaExceeded, project.MaxTasks)
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"crontab_go/internal/application/auth"
+	"crontab_go/internal/domain/entity"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// loginTimeout 从跳转到身份提供方到回调的最长时间
+	loginTimeout = 10 * time.Minute
+	// codeTimeout 回调后前端用一次性登录码换取令牌的最长时间
+	codeTimeout = time.Minute
+	// maxPendingLogins 等待回调的登录请求上限。发起登录不需要认证，超过上限时丢弃最早的请求，避免内存无限增长
+	maxPendingLogins = 10000
+)
+
+var (
+	// ErrInvalidState 回调的 state 无效或登录已超时
+	ErrInvalidState = errors.New("登录请求无效或已超时，请重新登录")
+	// ErrInvalidCode 一次性登录码无效、已使用或已过期
+	ErrInvalidCode = errors.New("登录码无效或已过期，请重新登录")
+)
+
+// IdentityProvider OpenID Connect 身份提供方
+type IdentityProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+	// Exchange 用授权码换取访问令牌和 ID Token
+	Exchange(ctx context.Context, code, codeVerifier string) (accessToken, idToken string, err error)
+	VerifyIDToken(ctx context.Context, rawToken, nonce string) (jwt.MapClaims, error)
+	UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error)
+}
+
+// ClaimMapping 身份提供方声明到用户的映射
+type ClaimMapping struct {
+	UsernameClaim string
+	GroupsClaim   string
+	RoleMapping   map[string]string // 用户组到角色，用户属于多个组时取最高的角色
+	DefaultRole   string
+	AutoCreate    bool
+	LinkByEmail   bool // 信任 email_verified，关联管理员已切换为单点登录的同邮箱账号
+}
+
+// pendingLogin 等待身份提供方回调的登录请求
+type pendingLogin struct {
+	nonce        string
+	codeVerifier string
+	redirect     string
+	expiresAt    time.Time
+}
+
+// completedLogin 已完成认证、等待前端换取令牌的登录
+type completedLogin struct {
+	response  *entity.LoginResponse
+	expiresAt time.Time
+}
+
+// Service OIDC 单点登录服务：授权码流程（PKCE）、即时创建用户和用户组到角色的映射。
+// 登录状态保存在内存中，多实例部署时需要将回调路由到发起登录的实例
+type Service struct {
+	provider    IdentityProvider
+	authService *auth.Service
+	mapping     ClaimMapping
+
+	mu        sync.Mutex
+	pending   map[string]*pendingLogin
+	completed map[string]*completedLogin
+}
+
+func NewService(provider IdentityProvider, authService *auth.Service, mapping ClaimMapping) *Service {
+	return &Service{
+		provider:    provider,
+		authService: authService,
+		mapping:     mapping,
+		pending:     make(map[string]*pendingLogin),
+		completed:   make(map[string]*completedLogin),
+	}
+}
+
+// Begin 开始登录，返回身份提供方的授权地址。redirect 为登录完成后前端跳转的页面
+func (s *Service) Begin(ctx context.Context, redirect string) (string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	authURL, err := s.provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	s.cleanup(now)
+	if len(s.pending) >= maxPendingLogins {
+		s.evictOldestPending()
+	}
+	s.pending[state] = &pendingLogin{
+		nonce:        nonce,
+		codeVerifier: verifier,
+		redirect:     redirect,
+		expiresAt:    now.Add(loginTimeout),
+	}
+	s.mu.Unlock()
+	return authURL, nil
+}
+
+// Callback 处理身份提供方的回调：用授权码换取并校验 ID Token，登录或创建用户。
+// 返回一次性登录码和登录前的页面，前端用登录码换取令牌，避免令牌出现在地址栏中
+func (s *Service) Callback(ctx context.Context, code, state, ip, userAgent string) (string, string, error) {
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return "", "", ErrInvalidState
+	}
+
+	accessToken, idToken, err := s.provider.Exchange(ctx, code, login.codeVerifier)
+	if err != nil {
+		return "", login.redirect, err
+	}
+	claims, err := s.provider.VerifyIDToken(ctx, idToken, login.nonce)
+	if err != nil {
+		return "", login.redirect, err
+	}
+	// ID Token 中没有用户名或用户组时从 userinfo 端点补充
+	if claims[s.mapping.UsernameClaim] == nil || (s.mapping.GroupsClaim != "" && claims[s.mapping.GroupsClaim] == nil) {
+		info, err := s.provider.UserInfo(ctx, accessToken)
+		if err != nil {
+			return "", login.redirect, err
+		}
+		if sub, _ := info["sub"].(string); info != nil && sub != "" && sub != claims["sub"] {
+			return "", login.redirect, errors.New("用户信息与 ID Token 不一致")
+		}
+		for key, value := range info {
+			if _, exists := claims[key]; !exists {
+				claims[key] = value
+			}
+		}
+	}
+
+	identity, err := s.identity(claims)
+	if err != nil {
+		return "", login.redirect, err
+	}
+	response, err := s.authService.ExternalLogin(identity, ip, userAgent)
+	if err != nil {
+		return "", login.redirect, err
+	}
+
+	loginCode, err := randomString()
+	if err != nil {
+		return "", login.redirect, err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	s.cleanup(now)
+	s.completed[loginCode] = &completedLogin{response: response, expiresAt: now.Add(codeTimeout)}
+	s.mu.Unlock()
+	return loginCode, login.redirect, nil
+}
+
+// Redeem 用一次性登录码换取令牌
+func (s *Service) Redeem(code string) (*entity.LoginResponse, error) {
+	s.mu.Lock()
+	login, ok := s.completed[code]
+	delete(s.completed, code)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrInvalidCode
+	}
+	return login.response, nil
+}
+
+// identity 将 ID Token 声明转换为外部身份
+func (s *Service) identity(claims jwt.MapClaims) (*entity.ExternalIdentity, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, errors.New("ID Token 中没有 sub")
+	}
+	email, _ := claims["email"].(string)
+	username, _ := claims[s.mapping.UsernameClaim].(string)
+	if username == "" {
+		username = email
+	}
+
+	return &entity.ExternalIdentity{
+		Provider: entity.AuthProviderOIDC,
+		Subject:  subject,
+		Username: username,
+		Email:    email,
+		// 身份提供方的 email_verified 只在配置 link_by_email 后用于关联账号，且不直接关联本地账号
+		EmailVerified: s.mapping.LinkByEmail && claimBool(claims["email_verified"]),
+		Role:          entity.MapGroupRole(claimStrings(claims[s.mapping.GroupsClaim]), s.mapping.RoleMapping, s.mapping.DefaultRole),
+		SyncRole:      len(s.mapping.RoleMapping) > 0,
+		AutoCreate:    s.mapping.AutoCreate,
+	}, nil
+}
+
+// cleanup 删除过期的登录状态，调用方需要持有锁
+func (s *Service) cleanup(now time.Time) {
+	for state, login := range s.pending {
+		if now.After(login.expiresAt) {
+			delete(s.pending, state)
+		}
+	}
+	for code, login := range s.completed {
+		if now.After(login.expiresAt) {
+			delete(s.completed, code)
+		}
+	}
+}
+
+// evictOldestPending 删除最早发起的登录请求，调用方需要持有锁
+func (s *Service) evictOldestPending() {
+	var oldestState string
+	var oldest *pendingLogin
+	for state, login := range s.pending {
+		if oldest == nil || login.expiresAt.Before(oldest.expiresAt) {
+			oldestState, oldest = state, login
+		}
+	}
+	delete(s.pending, oldestState)
+}
+
+// claimStrings 用户组声明可能是字符串数组、单个字符串或逗号分隔的字符串
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	case string:
+		var values []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func claimBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
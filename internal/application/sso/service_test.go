@@ -0,0 +1,341 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"crontab_go/internal/application/audit"
+	"crontab_go/internal/application/auth"
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/infrastructure/oidc"
+	"crontab_go/internal/infrastructure/oidc/oidctest"
+	"crontab_go/internal/infrastructure/persistence"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testMapping 测试使用的声明映射：ops-admins 组映射为管理员，ops 组映射为操作员
+func testMapping() ClaimMapping {
+	return ClaimMapping{
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		RoleMapping:   map[string]string{"ops-admins": entity.RoleAdmin, "ops": entity.RoleOperator},
+		DefaultRole:   entity.RoleViewer,
+		AutoCreate:    true,
+	}
+}
+
+// newTestService 创建连接到测试身份提供方的单点登录服务，用户保存在临时 SQLite 数据库中
+func newTestService(t *testing.T, idp *oidctest.Server, mapping ClaimMapping) (*Service, repository.UserRepository) {
+	t.Helper()
+
+	db, err := persistence.NewSQLiteDB(filepath.Join(t.TempDir(), "crontab.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	userRepo := persistence.NewUserRepository(db.Client)
+	authService := auth.NewService(
+		userRepo,
+		persistence.NewInviteCodeRepository(db.Client),
+		persistence.NewSessionRepository(db.Client),
+		"test-secret", time.Hour, 24*time.Hour, "closed", true, nil,
+		auth.NewLoginLimiter(0, 0, time.Minute, time.Minute),
+		audit.NewService(persistence.NewAuditLogRepository(db.Client)),
+	)
+
+	provider := oidc.NewProvider(idp.Issuer, oidctest.ClientID, oidctest.ClientSecret, oidctest.RedirectURL, []string{"profile", "email"})
+	return NewService(provider, authService, mapping), userRepo
+}
+
+// login 完成一次登录：发起登录、在身份提供方授权、处理回调并换取令牌
+func login(t *testing.T, s *Service, idp *oidctest.Server) (*entity.LoginResponse, error) {
+	t.Helper()
+
+	authURL, err := s.Begin(context.Background(), "/tasks")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	code, state := idp.Authorize(t, authURL)
+	loginCode, redirect, err := s.Callback(context.Background(), code, state, "127.0.0.1", "test")
+	if err != nil {
+		return nil, err
+	}
+	if redirect != "/tasks" {
+		t.Errorf("redirect = %q, want /tasks", redirect)
+	}
+	return s.Redeem(loginCode)
+}
+
+// aliceClaims 测试用户的声明
+func aliceClaims(groups ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"groups":             groups,
+	}
+}
+
+func TestServiceCallbackCreatesUserWithMappedRole(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	s, userRepo := newTestService(t, idp, testMapping())
+
+	idp.Claims = aliceClaims("ops")
+	response, err := login(t, s, idp)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if response.Token == "" || response.RefreshToken == "" {
+		t.Error("login response has no tokens")
+	}
+
+	user, err := userRepo.FindByUsername("alice")
+	if err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if user.AuthProvider != entity.AuthProviderOIDC || user.ExternalID != "user-1" {
+		t.Errorf("auth provider = %q, external id = %q", user.AuthProvider, user.ExternalID)
+	}
+	if user.Email != "alice@example.com" {
+		t.Errorf("email = %q", user.Email)
+	}
+	if response.User.ID != user.ID {
+		t.Errorf("response user = %d, want %d", response.User.ID, user.ID)
+	}
+
+	// 每次登录按用户组重新映射角色，取最高的角色；没有匹配的用户组时使用默认角色
+	tests := []struct {
+		groups []string
+		want   string
+	}{
+		{[]string{"ops"}, entity.RoleOperator},
+		{[]string{"ops", "ops-admins"}, entity.RoleAdmin},
+		{[]string{"developers"}, entity.RoleViewer},
+		{nil, entity.RoleViewer},
+	}
+	for _, tt := range tests {
+		idp.Claims = aliceClaims(tt.groups...)
+		response, err := login(t, s, idp)
+		if err != nil {
+			t.Fatalf("login with groups %v: %v", tt.groups, err)
+		}
+		if response.User.ID != user.ID {
+			t.Fatalf("login with groups %v created another user", tt.groups)
+		}
+		updated, _ := userRepo.FindByUsername("alice")
+		if updated.Role != tt.want {
+			t.Errorf("role with groups %v = %s, want %s", tt.groups, updated.Role, tt.want)
+		}
+	}
+}
+
+func TestServiceCallbackGroupsFromUserInfo(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	// ID Token 中没有用户组，从 userinfo 端点获取
+	idp.Claims = map[string]interface{}{"preferred_username": "alice", "email": "alice@example.com"}
+	idp.UserInfo = map[string]interface{}{"sub": "user-1", "groups": []string{"ops-admins"}}
+	s, userRepo := newTestService(t, idp, testMapping())
+
+	if _, err := login(t, s, idp); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	user, err := userRepo.FindByUsername("alice")
+	if err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if user.Role != entity.RoleAdmin {
+		t.Errorf("role = %s, want admin", user.Role)
+	}
+
+	// userinfo 的 sub 与 ID Token 不一致时拒绝
+	idp.UserInfo = map[string]interface{}{"sub": "user-2", "groups": []string{"ops-admins"}}
+	if _, err := login(t, s, idp); err == nil {
+		t.Fatal("login accepted userinfo for another subject")
+	}
+}
+
+func TestServiceCallbackRejectsInvalidState(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	idp.Claims = aliceClaims("ops")
+	s, _ := newTestService(t, idp, testMapping())
+	ctx := context.Background()
+
+	authURL, err := s.Begin(ctx, "/")
+	if err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	code, state := idp.Authorize(t, authURL)
+
+	if _, _, err := s.Callback(ctx, code, "forged-state", "127.0.0.1", "test"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Callback with unknown state error = %v, want ErrInvalidState", err)
+	}
+	loginCode, _, err := s.Callback(ctx, code, state, "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	// state 只能使用一次
+	code, _ = idp.Authorize(t, authURL)
+	if _, _, err := s.Callback(ctx, code, state, "127.0.0.1", "test"); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Callback with reused state error = %v, want ErrInvalidState", err)
+	}
+
+	// 登录码只能使用一次
+	if _, err := s.Redeem(loginCode); err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if _, err := s.Redeem(loginCode); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("Redeem reused code error = %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestServiceCallbackRejectsInvalidIDToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(jwt.MapClaims)
+	}{
+		{"nonce mismatch", func(c jwt.MapClaims) { c["nonce"] = "replayed-nonce" }},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "another-client" }},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := oidctest.NewServer(t, "")
+			idp.Claims = aliceClaims("ops-admins")
+			idp.TokenModifier = tt.modify
+			s, userRepo := newTestService(t, idp, testMapping())
+
+			if _, err := login(t, s, idp); err == nil {
+				t.Fatal("login succeeded with an invalid ID token")
+			}
+			if _, err := userRepo.FindByUsername("alice"); err == nil {
+				t.Error("user was created from an invalid ID token")
+			}
+		})
+	}
+}
+
+func TestServiceCallbackWithoutAutoCreate(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	idp.Claims = aliceClaims("ops")
+	mapping := testMapping()
+	mapping.AutoCreate = false
+	s, userRepo := newTestService(t, idp, mapping)
+
+	if _, err := login(t, s, idp); !errors.Is(err, auth.ErrExternalUserNotFound) {
+		t.Fatalf("login error = %v, want ErrExternalUserNotFound", err)
+	}
+	if _, err := userRepo.FindByUsername("alice"); err == nil {
+		t.Error("user was created without auto_create")
+	}
+}
+
+func TestServiceCallbackLinksAccountsByEmail(t *testing.T) {
+	tests := []struct {
+		name          string
+		role          string
+		authProvider  string
+		linkByEmail   bool
+		emailVerified bool
+		wantLinked    bool
+		wantErr       error
+	}{
+		{"switched account", entity.RoleOperator, entity.AuthProviderOIDC, true, true, true, nil},
+		{"switched account without link_by_email", entity.RoleOperator, entity.AuthProviderOIDC, false, true, false, nil},
+		{"switched account with unverified email", entity.RoleOperator, entity.AuthProviderOIDC, true, false, false, nil},
+		{"local account", entity.RoleOperator, entity.AuthProviderLocal, true, true, false, nil},
+		{"switched admin account", entity.RoleAdmin, entity.AuthProviderOIDC, true, true, false, auth.ErrExternalEmailInUse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := oidctest.NewServer(t, "")
+			claims := aliceClaims("ops")
+			claims["email_verified"] = tt.emailVerified
+			idp.Claims = claims
+			mapping := testMapping()
+			mapping.LinkByEmail = tt.linkByEmail
+			s, userRepo := newTestService(t, idp, mapping)
+
+			existing := &entity.User{
+				Username:     "bob",
+				Password:     "unused",
+				Email:        "alice@example.com",
+				Role:         tt.role,
+				IsActive:     true,
+				AuthProvider: tt.authProvider,
+			}
+			if err := userRepo.Create(existing); err != nil {
+				t.Fatalf("create user: %v", err)
+			}
+
+			response, err := login(t, s, idp)
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("login error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantLinked {
+				if err != nil {
+					t.Fatalf("login: %v", err)
+				}
+				if response.User.ID != existing.ID {
+					t.Errorf("logged in as user %d, want %d", response.User.ID, existing.ID)
+				}
+			} else if err == nil && response.User.ID == existing.ID {
+				t.Error("logged in as the existing account")
+			}
+
+			bob, err := userRepo.FindByUsername("bob")
+			if err != nil {
+				t.Fatalf("find user: %v", err)
+			}
+			if linked := bob.ExternalID == "user-1"; linked != tt.wantLinked {
+				t.Errorf("linked = %v, want %v", linked, tt.wantLinked)
+			}
+			if !tt.wantLinked && (bob.AuthProvider != tt.authProvider || bob.Role != tt.role) {
+				t.Errorf("unlinked account changed: auth provider = %q, role = %s", bob.AuthProvider, bob.Role)
+			}
+		})
+	}
+}
+
+func TestServiceBeginEvictsOldestPending(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	s, _ := newTestService(t, idp, testMapping())
+	ctx := context.Background()
+
+	var firstState, secondState string
+	for i := 0; i <= maxPendingLogins; i++ {
+		authURL, err := s.Begin(ctx, "/")
+		if err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+		if i < 2 {
+			parsed, _ := url.Parse(authURL)
+			if i == 0 {
+				firstState = parsed.Query().Get("state")
+			} else {
+				secondState = parsed.Query().Get("state")
+			}
+		}
+	}
+
+	s.mu.Lock()
+	pending := len(s.pending)
+	_, firstKept := s.pending[firstState]
+	_, secondKept := s.pending[secondState]
+	s.mu.Unlock()
+	if pending != maxPendingLogins {
+		t.Errorf("pending logins = %d, want %d", pending, maxPendingLogins)
+	}
+	if firstKept {
+		t.Error("oldest pending login was not evicted")
+	}
+	if !secondKept {
+		t.Error("second pending login was evicted")
+	}
+}
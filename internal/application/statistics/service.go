@@ -3,8 +3,10 @@ package statistics
 import (
 	"crontab_go/internal/domain/entity"
 	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/domain/service"
 	"math"
 	"sort"
+	"strconv"
 	"time"
 )
 
@@ -22,20 +24,19 @@ func NewService(taskRepo repository.TaskRepository, taskLogRepo repository.TaskL
 
 // GetTaskStatistics 获取任务统计信息
 func (s *Service) GetTaskStatistics(req *entity.StatisticsRequest) ([]entity.TaskStatistics, error) {
-	// 获取所有任务
-	tasks, err := s.taskRepo.FindAll()
+	tasks, err := s.findTasks(req)
 	if err != nil {
 		return nil, err
 	}
 
-	var statistics []entity.TaskStatistics
+	aggregates, err := s.aggregateByTask(req)
+	if err != nil {
+		return nil, err
+	}
 
+	var statistics []entity.TaskStatistics
 	for _, task := range tasks {
-		stat, err := s.calculateTaskStatistics(task, req)
-		if err != nil {
-			continue // 跳过错误的任务，继续处理其他任务
-		}
-		statistics = append(statistics, *stat)
+		statistics = append(statistics, *buildTaskStatistics(task, aggregates[task.ID]))
 	}
 
 	return statistics, nil
@@ -48,123 +49,76 @@ func (s *Service) GetTaskStatisticsByID(taskID int, req *entity.StatisticsReques
 		return nil, err
 	}
 
-	return s.calculateTaskStatistics(task, req)
-}
+	filter := s.buildFilter(req)
+	filter.TaskID = &taskID
 
-// calculateTaskStatistics 计算任务统计信息
-func (s *Service) calculateTaskStatistics(task *entity.Task, req *entity.StatisticsRequest) (*entity.TaskStatistics, error) {
-	// 获取任务日志
-	logs, err := s.getTaskLogsInRange(task.ID, req)
+	aggregates, err := s.taskLogRepo.AggregateByTask(filter)
 	if err != nil {
 		return nil, err
 	}
 
-	stat := &entity.TaskStatistics{
-		TaskID:   task.ID,
-		TaskName: task.Name,
+	var aggregate *entity.TaskLogAggregate
+	if len(aggregates) > 0 {
+		aggregate = &aggregates[0]
 	}
 
-	if len(logs) == 0 {
-		return stat, nil
-	}
-
-	// 计算基本统计
-	stat.TotalExecutions = int64(len(logs))
-	var totalDuration time.Duration
-	var lastExecution *entity.TaskLog
-
-	for _, log := range logs {
-		if log.Success {
-			stat.SuccessExecutions++
-		} else {
-			stat.FailureExecutions++
-		}
-
-		duration := log.EndTime.Sub(log.StartTime)
-		totalDuration += duration
-
-		// 找到最后执行的任务
-		if lastExecution == nil || log.StartTime.After(lastExecution.StartTime) {
-			lastExecution = &log
-		}
-	}
+	return buildTaskStatistics(task, aggregate), nil
+}
 
-	// 计算成功率
-	if stat.TotalExecutions > 0 {
-		stat.SuccessRate = float64(stat.SuccessExecutions) / float64(stat.TotalExecutions) * 100
+// buildTaskStatistics 根据聚合结果构建任务统计信息，aggregate 为空表示没有执行记录
+func buildTaskStatistics(task *entity.Task, aggregate *entity.TaskLogAggregate) *entity.TaskStatistics {
+	stat := &entity.TaskStatistics{
+		TaskID:   task.ID,
+		TaskName: task.Name,
 	}
 
-	// 计算平均执行时间
-	if stat.TotalExecutions > 0 {
-		stat.AverageExecutionTime = totalDuration.Seconds() / float64(stat.TotalExecutions)
+	if aggregate == nil || aggregate.TotalExecutions == 0 {
+		return stat
 	}
 
-	// 设置最后执行信息
-	if lastExecution != nil {
-		stat.LastExecutionTime = &lastExecution.StartTime
-		stat.LastExecutionStatus = lastExecution.Success
-	}
+	stat.TotalExecutions = aggregate.TotalExecutions
+	stat.SuccessExecutions = aggregate.SuccessExecutions
+	stat.FailureExecutions = aggregate.FailureExecutions
+	stat.SuccessRate = float64(aggregate.SuccessExecutions) / float64(aggregate.TotalExecutions) * 100
+	stat.AverageExecutionTime = aggregate.AvgDuration
+	stat.LastExecutionTime = aggregate.LastStartTime
+	stat.LastExecutionStatus = aggregate.LastSuccess
 
-	return stat, nil
+	return stat
 }
 
 // GetExecutionTrends 获取执行趋势数据
 func (s *Service) GetExecutionTrends(req *entity.StatisticsRequest) ([]entity.ExecutionTrend, error) {
-	// 确定日期范围
-	endDate := time.Now()
-	if req.EndDate != nil {
-		endDate = *req.EndDate
-	}
-
-	startDate := endDate.AddDate(0, 0, -req.Days)
-	if req.StartDate != nil {
-		startDate = *req.StartDate
-	}
+	startDate, endDate := resolveDateRange(req)
 
-	// 获取日期范围内的所有日志
-	logs, err := s.getLogsInDateRange(startDate, endDate, req.TaskID)
+	buckets, err := s.taskLogRepo.AggregateByDay(s.buildFilter(req))
 	if err != nil {
 		return nil, err
 	}
 
-	// 按日期分组统计
-	dailyStats := make(map[string]*entity.ExecutionTrend)
-
 	// 初始化所有日期
+	var trends []entity.ExecutionTrend
+	index := make(map[string]int)
 	for d := startDate; d.Before(endDate) || d.Equal(endDate); d = d.AddDate(0, 0, 1) {
 		dateStr := d.Format("2006-01-02")
-		dailyStats[dateStr] = &entity.ExecutionTrend{
-			Date: dateStr,
-		}
+		index[dateStr] = len(trends)
+		trends = append(trends, entity.ExecutionTrend{Date: dateStr})
 	}
 
-	// 统计每日数据
-	for _, log := range logs {
-		dateStr := log.StartTime.Format("2006-01-02")
-		if trend, exists := dailyStats[dateStr]; exists {
-			trend.TotalExecutions++
-			if log.Success {
-				trend.SuccessCount++
-			} else {
-				trend.FailureCount++
-			}
+	// 填充每日数据
+	for _, bucket := range buckets {
+		i, exists := index[bucket.Bucket]
+		if !exists {
+			continue
 		}
-	}
-
-	// 计算成功率并转换为切片
-	var trends []entity.ExecutionTrend
-	for _, trend := range dailyStats {
-		if trend.TotalExecutions > 0 {
-			trend.SuccessRate = float64(trend.SuccessCount) / float64(trend.TotalExecutions) * 100
+		trends[i].TotalExecutions = bucket.TotalExecutions
+		trends[i].SuccessCount = bucket.SuccessCount
+		trends[i].FailureCount = bucket.FailureCount
+		if bucket.TotalExecutions > 0 {
+			trends[i].SuccessRate = float64(bucket.SuccessCount) / float64(bucket.TotalExecutions) * 100
 		}
-		trends = append(trends, *trend)
 	}
 
-	// 按日期排序
-	sort.Slice(trends, func(i, j int) bool {
-		return trends[i].Date < trends[j].Date
-	})
-
 	return trends, nil
 }
 
@@ -174,40 +128,39 @@ func (s *Service) GetTaskExecutionReport(req *entity.StatisticsRequest) (*entity
 		ReportDate: time.Now().Format("2006-01-02"),
 	}
 
-	// 获取所有任务
-	tasks, err := s.taskRepo.FindAll()
+	// 获取统计范围内的所有任务
+	tasks, err := s.scopedTasks(req)
 	if err != nil {
 		return nil, err
 	}
 
 	report.TotalTasks = int64(len(tasks))
 
-	// 计算活跃任务数（最近30天有执行记录的任务）
-	activeTasks := 0
+	aggregates, err := s.aggregateByTask(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 计算活跃任务数（统计范围内有执行记录的任务）
 	var allStats []entity.TaskStatistics
-	var totalExecutions int64
 	var totalSuccessExecutions int64
 
 	for _, task := range tasks {
-		stat, err := s.calculateTaskStatistics(task, req)
-		if err != nil {
+		aggregate, exists := aggregates[task.ID]
+		if !exists || aggregate.TotalExecutions == 0 {
 			continue
 		}
 
-		if stat.TotalExecutions > 0 {
-			activeTasks++
-			allStats = append(allStats, *stat)
-			totalExecutions += stat.TotalExecutions
-			totalSuccessExecutions += stat.SuccessExecutions
-		}
+		allStats = append(allStats, *buildTaskStatistics(task, aggregate))
+		report.TotalExecutions += aggregate.TotalExecutions
+		totalSuccessExecutions += aggregate.SuccessExecutions
 	}
 
-	report.ActiveTasks = int64(activeTasks)
-	report.TotalExecutions = totalExecutions
+	report.ActiveTasks = int64(len(allStats))
 
 	// 计算整体成功率
-	if totalExecutions > 0 {
-		report.SuccessRate = float64(totalSuccessExecutions) / float64(totalExecutions) * 100
+	if report.TotalExecutions > 0 {
+		report.SuccessRate = float64(totalSuccessExecutions) / float64(report.TotalExecutions) * 100
 	}
 
 	// 获取执行次数最多的前10个任务
@@ -233,7 +186,26 @@ func (s *Service) GetTaskExecutionReport(req *entity.StatisticsRequest) (*entity
 
 // GetTaskPerformanceMetrics 获取任务性能指标
 func (s *Service) GetTaskPerformanceMetrics(req *entity.StatisticsRequest) ([]entity.TaskPerformanceMetrics, error) {
-	tasks, err := s.taskRepo.FindAll()
+	tasks, err := s.findTasks(req)
+	if err != nil {
+		return nil, err
+	}
+
+	aggregates, err := s.aggregateByTask(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 中位数及百分位数
+	percentiles, err := s.taskLogRepo.GetDurationPercentilesByTask(s.buildFilter(req), []float64{0.5, 0.9, 0.95, 0.99})
+	if err != nil {
+		return nil, err
+	}
+
+	// 所有任务的基线在一次分组查询中计算
+	startDate, endDate := resolveDateRange(req)
+	detector := service.NewAnomalyDetector(s.taskLogRepo, anomalyConfig(req))
+	baselines, err := detector.Baselines(activeTaskIDs(tasks, aggregates), startDate)
 	if err != nil {
 		return nil, err
 	}
@@ -241,75 +213,90 @@ func (s *Service) GetTaskPerformanceMetrics(req *entity.StatisticsRequest) ([]en
 	var metrics []entity.TaskPerformanceMetrics
 
 	for _, task := range tasks {
-		metric, err := s.calculateTaskPerformanceMetrics(task, req)
-		if err != nil {
-			continue
+		aggregate, exists := aggregates[task.ID]
+		if !exists || aggregate.TotalExecutions == 0 {
+			continue // 只包含有执行记录的任务
 		}
-		if metric.AverageExecutionTime > 0 { // 只包含有执行记录的任务
-			metrics = append(metrics, *metric)
+
+		metric := calculateTaskPerformanceMetrics(task, aggregate, percentiles[task.ID])
+		metric.Baseline = baselines[task.ID]
+		metric.Anomalies, err = detector.DetectInRange(task, metric.Baseline, aggregate, startDate, endDate)
+		if err != nil {
+			return nil, err
 		}
+		metrics = append(metrics, *metric)
 	}
 
 	return metrics, nil
 }
 
 // calculateTaskPerformanceMetrics 计算任务性能指标
-func (s *Service) calculateTaskPerformanceMetrics(task *entity.Task, req *entity.StatisticsRequest) (*entity.TaskPerformanceMetrics, error) {
-	logs, err := s.getTaskLogsInRange(task.ID, req)
-	if err != nil {
-		return nil, err
+// percentiles 为执行时间的中位数、P90、P95 和 P99
+func calculateTaskPerformanceMetrics(task *entity.Task, aggregate *entity.TaskLogAggregate, percentiles []float64) *entity.TaskPerformanceMetrics {
+	metric := &entity.TaskPerformanceMetrics{
+		TaskID:               task.ID,
+		TaskName:             task.Name,
+		MinExecutionTime:     aggregate.MinDuration,
+		MaxExecutionTime:     aggregate.MaxDuration,
+		AverageExecutionTime: aggregate.AvgDuration,
 	}
 
-	metric := &entity.TaskPerformanceMetrics{
-		TaskID:   task.ID,
-		TaskName: task.Name,
+	// 标准差: sqrt(E[X²] - E[X]²)
+	variance := aggregate.AvgSquaredDuration - aggregate.AvgDuration*aggregate.AvgDuration
+	if variance > 0 {
+		metric.ExecutionTimeStdDev = math.Sqrt(variance)
 	}
 
-	if len(logs) == 0 {
-		return metric, nil
+	if len(percentiles) == 4 {
+		metric.MedianExecutionTime = percentiles[0]
+		metric.P90ExecutionTime = percentiles[1]
+		metric.P95ExecutionTime = percentiles[2]
+		metric.P99ExecutionTime = percentiles[3]
 	}
 
-	// 计算执行时间
-	var durations []float64
-	var totalDuration float64
+	return metric
+}
 
-	for _, log := range logs {
-		duration := log.EndTime.Sub(log.StartTime).Seconds()
-		durations = append(durations, duration)
-		totalDuration += duration
+// GetAnomalies 获取统计范围内所有任务的异常执行
+func (s *Service) GetAnomalies(req *entity.StatisticsRequest) ([]entity.TaskAnomaly, error) {
+	tasks, err := s.findTasks(req)
+	if err != nil {
+		return nil, err
 	}
 
-	// 排序以计算中位数
-	sort.Float64s(durations)
-
-	// 基本统计
-	metric.MinExecutionTime = durations[0]
-	metric.MaxExecutionTime = durations[len(durations)-1]
-	metric.AverageExecutionTime = totalDuration / float64(len(durations))
+	aggregates, err := s.aggregateByTask(req)
+	if err != nil {
+		return nil, err
+	}
 
-	// 中位数
-	n := len(durations)
-	if n%2 == 0 {
-		metric.MedianExecutionTime = (durations[n/2-1] + durations[n/2]) / 2
-	} else {
-		metric.MedianExecutionTime = durations[n/2]
+	// 所有任务的基线在一次分组查询中计算，范围内没有执行记录的任务不需要检测
+	startDate, endDate := resolveDateRange(req)
+	detector := service.NewAnomalyDetector(s.taskLogRepo, anomalyConfig(req))
+	baselines, err := detector.Baselines(activeTaskIDs(tasks, aggregates), startDate)
+	if err != nil {
+		return nil, err
 	}
 
-	// 标准差
-	var variance float64
-	for _, duration := range durations {
-		variance += math.Pow(duration-metric.AverageExecutionTime, 2)
+	anomalies := []entity.TaskAnomaly{}
+	for _, task := range tasks {
+		aggregate, exists := aggregates[task.ID]
+		if !exists {
+			continue
+		}
+
+		taskAnomalies, err := detector.DetectInRange(task, baselines[task.ID], aggregate, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, taskAnomalies...)
 	}
-	variance /= float64(len(durations))
-	metric.ExecutionTimeStdDev = math.Sqrt(variance)
 
-	return metric, nil
+	return anomalies, nil
 }
 
 // GetHourlyExecutionStats 获取小时执行统计
 func (s *Service) GetHourlyExecutionStats(req *entity.StatisticsRequest) ([]entity.HourlyExecutionStats, error) {
-	// 获取日志数据
-	logs, err := s.getLogsInRange(req)
+	buckets, err := s.taskLogRepo.AggregateByHour(s.buildFilter(req))
 	if err != nil {
 		return nil, err
 	}
@@ -320,78 +307,106 @@ func (s *Service) GetHourlyExecutionStats(req *entity.StatisticsRequest) ([]enti
 		hourlyStats[i] = entity.HourlyExecutionStats{Hour: i}
 	}
 
-	// 统计每小时数据
-	for _, log := range logs {
-		hour := log.StartTime.Hour()
-		hourlyStats[hour].TotalExecutions++
-		if log.Success {
-			hourlyStats[hour].SuccessCount++
-		} else {
-			hourlyStats[hour].FailureCount++
+	// 填充每小时数据
+	for _, bucket := range buckets {
+		hour, err := strconv.Atoi(bucket.Bucket)
+		if err != nil || hour < 0 || hour > 23 {
+			continue
 		}
+		hourlyStats[hour].TotalExecutions = bucket.TotalExecutions
+		hourlyStats[hour].SuccessCount = bucket.SuccessCount
+		hourlyStats[hour].FailureCount = bucket.FailureCount
 	}
 
 	return hourlyStats, nil
 }
 
-// 辅助方法：获取指定任务在时间范围内的日志
-func (s *Service) getTaskLogsInRange(taskID int, req *entity.StatisticsRequest) ([]entity.TaskLog, error) {
-	// 这里需要扩展 TaskLogRepository 来支持日期范围查询
-	// 暂时使用现有方法获取所有日志，然后过滤
-	logs, err := s.taskLogRepo.GetLogsByTaskID(taskID)
-	if err != nil {
-		return nil, err
+// 辅助方法：获取统计范围内的任务，指定了任务ID时只返回该任务
+func (s *Service) findTasks(req *entity.StatisticsRequest) ([]*entity.Task, error) {
+	if req != nil && req.TaskID != nil {
+		task, err := s.taskRepo.FindByID(*req.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		return []*entity.Task{task}, nil
 	}
-
-	return s.filterLogsByDateRange(logs, req), nil
+	return s.scopedTasks(req)
 }
 
-// 辅助方法：获取日期范围内的日志
-func (s *Service) getLogsInDateRange(startDate, endDate time.Time, taskID *int) ([]entity.TaskLog, error) {
-	var logs []entity.TaskLog
-	var err error
+// 辅助方法：获取按用户权限限定范围内的所有任务
+func (s *Service) scopedTasks(req *entity.StatisticsRequest) ([]*entity.Task, error) {
+	tasks, err := s.taskRepo.FindAll()
+	if err != nil || req == nil || req.TaskIDs == nil {
+		return tasks, err
+	}
 
-	if taskID != nil {
-		logs, err = s.taskLogRepo.GetLogsByTaskID(*taskID)
-	} else {
-		logs, err = s.taskLogRepo.GetAllLogs()
+	allowed := make(map[int]bool, len(req.TaskIDs))
+	for _, id := range req.TaskIDs {
+		allowed[id] = true
 	}
+	var scoped []*entity.Task
+	for _, task := range tasks {
+		if allowed[task.ID] {
+			scoped = append(scoped, task)
+		}
+	}
+	return scoped, nil
+}
 
+// 辅助方法：按任务聚合统计范围内的日志
+func (s *Service) aggregateByTask(req *entity.StatisticsRequest) (map[int]*entity.TaskLogAggregate, error) {
+	aggregates, err := s.taskLogRepo.AggregateByTask(s.buildFilter(req))
 	if err != nil {
 		return nil, err
 	}
 
-	// 过滤日期范围
-	var filteredLogs []entity.TaskLog
-	for _, log := range logs {
-		if (log.StartTime.After(startDate) || log.StartTime.Equal(startDate)) &&
-			(log.StartTime.Before(endDate) || log.StartTime.Equal(endDate)) {
-			filteredLogs = append(filteredLogs, log)
-		}
+	result := make(map[int]*entity.TaskLogAggregate, len(aggregates))
+	for i := range aggregates {
+		result[aggregates[i].TaskID] = &aggregates[i]
 	}
-
-	return filteredLogs, nil
+	return result, nil
 }
 
-// 辅助方法：获取范围内的所有日志
-func (s *Service) getLogsInRange(req *entity.StatisticsRequest) ([]entity.TaskLog, error) {
-	endDate := time.Now()
-	if req.EndDate != nil {
-		endDate = *req.EndDate
+// 辅助方法：获取统计范围内有执行记录的任务ID
+func activeTaskIDs(tasks []*entity.Task, aggregates map[int]*entity.TaskLogAggregate) []int {
+	ids := make([]int, 0, len(tasks))
+	for _, task := range tasks {
+		if aggregate, exists := aggregates[task.ID]; exists && aggregate.TotalExecutions > 0 {
+			ids = append(ids, task.ID)
+		}
 	}
+	return ids
+}
 
-	startDate := endDate.AddDate(0, 0, -req.Days)
-	if req.StartDate != nil {
-		startDate = *req.StartDate
+// 辅助方法：根据统计请求构建日志查询条件
+func (s *Service) buildFilter(req *entity.StatisticsRequest) *entity.TaskLogFilter {
+	startDate, endDate := resolveDateRange(req)
+	filter := &entity.TaskLogFilter{
+		StartTime: &startDate,
+		EndTime:   &endDate,
+	}
+	if req != nil && req.TaskID != nil {
+		taskID := *req.TaskID
+		filter.TaskID = &taskID
 	}
+	if req != nil && req.TaskIDs != nil {
+		filter.TaskIDs = append([]int{}, req.TaskIDs...)
+	}
+	return filter
+}
 
-	return s.getLogsInDateRange(startDate, endDate, req.TaskID)
+// 辅助方法：获取异常检测配置
+func anomalyConfig(req *entity.StatisticsRequest) *entity.AnomalyConfig {
+	if req == nil || req.Anomaly == nil {
+		return entity.NewAnomalyConfig()
+	}
+	return req.Anomaly
 }
 
-// 辅助方法：按日期范围过滤日志
-func (s *Service) filterLogsByDateRange(logs []entity.TaskLog, req *entity.StatisticsRequest) []entity.TaskLog {
+// 辅助方法：确定统计的日期范围
+func resolveDateRange(req *entity.StatisticsRequest) (time.Time, time.Time) {
 	if req == nil {
-		return logs
+		req = entity.NewStatisticsRequest()
 	}
 
 	endDate := time.Now()
@@ -404,13 +419,5 @@ func (s *Service) filterLogsByDateRange(logs []entity.TaskLog, req *entity.Stati
 		startDate = *req.StartDate
 	}
 
-	var filteredLogs []entity.TaskLog
-	for _, log := range logs {
-		if (log.StartTime.After(startDate) || log.StartTime.Equal(startDate)) &&
-			(log.StartTime.Before(endDate) || log.StartTime.Equal(endDate)) {
-			filteredLogs = append(filteredLogs, log)
-		}
-	}
-
-	return filteredLogs
-}
\ No newline at end of file
+	return startDate, endDate
+}
@@ -59,9 +59,9 @@ func (s *Service) GetRealTimeStats() (*entity.SystemStats, error) {
 	return stats, nil
 }
 
-// CleanOldStats 只保留最新的100条数据
-func (s *Service) CleanOldStats() error {
-	return s.SystemRepo.KeepLatestStats(100)
+// CleanOldStats 只保留最新的 keep 条数据
+func (s *Service) CleanOldStats(keep int) error {
+	return s.SystemRepo.KeepLatestStats(keep)
 }
 
 // CollectAndSaveStats 收集并保存系统统计信息
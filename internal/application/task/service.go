@@ -1,40 +1,310 @@
 package task
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"crontab_go/internal/application/channel"
 	"crontab_go/internal/domain/entity"
 	"crontab_go/internal/domain/repository"
-	"strings"
 
 	"crontab_go/internal/domain/service"
 )
 
+var (
+	// ErrHeartbeatNotExecutable 心跳任务不能手动执行
+	ErrHeartbeatNotExecutable = errors.New("心跳任务由外部任务上报执行结果，不能手动执行")
+	// ErrPingTokenNotFound 心跳上报令牌不存在
+	ErrPingTokenNotFound = errors.New("心跳上报令牌不存在")
+)
+
 type Service struct {
-	taskRepo    repository.TaskRepository
-	taskLogRepo repository.TaskLogRepository
+	taskRepo       repository.TaskRepository
+	taskLogRepo    repository.TaskLogRepository
+	channelRepo    repository.NotificationChannelRepository
+	deliveryRepo   repository.NotificationDeliveryRepository
+	alertRepo      repository.TaskAlertRepository
+	policyRepo       repository.EscalationPolicyRepository
+	heartbeatRepo    repository.TaskHeartbeatRepository
+	grantRepo        repository.TaskGrantRepository
+	projectRepo      repository.ProjectRepository
+	alertEvaluator   *service.AlertEvaluator
+	heartbeatMonitor *service.HeartbeatMonitor
+	channelService   *channel.Service
+	outbox           *service.NotificationOutbox
 }
 
-func NewService(taskRepo repository.TaskRepository, taskLogRepo repository.TaskLogRepository) *Service {
-	return &Service{taskRepo: taskRepo, taskLogRepo: taskLogRepo}
+func NewService(taskRepo repository.TaskRepository, taskLogRepo repository.TaskLogRepository, channelRepo repository.NotificationChannelRepository, deliveryRepo repository.NotificationDeliveryRepository, alertRepo repository.TaskAlertRepository, policyRepo repository.EscalationPolicyRepository, heartbeatRepo repository.TaskHeartbeatRepository, grantRepo repository.TaskGrantRepository, projectRepo repository.ProjectRepository) *Service {
+	return &Service{
+		taskRepo:         taskRepo,
+		taskLogRepo:      taskLogRepo,
+		channelRepo:      channelRepo,
+		deliveryRepo:     deliveryRepo,
+		alertRepo:        alertRepo,
+		policyRepo:       policyRepo,
+		heartbeatRepo:    heartbeatRepo,
+		grantRepo:        grantRepo,
+		projectRepo:      projectRepo,
+		alertEvaluator:   service.NewAlertEvaluator(alertRepo),
+		heartbeatMonitor: service.NewHeartbeatMonitor(taskRepo, taskLogRepo, channelRepo, deliveryRepo, alertRepo, policyRepo, heartbeatRepo),
+		channelService:   channel.NewService(channelRepo),
+		outbox:           service.NewNotificationOutbox(deliveryRepo),
+	}
 }
 
 func (s *Service) CreateTask(task *entity.Task) error {
+	if err := normalizeTaskType(task, ""); err != nil {
+		return err
+	}
+	if err := s.normalizeAlertRule(task); err != nil {
+		return err
+	}
+	if err := s.resolveChannels(task); err != nil {
+		return err
+	}
 	return s.taskRepo.Create(task)
 }
 
 func (s *Service) UpdateTask(task *entity.Task) error {
+	existing, err := s.taskRepo.FindByID(task.ID)
+	if err != nil {
+		return err
+	}
+	if err := normalizeTaskType(task, existing.PingToken); err != nil {
+		return err
+	}
+	if err := s.normalizeAlertRule(task); err != nil {
+		return err
+	}
+	if err := s.resolveChannels(task); err != nil {
+		return err
+	}
 	return s.taskRepo.Update(task)
 }
 
+// resolveChannels 校验任务引用的通知渠道和通知模板，并将请求中的内联通知配置转换为通知渠道
+func (s *Service) resolveChannels(task *entity.Task) error {
+	if err := s.channelService.ValidateMessageTemplate(task.NotificationTemplate); err != nil {
+		return err
+	}
+
+	channelIDs, err := s.channelService.ResolveChannelIDs("任务 "+task.Name, task.NotificationTypes, task.NotificationConfig, task.NotificationChannelIDs)
+	if err != nil {
+		return err
+	}
+	task.NotificationChannelIDs, task.NotificationTypes, task.NotificationConfig = channelIDs, "", ""
+	return s.checkChannelProjects(task)
+}
+
+// checkChannelProjects 任务只能使用全局通知渠道和所属项目的通知渠道
+func (s *Service) checkChannelProjects(task *entity.Task) error {
+	ids, err := entity.ParseChannelIDs(task.NotificationChannelIDs)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	channels, err := s.channelRepo.FindByIDs(ids)
+	if err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		if ch.ProjectID != nil && (task.ProjectID == nil || *ch.ProjectID != *task.ProjectID) {
+			return fmt.Errorf("通知渠道 %s 属于其他项目，不能在该任务中使用", ch.Name)
+		}
+	}
+	return nil
+}
+
+// 心跳宽限时间（秒）
+const (
+	defaultGracePeriod = 300
+	maxGracePeriod     = 7 * 24 * 3600
+)
+
+// normalizeTaskType 校验任务类型。心跳任务需要标准 Cron 表达式，沿用已有的上报令牌，没有时生成新令牌；
+// 上报令牌只能由服务端生成，忽略请求中的值
+func normalizeTaskType(task *entity.Task, pingToken string) error {
+	switch task.Type {
+	case "":
+		task.Type = entity.TaskTypeCommand
+	case entity.TaskTypeCommand, entity.TaskTypeHeartbeat:
+	default:
+		return fmt.Errorf("不支持的任务类型: %s", task.Type)
+	}
+
+	if !task.IsHeartbeat() {
+		task.PingToken = ""
+		return nil
+	}
+
+	if _, err := cron.ParseStandard(task.Schedule); err != nil {
+		return fmt.Errorf("无效的Cron表达式: %v", err)
+	}
+	if task.GracePeriod == 0 {
+		task.GracePeriod = defaultGracePeriod
+	}
+	if task.GracePeriod < 1 || task.GracePeriod > maxGracePeriod {
+		return fmt.Errorf("心跳宽限时间需在 1-%d 秒之间", maxGracePeriod)
+	}
+
+	task.PingToken = pingToken
+	if task.PingToken == "" {
+		token, err := newPingToken()
+		if err != nil {
+			return err
+		}
+		task.PingToken = token
+	}
+	return nil
+}
+
+// newPingToken 生成随机的心跳上报令牌
+func newPingToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// maxAlertCooldown 告警冷却时间上限（秒）
+const maxAlertCooldown = 7 * 24 * 3600
+
+// normalizeAlertRule 校验任务的告警规则和升级策略并填充默认值
+func (s *Service) normalizeAlertRule(task *entity.Task) error {
+	switch task.AlertMode {
+	case "":
+		task.AlertMode = entity.AlertModeEvery
+	case entity.AlertModeEvery, entity.AlertModeStateChange:
+	default:
+		return fmt.Errorf("不支持的告警模式: %s", task.AlertMode)
+	}
+
+	if task.AlertThreshold == 0 {
+		task.AlertThreshold = 1
+	}
+	if task.AlertThreshold < 1 || task.AlertThreshold > 100 {
+		return fmt.Errorf("告警阈值需在 1-100 之间")
+	}
+	if task.AlertCooldown < 0 || task.AlertCooldown > maxAlertCooldown {
+		return fmt.Errorf("告警冷却时间需在 0-%d 秒之间", maxAlertCooldown)
+	}
+
+	if task.EscalationPolicyID != nil && *task.EscalationPolicyID == 0 {
+		task.EscalationPolicyID = nil
+	}
+	if task.EscalationPolicyID != nil {
+		policy, err := s.policyRepo.FindByID(*task.EscalationPolicyID)
+		if err != nil {
+			return errors.New("引用的升级策略不存在")
+		}
+		// 任务只能使用全局升级策略和所属项目的升级策略
+		if policy.ProjectID != nil && (task.ProjectID == nil || *policy.ProjectID != *task.ProjectID) {
+			return fmt.Errorf("升级策略 %s 属于其他项目，不能在该任务中使用", policy.Name)
+		}
+	}
+	return nil
+}
+
 func (s *Service) DeleteTask(id int) error {
-	return s.taskRepo.Delete(id)
+	if err := s.taskRepo.Delete(id); err != nil {
+		return err
+	}
+	if err := s.heartbeatRepo.Delete(id); err != nil {
+		return err
+	}
+	if err := s.grantRepo.DeleteByTaskID(id); err != nil {
+		return err
+	}
+	return s.alertRepo.Delete(id)
+}
+
+// Ping 记录心跳任务的上报，state 为空时视为 success
+func (s *Service) Ping(token, state, output string) (*entity.TaskLog, error) {
+	task, err := s.taskRepo.FindByPingToken(token)
+	if err != nil {
+		return nil, ErrPingTokenNotFound
+	}
+	if state == "" {
+		state = entity.HeartbeatStateSuccess
+	}
+	return s.heartbeatMonitor.Ping(task, state, output, time.Now())
+}
+
+// GetHeartbeat 获取心跳任务的监控状态，尚未开始监控时返回空状态
+func (s *Service) GetHeartbeat(taskID int) (*entity.TaskHeartbeat, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !task.IsHeartbeat() {
+		return nil, service.ErrNotHeartbeatTask
+	}
+
+	heartbeat, err := s.heartbeatRepo.FindByTaskID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if heartbeat == nil {
+		heartbeat = &entity.TaskHeartbeat{TaskID: taskID}
+	}
+	return heartbeat, nil
+}
+
+// RegeneratePingToken 重新生成心跳任务的上报令牌，旧令牌立即失效
+func (s *Service) RegeneratePingToken(taskID int) (*entity.Task, error) {
+	task, err := s.taskRepo.FindByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if !task.IsHeartbeat() {
+		return nil, service.ErrNotHeartbeatTask
+	}
+
+	token, err := newPingToken()
+	if err != nil {
+		return nil, err
+	}
+	task.PingToken = token
+	if err := s.taskRepo.Update(task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetAlertState 获取任务的告警状态，任务从未执行过时返回正常状态
+func (s *Service) GetAlertState(taskID int) (*entity.TaskAlertState, error) {
+	state, err := s.alertRepo.FindByTaskID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = &entity.TaskAlertState{TaskID: taskID, Status: entity.AlertStatusOK}
+	}
+	return state, nil
+}
+
+// AcknowledgeAlert 确认任务的告警，停止升级
+func (s *Service) AcknowledgeAlert(taskID int, username string) (*entity.TaskAlertState, error) {
+	return s.alertEvaluator.Acknowledge(taskID, username)
+}
+
+// ResetAlertState 清除任务的告警状态，下一次失败将重新开始计数
+func (s *Service) ResetAlertState(taskID int) error {
+	return s.alertRepo.Delete(taskID)
 }
 
 func (s *Service) GetTask(id int) (*entity.Task, error) {
 	return s.taskRepo.FindByID(id)
 }
 
-func (s *Service) ListTasks() ([]*entity.Task, error) {
-	return s.taskRepo.FindAll()
+// ListTasks 获取范围内的任务，scope 为 nil 时返回所有任务
+func (s *Service) ListTasks(scope *entity.TaskScope) ([]*entity.Task, error) {
+	return s.taskRepo.FindByScope(scope)
 }
 
 func (s *Service) ListEnabledTasks() ([]*entity.Task, error) {
@@ -46,9 +316,9 @@ func (s *Service) GetTaskLogs(taskID int) ([]entity.TaskLog, error) {
 	return s.taskLogRepo.GetLogsByTaskID(taskID)
 }
 
-// GetAllLogs 获取所有任务执行日志
-func (s *Service) GetAllLogs() ([]entity.TaskLog, error) {
-	return s.taskLogRepo.GetAllLogs()
+// GetAllLogs 获取最近的 limit 条任务执行日志，taskIDs 不为 nil 时只返回这些任务的日志
+func (s *Service) GetAllLogs(taskIDs []int, limit int) ([]entity.TaskLog, error) {
+	return s.taskLogRepo.GetAllLogs(taskIDs, limit)
 }
 
 // GetAllLogsWithPagination 分页获取所有任务执行日志
@@ -56,14 +326,63 @@ func (s *Service) GetAllLogsWithPagination(page, pageSize int) ([]entity.TaskLog
 	return s.taskLogRepo.GetAllLogsWithPagination(page, pageSize)
 }
 
-// ListTasksWithPagination 分页获取任务列表
-func (s *Service) ListTasksWithPagination(req *entity.PaginationRequest) (*entity.PaginationResponse, error) {
-	tasks, total, err := s.taskRepo.FindWithPagination(req)
+// SearchLogs 按条件搜索任务执行日志
+func (s *Service) SearchLogs(req *entity.TaskLogSearchRequest) (*entity.TaskLogSearchResult, error) {
+	logs, next, err := s.taskLogRepo.SearchLogs(req)
 	if err != nil {
 		return nil, err
 	}
+
+	result := &entity.TaskLogSearchResult{
+		Logs:     logs,
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		HasMore:  next != nil,
+	}
+	if next != nil {
+		result.NextCursor = next.Encode()
+	}
+
+	// 游标分页时不统计总数，避免大表上的全量计数
+	if req.Cursor == nil {
+		total, err := s.taskLogRepo.CountLogs(&req.TaskLogFilter)
+		if err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	return result, nil
+}
+
+// GetLogDeliveries 获取一次任务执行的通知发送记录
+func (s *Service) GetLogDeliveries(logID uint) ([]*entity.NotificationDelivery, error) {
+	return s.deliveryRepo.FindByTaskLogID(logID)
+}
+
+// GetTaskDeliveries 获取任务最近的通知发送记录
+func (s *Service) GetTaskDeliveries(taskID int, limit int) ([]*entity.NotificationDelivery, error) {
+	return s.deliveryRepo.FindByTaskID(taskID, limit)
+}
+
+// GetDelivery 获取通知发送记录
+func (s *Service) GetDelivery(id uint) (*entity.NotificationDelivery, error) {
+	return s.deliveryRepo.FindByID(id)
+}
+
+// RetryDelivery 重新发送失败的通知
+func (s *Service) RetryDelivery(id uint) (*entity.NotificationDelivery, error) {
+	return s.outbox.Retry(id)
+}
+
+// ListTasksWithPagination 分页获取范围内的任务列表，scope 为 nil 时不限制
+func (s *Service) ListTasksWithPagination(scope *entity.TaskScope, req *entity.PaginationRequest) ([]*entity.Task, *entity.PaginationResponse, error) {
+	tasks, total, err := s.taskRepo.FindWithPagination(scope, req)
+	if err != nil {
+		return nil, nil, err
+	}
 	
-	return entity.NewPaginationResponse(req.Page, req.PageSize, total, tasks), nil
+	return tasks, entity.NewPaginationResponse(req.Page, req.PageSize, total, tasks), nil
 }
 
 // GetTaskLogsWithPagination 分页获取任务执行日志
@@ -82,16 +401,17 @@ func (s *Service) ExecuteTask(id int) error {
 	if err != nil {
 		return err
 	}
+	if task.IsHeartbeat() {
+		return ErrHeartbeatNotExecutable
+	}
 
 	// 创建TaskExecutor实例来执行任务
-	taskExecutor := service.NewTaskExecutor(s.taskRepo, s.taskLogRepo)
-	
-	// 根据任务命令是否为URL来决定执行方式
-	if strings.HasPrefix(task.Command, "http://") || strings.HasPrefix(task.Command, "https://") {
-		taskExecutor.ExecuteHTTPRequest(task)
-	} else {
-		taskExecutor.ExecuteSystemCommand(task)
-	}
-	
-	return nil
+	taskExecutor := service.NewTaskExecutor(s.taskRepo, s.taskLogRepo, s.channelRepo, s.deliveryRepo, s.alertRepo, s.policyRepo, s.projectRepo)
+	return taskExecutor.Execute(task, entity.TriggerManual)
+}
+
+// CancelTask 取消任务正在执行的运行（包括计划执行和手动执行），返回取消的运行数。
+// 没有正在执行的运行时返回 service.ErrTaskNotRunning
+func (s *Service) CancelTask(id int) (int, error) {
+	return service.CancelTaskRuns(id)
 }
\ No newline at end of file
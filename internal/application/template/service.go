@@ -1,28 +1,31 @@
 package template
 
 import (
-	"errors"
 	"time"
 
+	"crontab_go/internal/application/channel"
 	"crontab_go/internal/domain/entity"
 	"crontab_go/internal/domain/repository"
 )
 
 type Service struct {
-	templateRepo repository.TaskTemplateRepository
-	categoryRepo repository.TaskTemplateCategoryRepository
-	taskRepo     repository.TaskRepository
+	templateRepo   repository.TaskTemplateRepository
+	categoryRepo   repository.TaskTemplateCategoryRepository
+	taskRepo       repository.TaskRepository
+	channelService *channel.Service
 }
 
 func NewService(
 	templateRepo repository.TaskTemplateRepository,
 	categoryRepo repository.TaskTemplateCategoryRepository,
 	taskRepo repository.TaskRepository,
+	channelRepo repository.NotificationChannelRepository,
 ) *Service {
 	return &Service{
-		templateRepo: templateRepo,
-		categoryRepo: categoryRepo,
-		taskRepo:     taskRepo,
+		templateRepo:   templateRepo,
+		categoryRepo:   categoryRepo,
+		taskRepo:       taskRepo,
+		channelService: channel.NewService(channelRepo),
 	}
 }
 
@@ -36,6 +39,10 @@ func (s *Service) CreateTemplate(template *entity.TaskTemplate) error {
 		}
 	}
 
+	if err := s.resolveChannels(template); err != nil {
+		return err
+	}
+
 	template.CreatedAt = time.Now()
 	template.UpdatedAt = time.Now()
 	return s.templateRepo.Create(template)
@@ -49,9 +56,14 @@ func (s *Service) UpdateTemplate(template *entity.TaskTemplate) error {
 		return err
 	}
 
-	// 保留创建时间和创建者
+	if err := s.resolveChannels(template); err != nil {
+		return err
+	}
+
+	// 保留创建时间、创建者和所属项目
 	template.CreatedAt = existing.CreatedAt
 	template.CreatedBy = existing.CreatedBy
+	template.ProjectID = existing.ProjectID
 	template.UpdatedAt = time.Now()
 
 	return s.templateRepo.Update(template)
@@ -100,7 +112,8 @@ func (s *Service) GetPopularTemplates(limit int) ([]*entity.PopularTemplate, err
 	return s.templateRepo.GetPopularTemplates(limit)
 }
 
-// CreateTaskFromTemplate 从模板创建任务
+// CreateTaskFromTemplate 从模板创建任务，任务属于 req.ProjectID 指定的项目。
+// 调用方负责检查用户是否可以使用模板以及项目的权限和配额
 func (s *Service) CreateTaskFromTemplate(req *entity.CreateTaskFromTemplateRequest, userID int) (*entity.Task, error) {
 	// 获取模板
 	template, err := s.templateRepo.FindByID(req.TemplateID)
@@ -108,24 +121,23 @@ func (s *Service) CreateTaskFromTemplate(req *entity.CreateTaskFromTemplateReque
 		return nil, err
 	}
 
-	// 检查权限（如果是私有模板，只有创建者可以使用）
-	if !template.IsPublic && template.CreatedBy != userID {
-		return nil, errors.New("无权限使用此模板")
-	}
-
 	// 创建任务
 	task := &entity.Task{
-		Name:               req.TaskName,
-		Schedule:           template.Schedule,
-		Command:            template.Command,
-		Method:             template.Method,
-		Headers:            template.Headers,
-		Enabled:            req.Enabled,
-		Description:        template.Description,
-		NotifyOnSuccess:    template.NotifyOnSuccess,
-		NotifyOnFailure:    template.NotifyOnFailure,
-		NotificationTypes:  template.NotificationTypes,
-		NotificationConfig: template.NotificationConfig,
+		Name:                   req.TaskName,
+		Schedule:               template.Schedule,
+		Command:                template.Command,
+		Method:                 template.Method,
+		Headers:                template.Headers,
+		Enabled:                req.Enabled,
+		Description:            template.Description,
+		NotifyOnSuccess:        template.NotifyOnSuccess,
+		NotifyOnFailure:        template.NotifyOnFailure,
+		NotificationChannelIDs: template.NotificationChannelIDs,
+		ProjectID:              req.ProjectID,
+	}
+	if userID > 0 {
+		ownerID := uint(userID)
+		task.OwnerID = &ownerID
 	}
 
 	// 应用覆盖设置
@@ -169,6 +181,16 @@ func (s *Service) applyOverrides(task *entity.Task, overrides map[string]interfa
 	}
 }
 
+// resolveChannels 校验模板引用的通知渠道，并将请求中的内联通知配置转换为通知渠道
+func (s *Service) resolveChannels(template *entity.TaskTemplate) error {
+	channelIDs, err := s.channelService.ResolveChannelIDs("模板 "+template.Name, template.NotificationTypes, template.NotificationConfig, template.NotificationChannelIDs)
+	if err != nil {
+		return err
+	}
+	template.NotificationChannelIDs, template.NotificationTypes, template.NotificationConfig = channelIDs, "", ""
+	return nil
+}
+
 // GetTemplateStats 获取模板统计
 func (s *Service) GetTemplateStats() (*entity.TemplateStats, error) {
 	return s.templateRepo.GetStats()
@@ -0,0 +1,59 @@
+package entity
+
+import "time"
+
+// APITokenPrefix 个人访问令牌的前缀，用于和 JWT 区分
+const APITokenPrefix = "cgp_"
+
+// 个人访问令牌的权限范围，只限制令牌可以调用的接口，不会超出令牌所属用户的权限
+const (
+	TokenScopeRead    = "read"    // 只读，只能调用查询接口
+	TokenScopeExecute = "execute" // 只读，并且可以立即执行任务、取消执行和确认告警
+	TokenScopeAdmin   = "admin"   // 与用户登录后的权限相同
+)
+
+// ValidTokenScope 是否为有效的令牌权限范围
+func ValidTokenScope(scope string) bool {
+	switch scope {
+	case TokenScopeRead, TokenScopeExecute, TokenScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// APIToken 个人访问令牌，用于脚本和 CI 调用 API。只保存令牌的哈希，明文只在创建时返回一次
+type APIToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name" gorm:"not null"`
+	Prefix     string     `json:"prefix"` // 令牌的前几位，用于识别令牌
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	Scope      string     `json:"scope" gorm:"not null"`
+	ExpiresAt  *time.Time `json:"expires_at"` // 为空时不过期
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// Usable 令牌是否未撤销且未过期
+func (t *APIToken) Usable(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// CreateAPITokenRequest 创建个人访问令牌请求
+type CreateAPITokenRequest struct {
+	Name      string `json:"name" binding:"required,max=100"`
+	Scope     string `json:"scope" binding:"required"`
+	ExpiresIn int    `json:"expires_in"` // 有效天数，0 表示不过期
+}
+
+// CreateAPITokenResponse 创建个人访问令牌响应，token 为令牌明文，只返回这一次
+type CreateAPITokenResponse struct {
+	Token    string    `json:"token"`
+	APIToken *APIToken `json:"api_token"`
+}
@@ -0,0 +1,80 @@
+package entity
+
+import "time"
+
+// 审计操作
+const (
+	AuditLoginFailed = "login.failed" // 登录失败
+	AuditLoginLocked = "login.locked" // 登录失败次数过多，账号或 IP 被临时锁定
+
+	AuditTaskCreate  = "task.create"
+	AuditTaskUpdate  = "task.update"
+	AuditTaskDelete  = "task.delete"
+	AuditTaskExecute = "task.execute" // 手动执行任务
+	AuditTaskCancel  = "task.cancel"  // 取消正在执行的任务
+
+	AuditTemplateCreate = "template.create"
+	AuditTemplateUpdate = "template.update"
+	AuditTemplateDelete = "template.delete"
+
+	AuditCategoryCreate = "category.create"
+	AuditCategoryUpdate = "category.update"
+	AuditCategoryDelete = "category.delete"
+
+	AuditChannelCreate = "channel.create"
+	AuditChannelUpdate = "channel.update"
+	AuditChannelDelete = "channel.delete"
+
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update" // 修改角色、启用或禁用、审核通过
+	AuditUserDelete         = "user.delete"
+	AuditUserResetPassword  = "user.reset_password"
+	AuditUserResetTwoFactor = "user.reset_2fa"
+	AuditUserRevokeSessions = "user.revoke_sessions"
+)
+
+// 审计对象类型
+const (
+	AuditTargetTask     = "task"
+	AuditTargetTemplate = "template"
+	AuditTargetCategory = "category"
+	AuditTargetChannel  = "channel"
+	AuditTargetUser     = "user"
+)
+
+// AuditLog 审计日志
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"index"` // 操作人，未登录时为 0
+	Username   string    `json:"username"`             // 操作人用户名，登录失败时为尝试登录的用户名
+	Action     string    `json:"action" gorm:"index;not null"`
+	TargetType string    `json:"target_type" gorm:"index:idx_audit_logs_target"`
+	TargetID   string    `json:"target_id" gorm:"index:idx_audit_logs_target"`
+	Detail     string    `json:"detail"`
+	Changes    string    `json:"changes" gorm:"type:text"` // 修改前后的字段值，JSON格式存储 {"字段": {"before": 旧值, "after": 新值}}
+	IP         string    `json:"ip" gorm:"index"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
+
+// AuditLogFilter 审计日志查询条件，所有字段均为可选
+type AuditLogFilter struct {
+	Action     string
+	UserID     *uint
+	Username   string
+	TargetType string
+	TargetID   string
+	IP         string
+	StartTime  *time.Time
+	EndTime    *time.Time
+}
+
+// AuditChange 字段修改前后的值，创建时 Before 为空，删除时 After 为空
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
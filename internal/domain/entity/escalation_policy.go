@@ -0,0 +1,39 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EscalationStep 升级步骤：告警开始后经过 Delay 分钟仍未恢复且未确认时，通知 ChannelIDs 中的渠道
+type EscalationStep struct {
+	Delay      int   `json:"delay"`       // 距离开始告警的分钟数，0 表示立即通知
+	ChannelIDs []int `json:"channel_ids"` // 通知渠道ID
+}
+
+// EscalationPolicy 告警升级策略，任务通过 escalation_policy_id 引用
+type EscalationPolicy struct {
+	ID          int       `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"not null"`
+	Description string    `json:"description"`
+	Steps       string    `json:"steps" gorm:"type:text"`  // 升级步骤，JSON格式存储 [{"delay": 0, "channel_ids": [1]}, {"delay": 15, "channel_ids": [2]}]
+	ProjectID   *uint     `json:"project_id" gorm:"index"` // 所属项目，为空时为全局策略，所有任务都可以使用
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (EscalationPolicy) TableName() string {
+	return "escalation_policies"
+}
+
+// ParseSteps 解析升级步骤
+func (p *EscalationPolicy) ParseSteps() ([]EscalationStep, error) {
+	if p.Steps == "" {
+		return nil, nil
+	}
+	var steps []EscalationStep
+	if err := json.Unmarshal([]byte(p.Steps), &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// InviteCode 注册邀请码，每个邀请码只能使用一次
+type InviteCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	Code      string     `json:"code" gorm:"uniqueIndex;not null"`
+	Role      string     `json:"role" gorm:"default:'viewer'"` // 使用邀请码注册的用户角色
+	Note      string     `json:"note"`                         // 备注，如邀请对象
+	CreatedBy uint       `json:"created_by"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空时不过期
+	UsedBy    *uint      `json:"used_by"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (InviteCode) TableName() string {
+	return "invite_codes"
+}
+
+// Usable 邀请码是否未使用且未过期
+func (c *InviteCode) Usable(now time.Time) bool {
+	return c.UsedBy == nil && (c.ExpiresAt == nil || now.Before(*c.ExpiresAt))
+}
@@ -0,0 +1,51 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// 消息内容格式，markdown 使用 text/template 渲染，html 使用 html/template 渲染
+const (
+	MessageFormatMarkdown = "markdown"
+	MessageFormatHTML     = "html"
+)
+
+// 默认消息模板语言
+const (
+	LanguageZh = "zh"
+	LanguageEn = "en"
+)
+
+// MessageTemplate 通知消息模板，使用 Go 模板语法。
+// Body 用于 Markdown 格式的渠道，HTMLBody 用于邮件；为空的部分依次使用渠道模板和默认模板
+type MessageTemplate struct {
+	Title    string `json:"title,omitempty"`
+	Body     string `json:"body,omitempty"`
+	HTMLBody string `json:"html_body,omitempty"`
+}
+
+// BodyFor 获取指定消息格式的正文模板
+func (t MessageTemplate) BodyFor(format string) string {
+	if format == MessageFormatHTML {
+		return t.HTMLBody
+	}
+	return t.Body
+}
+
+// ParseMessageTemplate 解析 JSON 格式存储的消息模板，空字符串返回空模板
+func ParseMessageTemplate(value string) (MessageTemplate, error) {
+	var tmpl MessageTemplate
+	if strings.TrimSpace(value) == "" {
+		return tmpl, nil
+	}
+	err := json.Unmarshal([]byte(value), &tmpl)
+	return tmpl, err
+}
+
+// MessagePreview 消息模板预览结果
+type MessagePreview struct {
+	Format  string `json:"format"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
@@ -1,12 +1,51 @@
 package entity
 
-// NotificationConfig 通知配置
-type NotificationConfig struct {
-	Email    *EmailConfig    `json:"email,omitempty"`
-	DingTalk *DingTalkConfig `json:"dingtalk,omitempty"`
-	WeChat   *WeChatConfig   `json:"wechat,omitempty"`
+import "encoding/json"
+
+// NotificationConfig 通知配置，键为通知类型，值为对应渠道的配置，如
+// {"email": {...}, "dingtalk": {...}, "wechat": {...}}
+type NotificationConfig map[string]json.RawMessage
+
+// 通知渠道配置项类型
+const (
+	NotifierFieldString     = "string"
+	NotifierFieldPassword   = "password"
+	NotifierFieldNumber     = "number"
+	NotifierFieldBool       = "bool"
+	NotifierFieldStringList = "string_list"
+	NotifierFieldText       = "text" // 多行文本
+	NotifierFieldMap        = "map"  // 键值对，如 HTTP 请求头
+	NotifierFieldSelect     = "select" // 从 Options 中选择一项
+)
+
+// NotifierField 通知渠道配置项说明
+type NotifierField struct {
+	Name        string      `json:"name"`                  // 配置项名称，对应配置 JSON 中的键
+	Label       string      `json:"label"`                 // 显示名称
+	Type        string      `json:"type"`                  // 配置项类型
+	Required    bool        `json:"required"`              // 是否必填
+	Default     interface{} `json:"default,omitempty"`     // 默认值
+	Description string      `json:"description,omitempty"` // 说明
+	Secret      bool        `json:"secret,omitempty"`      // 是否为敏感信息，接口响应中会被掩码
+	Connection  bool        `json:"connection,omitempty"`  // 是否决定敏感信息发送到哪里，如服务器地址，修改后需要重新填写敏感信息
+	Options     []string    `json:"options,omitempty"`     // 可选值，仅 select 类型使用
+}
+
+// NotifierInfo 通知渠道信息
+type NotifierInfo struct {
+	Type   string          `json:"type"`
+	Name   string          `json:"name"`
+	Format string          `json:"format"` // 消息内容格式: markdown, html
+	Fields []NotifierField `json:"fields"`
 }
 
+// 邮件连接加密方式
+const (
+	EmailSecuritySTARTTLS = "starttls" // 建立明文连接后通过 STARTTLS 升级，服务器不支持时发送失败
+	EmailSecurityTLS      = "tls"      // 隐式 TLS（SMTPS），通常使用 465 端口
+	EmailSecurityNone     = "none"     // 不加密
+)
+
 // EmailConfig 邮件通知配置
 type EmailConfig struct {
 	SMTPHost     string   `json:"smtp_host"`
@@ -15,8 +54,12 @@ type EmailConfig struct {
 	Password     string   `json:"password"`
 	From         string   `json:"from"`
 	To           []string `json:"to"`
+	Cc           []string `json:"cc,omitempty"`
+	Bcc          []string `json:"bcc,omitempty"`
 	Subject      string   `json:"subject,omitempty"`
-	EnableTLS    bool     `json:"enable_tls"`
+	Security     string   `json:"security,omitempty"` // 加密方式: starttls, tls, none，为空时按 EnableTLS 和端口推断
+	EnableTLS    bool     `json:"enable_tls"`         // 已废弃，保留用于兼容旧配置
+	AttachOutput bool     `json:"attach_output,omitempty"` // 是否将任务输出作为附件发送
 }
 
 // DingTalkConfig 钉钉通知配置
@@ -34,6 +77,35 @@ type WeChatConfig struct {
 	AtAll      bool     `json:"at_all,omitempty"`
 }
 
+// WebhookConfig 通用 Webhook 通知配置
+type WebhookConfig struct {
+	URL          string            `json:"url"`
+	Method       string            `json:"method,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	BodyTemplate string            `json:"body_template,omitempty"` // 请求体模板，Go text/template 语法，渲染结果需为 JSON
+}
+
+// SlackConfig Slack Incoming Webhook 通知配置
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Channel    string `json:"channel,omitempty"`
+	Username   string `json:"username,omitempty"`
+	IconEmoji  string `json:"icon_emoji,omitempty"`
+}
+
+// FeishuConfig 飞书/Lark 机器人通知配置
+type FeishuConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Secret     string `json:"secret,omitempty"`
+}
+
+// TelegramConfig Telegram 机器人通知配置
+type TelegramConfig struct {
+	BotToken string `json:"bot_token"`
+	ChatID   string `json:"chat_id"`
+	APIURL   string `json:"api_url,omitempty"` // Bot API 地址，默认 https://api.telegram.org
+}
+
 // NotificationMessage 通知消息
 type NotificationMessage struct {
 	TaskName    string `json:"task_name"`
@@ -43,4 +115,15 @@ type NotificationMessage struct {
 	Duration    string `json:"duration"`
 	Output      string `json:"output,omitempty"`
 	Error       string `json:"error,omitempty"`
+	Anomalies   []string `json:"anomalies,omitempty"` // 异常检测结果
+	Alert       *AlertInfo `json:"alert,omitempty"`   // 告警信息：开始告警、重复告警或恢复
+
+	// 以下字段仅供消息模板使用
+	Task       *Task           `json:"-"` // 执行的任务
+	Run        *TaskLog        `json:"-"` // 本次执行日志
+	Statistics *TaskStatistics `json:"-"` // 任务历史执行统计
+
+	// 按渠道模板渲染后的标题和内容，内容格式由渠道决定
+	Title   string `json:"-"`
+	Content string `json:"-"`
 }
\ No newline at end of file
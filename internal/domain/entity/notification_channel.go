@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// MaskedValue 接口响应中敏感配置的掩码
+const MaskedValue = "******"
+
+// NotificationChannel 通知渠道，多个任务、模板和报表摘要可共享同一个渠道
+type NotificationChannel struct {
+	ID              int       `json:"id" gorm:"primaryKey"`
+	Name            string    `json:"name" gorm:"not null;uniqueIndex"`
+	Type            string    `json:"type" gorm:"not null"`              // 渠道类型: email, dingtalk, wechat 等
+	Config          string    `json:"config"`                            // 渠道配置，JSON格式存储，接口响应中敏感字段会被掩码
+	Language        string    `json:"language" gorm:"default:'zh'"`      // 默认消息模板语言: zh, en
+	MessageTemplate string    `json:"message_template" gorm:"type:text"` // 渠道消息模板，JSON格式存储，为空时使用默认模板
+	Timeout         int       `json:"timeout" gorm:"default:10"`         // 单次发送超时时间（秒）
+	MaxAttempts     int       `json:"max_attempts" gorm:"default:4"`     // 最多尝试次数，包含首次发送，1 表示不重试
+	Description     string    `json:"description"`
+	ProjectID       *uint     `json:"project_id" gorm:"index"` // 所属项目，为空时为全局渠道，所有任务都可以使用
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (NotificationChannel) TableName() string {
+	return "notification_channels"
+}
+
+// ParseChannelIDs 解析 JSON 格式存储的通知渠道ID列表
+func ParseChannelIDs(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var ids []int
+	if err := json.Unmarshal([]byte(value), &ids); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// FormatChannelIDs 将通知渠道ID列表格式化为 JSON，列表为空时返回空字符串
+func FormatChannelIDs(ids []int) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(ids)
+	return string(data)
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// 通知发送状态
+const (
+	DeliveryPending = "pending" // 等待发送或等待重试
+	DeliverySending = "sending" // 发送中
+	DeliverySuccess = "success" // 发送成功
+	DeliveryFailed  = "failed"  // 重试次数用尽，发送失败
+)
+
+// NotificationDelivery 通知发送记录，同时作为发送队列：每个任务执行结果向每个渠道发送一条
+type NotificationDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	TaskID        int        `json:"task_id" gorm:"index"`
+	TaskLogID     uint       `json:"task_log_id" gorm:"index"` // 关联的执行日志
+	ChannelID     int        `json:"channel_id" gorm:"index"`
+	ChannelName   string     `json:"channel_name"`
+	ChannelType   string     `json:"channel_type"`
+	Title         string     `json:"title"`                    // 渲染后的标题
+	Content       string     `json:"content" gorm:"type:text"` // 渲染后的内容
+	Message       string     `json:"-" gorm:"type:text"`       // 通知消息，JSON格式存储
+	Status        string     `json:"status" gorm:"not null;index:idx_notification_deliveries_due,priority:1"`
+	Attempts      int        `json:"attempts"`     // 已尝试次数
+	MaxAttempts   int        `json:"max_attempts"` // 最多尝试次数，包含首次发送
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_notification_deliveries_due,priority:2"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	Response      string     `json:"response" gorm:"type:text"` // 最近一次发送的响应摘要
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (NotificationDelivery) TableName() string {
+	return "notification_deliveries"
+}
+
+// Finished 是否已结束（发送成功或重试次数用尽）
+func (d *NotificationDelivery) Finished() bool {
+	return d.Status == DeliverySuccess || d.Status == DeliveryFailed
+}
@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// Project 项目（命名空间），用于隔离不同团队的任务、模板、通知渠道和执行日志
+type Project struct {
+	ID                uint      `json:"id" gorm:"primaryKey"`
+	Name              string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description       string    `json:"description"`
+	MaxTasks          int       `json:"max_tasks" gorm:"default:0"`           // 项目中最多可创建的任务数，0 表示不限制
+	MaxConcurrentRuns int       `json:"max_concurrent_runs" gorm:"default:0"` // 项目中同时执行的任务数上限，0 表示不限制
+	Role              string    `json:"role,omitempty" gorm:"-"`              // 当前用户在项目中的角色，仅在接口响应中返回
+	TaskCount         int64     `json:"task_count" gorm:"-"`                  // 项目中的任务数
+	RunningTasks      int       `json:"running_tasks" gorm:"-"`               // 项目中正在执行的任务数
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func (Project) TableName() string {
+	return "projects"
+}
+
+// ProjectMember 项目成员，Role 为成员在项目中的角色（admin, operator, viewer）
+type ProjectMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProjectID uint      `json:"project_id" gorm:"uniqueIndex:idx_project_member;not null"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_project_member;not null"`
+	Role      string    `json:"role" gorm:"not null"`
+	User      *User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (ProjectMember) TableName() string {
+	return "project_members"
+}
+
+// ProjectRolePermission 项目角色对项目中所有任务拥有的权限：项目管理员可以管理，操作员可以修改和执行，只读成员可以查看
+func ProjectRolePermission(role string) string {
+	switch role {
+	case RoleAdmin:
+		return PermissionManage
+	case RoleOperator:
+		return PermissionEdit
+	case RoleViewer:
+		return PermissionView
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,69 @@
+package entity
+
+import "time"
+
+// 报表摘要周期
+const (
+	DigestPeriodDaily  = "daily"
+	DigestPeriodWeekly = "weekly"
+)
+
+// ReportDigest 定期报表摘要配置，按计划汇总执行报表并通过通知渠道发送
+type ReportDigest struct {
+	ID                     int        `json:"id" gorm:"primaryKey"`
+	Name                   string     `json:"name" gorm:"not null"`
+	Period                 string     `json:"period" gorm:"not null;default:'daily'"` // 统计周期: daily, weekly
+	Schedule               string     `json:"schedule"`                               // 发送时间，Cron表达式，为空时按周期使用默认值
+	Enabled                bool       `json:"enabled" gorm:"default:true"`
+	TopN                   int        `json:"top_n" gorm:"default:5"`   // 失败最多、最慢任务各列出的数量
+	NotificationTypes      string     `json:"notification_types"`       // 通知类型，JSON格式存储 ["email", "dingtalk", "wechat"]
+	NotificationConfig     string     `json:"notification_config"`      // 通知配置，JSON格式存储
+	NotificationChannelIDs string     `json:"notification_channel_ids"` // 通知渠道ID，JSON格式存储 [1, 2]
+	LastSentAt             *time.Time `json:"last_sent_at"`             // 最后发送时间
+	CreatedAt              time.Time  `json:"created_at"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+func (ReportDigest) TableName() string {
+	return "report_digests"
+}
+
+// DefaultSchedule 周期对应的默认发送时间：每天9点，或每周一9点
+func (d *ReportDigest) DefaultSchedule() string {
+	if d.Period == DigestPeriodWeekly {
+		return "0 9 * * 1"
+	}
+	return "0 9 * * *"
+}
+
+// PeriodDuration 统计周期长度
+func (d *ReportDigest) PeriodDuration() time.Duration {
+	if d.Period == DigestPeriodWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// DigestPeriodSummary 统计周期概览
+type DigestPeriodSummary struct {
+	StartTime         time.Time `json:"start_time"`
+	EndTime           time.Time `json:"end_time"`
+	ActiveTasks       int64     `json:"active_tasks"`       // 有执行记录的任务数
+	TotalExecutions   int64     `json:"total_executions"`   // 总执行次数
+	FailureExecutions int64     `json:"failure_executions"` // 失败次数
+	SuccessRate       float64   `json:"success_rate"`       // 成功率 (%)
+}
+
+// DigestReport 报表摘要内容
+type DigestReport struct {
+	Name              string              `json:"name"`
+	Period            string              `json:"period"`
+	TotalTasks        int64               `json:"total_tasks"`         // 总任务数
+	Current           DigestPeriodSummary `json:"current"`             // 本期概览
+	Previous          DigestPeriodSummary `json:"previous"`            // 上期概览
+	ExecutionsChange  *float64            `json:"executions_change"`   // 执行次数环比变化 (%)，上期无执行时为空
+	SuccessRateChange *float64            `json:"success_rate_change"` // 成功率变化 (百分点)，任一期无执行时为空
+	TopFailures       []TaskStatistics    `json:"top_failures"`        // 失败次数最多的任务
+	SlowestTasks      []TaskStatistics    `json:"slowest_tasks"`       // 平均执行时间最长的任务
+	Trends            []ExecutionTrend    `json:"trends"`              // 本期每日趋势
+}
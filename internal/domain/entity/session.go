@@ -0,0 +1,33 @@
+package entity
+
+import "time"
+
+// Session 登录会话。每次登录创建一个会话，访问令牌（JWT）携带会话 ID，会话被撤销后访问令牌立即失效。
+// 刷新令牌每次使用后轮换，只保存哈希
+type Session struct {
+	ID                uint       `json:"id" gorm:"primaryKey"`
+	UserID            uint       `json:"user_id" gorm:"index;not null"`
+	RefreshTokenHash  string     `json:"-" gorm:"uniqueIndex;not null"`
+	PreviousTokenHash string     `json:"-" gorm:"index"` // 上一个刷新令牌，再次使用说明令牌可能已泄露
+	UserAgent         string     `json:"user_agent"`
+	IP                string     `json:"ip"`
+	ExpiresAt         time.Time  `json:"expires_at"` // 刷新令牌过期时间
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+	Current           bool       `json:"current" gorm:"-"` // 是否为当前请求使用的会话
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+// Active 会话是否未撤销且刷新令牌未过期
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshRequest 刷新访问令牌请求
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
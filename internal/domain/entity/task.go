@@ -1,20 +1,50 @@
 package entity
 
+// 任务类型
+const (
+	TaskTypeCommand   = "command"   // 按计划执行命令或HTTP请求
+	TaskTypeHeartbeat = "heartbeat" // 不执行命令，按计划等待外部任务上报心跳
+)
+
 type Task struct {
 	ID                int    `json:"id" gorm:"primaryKey"`
 	Name              string `json:"name" gorm:"not null"`
 	Schedule          string `json:"schedule" gorm:"not null"`
 	Command           string `json:"command" gorm:"not null"`
+	Type              string `json:"type" gorm:"default:'command'"`               // 任务类型: command, heartbeat
+	PingToken         string `json:"-" gorm:"index"`                               // 心跳任务的上报令牌，只在创建任务和重新生成时返回
+	GracePeriod       int    `json:"grace_period" gorm:"default:300"`              // 心跳宽限时间（秒），超过计划时间该时长仍未收到心跳视为错过
 	Method            string `json:"method" gorm:"default:'GET'"` // HTTP请求方法
 	Headers           string `json:"headers"`                    // HTTP请求头，JSON格式存储
 	Enabled           bool   `json:"enabled" gorm:"default:true"`
 	Description       string `json:"description"`
 	NotifyOnSuccess   bool   `json:"notify_on_success" gorm:"default:false"`   // 成功时是否通知
 	NotifyOnFailure   bool   `json:"notify_on_failure" gorm:"default:true"`    // 失败时是否通知
+	NotifyOnAnomaly   bool   `json:"notify_on_anomaly" gorm:"default:false"`   // 检测到异常执行时是否通知
 	NotificationTypes string `json:"notification_types"`                       // 通知类型，JSON格式存储 ["email", "dingtalk", "wechat"]
 	NotificationConfig string `json:"notification_config"`                     // 通知配置，JSON格式存储
+	NotificationChannelIDs string `json:"notification_channel_ids"` // 通知渠道ID，JSON格式存储 [1, 2]
+	NotificationTemplate string `json:"notification_template" gorm:"type:text"` // 任务级通知消息模板，JSON格式存储，优先于渠道模板
+	AlertMode         string `json:"alert_mode" gorm:"default:'every'"`         // 告警通知模式: every 每次失败都通知, state_change 仅状态变化时通知
+	AlertThreshold    int    `json:"alert_threshold" gorm:"default:1"`          // 连续失败多少次后开始告警
+	AlertCooldown     int    `json:"alert_cooldown" gorm:"default:0"`           // 重复通知的冷却时间（秒），0 表示不限制
+	NotifyOnRecovery  bool   `json:"notify_on_recovery" gorm:"default:false"`   // 告警恢复时是否发送恢复通知
+	EscalationPolicyID *int  `json:"escalation_policy_id" gorm:"index"`         // 告警升级策略ID，为空时不升级
+	OwnerID           *uint  `json:"owner_id" gorm:"index"`                     // 任务所有者的用户ID
+	ProjectID         *uint  `json:"project_id" gorm:"index"`                   // 所属项目，为空时不属于任何项目
+	Permission        string `json:"permission,omitempty" gorm:"-"`             // 当前用户对任务的权限，仅在接口响应中返回
 }
 
 func (Task) TableName() string {
 	return "tasks"
+}
+
+// IsOwnedBy 任务是否属于该用户
+func (t *Task) IsOwnedBy(userID uint) bool {
+	return t.OwnerID != nil && *t.OwnerID == userID
+}
+
+// IsHeartbeat 是否为心跳任务
+func (t *Task) IsHeartbeat() bool {
+	return t.Type == TaskTypeHeartbeat
 }
\ No newline at end of file
@@ -0,0 +1,68 @@
+package entity
+
+import "time"
+
+// 告警通知模式
+const (
+	AlertModeEvery       = "every"        // 每次失败都通知，可配合冷却时间限流
+	AlertModeStateChange = "state_change" // 仅在状态变化时通知：开始失败和恢复
+)
+
+// 告警状态
+const (
+	AlertStatusOK     = "ok"     // 正常，或连续失败次数未达到阈值
+	AlertStatusFiring = "firing" // 告警中
+)
+
+// 告警事件，决定通知消息的类型
+const (
+	AlertEventFiring   = "firing"   // 开始告警
+	AlertEventRepeat   = "repeat"   // 告警期间再次通知
+	AlertEventResolved = "resolved" // 告警恢复
+	AlertEventEscalate = "escalate" // 按升级策略通知
+)
+
+// TaskAlertState 任务告警状态，每个任务一条，用于通知去重、限流、恢复通知和告警升级
+type TaskAlertState struct {
+	TaskID              int        `json:"task_id" gorm:"primaryKey;autoIncrement:false"`
+	Status              string     `json:"status" gorm:"default:'ok'"`
+	ConsecutiveFailures int        `json:"consecutive_failures"` // 当前连续失败次数
+	FailingSince        *time.Time `json:"failing_since"`        // 本轮连续失败的首次失败时间
+	FiringSince         *time.Time `json:"firing_since"`         // 开始告警的时间
+	LastNotifiedAt      *time.Time `json:"last_notified_at"`     // 最近一次发送通知的时间
+	LastNotifiedEvent   string     `json:"last_notified_event"`  // 最近一次通知的类型: firing, repeat, resolved, success
+	Suppressed          int        `json:"suppressed"`           // 自上次通知以来被抑制的通知数
+	ResolvedAt          *time.Time `json:"resolved_at"`          // 最近一次恢复的时间
+	EscalationLevel     int        `json:"escalation_level"`     // 本轮告警已执行的升级步骤数
+	AcknowledgedAt      *time.Time `json:"acknowledged_at"`      // 确认告警的时间，确认后不再升级
+	AcknowledgedBy      string     `json:"acknowledged_by"`      // 确认告警的用户
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+func (TaskAlertState) TableName() string {
+	return "task_alert_states"
+}
+
+// AlertInfo 通知消息中的告警信息，供消息模板使用
+type AlertInfo struct {
+	Event               string `json:"event"`                      // firing, repeat, resolved, escalate
+	EscalationLevel     int    `json:"escalation_level,omitempty"` // 升级级别，从 1 开始，仅升级通知中有值
+	ConsecutiveFailures int    `json:"consecutive_failures"`       // 连续失败次数，恢复通知中为恢复前的连续失败次数
+	FailingSince        string `json:"failing_since"`              // 本轮连续失败开始时间
+	Suppressed          int    `json:"suppressed,omitempty"`       // 自上次通知以来省略的通知数
+}
+
+// Resolved 是否为恢复通知
+func (a *AlertInfo) Resolved() bool {
+	return a.Event == AlertEventResolved
+}
+
+// Escalated 是否为升级通知
+func (a *AlertInfo) Escalated() bool {
+	return a.Event == AlertEventEscalate
+}
+
+// Acknowledged 告警是否已被确认
+func (s *TaskAlertState) Acknowledged() bool {
+	return s.AcknowledgedAt != nil
+}
@@ -0,0 +1,68 @@
+package entity
+
+import "time"
+
+// 任务权限，按级别从低到高，高级别包含低级别的权限
+const (
+	PermissionView    = "view"    // 查看任务、执行日志和统计
+	PermissionExecute = "execute" // 手动执行和取消执行任务，确认和重置告警
+	PermissionEdit    = "edit"    // 修改任务
+	PermissionManage  = "manage"  // 删除任务和管理授权，任务所有者和管理员拥有该权限
+)
+
+var permissionLevels = map[string]int{
+	PermissionView:    1,
+	PermissionExecute: 2,
+	PermissionEdit:    3,
+	PermissionManage:  4,
+}
+
+// PermissionAllows 权限 granted 是否包含 required
+func PermissionAllows(granted, required string) bool {
+	return granted != "" && permissionLevels[granted] >= permissionLevels[required]
+}
+
+// MaxPermission 返回两个权限中较高的一个
+func MaxPermission(a, b string) string {
+	if permissionLevels[a] >= permissionLevels[b] {
+		return a
+	}
+	return b
+}
+
+// MinPermission 返回两个权限中较低的一个
+func MinPermission(a, b string) string {
+	if permissionLevels[a] <= permissionLevels[b] {
+		return a
+	}
+	return b
+}
+
+// ValidGrantPermission 是否为可授予的权限，manage 只属于任务所有者和管理员
+func ValidGrantPermission(permission string) bool {
+	return permission == PermissionView || permission == PermissionExecute || permission == PermissionEdit
+}
+
+// TaskGrant 任务授权，授予用户或用户组对任务的权限
+type TaskGrant struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TaskID     int       `json:"task_id" gorm:"index;not null"`
+	UserID     *uint     `json:"user_id,omitempty" gorm:"index"`  // 被授权的用户，与 GroupID 二选一
+	GroupID    *uint     `json:"group_id,omitempty" gorm:"index"` // 被授权的用户组
+	Permission string    `json:"permission" gorm:"not null"`      // view, execute, edit
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (TaskGrant) TableName() string {
+	return "task_grants"
+}
+
+// TaskScope 用户可访问的任务范围：自己拥有的任务、被授权的任务和所属项目中的任务。
+// 为 nil 时表示不限制（管理员未选择项目）
+type TaskScope struct {
+	Unrestricted bool // 不限制可见范围（管理员），只按 ProjectID 筛选
+	OwnerID      uint
+	TaskIDs      []int
+	ProjectIDs   []uint // 用户所属的项目
+	ProjectID    *uint  // 项目选择器，只返回该项目中的任务
+}
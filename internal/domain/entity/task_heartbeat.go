@@ -0,0 +1,48 @@
+package entity
+
+import "time"
+
+// 心跳上报状态
+const (
+	HeartbeatStateStart   = "start"   // 外部任务开始执行
+	HeartbeatStateSuccess = "success" // 外部任务执行成功
+	HeartbeatStateFail    = "fail"    // 外部任务执行失败
+)
+
+// TaskHeartbeat 心跳任务的监控状态，每个心跳任务一条
+type TaskHeartbeat struct {
+	TaskID        int        `json:"task_id" gorm:"primaryKey;autoIncrement:false"`
+	Schedule      string     `json:"schedule"`        // 计算 ExpectedAt 时使用的 Cron 表达式，任务调度变化时重新计算
+	ExpectedAt    *time.Time `json:"expected_at"`     // 下一次期望收到心跳的计划时间，为空时尚未开始监控
+	StartedAt     *time.Time `json:"started_at"`      // 收到开始心跳的时间，收到结束心跳前不为空
+	LastPingAt    *time.Time `json:"last_ping_at"`    // 最近一次收到心跳的时间
+	LastPingState string     `json:"last_ping_state"` // 最近一次心跳的状态: start, success, fail
+	LastMissedAt  *time.Time `json:"last_missed_at"`  // 最近一次判定错过心跳的时间
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (TaskHeartbeat) TableName() string {
+	return "task_heartbeats"
+}
+
+// Deadline 当前等待的心跳截止时间：已开始时为开始时间加宽限时间，否则为计划时间加宽限时间
+func (h *TaskHeartbeat) Deadline(gracePeriod int) *time.Time {
+	base := h.ExpectedAt
+	if h.StartedAt != nil {
+		base = h.StartedAt
+	}
+	if base == nil {
+		return nil
+	}
+	deadline := base.Add(time.Duration(gracePeriod) * time.Second)
+	return &deadline
+}
+
+// ValidHeartbeatState 是否为有效的心跳上报状态
+func ValidHeartbeatState(state string) bool {
+	switch state {
+	case HeartbeatStateStart, HeartbeatStateSuccess, HeartbeatStateFail:
+		return true
+	}
+	return false
+}
@@ -1,22 +1,131 @@
 package entity
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
+// 任务触发来源
+const (
+	TriggerSchedule  = "schedule"  // 定时调度
+	TriggerManual    = "manual"    // 手动执行
+	TriggerHeartbeat = "heartbeat" // 外部心跳上报或错过心跳
+)
+
 // TaskLog 任务执行日志
 type TaskLog struct {
 	ID        uint      `gorm:"primaryKey"`
-	TaskID    int       `gorm:"not null"` // 关联的任务ID
-	TaskName  string    `gorm:"not null"` // 任务名称（冗余存储，便于查询）
-	StartTime time.Time `gorm:"not null"` // 任务开始执行时间
-	EndTime   time.Time `gorm:"not null"` // 任务执行结束时间
-	Success   bool      `gorm:"not null"` // 执行是否成功
-	Output    string    `gorm:"type:text"` // 任务输出
-	Error     string    `gorm:"type:text"` // 错误信息（如果有的话）
+	TaskID    int       `gorm:"not null;index:idx_task_logs_task_start,priority:1"`       // 关联的任务ID
+	TaskName  string    `gorm:"not null"`                                                 // 任务名称（冗余存储，便于查询）
+	StartTime time.Time `gorm:"not null;index;index:idx_task_logs_task_start,priority:2"` // 任务开始执行时间
+	EndTime   time.Time `gorm:"not null"`                                                 // 任务执行结束时间
+	Success   bool      `gorm:"not null"`                                                 // 执行是否成功
+	Output    string    `gorm:"type:text"`                                                // 任务输出
+	Error     string    `gorm:"type:text"`                                                // 错误信息（如果有的话）
+
+	TriggerSource string `gorm:"default:'schedule';index"` // 触发来源: schedule, manual, heartbeat
 }
 
 // TableName 设置表名
 func (TaskLog) TableName() string {
 	return "task_logs"
-}
\ No newline at end of file
+}
+
+// TaskLogFilter 任务日志查询条件，所有字段均为可选
+type TaskLogFilter struct {
+	TaskID        *int       // 特定任务ID
+	TaskIDs       []int      // 多个任务ID，为 nil 时不限制，为空列表时不匹配任何日志
+	Success       *bool      // 执行状态
+	TriggerSource string     // 触发来源
+	StartTime     *time.Time // 开始时间下限（包含）
+	EndTime       *time.Time // 开始时间上限（包含）
+
+	MinDuration *float64 // 最短执行时间 (秒)
+	MaxDuration *float64 // 最长执行时间 (秒)
+	Keyword     string   // 在输出和错误信息中全文搜索
+	Limit       int      // 返回的最大条数，0 表示不限制
+}
+
+// 日志排序字段
+const (
+	LogSortByStartTime = "start_time"
+	LogSortByDuration  = "duration"
+	LogSortByID        = "id"
+)
+
+// TaskLogSearchRequest 日志搜索请求，支持页码分页和游标分页，提供游标时忽略页码
+type TaskLogSearchRequest struct {
+	TaskLogFilter
+	SortBy   string         // 排序字段: start_time, duration, id
+	SortDesc bool           // 是否倒序
+	Cursor   *TaskLogCursor // 游标，为空时使用页码分页
+	Page     int
+	PageSize int
+}
+
+// TaskLogSearchResult 日志搜索结果
+type TaskLogSearchResult struct {
+	Logs       []TaskLog `json:"logs"`
+	Total      *int64    `json:"total,omitempty"` // 游标分页时不统计总数
+	Page       int       `json:"page"`
+	PageSize   int       `json:"pageSize"`
+	NextCursor string    `json:"next_cursor,omitempty"` // 下一页游标
+	HasMore    bool      `json:"has_more"`
+}
+
+// TaskLogCursor 日志游标，记录上一页最后一条日志的排序值和ID
+type TaskLogCursor struct {
+	SortBy    string     `json:"s"`
+	SortDesc  bool       `json:"d"`
+	StartTime *time.Time `json:"t,omitempty"`
+	Duration  *float64   `json:"v,omitempty"`
+	ID        uint       `json:"i"`
+}
+
+// Encode 编码游标
+func (c *TaskLogCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeTaskLogCursor 解码游标
+func DecodeTaskLogCursor(value string) (*TaskLogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("无效的游标")
+	}
+
+	var cursor TaskLogCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.New("无效的游标")
+	}
+	if (cursor.SortBy == LogSortByStartTime && cursor.StartTime == nil) ||
+		(cursor.SortBy == LogSortByDuration && cursor.Duration == nil) {
+		return nil, errors.New("无效的游标")
+	}
+	return &cursor, nil
+}
+
+// TaskLogAggregate 按任务聚合的日志统计
+type TaskLogAggregate struct {
+	TaskID             int
+	TotalExecutions    int64
+	SuccessExecutions  int64
+	FailureExecutions  int64
+	AvgDuration        float64    // 平均执行时间 (秒)
+	MinDuration        float64    // 最短执行时间 (秒)
+	MaxDuration        float64    // 最长执行时间 (秒)
+	AvgSquaredDuration float64    // 执行时间平方的平均值，用于计算标准差
+	LastStartTime      *time.Time // 最后执行时间
+	LastSuccess        bool       // 最后执行状态
+}
+
+// TaskLogBucket 按时间段（天/小时）聚合的日志统计
+type TaskLogBucket struct {
+	Bucket          string // 日期 (YYYY-MM-DD) 或小时 (00-23)
+	TotalExecutions int64
+	SuccessCount    int64
+	FailureCount    int64
+}
@@ -44,6 +44,62 @@ type TaskPerformanceMetrics struct {
 	AverageExecutionTime float64 `json:"average_execution_time"` // 平均执行时间 (秒)
 	MedianExecutionTime float64 `json:"median_execution_time"`  // 中位数执行时间 (秒)
 	ExecutionTimeStdDev float64 `json:"execution_time_std_dev"` // 执行时间标准差
+	P90ExecutionTime    float64 `json:"p90_execution_time"`     // P90执行时间 (秒)
+	P95ExecutionTime    float64 `json:"p95_execution_time"`     // P95执行时间 (秒)
+	P99ExecutionTime    float64 `json:"p99_execution_time"`     // P99执行时间 (秒)
+	Baseline            *TaskBaseline `json:"baseline,omitempty"` // 统计范围之前的执行基线
+	Anomalies           []TaskAnomaly `json:"anomalies"`          // 统计范围内的异常执行
+}
+
+// TaskBaseline 任务执行基线，由统计范围之前一段时间内的执行记录计算
+type TaskBaseline struct {
+	TaskID         int       `json:"task_id"`
+	SampleSize     int64     `json:"sample_size"`      // 基线样本数
+	MeanDuration   float64   `json:"mean_duration"`    // 平均执行时间 (秒)
+	StdDevDuration float64   `json:"std_dev_duration"` // 执行时间标准差
+	FailureRate    float64   `json:"failure_rate"`     // 失败率 (0-1)
+	WindowStart    time.Time `json:"window_start"`     // 基线窗口开始时间
+	WindowEnd      time.Time `json:"window_end"`       // 基线窗口结束时间
+}
+
+// 异常类型
+const (
+	AnomalyTypeDuration    = "duration"     // 执行时间异常
+	AnomalyTypeFailureRate = "failure_rate" // 失败率异常
+)
+
+// TaskAnomaly 异常执行
+type TaskAnomaly struct {
+	TaskID    int        `json:"task_id"`
+	TaskName  string     `json:"task_name"`
+	LogID     uint       `json:"log_id,omitempty"`    // 异常执行的日志ID，失败率异常时为空
+	Type      string     `json:"type"`                // 异常类型: duration, failure_rate
+	Value     float64    `json:"value"`               // 实际值（执行时间秒数或失败率）
+	Baseline  float64    `json:"baseline"`            // 基线值
+	Threshold float64    `json:"threshold"`           // 判定阈值
+	Deviation float64    `json:"deviation"`           // 偏离基线的标准差倍数
+	StartTime *time.Time `json:"start_time,omitempty"` // 异常执行的开始时间
+	Message   string     `json:"message"`             // 异常描述
+}
+
+// AnomalyConfig 异常检测配置
+type AnomalyConfig struct {
+	Sigma        float64 `json:"sigma"`         // 超出基线多少个标准差视为异常
+	BaselineDays int     `json:"baseline_days"` // 基线窗口天数
+	MinSamples   int64   `json:"min_samples"`   // 基线最少样本数，不足时不做检测
+	RecentRuns   int     `json:"recent_runs"`   // 计算近期失败率时使用的执行次数
+	MaxAnomalies int     `json:"max_anomalies"` // 每个任务最多返回的异常执行数
+}
+
+// NewAnomalyConfig 创建异常检测配置
+func NewAnomalyConfig() *AnomalyConfig {
+	return &AnomalyConfig{
+		Sigma:        3,
+		BaselineDays: 7,
+		MinSamples:   10,
+		RecentRuns:   10,
+		MaxAnomalies: 20,
+	}
 }
 
 // HourlyExecutionStats 小时执行统计
@@ -57,14 +113,17 @@ type HourlyExecutionStats struct {
 // StatisticsRequest 统计请求参数
 type StatisticsRequest struct {
 	TaskID    *int       `json:"task_id,omitempty"`    // 可选，特定任务ID
+	TaskIDs   []int      `json:"-"`                    // 按用户权限限定的任务范围，为 nil 时不限制
 	StartDate *time.Time `json:"start_date,omitempty"` // 开始日期
 	EndDate   *time.Time `json:"end_date,omitempty"`   // 结束日期
 	Days      int        `json:"days,omitempty"`       // 最近N天，默认30天
+	Anomaly   *AnomalyConfig `json:"anomaly,omitempty"` // 异常检测配置
 }
 
 // NewStatisticsRequest 创建统计请求
 func NewStatisticsRequest() *StatisticsRequest {
 	return &StatisticsRequest{
-		Days: 30, // 默认30天
+		Days:    30, // 默认30天
+		Anomaly: NewAnomalyConfig(),
 	}
 }
\ No newline at end of file
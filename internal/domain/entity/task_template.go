@@ -16,9 +16,11 @@ type TaskTemplate struct {
 	NotifyOnFailure    bool      `json:"notify_on_failure" gorm:"default:true"`  // 失败时是否通知
 	NotificationTypes  string    `json:"notification_types"`                     // 通知类型，JSON格式存储
 	NotificationConfig string    `json:"notification_config"`                    // 通知配置，JSON格式存储
+	NotificationChannelIDs string `json:"notification_channel_ids"`   // 通知渠道ID，JSON格式存储
 	Tags               string    `json:"tags"`                                    // 标签，JSON格式存储
 	IsPublic           bool      `json:"is_public" gorm:"default:false"`         // 是否为公共模板
 	CreatedBy          int       `json:"created_by"`                              // 创建者ID
+	ProjectID          *uint     `json:"project_id" gorm:"index"`                 // 所属项目，项目模板只对项目成员可见
 	UsageCount         int       `json:"usage_count" gorm:"default:0"`           // 使用次数
 	CreatedAt          time.Time `json:"created_at"`
 	UpdatedAt          time.Time `json:"updated_at"`
@@ -48,6 +50,7 @@ type CreateTaskFromTemplateRequest struct {
 	TaskName    string                 `json:"task_name" binding:"required"`
 	Overrides   map[string]interface{} `json:"overrides,omitempty"` // 覆盖的字段
 	Enabled     bool                   `json:"enabled"`
+	ProjectID   *uint                  `json:"project_id,omitempty"` // 任务所属项目，为空时使用模板所属的项目
 }
 
 // TaskTemplateSearchRequest 模板搜索请求
@@ -59,6 +62,9 @@ type TaskTemplateSearchRequest struct {
 	Tags       string `json:"tags,omitempty"`
 	Page       int    `json:"page,omitempty"`
 	PageSize   int    `json:"page_size,omitempty"`
+	VisibleTo  *int   `json:"-" form:"-"` // 只返回公共模板和该用户创建的模板，为空时不限制
+	VisibleProjects []uint `json:"-" form:"-"` // 与 VisibleTo 一起使用，还返回这些项目中的模板
+	ProjectID  *uint  `json:"-" form:"-"` // 只返回该项目中的模板
 }
 
 // TaskTemplateWithStats 带统计信息的任务模板
@@ -2,32 +2,152 @@ package entity
 
 import "time"
 
+// 用户角色，按权限从低到高
+const (
+	RoleViewer   = "viewer"   // 只读用户，只能查看自己拥有或被授权的任务
+	RoleOperator = "operator" // 操作员，可以创建任务，管理自己拥有或被授权的任务
+	RoleAdmin    = "admin"    // 管理员，可以管理所有任务、用户和系统配置
+)
+
+var roleLevels = map[string]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+}
+
+// ValidRole 是否为有效的用户角色
+func ValidRole(role string) bool {
+	_, ok := roleLevels[role]
+	return ok
+}
+
+// 注册模式
+const (
+	RegistrationOpen     = "open"     // 任何人都可以注册，注册后立即可用
+	RegistrationApproval = "approval" // 注册后需要管理员审核，使用邀请码注册时无需审核
+	RegistrationInvite   = "invite"   // 只能使用邀请码注册
+	RegistrationDisabled = "disabled" // 关闭自助注册，只能由管理员创建用户
+)
+
+// 用户的认证来源
+const (
+	AuthProviderLocal = "local" // 本地账号密码
+	AuthProviderOIDC  = "oidc"  // OpenID Connect 单点登录
+	AuthProviderLDAP  = "ldap"  // LDAP / Active Directory 账号密码
+)
+
 // User 用户实体
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Username  string    `json:"username" gorm:"uniqueIndex;not null"`
-	Password  string    `json:"-" gorm:"not null"` // 不在JSON中返回密码
-	Email     string    `json:"email" gorm:"uniqueIndex"`
-	Role      string    `json:"role" gorm:"default:'user'"` // admin, user
-	IsActive  bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                 uint      `json:"id" gorm:"primaryKey"`
+	Username           string    `json:"username" gorm:"uniqueIndex;not null"`
+	Password           string    `json:"-" gorm:"not null"` // 不在JSON中返回密码
+	Email              string    `json:"email" gorm:"uniqueIndex"`
+	Role               string    `json:"role" gorm:"default:'viewer'"` // admin, operator, viewer
+	IsActive           bool      `json:"is_active" gorm:"default:true"`
+	PendingApproval    bool      `json:"pending_approval" gorm:"default:false"`      // 注册后等待管理员审核
+	MustChangePassword bool      `json:"must_change_password" gorm:"default:false"` // 下次登录后必须先修改密码，如默认管理员和被重置密码的用户
+	AuthProvider       string    `json:"auth_provider" gorm:"default:'local'"`      // 认证来源: local, oidc, ldap
+	ExternalID         string    `json:"-" gorm:"index"`                            // 在身份提供方中的用户标识，如 OIDC 的 sub、LDAP 的用户名
+	DirectoryDisabled  bool      `json:"directory_disabled" gorm:"default:false"`   // 因在用户目录中被禁用或删除而由同步禁用，目录中恢复后自动启用
+	TOTPEnabled        bool      `json:"totp_enabled" gorm:"default:false"`         // 已启用两步验证，账号密码登录时需要输入动态验证码
+	TOTPSecret         string    `json:"-"`                                         // TOTP 密钥，启用前为正在绑定的密钥
+	TOTPLastStep       int64     `json:"-"`                                         // 最后一次使用的验证码时间步，同一验证码不能重复使用
+	RecoveryCodes      string    `json:"-"`                                         // 未使用的恢复码哈希，JSON 数组
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
 }
 
 func (User) TableName() string {
 	return "users"
 }
 
+// HasRole 用户角色是否不低于 role
+func (u *User) HasRole(role string) bool {
+	return RoleAtLeast(u.Role, role)
+}
+
+// RoleAtLeast 角色 role 是否不低于 required
+func RoleAtLeast(role, required string) bool {
+	return role != "" && roleLevels[role] >= roleLevels[required]
+}
+
+// IsAdmin 是否为管理员
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// MaxTaskPermission 用户角色在任务上可拥有的最高权限，授权超出该权限时按该权限处理
+func (u *User) MaxTaskPermission() string {
+	return RoleMaxTaskPermission(u.Role)
+}
+
+// RoleMaxTaskPermission 角色在任务上可拥有的最高权限
+func RoleMaxTaskPermission(role string) string {
+	switch role {
+	case RoleAdmin, RoleOperator:
+		return PermissionManage
+	case RoleViewer:
+		return PermissionView
+	default:
+		return ""
+	}
+}
+
 // LoginRequest 登录请求
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	OTPCode  string `json:"otp_code"` // 启用两步验证时的动态验证码或恢复码
+}
+
+// IsLocal 是否为本地账号，外部身份提供方的用户不能使用密码登录
+func (u *User) IsLocal() bool {
+	return u.AuthProvider == "" || u.AuthProvider == AuthProviderLocal
+}
+
+// MapGroupRole 返回用户组映射的最高角色，没有匹配的用户组时返回 defaultRole
+func MapGroupRole(groups []string, mapping map[string]string, defaultRole string) string {
+	role := ""
+	for _, group := range groups {
+		mapped, ok := mapping[group]
+		if ok && (role == "" || RoleAtLeast(mapped, role)) {
+			role = mapped
+		}
+	}
+	if role == "" {
+		role = defaultRole
+	}
+	return role
+}
+
+// ExternalIdentity 外部身份提供方认证通过的用户身份
+type ExternalIdentity struct {
+	Provider      string // 认证来源，如 oidc、ldap
+	Subject       string // 在身份提供方中的唯一标识
+	Username      string
+	Email         string
+	EmailVerified bool   // 邮箱已验证时可以关联同邮箱的非管理员账号
+	LinkLocal     bool   // 是否直接关联同邮箱的本地账号，否则只关联管理员已切换到该认证来源、尚未绑定的账号
+	Role          string // 映射后的角色
+	SyncRole      bool   // 每次登录时是否用映射的角色更新已有用户的角色
+	AutoCreate    bool   // 用户不存在时是否自动创建
+}
+
+// DirectoryEntry 用户目录（LDAP / Active Directory）中的用户
+type DirectoryEntry struct {
+	DN       string
+	Username string
+	Email    string
+	Groups   []string // 所属用户组的名称
+	Disabled bool     // 账号在目录中已禁用，如 Active Directory 的 ACCOUNTDISABLE
 }
 
 // LoginResponse 登录响应
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token        string `json:"token"`         // 访问令牌
+	RefreshToken string `json:"refresh_token"` // 刷新令牌，访问令牌过期后用于获取新的令牌，每次使用后更换
+	ExpiresIn    int64  `json:"expires_in"`    // 访问令牌有效秒数
+	User         *User  `json:"user"`
 }
 
 // RegisterRequest 注册请求
@@ -35,4 +155,32 @@ type RegisterRequest struct {
 	Username string `json:"username" binding:"required,min=3,max=20"`
 	Password string `json:"password" binding:"required,min=6"`
 	Email    string `json:"email" binding:"required,email"`
+	InviteCode string `json:"invite_code"` // 邀请码，邀请注册模式下必填
+}
+
+// CreateUserRequest 管理员创建用户请求
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=3,max=20"`
+	Password string `json:"password" binding:"required,min=6"`
+	Email    string `json:"email" binding:"required,email"`
+	Role     string `json:"role"` // 默认为 viewer
+	// MustChangePassword 用户首次登录后是否必须修改密码
+	MustChangePassword bool `json:"must_change_password"`
+}
+
+// TOTPSetupResponse 绑定两步验证时返回的密钥，用户在验证器应用中添加后输入验证码完成启用
+type TOTPSetupResponse struct {
+	Secret string `json:"secret"` // Base32 编码的密钥，用于手动输入
+	URL    string `json:"url"`    // otpauth:// 地址，用于生成二维码
+}
+
+// TwoFactorCodeRequest 需要动态验证码或恢复码确认的请求
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// ChangePasswordRequest 修改密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
 }
\ No newline at end of file
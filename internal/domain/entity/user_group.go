@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// UserGroup 用户组，用于批量授予任务权限
+type UserGroup struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"uniqueIndex;not null"`
+	Description string    `json:"description"`
+	Members     []*User   `json:"members,omitempty" gorm:"many2many:user_group_members"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (UserGroup) TableName() string {
+	return "user_groups"
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+type APITokenRepository interface {
+	Create(token *entity.APIToken) error
+	FindByID(id uint) (*entity.APIToken, error)
+	FindByHash(hash string) (*entity.APIToken, error)
+	FindByUser(userID uint) ([]*entity.APIToken, error)
+	FindAll() ([]*entity.APIToken, error)
+	// Revoke 撤销令牌，已撤销的令牌保持原撤销时间
+	Revoke(id uint, at time.Time) error
+	// Touch 记录令牌的最近使用时间和来源 IP
+	Touch(id uint, at time.Time, ip string) error
+	DeleteByUser(userID uint) error
+}
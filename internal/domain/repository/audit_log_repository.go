@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// AuditLogRepository 审计日志仓库接口
+type AuditLogRepository interface {
+	Create(log *entity.AuditLog) error
+	// Find 按条件分页查询审计日志，按时间倒序，返回当前页和总数
+	Find(filter *entity.AuditLogFilter, offset, limit int) ([]*entity.AuditLog, int64, error)
+	// DeleteBefore 删除指定时间之前的审计日志，返回删除的条数
+	DeleteBefore(before time.Time) (int64, error)
+}
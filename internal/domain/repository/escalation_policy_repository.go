@@ -0,0 +1,13 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type EscalationPolicyRepository interface {
+	Create(policy *entity.EscalationPolicy) error
+	Update(policy *entity.EscalationPolicy) error
+	Delete(id int) error
+	FindByID(id int) (*entity.EscalationPolicy, error)
+	FindAll() ([]*entity.EscalationPolicy, error)
+	// CountReferences 统计引用升级策略的任务数量
+	CountReferences(id int) (int64, error)
+}
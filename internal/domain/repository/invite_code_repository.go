@@ -0,0 +1,12 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type InviteCodeRepository interface {
+	Create(code *entity.InviteCode) error
+	Delete(id uint) error
+	FindByCode(code string) (*entity.InviteCode, error)
+	FindAll() ([]*entity.InviteCode, error)
+	// MarkUsed 将未使用的邀请码标记为已被用户使用，邀请码已被使用时返回 false
+	MarkUsed(id, userID uint) (bool, error)
+}
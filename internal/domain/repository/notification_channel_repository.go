@@ -0,0 +1,15 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type NotificationChannelRepository interface {
+	Create(channel *entity.NotificationChannel) error
+	Update(channel *entity.NotificationChannel) error
+	Delete(id int) error
+	FindByID(id int) (*entity.NotificationChannel, error)
+	FindByIDs(ids []int) ([]*entity.NotificationChannel, error)
+	FindByName(name string) (*entity.NotificationChannel, error)
+	FindAll() ([]*entity.NotificationChannel, error)
+	// CountReferences 统计引用该渠道的任务、模板、报表摘要和升级策略数量
+	CountReferences(id int) (int64, error)
+}
@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// NotificationDeliveryRepository 通知发送记录仓库接口
+type NotificationDeliveryRepository interface {
+	// Create 创建发送记录
+	Create(delivery *entity.NotificationDelivery) error
+
+	// Update 更新发送记录
+	Update(delivery *entity.NotificationDelivery) error
+
+	// FindByID 根据ID获取发送记录
+	FindByID(id uint) (*entity.NotificationDelivery, error)
+
+	// FindByTaskLogID 获取一次任务执行的所有发送记录
+	FindByTaskLogID(taskLogID uint) ([]*entity.NotificationDelivery, error)
+
+	// FindByTaskID 获取任务最近的发送记录，按创建时间倒序
+	FindByTaskID(taskID int, limit int) ([]*entity.NotificationDelivery, error)
+
+	// ClaimDue 将到期待发送的记录标记为发送中并返回，最多 limit 条
+	ClaimDue(now time.Time, limit int) ([]*entity.NotificationDelivery, error)
+
+	// ResetSending 将发送中的记录恢复为待发送，用于服务重启后继续发送
+	ResetSending() error
+
+	// DeleteFinishedBefore 删除指定时间之前创建且已结束的发送记录
+	DeleteFinishedBefore(before time.Time) (int64, error)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type ProjectRepository interface {
+	Create(project *entity.Project) error
+	Update(project *entity.Project) error
+	// Delete 删除项目及其成员
+	Delete(id uint) error
+	FindByID(id uint) (*entity.Project, error)
+	FindAll() ([]*entity.Project, error)
+	FindByIDs(ids []uint) ([]*entity.Project, error)
+	// SaveMember 添加项目成员，成员已存在时更新角色
+	SaveMember(member *entity.ProjectMember) error
+	RemoveMember(projectID, userID uint) error
+	// FindMembers 获取项目成员及用户信息
+	FindMembers(projectID uint) ([]*entity.ProjectMember, error)
+	// FindMembershipsByUser 获取用户加入的项目
+	FindMembershipsByUser(userID uint) ([]*entity.ProjectMember, error)
+	// DeleteMembersByUser 将用户移出所有项目
+	DeleteMembersByUser(userID uint) error
+}
@@ -0,0 +1,12 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type ReportDigestRepository interface {
+	Create(digest *entity.ReportDigest) error
+	Update(digest *entity.ReportDigest) error
+	Delete(id int) error
+	FindByID(id int) (*entity.ReportDigest, error)
+	FindAll() ([]*entity.ReportDigest, error)
+	FindEnabled() ([]*entity.ReportDigest, error)
+}
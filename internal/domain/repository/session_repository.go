@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+type SessionRepository interface {
+	Create(session *entity.Session) error
+	FindByID(id uint) (*entity.Session, error)
+	FindByRefreshHash(hash string) (*entity.Session, error)
+	FindByPreviousHash(hash string) (*entity.Session, error)
+	// FindActiveByUser 获取用户未撤销且未过期的会话
+	FindActiveByUser(userID uint, now time.Time) ([]*entity.Session, error)
+	// Rotate 将会话的刷新令牌从 oldHash 替换为 newHash，刷新令牌已被替换时返回 false
+	Rotate(id uint, oldHash, newHash string, expiresAt, usedAt time.Time, ip string) (bool, error)
+	Revoke(id uint, at time.Time) error
+	// RevokeByUser 撤销用户的所有会话，exceptID 不为 0 时保留该会话
+	RevokeByUser(userID uint, exceptID uint, at time.Time) error
+	// DeleteExpired 删除刷新令牌已过期的会话
+	DeleteExpired(now time.Time) (int64, error)
+}
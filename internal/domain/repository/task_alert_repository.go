@@ -0,0 +1,12 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type TaskAlertRepository interface {
+	// FindByTaskID 获取任务的告警状态，不存在时返回 nil
+	FindByTaskID(taskID int) (*entity.TaskAlertState, error)
+	// FindFiring 获取所有告警中的状态
+	FindFiring() ([]*entity.TaskAlertState, error)
+	Save(state *entity.TaskAlertState) error
+	Delete(taskID int) error
+}
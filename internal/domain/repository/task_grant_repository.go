@@ -0,0 +1,16 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type TaskGrantRepository interface {
+	Create(grant *entity.TaskGrant) error
+	Delete(id uint) error
+	FindByID(id uint) (*entity.TaskGrant, error)
+	// FindByTaskID 获取任务的所有授权
+	FindByTaskID(taskID int) ([]*entity.TaskGrant, error)
+	// FindBySubject 获取授予用户本人或其所在用户组的所有授权
+	FindBySubject(userID uint, groupIDs []uint) ([]*entity.TaskGrant, error)
+	DeleteByTaskID(taskID int) error
+	DeleteByGroupID(groupID uint) error
+	DeleteByUserID(userID uint) error
+}
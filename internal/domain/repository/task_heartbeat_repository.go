@@ -0,0 +1,10 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type TaskHeartbeatRepository interface {
+	// FindByTaskID 获取任务的心跳状态，不存在时返回 nil
+	FindByTaskID(taskID int) (*entity.TaskHeartbeat, error)
+	Save(heartbeat *entity.TaskHeartbeat) error
+	Delete(taskID int) error
+}
@@ -10,12 +10,36 @@ type TaskLogRepository interface {
 	// GetLogsByTaskID 根据任务ID获取任务日志
 	GetLogsByTaskID(taskID int) ([]entity.TaskLog, error)
 	
+	// GetLatestLog 获取任务最近一次执行日志，没有日志时返回 nil
+	GetLatestLog(taskID int) (*entity.TaskLog, error)
+	
 	// GetLogsByTaskIDWithPagination 根据任务ID分页获取任务日志
 	GetLogsByTaskIDWithPagination(taskID int, req *entity.PaginationRequest) ([]entity.TaskLog, int64, error)
 
-	// GetAllLogs 获取所有任务日志
-	GetAllLogs() ([]entity.TaskLog, error)
+	// GetAllLogs 获取最近的 limit 条任务日志，taskIDs 不为 nil 时只返回这些任务的日志
+	GetAllLogs(taskIDs []int, limit int) ([]entity.TaskLog, error)
 
 	// GetAllLogsWithPagination 分页获取所有任务日志
 	GetAllLogsWithPagination(page, pageSize int) ([]entity.TaskLog, int64, error)
-}
\ No newline at end of file
+
+	// FindLogs 根据条件获取任务日志
+	FindLogs(filter *entity.TaskLogFilter) ([]entity.TaskLog, error)
+
+	// CountLogs 根据条件统计任务日志数量
+	CountLogs(filter *entity.TaskLogFilter) (int64, error)
+
+	// AggregateByTask 按任务聚合执行次数、成功率和执行时间
+	AggregateByTask(filter *entity.TaskLogFilter) ([]entity.TaskLogAggregate, error)
+
+	// AggregateByDay 按天聚合执行次数
+	AggregateByDay(filter *entity.TaskLogFilter) ([]entity.TaskLogBucket, error)
+
+	// AggregateByHour 按小时聚合执行次数
+	AggregateByHour(filter *entity.TaskLogFilter) ([]entity.TaskLogBucket, error)
+
+	// SearchLogs 按条件搜索日志，返回当前页的日志和下一页的游标（没有更多数据时为空）
+	SearchLogs(req *entity.TaskLogSearchRequest) ([]entity.TaskLog, *entity.TaskLogCursor, error)
+
+	// GetDurationPercentilesByTask 按任务获取执行时间的百分位数 (秒)，percentiles 取值范围 0-1，没有日志的任务不在结果中
+	GetDurationPercentilesByTask(filter *entity.TaskLogFilter, percentiles []float64) (map[int][]float64, error)
+}
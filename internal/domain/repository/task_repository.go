@@ -9,5 +9,16 @@ type TaskRepository interface {
 	FindByID(id int) (*entity.Task, error)
 	FindAll() ([]*entity.Task, error)
 	FindEnabled() ([]*entity.Task, error)
-	FindWithPagination(req *entity.PaginationRequest) ([]*entity.Task, int64, error)
+	// FindByScope 获取范围内的任务，scope 为 nil 时返回所有任务
+	FindByScope(scope *entity.TaskScope) ([]*entity.Task, error)
+	// FindWithPagination 分页获取范围内的任务，scope 为 nil 时不限制
+	FindWithPagination(scope *entity.TaskScope, req *entity.PaginationRequest) ([]*entity.Task, int64, error)
+	// ClearOwner 清除用户拥有的任务的所有者，用于删除用户
+	ClearOwner(userID uint) error
+	// CountByProject 统计项目中的任务数
+	CountByProject(projectID uint) (int64, error)
+	// FindByPingToken 根据心跳上报令牌查找心跳任务
+	FindByPingToken(token string) (*entity.Task, error)
+	// FindHeartbeats 获取所有心跳任务
+	FindHeartbeats() ([]*entity.Task, error)
 }
\ No newline at end of file
@@ -0,0 +1,19 @@
+package repository
+
+import "crontab_go/internal/domain/entity"
+
+type UserGroupRepository interface {
+	Create(group *entity.UserGroup) error
+	Update(group *entity.UserGroup) error
+	Delete(id uint) error
+	// FindByID 获取用户组及其成员
+	FindByID(id uint) (*entity.UserGroup, error)
+	// FindAll 获取所有用户组及其成员
+	FindAll() ([]*entity.UserGroup, error)
+	AddMember(groupID, userID uint) error
+	RemoveMember(groupID, userID uint) error
+	// DeleteMembersByUser 将用户移出所有用户组
+	DeleteMembersByUser(userID uint) error
+	// FindGroupIDsByUser 获取用户所在的用户组ID
+	FindGroupIDsByUser(userID uint) ([]uint, error)
+}
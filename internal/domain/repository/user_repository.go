@@ -21,4 +21,22 @@ type UserRepository interface {
 	
 	// FindAll 获取所有用户
 	FindAll() ([]*entity.User, error)
+
+	// CountActiveAdmins 统计已启用的管理员数量
+	CountActiveAdmins() (int64, error)
+
+	// FindByExternalID 根据认证来源和外部身份标识查找用户
+	FindByExternalID(provider, externalID string) (*entity.User, error)
+
+	// FindByEmail 根据邮箱查找用户
+	FindByEmail(email string) (*entity.User, error)
+
+	// FindByAuthProvider 获取指定认证来源的所有用户
+	FindByAuthProvider(provider string) ([]*entity.User, error)
+
+	// UseTOTPStep 记录已使用的验证码时间步，step 不大于已记录的时间步（验证码已被使用）时返回 false
+	UseTOTPStep(id uint, step int64) (bool, error)
+
+	// ReplaceRecoveryCodes 将恢复码从 previous 替换为 remaining，恢复码已被修改（如被同时使用）时返回 false
+	ReplaceRecoveryCodes(id uint, previous, remaining string) (bool, error)
 }
\ No newline at end of file
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// ErrAlertNotFiring 任务当前没有告警，无法确认
+var ErrAlertNotFiring = errors.New("任务当前没有告警")
+
+// alertStateMu 串行化告警状态的读取和更新，同一进程中的多个 TaskExecutor 共享
+var alertStateMu sync.Mutex
+
+// AlertDecision 告警规则的判定结果
+type AlertDecision struct {
+	Notify bool              // 是否发送通知
+	Alert  *entity.AlertInfo // 告警信息，非告警相关的通知为 nil
+	Reason string            // 不发送通知的原因，用于日志
+
+	// EscalationLevel 恢复时本轮告警已执行的升级步骤数，恢复通知也会发送到这些步骤的渠道
+	EscalationLevel int
+}
+
+// AlertEvaluator 按任务的告警规则判定是否发送通知，并维护持久化的告警状态：
+// 连续失败达到阈值后开始告警，告警期间按模式和冷却时间去重，恢复后发送恢复通知
+type AlertEvaluator struct {
+	alertRepo repository.TaskAlertRepository
+}
+
+func NewAlertEvaluator(alertRepo repository.TaskAlertRepository) *AlertEvaluator {
+	return &AlertEvaluator{alertRepo: alertRepo}
+}
+
+// Evaluate 根据本次执行结果更新告警状态并判定是否通知，force 为 true 时（如检测到异常）总是通知
+func (e *AlertEvaluator) Evaluate(task *entity.Task, taskLog *entity.TaskLog, force bool) *AlertDecision {
+	alertStateMu.Lock()
+	defer alertStateMu.Unlock()
+
+	state, err := e.alertRepo.FindByTaskID(task.ID)
+	if err != nil {
+		log.Printf("Failed to load alert state for task %s: %v", task.Name, err)
+	}
+	if state == nil {
+		state = &entity.TaskAlertState{TaskID: task.ID, Status: entity.AlertStatusOK}
+	}
+
+	now := taskLog.EndTime
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var decision *AlertDecision
+	if taskLog.Success {
+		decision = e.evaluateSuccess(task, state, now)
+	} else {
+		decision = e.evaluateFailure(task, state, taskLog, now)
+	}
+
+	if force && !decision.Notify {
+		decision.Notify, decision.Reason = true, ""
+	}
+	if decision.Notify {
+		if decision.Alert != nil {
+			decision.Alert.Suppressed = state.Suppressed
+			state.LastNotifiedEvent = decision.Alert.Event
+		} else {
+			state.LastNotifiedEvent = alertEventSuccess
+		}
+		state.LastNotifiedAt = &now
+		state.Suppressed = 0
+	}
+
+	if err := e.alertRepo.Save(state); err != nil {
+		log.Printf("Failed to save alert state for task %s: %v", task.Name, err)
+	}
+	return decision
+}
+
+// alertEventSuccess 记录在 LastNotifiedEvent 中的普通成功通知
+const alertEventSuccess = "success"
+
+// evaluateFailure 处理失败的执行
+func (e *AlertEvaluator) evaluateFailure(task *entity.Task, state *entity.TaskAlertState, taskLog *entity.TaskLog, now time.Time) *AlertDecision {
+	if state.ConsecutiveFailures == 0 {
+		failingSince := taskLog.StartTime
+		state.FailingSince = &failingSince
+	}
+	state.ConsecutiveFailures++
+
+	if state.Status != entity.AlertStatusFiring {
+		if state.ConsecutiveFailures < alertThreshold(task) {
+			return &AlertDecision{Reason: "连续失败次数未达到告警阈值"}
+		}
+		state.Status = entity.AlertStatusFiring
+		state.FiringSince = &now
+		state.EscalationLevel = 0
+		state.AcknowledgedAt, state.AcknowledgedBy = nil, ""
+		wakeEscalations()
+		return &AlertDecision{
+			Notify: task.NotifyOnFailure,
+			Alert:  alertInfo(entity.AlertEventFiring, state),
+			Reason: "未开启失败通知",
+		}
+	}
+
+	decision := &AlertDecision{Alert: alertInfo(entity.AlertEventRepeat, state)}
+	switch {
+	case !task.NotifyOnFailure:
+		decision.Reason = "未开启失败通知"
+	case task.AlertMode == entity.AlertModeStateChange:
+		decision.Reason = "告警已发送，仅在状态变化时通知"
+		state.Suppressed++
+	case !cooldownElapsed(task, state, now):
+		decision.Reason = "处于通知冷却时间内"
+		state.Suppressed++
+	default:
+		decision.Notify = true
+	}
+	return decision
+}
+
+// evaluateSuccess 处理成功的执行
+func (e *AlertEvaluator) evaluateSuccess(task *entity.Task, state *entity.TaskAlertState, now time.Time) *AlertDecision {
+	decision := &AlertDecision{}
+
+	if state.Status == entity.AlertStatusFiring {
+		decision.Alert = alertInfo(entity.AlertEventResolved, state)
+		decision.EscalationLevel = state.EscalationLevel
+		decision.Notify = task.NotifyOnRecovery || task.NotifyOnSuccess
+		decision.Reason = "未开启恢复通知"
+		state.Status = entity.AlertStatusOK
+		state.ResolvedAt = &now
+	} else {
+		switch {
+		case !task.NotifyOnSuccess:
+			decision.Reason = "未开启成功通知"
+		case task.AlertMode == entity.AlertModeStateChange && state.ConsecutiveFailures == 0:
+			decision.Reason = "执行状态未变化"
+		case state.LastNotifiedEvent == alertEventSuccess && !cooldownElapsed(task, state, now):
+			decision.Reason = "处于通知冷却时间内"
+			state.Suppressed++
+		default:
+			decision.Notify = true
+		}
+	}
+
+	state.ConsecutiveFailures = 0
+	state.FailingSince = nil
+	state.FiringSince = nil
+	return decision
+}
+
+// alertThreshold 任务的告警阈值，至少为 1
+func alertThreshold(task *entity.Task) int {
+	if task.AlertThreshold < 1 {
+		return 1
+	}
+	return task.AlertThreshold
+}
+
+// cooldownElapsed 距离上次通知是否已超过冷却时间
+func cooldownElapsed(task *entity.Task, state *entity.TaskAlertState, now time.Time) bool {
+	if task.AlertCooldown <= 0 || state.LastNotifiedAt == nil {
+		return true
+	}
+	return now.Sub(*state.LastNotifiedAt) >= time.Duration(task.AlertCooldown)*time.Second
+}
+
+// alertInfo 根据告警状态生成通知消息中的告警信息
+func alertInfo(event string, state *entity.TaskAlertState) *entity.AlertInfo {
+	info := &entity.AlertInfo{Event: event, ConsecutiveFailures: state.ConsecutiveFailures}
+	if state.FailingSince != nil {
+		info.FailingSince = state.FailingSince.Format("2006-01-02 15:04:05")
+	}
+	return info
+}
+
+// Acknowledge 确认任务的告警，确认后不再升级，直到下一轮告警
+func (e *AlertEvaluator) Acknowledge(taskID int, username string) (*entity.TaskAlertState, error) {
+	alertStateMu.Lock()
+	defer alertStateMu.Unlock()
+
+	state, err := e.alertRepo.FindByTaskID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || state.Status != entity.AlertStatusFiring {
+		return nil, ErrAlertNotFiring
+	}
+	if state.Acknowledged() {
+		return state, nil
+	}
+
+	now := time.Now()
+	state.AcknowledgedAt, state.AcknowledgedBy = &now, username
+	if err := e.alertRepo.Save(state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
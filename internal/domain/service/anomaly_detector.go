@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// minStdDevRatio 基线标准差的下限（相对平均执行时间），避免执行时间非常稳定的任务被轻微波动误判为异常
+const minStdDevRatio = 0.1
+
+// AnomalyDetector 基于历史基线检测任务的异常执行
+type AnomalyDetector struct {
+	taskLogRepo repository.TaskLogRepository
+	config      *entity.AnomalyConfig
+}
+
+func NewAnomalyDetector(taskLogRepo repository.TaskLogRepository, config *entity.AnomalyConfig) *AnomalyDetector {
+	if config == nil {
+		config = entity.NewAnomalyConfig()
+	}
+	return &AnomalyDetector{
+		taskLogRepo: taskLogRepo,
+		config:      config,
+	}
+}
+
+// Baseline 计算任务在 before 之前 BaselineDays 天内的执行基线
+func (d *AnomalyDetector) Baseline(taskID int, before time.Time) (*entity.TaskBaseline, error) {
+	baselines, err := d.Baselines([]int{taskID}, before)
+	if err != nil {
+		return nil, err
+	}
+	return baselines[taskID], nil
+}
+
+// Baselines 在一次分组查询中计算多个任务在 before 之前 BaselineDays 天内的执行基线，键为任务ID
+func (d *AnomalyDetector) Baselines(taskIDs []int, before time.Time) (map[int]*entity.TaskBaseline, error) {
+	windowStart := before.AddDate(0, 0, -d.config.BaselineDays)
+	windowEnd := before.Add(-time.Nanosecond)
+
+	baselines := make(map[int]*entity.TaskBaseline, len(taskIDs))
+	for _, taskID := range taskIDs {
+		baselines[taskID] = &entity.TaskBaseline{
+			TaskID:      taskID,
+			WindowStart: windowStart,
+			WindowEnd:   before,
+		}
+	}
+	if len(taskIDs) == 0 {
+		return baselines, nil
+	}
+
+	aggregates, err := d.taskLogRepo.AggregateByTask(&entity.TaskLogFilter{
+		TaskIDs:   taskIDs,
+		StartTime: &windowStart,
+		EndTime:   &windowEnd,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, aggregate := range aggregates {
+		baseline, exists := baselines[aggregate.TaskID]
+		if !exists || aggregate.TotalExecutions == 0 {
+			continue
+		}
+		baseline.SampleSize = aggregate.TotalExecutions
+		baseline.MeanDuration = aggregate.AvgDuration
+		if variance := aggregate.AvgSquaredDuration - aggregate.AvgDuration*aggregate.AvgDuration; variance > 0 {
+			baseline.StdDevDuration = math.Sqrt(variance)
+		}
+		baseline.FailureRate = float64(aggregate.FailureExecutions) / float64(aggregate.TotalExecutions)
+	}
+
+	return baselines, nil
+}
+
+// DetectInRange 检测任务在指定时间范围内的异常执行，aggregate 为任务在该范围内的聚合结果，
+// 为空时表示范围内没有执行记录
+func (d *AnomalyDetector) DetectInRange(task *entity.Task, baseline *entity.TaskBaseline, aggregate *entity.TaskLogAggregate, startTime, endTime time.Time) ([]entity.TaskAnomaly, error) {
+	anomalies := []entity.TaskAnomaly{}
+	if !d.hasEnoughSamples(baseline) || aggregate == nil || aggregate.TotalExecutions == 0 {
+		return anomalies, nil
+	}
+
+	// 执行时间超出阈值的记录，范围内最长执行时间未超出阈值时不需要查询
+	threshold := d.durationThreshold(baseline)
+	if aggregate.MaxDuration > threshold {
+		logs, err := d.taskLogRepo.FindLogs(&entity.TaskLogFilter{
+			TaskID:      &task.ID,
+			StartTime:   &startTime,
+			EndTime:     &endTime,
+			MinDuration: &threshold,
+			Limit:       d.config.MaxAnomalies,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range logs {
+			if anomaly := d.checkDuration(task, &logs[i], baseline); anomaly != nil {
+				anomalies = append(anomalies, *anomaly)
+			}
+		}
+	}
+
+	// 范围内整体失败率
+	if anomaly := d.checkFailureRate(task, aggregate.FailureExecutions, aggregate.TotalExecutions, baseline); anomaly != nil {
+		anomalies = append(anomalies, *anomaly)
+	}
+
+	return anomalies, nil
+}
+
+// DetectRun 检测单次执行是否异常：执行时间是否超出基线，以及失败时最近几次执行的失败率是否超出基线
+func (d *AnomalyDetector) DetectRun(task *entity.Task, taskLog *entity.TaskLog) ([]entity.TaskAnomaly, error) {
+	baseline, err := d.Baseline(task.ID, taskLog.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var anomalies []entity.TaskAnomaly
+	if !d.hasEnoughSamples(baseline) {
+		return anomalies, nil
+	}
+
+	if anomaly := d.checkDuration(task, taskLog, baseline); anomaly != nil {
+		anomalies = append(anomalies, *anomaly)
+	}
+
+	if !taskLog.Success {
+		endTime := taskLog.StartTime
+		recent, err := d.taskLogRepo.FindLogs(&entity.TaskLogFilter{
+			TaskID:  &task.ID,
+			EndTime: &endTime,
+			Limit:   d.config.RecentRuns,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var failures int64
+		for _, log := range recent {
+			if !log.Success {
+				failures++
+			}
+		}
+		if anomaly := d.checkFailureRate(task, failures, int64(len(recent)), baseline); anomaly != nil {
+			anomalies = append(anomalies, *anomaly)
+		}
+	}
+
+	return anomalies, nil
+}
+
+// hasEnoughSamples 基线样本是否足够进行检测
+func (d *AnomalyDetector) hasEnoughSamples(baseline *entity.TaskBaseline) bool {
+	return baseline != nil && baseline.SampleSize > 0 && baseline.SampleSize >= d.config.MinSamples
+}
+
+// durationStdDev 用于判定的执行时间标准差（带下限）
+func (d *AnomalyDetector) durationStdDev(baseline *entity.TaskBaseline) float64 {
+	return math.Max(baseline.StdDevDuration, baseline.MeanDuration*minStdDevRatio)
+}
+
+// durationThreshold 执行时间异常阈值 (秒)
+func (d *AnomalyDetector) durationThreshold(baseline *entity.TaskBaseline) float64 {
+	return baseline.MeanDuration + d.config.Sigma*d.durationStdDev(baseline)
+}
+
+// checkDuration 检查单次执行时间是否超出基线
+func (d *AnomalyDetector) checkDuration(task *entity.Task, taskLog *entity.TaskLog, baseline *entity.TaskBaseline) *entity.TaskAnomaly {
+	stdDev := d.durationStdDev(baseline)
+	if stdDev <= 0 {
+		return nil
+	}
+
+	duration := taskLog.EndTime.Sub(taskLog.StartTime).Seconds()
+	threshold := d.durationThreshold(baseline)
+	if duration <= threshold {
+		return nil
+	}
+
+	startTime := taskLog.StartTime
+	deviation := (duration - baseline.MeanDuration) / stdDev
+	return &entity.TaskAnomaly{
+		TaskID:    task.ID,
+		TaskName:  task.Name,
+		LogID:     taskLog.ID,
+		Type:      entity.AnomalyTypeDuration,
+		Value:     duration,
+		Baseline:  baseline.MeanDuration,
+		Threshold: threshold,
+		Deviation: deviation,
+		StartTime: &startTime,
+		Message: fmt.Sprintf("执行时间 %.2fs 超出基线 %.2fs（%.1f 个标准差）",
+			duration, baseline.MeanDuration, deviation),
+	}
+}
+
+// checkFailureRate 检查失败率是否超出基线。基线失败率做拉普拉斯平滑，
+// 避免基线中从未失败时标准差为零；执行次数少于 MinSamples 时不做判定，避免一两次失败就被视为异常
+func (d *AnomalyDetector) checkFailureRate(task *entity.Task, failures, total int64, baseline *entity.TaskBaseline) *entity.TaskAnomaly {
+	if total == 0 || total < d.config.MinSamples || failures == 0 {
+		return nil
+	}
+
+	baselineFailures := baseline.FailureRate * float64(baseline.SampleSize)
+	smoothed := (baselineFailures + 1) / (float64(baseline.SampleSize) + 2)
+	stdDev := math.Sqrt(smoothed * (1 - smoothed) / float64(total))
+
+	rate := float64(failures) / float64(total)
+	threshold := baseline.FailureRate + d.config.Sigma*stdDev
+	if rate <= threshold {
+		return nil
+	}
+
+	deviation := (rate - baseline.FailureRate) / stdDev
+	return &entity.TaskAnomaly{
+		TaskID:    task.ID,
+		TaskName:  task.Name,
+		Type:      entity.AnomalyTypeFailureRate,
+		Value:     rate,
+		Baseline:  baseline.FailureRate,
+		Threshold: threshold,
+		Deviation: deviation,
+		Message: fmt.Sprintf("%d 次执行的失败率 %.1f%% 超出基线 %.1f%%（%.1f 个标准差）",
+			total, rate*100, baseline.FailureRate*100, deviation),
+	}
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"crontab_go/internal/infrastructure/persistence"
+
+	"gorm.io/gorm"
+)
+
+// countingLogRepo 记录聚合和日志查询次数的日志仓储
+type countingLogRepo struct {
+	repository.TaskLogRepository
+	aggregates int
+	findLogs   int
+}
+
+func (r *countingLogRepo) AggregateByTask(filter *entity.TaskLogFilter) ([]entity.TaskLogAggregate, error) {
+	r.aggregates++
+	return r.TaskLogRepository.AggregateByTask(filter)
+}
+
+func (r *countingLogRepo) FindLogs(filter *entity.TaskLogFilter) ([]entity.TaskLog, error) {
+	r.findLogs++
+	return r.TaskLogRepository.FindLogs(filter)
+}
+
+func newTestLogRepo(t *testing.T) (*gorm.DB, *countingLogRepo) {
+	t.Helper()
+
+	db, err := persistence.NewSQLiteDB(filepath.Join(t.TempDir(), "crontab.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	return db.Client, &countingLogRepo{TaskLogRepository: persistence.NewTaskLogRepository(db.Client)}
+}
+
+// addRuns 从 start 开始每分钟写入一条执行记录，durations 为执行时间（秒），failed 为失败记录的下标
+func addRuns(t *testing.T, db *gorm.DB, taskID int, start time.Time, durations []float64, failed ...int) {
+	t.Helper()
+
+	failures := make(map[int]bool, len(failed))
+	for _, i := range failed {
+		failures[i] = true
+	}
+	for i, duration := range durations {
+		startTime := start.Add(time.Duration(i) * time.Minute)
+		log := &entity.TaskLog{
+			TaskID:    taskID,
+			TaskName:  "task",
+			StartTime: startTime,
+			EndTime:   startTime.Add(time.Duration(duration * float64(time.Second))),
+			Success:   !failures[i],
+		}
+		if err := db.Create(log).Error; err != nil {
+			t.Fatalf("create log: %v", err)
+		}
+	}
+}
+
+// repeat 返回 n 个相同的执行时间
+func repeat(duration float64, n int) []float64 {
+	durations := make([]float64, n)
+	for i := range durations {
+		durations[i] = duration
+	}
+	return durations
+}
+
+func TestAnomalyDetectorBaselines(t *testing.T) {
+	db, repo := newTestLogRepo(t)
+	now := time.Now().Truncate(time.Second)
+	before := now.AddDate(0, 0, -1)
+
+	addRuns(t, db, 1, before.AddDate(0, 0, -2), []float64{8, 12, 8, 12}, 1)
+	addRuns(t, db, 2, before.AddDate(0, 0, -2), repeat(5, 3))
+	// 基线窗口之外的记录不计入
+	addRuns(t, db, 2, before.AddDate(0, 0, -30), repeat(100, 3))
+	addRuns(t, db, 2, before, repeat(100, 3))
+
+	detector := NewAnomalyDetector(repo, entity.NewAnomalyConfig())
+	baselines, err := detector.Baselines([]int{1, 2, 3}, before)
+	if err != nil {
+		t.Fatalf("Baselines: %v", err)
+	}
+	if repo.aggregates != 1 {
+		t.Errorf("AggregateByTask called %d times, want 1", repo.aggregates)
+	}
+
+	tests := []struct {
+		taskID      int
+		sampleSize  int64
+		mean        float64
+		stdDev      float64
+		failureRate float64
+	}{
+		{1, 4, 10, 2, 0.25},
+		{2, 3, 5, 0, 0},
+		{3, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		baseline := baselines[tt.taskID]
+		if baseline == nil {
+			t.Errorf("task %d: no baseline", tt.taskID)
+			continue
+		}
+		if baseline.SampleSize != tt.sampleSize {
+			t.Errorf("task %d: SampleSize = %d, want %d", tt.taskID, baseline.SampleSize, tt.sampleSize)
+		}
+		if !approxEqual(baseline.MeanDuration, tt.mean) {
+			t.Errorf("task %d: MeanDuration = %v, want %v", tt.taskID, baseline.MeanDuration, tt.mean)
+		}
+		if !approxEqual(baseline.StdDevDuration, tt.stdDev) {
+			t.Errorf("task %d: StdDevDuration = %v, want %v", tt.taskID, baseline.StdDevDuration, tt.stdDev)
+		}
+		if !approxEqual(baseline.FailureRate, tt.failureRate) {
+			t.Errorf("task %d: FailureRate = %v, want %v", tt.taskID, baseline.FailureRate, tt.failureRate)
+		}
+		if !baseline.WindowEnd.Equal(before) || !baseline.WindowStart.Equal(before.AddDate(0, 0, -7)) {
+			t.Errorf("task %d: window = %v - %v", tt.taskID, baseline.WindowStart, baseline.WindowEnd)
+		}
+	}
+}
+
+func TestAnomalyDetectorDetectInRange(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	rangeStart := now.AddDate(0, 0, -1)
+	baselineStart := rangeStart.AddDate(0, 0, -2)
+
+	tests := []struct {
+		name          string
+		baselineRuns  int
+		rangeRuns     []float64
+		rangeFailures []int
+		wantDuration  int
+		wantFailure   bool
+		wantFindLogs  int
+	}{
+		// 基线执行时间均为 10s，标准差取下限 1s，阈值为 13s
+		{"no anomalies", 10, []float64{10, 11, 12}, nil, 0, false, 0},
+		{"slow runs", 10, []float64{10, 14, 20}, nil, 2, false, 1},
+		{"not enough baseline samples", 9, []float64{10, 20}, nil, 0, false, 0},
+		{"failures below min samples", 10, repeat(10, 3), []int{0, 1, 2}, 0, false, 0},
+		{"failure rate", 10, repeat(10, 10), []int{0, 1, 2, 3, 4}, 0, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, repo := newTestLogRepo(t)
+			task := &entity.Task{ID: 1, Name: "task"}
+			addRuns(t, db, task.ID, baselineStart, repeat(10, tt.baselineRuns))
+			addRuns(t, db, task.ID, rangeStart, tt.rangeRuns, tt.rangeFailures...)
+
+			detector := NewAnomalyDetector(repo, entity.NewAnomalyConfig())
+			baseline, err := detector.Baseline(task.ID, rangeStart)
+			if err != nil {
+				t.Fatalf("Baseline: %v", err)
+			}
+			aggregates, err := repo.AggregateByTask(&entity.TaskLogFilter{TaskID: &task.ID, StartTime: &rangeStart, EndTime: &now})
+			if err != nil || len(aggregates) != 1 {
+				t.Fatalf("AggregateByTask = %v, %v", aggregates, err)
+			}
+
+			anomalies, err := detector.DetectInRange(task, baseline, &aggregates[0], rangeStart, now)
+			if err != nil {
+				t.Fatalf("DetectInRange: %v", err)
+			}
+			var durations int
+			var failure bool
+			for _, anomaly := range anomalies {
+				switch anomaly.Type {
+				case entity.AnomalyTypeDuration:
+					durations++
+					if anomaly.Value <= anomaly.Threshold || !approxEqual(anomaly.Threshold, 13) {
+						t.Errorf("duration anomaly value = %v, threshold = %v", anomaly.Value, anomaly.Threshold)
+					}
+				case entity.AnomalyTypeFailureRate:
+					failure = true
+				}
+			}
+			if durations != tt.wantDuration {
+				t.Errorf("duration anomalies = %d, want %d", durations, tt.wantDuration)
+			}
+			if failure != tt.wantFailure {
+				t.Errorf("failure rate anomaly = %v, want %v", failure, tt.wantFailure)
+			}
+			if repo.findLogs != tt.wantFindLogs {
+				t.Errorf("FindLogs called %d times, want %d", repo.findLogs, tt.wantFindLogs)
+			}
+		})
+	}
+}
+
+func TestAnomalyDetectorCheckFailureRate(t *testing.T) {
+	detector := NewAnomalyDetector(nil, entity.NewAnomalyConfig())
+	task := &entity.Task{ID: 1, Name: "task"}
+
+	tests := []struct {
+		name     string
+		baseline *entity.TaskBaseline
+		failures int64
+		total    int64
+		want     bool
+	}{
+		{"no runs", &entity.TaskBaseline{SampleSize: 100}, 0, 0, false},
+		{"no failures", &entity.TaskBaseline{SampleSize: 100}, 0, 20, false},
+		{"single failed run", &entity.TaskBaseline{SampleSize: 100}, 1, 1, false},
+		{"below min samples", &entity.TaskBaseline{SampleSize: 100}, 9, 9, false},
+		{"all failed at min samples", &entity.TaskBaseline{SampleSize: 100}, 10, 10, true},
+		{"within baseline", &entity.TaskBaseline{SampleSize: 100, FailureRate: 0.5}, 6, 10, false},
+		{"above baseline", &entity.TaskBaseline{SampleSize: 100, FailureRate: 0.05}, 8, 20, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomaly := detector.checkFailureRate(task, tt.failures, tt.total, tt.baseline)
+			if (anomaly != nil) != tt.want {
+				t.Fatalf("checkFailureRate = %+v, want anomaly %v", anomaly, tt.want)
+			}
+			if anomaly != nil && anomaly.Value <= anomaly.Threshold {
+				t.Errorf("value %v does not exceed threshold %v", anomaly.Value, anomaly.Threshold)
+			}
+		})
+	}
+}
+
+func TestAnomalyDetectorDetectRun(t *testing.T) {
+	db, repo := newTestLogRepo(t)
+	now := time.Now().Truncate(time.Second)
+	task := &entity.Task{ID: 1, Name: "task"}
+	addRuns(t, db, task.ID, now.AddDate(0, 0, -2), repeat(10, 20))
+
+	detector := NewAnomalyDetector(repo, entity.NewAnomalyConfig())
+
+	// 最近只有两次执行且都失败，次数不足时不判定失败率
+	addRuns(t, db, task.ID, now.Add(-time.Hour), repeat(10, 2), 0, 1)
+	run := &entity.TaskLog{TaskID: task.ID, StartTime: now, EndTime: now.Add(30 * time.Second), Success: false}
+	anomalies, err := detector.DetectRun(task, run)
+	if err != nil {
+		t.Fatalf("DetectRun: %v", err)
+	}
+	if len(anomalies) != 1 || anomalies[0].Type != entity.AnomalyTypeDuration {
+		t.Fatalf("anomalies = %+v, want a single duration anomaly", anomalies)
+	}
+
+	// 最近的执行全部失败
+	addRuns(t, db, task.ID, now.Add(-30*time.Minute), repeat(10, 10), 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	run = &entity.TaskLog{TaskID: task.ID, StartTime: now, EndTime: now.Add(10 * time.Second), Success: false}
+	anomalies, err = detector.DetectRun(task, run)
+	if err != nil {
+		t.Fatalf("DetectRun: %v", err)
+	}
+	if len(anomalies) != 1 || anomalies[0].Type != entity.AnomalyTypeFailureRate {
+		t.Fatalf("anomalies = %+v, want a single failure rate anomaly", anomalies)
+	}
+}
+
+// approxEqual 浮点数是否近似相等，SQLite 按 julianday 计算的执行时间有毫秒级误差
+func approxEqual(a, b float64) bool {
+	diff := a - b
+	return diff < 1e-3 && diff > -1e-3
+}
@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// digestFuncs 摘要模板中使用的格式化函数
+var digestFuncs = map[string]interface{}{
+	"period":   digestPeriodLabel,
+	"time":     func(t interface{ Format(string) string }) string { return t.Format("2006-01-02 15:04") },
+	"rate":     func(v float64) string { return fmt.Sprintf("%.1f%%", v) },
+	"seconds":  func(v float64) string { return fmt.Sprintf("%.2fs", v) },
+	"change":   formatExecutionsChange,
+	"rateDiff": formatSuccessRateChange,
+}
+
+// digestMarkdownTemplate 钉钉、企业微信使用的 Markdown 摘要模板
+var digestMarkdownTemplate = template.Must(template.New("digest").Funcs(digestFuncs).Parse(
+	`## {{.Name}} - {{period .Period}}执行报表
+
+**统计区间:** {{time .Current.StartTime}} ~ {{time .Current.EndTime}}
+
+**执行次数:** {{.Current.TotalExecutions}}（{{change .ExecutionsChange}}）
+
+**成功率:** {{rate .Current.SuccessRate}}（{{rateDiff .SuccessRateChange}}）
+
+**失败次数:** {{.Current.FailureExecutions}}，活跃任务 {{.Current.ActiveTasks}}/{{.TotalTasks}}
+{{if .TopFailures}}
+### 失败最多的任务
+{{range .TopFailures}}
+- {{.TaskName}}：失败 {{.FailureExecutions}}/{{.TotalExecutions}} 次，成功率 {{rate .SuccessRate}}{{end}}
+{{end}}{{if .SlowestTasks}}
+### 最慢的任务
+{{range .SlowestTasks}}
+- {{.TaskName}}：平均 {{seconds .AverageExecutionTime}}，共 {{.TotalExecutions}} 次{{end}}
+{{end}}`))
+
+// digestEmailTemplate 邮件摘要模板
+var digestEmailTemplate = htmltemplate.Must(htmltemplate.New("digest").Funcs(digestFuncs).Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>{{.Name}}</title>
+</head>
+<body style="font-family: Arial, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5;">
+    <div style="max-width: 600px; margin: 0 auto; background-color: white; border-radius: 8px; box-shadow: 0 2px 10px rgba(0,0,0,0.1);">
+        <div style="background-color: #007bff; color: white; padding: 20px; border-radius: 8px 8px 0 0;">
+            <h2 style="margin: 0;">{{.Name}} - {{period .Period}}执行报表</h2>
+            <div style="margin-top: 8px; font-size: 13px;">{{time .Current.StartTime}} ~ {{time .Current.EndTime}}</div>
+        </div>
+        <div style="padding: 20px;">
+            <table style="width: 100%; border-collapse: collapse;">
+                <tr>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee; font-weight: bold; width: 120px;">执行次数:</td>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee;">{{.Current.TotalExecutions}}（{{change .ExecutionsChange}}）</td>
+                </tr>
+                <tr>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee; font-weight: bold;">成功率:</td>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee;">{{rate .Current.SuccessRate}}（{{rateDiff .SuccessRateChange}}）</td>
+                </tr>
+                <tr>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee; font-weight: bold;">失败次数:</td>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee; color: #dc3545;">{{.Current.FailureExecutions}}</td>
+                </tr>
+                <tr>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee; font-weight: bold;">活跃任务:</td>
+                    <td style="padding: 10px; border-bottom: 1px solid #eee;">{{.Current.ActiveTasks}}/{{.TotalTasks}}</td>
+                </tr>
+            </table>
+            {{if .TopFailures}}
+            <h3 style="margin: 20px 0 10px;">失败最多的任务</h3>
+            <table style="width: 100%; border-collapse: collapse;">
+                <tr style="background-color: #f8f9fa;">
+                    <th style="padding: 8px; text-align: left;">任务</th>
+                    <th style="padding: 8px; text-align: right;">失败/总次数</th>
+                    <th style="padding: 8px; text-align: right;">成功率</th>
+                </tr>
+                {{range .TopFailures}}
+                <tr>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee;">{{.TaskName}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right; color: #dc3545;">{{.FailureExecutions}}/{{.TotalExecutions}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right;">{{rate .SuccessRate}}</td>
+                </tr>
+                {{end}}
+            </table>
+            {{end}}
+            {{if .SlowestTasks}}
+            <h3 style="margin: 20px 0 10px;">最慢的任务</h3>
+            <table style="width: 100%; border-collapse: collapse;">
+                <tr style="background-color: #f8f9fa;">
+                    <th style="padding: 8px; text-align: left;">任务</th>
+                    <th style="padding: 8px; text-align: right;">平均执行时间</th>
+                    <th style="padding: 8px; text-align: right;">执行次数</th>
+                </tr>
+                {{range .SlowestTasks}}
+                <tr>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee;">{{.TaskName}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right;">{{seconds .AverageExecutionTime}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right;">{{.TotalExecutions}}</td>
+                </tr>
+                {{end}}
+            </table>
+            {{end}}
+            {{if .Trends}}
+            <h3 style="margin: 20px 0 10px;">每日趋势</h3>
+            <table style="width: 100%; border-collapse: collapse;">
+                <tr style="background-color: #f8f9fa;">
+                    <th style="padding: 8px; text-align: left;">日期</th>
+                    <th style="padding: 8px; text-align: right;">执行次数</th>
+                    <th style="padding: 8px; text-align: right;">失败次数</th>
+                    <th style="padding: 8px; text-align: right;">成功率</th>
+                </tr>
+                {{range .Trends}}
+                <tr>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee;">{{.Date}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right;">{{.TotalExecutions}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right;">{{.FailureCount}}</td>
+                    <td style="padding: 8px; border-bottom: 1px solid #eee; text-align: right;">{{rate .SuccessRate}}</td>
+                </tr>
+                {{end}}
+            </table>
+            {{end}}
+        </div>
+        <div style="padding: 20px; background-color: #f8f9fa; border-radius: 0 0 8px 8px; text-align: center; color: #6c757d; font-size: 12px;">
+            此邮件由 Crontab 管理系统自动发送，请勿回复。
+        </div>
+    </div>
+</body>
+</html>`))
+
+// digestTitle 报表摘要标题
+func digestTitle(report *entity.DigestReport) string {
+	return fmt.Sprintf("%s - %s执行报表", report.Name, digestPeriodLabel(report.Period))
+}
+
+// renderDigestMarkdown 渲染 Markdown 格式的报表摘要
+func renderDigestMarkdown(report *entity.DigestReport) (string, error) {
+	var buf bytes.Buffer
+	if err := digestMarkdownTemplate.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// renderDigestEmail 渲染 HTML 格式的报表摘要
+func renderDigestEmail(report *entity.DigestReport) (string, error) {
+	var buf bytes.Buffer
+	if err := digestEmailTemplate.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// digestPeriodLabel 统计周期名称
+func digestPeriodLabel(period string) string {
+	if period == entity.DigestPeriodWeekly {
+		return "每周"
+	}
+	return "每日"
+}
+
+// formatExecutionsChange 格式化执行次数环比变化
+func formatExecutionsChange(change *float64) string {
+	if change == nil {
+		return "上期无执行"
+	}
+	return fmt.Sprintf("较上期 %+.1f%%", *change)
+}
+
+// formatSuccessRateChange 格式化成功率变化
+func formatSuccessRateChange(change *float64) string {
+	if change == nil {
+		return "无对比数据"
+	}
+	return fmt.Sprintf("较上期 %+.1f 个百分点", *change)
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// emailAttachment 邮件附件
+type emailAttachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// emailMessage 待发送的邮件。正文为 multipart/alternative（纯文本和 HTML），有附件时外层为 multipart/mixed；
+// 密送地址只用于投递，不写入邮件头
+type emailMessage struct {
+	From        *mail.Address
+	To          []*mail.Address
+	Cc          []*mail.Address
+	Subject     string
+	HTML        string
+	Text        string // 为空时由 HTML 转换得到
+	Attachments []emailAttachment
+	Date        time.Time
+	MessageID   string
+}
+
+// newEmailMessageID 生成 Message-ID，域名取自发件人地址
+func newEmailMessageID(from *mail.Address, now time.Time) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 && at < len(from.Address)-1 {
+		domain = from.Address[at+1:]
+	}
+
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), hex.EncodeToString(buf), domain)
+}
+
+// Bytes 生成符合 RFC 5322 的邮件内容，主题按 RFC 2047 编码，正文使用 quoted-printable 编码
+func (m *emailMessage) Bytes() ([]byte, error) {
+	text := m.Text
+	if text == "" {
+		text = htmlToText(m.HTML)
+	}
+
+	var alternative bytes.Buffer
+	alternativeWriter := multipart.NewWriter(&alternative)
+	if err := writeQuotedPrintablePart(alternativeWriter, "text/plain; charset=UTF-8", text); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintablePart(alternativeWriter, "text/html; charset=UTF-8", m.HTML); err != nil {
+		return nil, err
+	}
+	if err := alternativeWriter.Close(); err != nil {
+		return nil, err
+	}
+	alternativeType := "multipart/alternative; boundary=" + alternativeWriter.Boundary()
+
+	var buf bytes.Buffer
+	writeEmailHeader(&buf, "From", m.From.String())
+	writeEmailHeader(&buf, "To", joinAddresses(m.To))
+	if len(m.Cc) > 0 {
+		writeEmailHeader(&buf, "Cc", joinAddresses(m.Cc))
+	}
+	subject := strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(m.Subject)
+	writeEmailHeader(&buf, "Subject", mime.QEncoding.Encode("UTF-8", subject))
+	writeEmailHeader(&buf, "Date", m.Date.Format(time.RFC1123Z))
+	writeEmailHeader(&buf, "Message-ID", m.MessageID)
+	writeEmailHeader(&buf, "MIME-Version", "1.0")
+
+	if len(m.Attachments) == 0 {
+		writeEmailHeader(&buf, "Content-Type", alternativeType)
+		buf.WriteString("\r\n")
+		buf.Write(alternative.Bytes())
+		return buf.Bytes(), nil
+	}
+
+	mixedWriter := multipart.NewWriter(&buf)
+	writeEmailHeader(&buf, "Content-Type", "multipart/mixed; boundary="+mixedWriter.Boundary())
+	buf.WriteString("\r\n")
+
+	part, err := mixedWriter.CreatePart(textproto.MIMEHeader{"Content-Type": {alternativeType}})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(alternative.Bytes()); err != nil {
+		return nil, err
+	}
+
+	for _, attachment := range m.Attachments {
+		if err := writeAttachmentPart(mixedWriter, attachment); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixedWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeEmailHeader 写入一行邮件头，去除值中的换行避免邮件头注入
+func writeEmailHeader(buf *bytes.Buffer, name, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	fmt.Fprintf(buf, "%s: %s\r\n", name, value)
+}
+
+// joinAddresses 将地址列表格式化为邮件头的值，非 ASCII 的名称会被编码
+func joinAddresses(addresses []*mail.Address) string {
+	values := make([]string, len(addresses))
+	for i, address := range addresses {
+		values[i] = address.String()
+	}
+	return strings.Join(values, ", ")
+}
+
+func writeQuotedPrintablePart(writer *multipart.Writer, contentType, content string) error {
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := io.WriteString(qp, content); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeAttachmentPart(writer *multipart.Writer, attachment emailAttachment) error {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+	})
+	if err != nil {
+		return err
+	}
+
+	// base64 编码后按 76 个字符换行
+	encoded := base64.StdEncoding.EncodeToString(attachment.Data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(part, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err = io.WriteString(part, encoded+"\r\n")
+	return err
+}
+
+var (
+	htmlHiddenPattern    = regexp.MustCompile(`(?is)<(head|style|script)\b.*?</(head|style|script)>`)
+	htmlIndentPattern    = regexp.MustCompile(`>\s*\n\s*<`)
+	htmlBreakPattern     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|tr|li|pre|table|h[1-6])>`)
+	htmlCellPattern      = regexp.MustCompile(`(?i)</t[dh]>`)
+	htmlTagPattern       = regexp.MustCompile(`<[^>]*>`)
+	blankLinesPattern    = regexp.MustCompile(`\n{3,}`)
+	trailingSpacePattern = regexp.MustCompile(`[ \t]+\n`)
+)
+
+// htmlToText 将 HTML 正文转换为纯文本，用作邮件的纯文本部分。模板缩进产生的空白被忽略，
+// <pre> 中的换行保留
+func htmlToText(content string) string {
+	content = htmlHiddenPattern.ReplaceAllString(content, "")
+	content = htmlIndentPattern.ReplaceAllString(content, "><")
+	content = htmlBreakPattern.ReplaceAllString(content, "\n")
+	content = htmlCellPattern.ReplaceAllString(content, " ")
+	content = htmlTagPattern.ReplaceAllString(content, "")
+	content = html.UnescapeString(content)
+	content = trailingSpacePattern.ReplaceAllString(content, "\n")
+	content = blankLinesPattern.ReplaceAllString(content, "\n\n")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"bytes"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEmailMessage 测试使用的邮件
+func testEmailMessage() *emailMessage {
+	return &emailMessage{
+		From:      &mail.Address{Name: "Crontab", Address: "crontab@example.com"},
+		To:        []*mail.Address{{Address: "alice@example.com"}},
+		Subject:   "backup",
+		HTML:      "<p>ok</p>",
+		Date:      time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC),
+		MessageID: "<1.abc@example.com>",
+	}
+}
+
+func TestEmailMessageBytesEncodesSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject string
+		want    string
+	}{
+		{"ascii", "backup done", "backup done"},
+		{"utf-8", "任务执行失败: 备份", "任务执行失败: 备份"},
+		{"newlines folded", "第一行\r\n第二行\n第三行", "第一行 第二行 第三行"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testEmailMessage()
+			m.Subject = tt.subject
+			data, err := m.Bytes()
+			if err != nil {
+				t.Fatalf("Bytes: %v", err)
+			}
+
+			message, err := mail.ReadMessage(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("read message: %v", err)
+			}
+			raw := message.Header.Get("Subject")
+			for _, r := range raw {
+				if r > 127 {
+					t.Fatalf("Subject header contains non-ASCII characters: %q", raw)
+				}
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(raw)
+			if err != nil || subject != tt.want {
+				t.Errorf("decoded Subject = %q (%v), want %q", subject, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailMessageBytesStripsHeaderInjection(t *testing.T) {
+	m := testEmailMessage()
+	m.Subject = "alert\r\nBcc: victim@example.com"
+	m.MessageID = "<1.abc@example.com>\r\nX-Injected: yes"
+	m.To = []*mail.Address{{Name: "Alice\r\nX-Name-Injected: yes", Address: "alice@example.com"}}
+
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	for _, name := range []string{"Bcc", "X-Injected", "X-Name-Injected"} {
+		if got := message.Header.Get(name); got != "" {
+			t.Errorf("injected header %s = %q", name, got)
+		}
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if subject != "alert Bcc: victim@example.com" {
+		t.Errorf("Subject = %q, want newlines replaced", subject)
+	}
+}
+
+func TestEmailMessageBytesCcHeader(t *testing.T) {
+	m := testEmailMessage()
+	m.Cc = []*mail.Address{{Name: "鲍勃", Address: "bob@example.com"}, {Address: "carol@example.com"}}
+
+	data, err := m.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	cc, err := message.Header.AddressList("Cc")
+	if err != nil {
+		t.Fatalf("parse Cc: %v", err)
+	}
+	if len(cc) != 2 || cc[0].Name != "鲍勃" || cc[0].Address != "bob@example.com" || cc[1].Address != "carol@example.com" {
+		t.Errorf("Cc = %v", cc)
+	}
+	if raw := message.Header.Get("Cc"); strings.Contains(raw, "鲍勃") {
+		t.Errorf("Cc header = %q, want encoded non-ASCII name", raw)
+	}
+
+	// 没有抄送时不写 Cc 头
+	m.Cc = nil
+	data, _ = m.Bytes()
+	if bytes.Contains(data, []byte("\r\nCc:")) {
+		t.Error("empty Cc list produced a Cc header")
+	}
+}
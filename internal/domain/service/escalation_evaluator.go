@@ -0,0 +1,180 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// escalationInterval 检查待升级告警的间隔
+const escalationInterval = 30 * time.Second
+
+// escalationWake 任务开始告警时唤醒同一进程中的 EscalationEvaluator，使延迟为 0 的步骤立即执行
+var escalationWake = make(chan struct{}, 1)
+
+// wakeEscalations 非阻塞地唤醒升级检查
+func wakeEscalations() {
+	select {
+	case escalationWake <- struct{}{}:
+	default:
+	}
+}
+
+// EscalationEvaluator 告警升级检查器，定期检查告警中的任务：最近一次执行仍失败且告警未确认时，
+// 按任务的升级策略依次通知各步骤的渠道
+type EscalationEvaluator struct {
+	alertRepo   repository.TaskAlertRepository
+	taskRepo    repository.TaskRepository
+	taskLogRepo repository.TaskLogRepository
+	channelRepo repository.NotificationChannelRepository
+	policyRepo  repository.EscalationPolicyRepository
+	outbox      *NotificationOutbox
+	stop        chan struct{}
+	wg          sync.WaitGroup
+}
+
+func NewEscalationEvaluator(alertRepo repository.TaskAlertRepository, taskRepo repository.TaskRepository, taskLogRepo repository.TaskLogRepository, channelRepo repository.NotificationChannelRepository, policyRepo repository.EscalationPolicyRepository, deliveryRepo repository.NotificationDeliveryRepository) *EscalationEvaluator {
+	return &EscalationEvaluator{
+		alertRepo:   alertRepo,
+		taskRepo:    taskRepo,
+		taskLogRepo: taskLogRepo,
+		channelRepo: channelRepo,
+		policyRepo:  policyRepo,
+		outbox:      NewNotificationOutbox(deliveryRepo),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start 启动升级检查
+func (e *EscalationEvaluator) Start() {
+	e.wg.Add(1)
+	go e.loop()
+	log.Println("Escalation evaluator started")
+}
+
+// Stop 停止升级检查
+func (e *EscalationEvaluator) Stop() {
+	close(e.stop)
+	e.wg.Wait()
+}
+
+func (e *EscalationEvaluator) loop() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(escalationInterval)
+	defer ticker.Stop()
+
+	for {
+		e.Evaluate(time.Now())
+
+		select {
+		case <-e.stop:
+			return
+		case <-ticker.C:
+		case <-escalationWake:
+		}
+	}
+}
+
+// Evaluate 检查所有告警中的任务，执行到期的升级步骤
+func (e *EscalationEvaluator) Evaluate(now time.Time) {
+	states, err := e.alertRepo.FindFiring()
+	if err != nil {
+		log.Printf("Failed to load firing alerts: %v", err)
+		return
+	}
+
+	for _, state := range states {
+		if state.Acknowledged() {
+			continue
+		}
+		e.escalate(state.TaskID, now)
+	}
+}
+
+// escalate 执行任务到期的升级步骤。重新读取告警状态，避免覆盖执行器在此期间的更新
+func (e *EscalationEvaluator) escalate(taskID int, now time.Time) {
+	alertStateMu.Lock()
+	defer alertStateMu.Unlock()
+
+	state, err := e.alertRepo.FindByTaskID(taskID)
+	if err != nil || state == nil || state.Status != entity.AlertStatusFiring || state.Acknowledged() || state.FiringSince == nil {
+		return
+	}
+
+	task, err := e.taskRepo.FindByID(taskID)
+	if err != nil || task.EscalationPolicyID == nil {
+		return
+	}
+	policy, err := e.policyRepo.FindByID(*task.EscalationPolicyID)
+	if err != nil {
+		log.Printf("Failed to load escalation policy for task %s: %v", task.Name, err)
+		return
+	}
+	steps, err := policy.ParseSteps()
+	if err != nil {
+		log.Printf("Invalid escalation policy %s: %v", policy.Name, err)
+		return
+	}
+	if state.EscalationLevel >= len(steps) {
+		return
+	}
+
+	// 以最近一次执行日志为准，任务已恢复时等待执行器更新告警状态
+	latest, err := e.taskLogRepo.GetLatestLog(task.ID)
+	if err != nil || latest == nil || latest.Success {
+		return
+	}
+
+	escalated := false
+	for state.EscalationLevel < len(steps) {
+		step := steps[state.EscalationLevel]
+		if now.Before(state.FiringSince.Add(time.Duration(step.Delay) * time.Minute)) {
+			break
+		}
+		state.EscalationLevel++
+		escalated = true
+
+		channels, err := e.channelRepo.FindByIDs(step.ChannelIDs)
+		if err != nil {
+			log.Printf("Failed to load escalation channels for task %s: %v", task.Name, err)
+			continue
+		}
+
+		message := newTaskNotificationMessage(e.taskLogRepo, task, latest)
+		message.Alert = alertInfo(entity.AlertEventEscalate, state)
+		message.Alert.EscalationLevel = state.EscalationLevel
+		log.Printf("Escalating alert for task %s to level %d", task.Name, state.EscalationLevel)
+		e.outbox.Enqueue(channels, message)
+	}
+
+	if escalated {
+		if err := e.alertRepo.Save(state); err != nil {
+			log.Printf("Failed to save alert state for task %s: %v", task.Name, err)
+		}
+	}
+}
+
+// escalatedChannelIDs 本轮告警已通知过的升级步骤中的渠道ID，用于发送恢复通知
+func escalatedChannelIDs(policyRepo repository.EscalationPolicyRepository, task *entity.Task, level int) []int {
+	if task.EscalationPolicyID == nil || level <= 0 {
+		return nil
+	}
+	policy, err := policyRepo.FindByID(*task.EscalationPolicyID)
+	if err != nil {
+		return nil
+	}
+	steps, err := policy.ParseSteps()
+	if err != nil {
+		return nil
+	}
+
+	var channelIDs []int
+	for i := 0; i < level && i < len(steps); i++ {
+		channelIDs = append(channelIDs, steps[i].ChannelIDs...)
+	}
+	return channelIDs
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// heartbeatInterval 检查错过心跳的间隔
+const heartbeatInterval = 30 * time.Second
+
+// heartbeatMu 串行化心跳上报和错过心跳检查，避免同一任务的心跳状态被并发覆盖
+var heartbeatMu sync.Mutex
+
+var (
+	// ErrNotHeartbeatTask 任务不是心跳任务
+	ErrNotHeartbeatTask = errors.New("任务不是心跳任务")
+	// ErrInvalidHeartbeatState 心跳状态无效
+	ErrInvalidHeartbeatState = errors.New("无效的心跳状态，可选值: start, success, fail")
+)
+
+// HeartbeatMonitor 心跳任务监控：记录外部任务上报的心跳，并在计划时间加宽限时间内未收到心跳时
+// 记录一次失败的执行日志，按任务的通知配置发送通知
+type HeartbeatMonitor struct {
+	taskRepo      repository.TaskRepository
+	heartbeatRepo repository.TaskHeartbeatRepository
+	taskLogRepo   repository.TaskLogRepository
+	executor      *TaskExecutor
+	stop          chan struct{}
+	wg            sync.WaitGroup
+}
+
+func NewHeartbeatMonitor(taskRepo repository.TaskRepository, taskLogRepo repository.TaskLogRepository, channelRepo repository.NotificationChannelRepository, deliveryRepo repository.NotificationDeliveryRepository, alertRepo repository.TaskAlertRepository, policyRepo repository.EscalationPolicyRepository, heartbeatRepo repository.TaskHeartbeatRepository) *HeartbeatMonitor {
+	return &HeartbeatMonitor{
+		taskRepo:      taskRepo,
+		heartbeatRepo: heartbeatRepo,
+		taskLogRepo:   taskLogRepo,
+		executor:      NewTaskExecutor(taskRepo, taskLogRepo, channelRepo, deliveryRepo, alertRepo, policyRepo, nil), // 只用于记录结果和发送通知，不执行任务
+		stop:          make(chan struct{}),
+	}
+}
+
+// Start 启动错过心跳检查
+func (m *HeartbeatMonitor) Start() {
+	m.wg.Add(1)
+	go m.loop()
+	log.Println("Heartbeat monitor started")
+}
+
+// Stop 停止错过心跳检查
+func (m *HeartbeatMonitor) Stop() {
+	close(m.stop)
+	m.wg.Wait()
+}
+
+func (m *HeartbeatMonitor) loop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		m.Check(time.Now())
+
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check 检查所有心跳任务，为超过截止时间仍未收到心跳的任务记录失败日志并发送通知
+func (m *HeartbeatMonitor) Check(now time.Time) {
+	tasks, err := m.taskRepo.FindHeartbeats()
+	if err != nil {
+		log.Printf("Failed to load heartbeat tasks: %v", err)
+		return
+	}
+
+	for _, task := range tasks {
+		if taskLog := m.checkTask(task, now); taskLog != nil {
+			m.executor.finishExecution(task, taskLog)
+		}
+	}
+}
+
+// checkTask 检查单个心跳任务，错过心跳时返回已保存的失败日志
+func (m *HeartbeatMonitor) checkTask(task *entity.Task, now time.Time) *entity.TaskLog {
+	heartbeatMu.Lock()
+	defer heartbeatMu.Unlock()
+
+	heartbeat, err := m.heartbeatRepo.FindByTaskID(task.ID)
+	if err != nil {
+		log.Printf("Failed to load heartbeat state for task %s: %v", task.Name, err)
+		return nil
+	}
+	if heartbeat == nil {
+		heartbeat = &entity.TaskHeartbeat{TaskID: task.ID}
+	}
+
+	// 禁用的任务不检查，重新启用后从下一个计划时间开始监控
+	if !task.Enabled {
+		if heartbeat.ExpectedAt != nil || heartbeat.StartedAt != nil {
+			heartbeat.ExpectedAt, heartbeat.StartedAt = nil, nil
+			m.save(task, heartbeat)
+		}
+		return nil
+	}
+
+	// 首次检查或调度变化时从当前时间开始计算下一个计划时间
+	if heartbeat.ExpectedAt == nil || heartbeat.Schedule != task.Schedule {
+		if err := m.arm(task, heartbeat, now); err != nil {
+			log.Printf("Invalid schedule for heartbeat task %s: %v", task.Name, err)
+			return nil
+		}
+		m.save(task, heartbeat)
+		return nil
+	}
+
+	deadline := heartbeat.Deadline(task.GracePeriod)
+	if now.Before(*deadline) {
+		return nil
+	}
+
+	taskLog := &entity.TaskLog{
+		TaskID:        task.ID,
+		TaskName:      task.Name,
+		TriggerSource: entity.TriggerHeartbeat,
+		StartTime:     *heartbeat.ExpectedAt,
+		EndTime:       now,
+		Success:       false,
+		Error:         fmt.Sprintf("未在宽限时间内收到心跳，计划时间 %s", heartbeat.ExpectedAt.Format("2006-01-02 15:04:05")),
+	}
+	if heartbeat.StartedAt != nil {
+		taskLog.StartTime = *heartbeat.StartedAt
+		taskLog.Error = fmt.Sprintf("任务已于 %s 开始，但未在宽限时间内收到结束心跳", heartbeat.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	log.Printf("Heartbeat missed for task %s: %s", task.Name, taskLog.Error)
+	if err := m.taskLogRepo.Create(taskLog); err != nil {
+		log.Printf("Failed to save task log for task %s: %v", task.Name, err)
+	}
+
+	// 错过多个计划时间时只记录一次，从当前时间开始等待下一次心跳
+	heartbeat.StartedAt = nil
+	heartbeat.LastMissedAt = &now
+	if err := m.arm(task, heartbeat, now); err != nil {
+		log.Printf("Invalid schedule for heartbeat task %s: %v", task.Name, err)
+	}
+	m.save(task, heartbeat)
+	return taskLog
+}
+
+// Ping 记录心跳任务的一次上报。start 只记录开始时间；success 和 fail 记录一条执行日志，
+// 执行时间从开始心跳算起，并按任务的通知配置发送通知
+func (m *HeartbeatMonitor) Ping(task *entity.Task, state, output string, now time.Time) (*entity.TaskLog, error) {
+	if !task.IsHeartbeat() {
+		return nil, ErrNotHeartbeatTask
+	}
+	if !entity.ValidHeartbeatState(state) {
+		return nil, ErrInvalidHeartbeatState
+	}
+
+	taskLog, err := m.recordPing(task, state, output, now)
+	if err != nil || taskLog == nil {
+		return nil, err
+	}
+	m.executor.finishExecution(task, taskLog)
+	return taskLog, nil
+}
+
+func (m *HeartbeatMonitor) recordPing(task *entity.Task, state, output string, now time.Time) (*entity.TaskLog, error) {
+	heartbeatMu.Lock()
+	defer heartbeatMu.Unlock()
+
+	heartbeat, err := m.heartbeatRepo.FindByTaskID(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if heartbeat == nil {
+		heartbeat = &entity.TaskHeartbeat{TaskID: task.ID}
+	}
+	heartbeat.LastPingAt = &now
+	heartbeat.LastPingState = state
+
+	if state == entity.HeartbeatStateStart {
+		heartbeat.StartedAt = &now
+		return nil, m.heartbeatRepo.Save(heartbeat)
+	}
+
+	taskLog := &entity.TaskLog{
+		TaskID:        task.ID,
+		TaskName:      task.Name,
+		TriggerSource: entity.TriggerHeartbeat,
+		StartTime:     now,
+		EndTime:       now,
+		Success:       state == entity.HeartbeatStateSuccess,
+		Output:        output,
+	}
+	if heartbeat.StartedAt != nil {
+		taskLog.StartTime = *heartbeat.StartedAt
+	}
+	if !taskLog.Success {
+		taskLog.Error = "外部任务上报执行失败"
+	}
+	if err := m.taskLogRepo.Create(taskLog); err != nil {
+		return nil, err
+	}
+
+	heartbeat.StartedAt = nil
+	if task.Enabled {
+		if err := m.advance(task, heartbeat, now); err != nil {
+			log.Printf("Invalid schedule for heartbeat task %s: %v", task.Name, err)
+		}
+	}
+	if err := m.heartbeatRepo.Save(heartbeat); err != nil {
+		log.Printf("Failed to save heartbeat state for task %s: %v", task.Name, err)
+	}
+	return taskLog, nil
+}
+
+// arm 从 now 开始计算下一个计划时间
+func (m *HeartbeatMonitor) arm(task *entity.Task, heartbeat *entity.TaskHeartbeat, now time.Time) error {
+	schedule, err := cron.ParseStandard(task.Schedule)
+	if err != nil {
+		heartbeat.ExpectedAt = nil
+		return err
+	}
+	next := schedule.Next(now)
+	heartbeat.Schedule = task.Schedule
+	heartbeat.ExpectedAt = &next
+	return nil
+}
+
+// advance 收到结束心跳后计算下一个计划时间。心跳在计划时间之前的宽限时间内到达时（如时钟偏差），
+// 视为本次计划时间的心跳，从计划时间之后开始计算
+func (m *HeartbeatMonitor) advance(task *entity.Task, heartbeat *entity.TaskHeartbeat, now time.Time) error {
+	base := now
+	if heartbeat.ExpectedAt != nil && heartbeat.Schedule == task.Schedule && heartbeat.ExpectedAt.After(now) &&
+		heartbeat.ExpectedAt.Sub(now) <= time.Duration(task.GracePeriod)*time.Second {
+		base = *heartbeat.ExpectedAt
+	}
+	return m.arm(task, heartbeat, base)
+}
+
+func (m *HeartbeatMonitor) save(task *entity.Task, heartbeat *entity.TaskHeartbeat) {
+	if err := m.heartbeatRepo.Save(heartbeat); err != nil {
+		log.Printf("Failed to save heartbeat state for task %s: %v", task.Name, err)
+	}
+}
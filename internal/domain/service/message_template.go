@@ -0,0 +1,263 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplateFiles embed.FS
+
+// messageTemplateFuncs 消息模板中可用的函数
+var messageTemplateFuncs = template.FuncMap{
+	// json 将值序列化为 JSON
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// join 用分隔符连接字符串列表，如 {{join ", " .Anomalies}}
+	"join": func(sep string, items []string) string {
+		return strings.Join(items, sep)
+	},
+	// truncate 截断过长的内容，如 {{.Output | truncate 500}}
+	"truncate": func(limit int, value string) string {
+		return truncateRunes(value, limit)
+	},
+	// default 值为空时使用默认值，如 {{.Error | default "无"}}
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"trim":  strings.TrimSpace,
+}
+
+// defaultMessageTitles 默认通知标题模板
+var defaultMessageTitles = map[string]string{
+	entity.LanguageZh: "{{if and .Alert .Alert.Resolved}}任务已恢复{{else if and .Alert .Alert.Escalated}}任务告警升级{{else}}任务执行通知{{end}} - {{.TaskName}}",
+	entity.LanguageEn: "{{if and .Alert .Alert.Resolved}}Task recovered{{else if and .Alert .Alert.Escalated}}Task alert escalated{{else}}Task notification{{end}} - {{.TaskName}}",
+}
+
+// messageTemplateData 消息模板数据，可直接访问通知消息的字段，如 .TaskName .Task .Run .Statistics
+type messageTemplateData struct {
+	*entity.NotificationMessage
+	Lang string
+}
+
+// defaultMessageTemplate 读取内置的默认模板，name 为 templates 目录下的模板名称（不含语言和扩展名）
+func defaultMessageTemplate(name, lang string) entity.MessageTemplate {
+	lang = normalizeLanguage(lang)
+	body, err := defaultTemplateFiles.ReadFile(fmt.Sprintf("templates/%s.%s.tmpl", name, lang))
+	if err != nil {
+		panic(fmt.Sprintf("default message template %s.%s is missing: %v", name, lang, err))
+	}
+
+	tmpl := entity.MessageTemplate{Title: defaultMessageTitles[lang]}
+	if strings.HasPrefix(name, "task_html") {
+		tmpl.HTMLBody = string(body)
+	} else {
+		tmpl.Body = string(body)
+	}
+	return tmpl
+}
+
+// normalizeLanguage 规范化模板语言，未知语言使用中文
+func normalizeLanguage(lang string) string {
+	if lang == entity.LanguageEn {
+		return entity.LanguageEn
+	}
+	return entity.LanguageZh
+}
+
+// resolveMessageTemplate 按优先级合并消息模板：标题和正文分别取第一个非空的模板，都为空时使用渠道默认模板
+func resolveMessageTemplate(notifier Notifier, lang string, templates ...entity.MessageTemplate) entity.MessageTemplate {
+	result := notifier.DefaultTemplate(normalizeLanguage(lang))
+	format := notifier.Format()
+
+	for i := len(templates) - 1; i >= 0; i-- {
+		if templates[i].Title != "" {
+			result.Title = templates[i].Title
+		}
+		if body := templates[i].BodyFor(format); body != "" {
+			if format == entity.MessageFormatHTML {
+				result.HTMLBody = body
+			} else {
+				result.Body = body
+			}
+		}
+	}
+	return result
+}
+
+// renderMessage 按合并后的模板渲染通知，返回填充了 Title 和 Content 的消息副本
+func renderMessage(notifier Notifier, lang string, message *entity.NotificationMessage, templates ...entity.MessageTemplate) (*entity.NotificationMessage, error) {
+	tmpl := resolveMessageTemplate(notifier, lang, templates...)
+	title, content, err := renderMessageTemplate(notifier.Format(), tmpl, message, lang)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered := *message
+	rendered.Title, rendered.Content = title, content
+	return &rendered, nil
+}
+
+// renderMessageTemplate 渲染通知标题和指定格式的正文。标题始终按纯文本渲染，HTML 正文会自动转义
+func renderMessageTemplate(format string, tmpl entity.MessageTemplate, message *entity.NotificationMessage, lang string) (string, string, error) {
+	data := &messageTemplateData{NotificationMessage: message, Lang: normalizeLanguage(lang)}
+
+	title, err := executeTextTemplate("title", tmpl.Title, data)
+	if err != nil {
+		return "", "", fmt.Errorf("标题模板错误: %v", err)
+	}
+
+	var content string
+	if format == entity.MessageFormatHTML {
+		content, err = executeHTMLTemplate("body", tmpl.HTMLBody, data)
+	} else {
+		content, err = executeTextTemplate("body", tmpl.Body, data)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("正文模板错误: %v", err)
+	}
+
+	return strings.TrimSpace(title), strings.TrimSpace(content), nil
+}
+
+// executeTextTemplate 使用 text/template 渲染模板
+func executeTextTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := template.New(name).Funcs(messageTemplateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// executeHTMLTemplate 使用 html/template 渲染模板，模板数据中的内容会按上下文转义
+func executeHTMLTemplate(name, text string, data interface{}) (string, error) {
+	tmpl, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(messageTemplateFuncs)).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// validateMessageTemplate 使用示例数据渲染模板，检查语法和字段引用是否正确
+func validateMessageTemplate(tmpl entity.MessageTemplate) error {
+	message := sampleNotificationMessage(entity.LanguageZh, false)
+	if _, _, err := renderMessageTemplate(entity.MessageFormatMarkdown, tmpl, message, entity.LanguageZh); err != nil {
+		return err
+	}
+	data := &messageTemplateData{NotificationMessage: message, Lang: entity.LanguageZh}
+	if _, err := executeHTMLTemplate("html_body", tmpl.HTMLBody, data); err != nil {
+		return fmt.Errorf("HTML正文模板错误: %v", err)
+	}
+	return nil
+}
+
+// sampleNotificationMessage 预览模板使用的示例数据
+func sampleNotificationMessage(lang string, success bool) *entity.NotificationMessage {
+	now := time.Now()
+	start := now.Add(-42 * time.Second)
+	lastRun := now.Add(-time.Hour)
+
+	name, output, errorMessage := "数据库备份", "backup finished: 3 databases, 1.2 GB", "exit status 1"
+	anomalies := []string{"执行时间 42s 明显高于平均值 12.5s"}
+	if normalizeLanguage(lang) == entity.LanguageEn {
+		name = "Database backup"
+		anomalies = []string{"duration 42s is well above the average of 12.5s"}
+	}
+
+	task := &entity.Task{
+		ID:              1,
+		Name:            name,
+		Schedule:        "0 0 2 * * *",
+		Command:         "/opt/scripts/backup.sh",
+		Enabled:         true,
+		NotifyOnFailure: true,
+	}
+	run := &entity.TaskLog{
+		ID:            1024,
+		TaskID:        task.ID,
+		TaskName:      task.Name,
+		StartTime:     start,
+		EndTime:       now,
+		Success:       success,
+		Output:        output,
+		TriggerSource: entity.TriggerSchedule,
+	}
+	var alert *entity.AlertInfo
+	if !success {
+		run.Error = errorMessage
+		alert = &entity.AlertInfo{
+			Event:               entity.AlertEventRepeat,
+			ConsecutiveFailures: 3,
+			FailingSince:        now.Add(-2 * time.Hour).Format("2006-01-02 15:04:05"),
+			Suppressed:          1,
+		}
+	}
+
+	message := &entity.NotificationMessage{
+		TaskName:  task.Name,
+		Success:   success,
+		StartTime: start.Format("2006-01-02 15:04:05"),
+		EndTime:   now.Format("2006-01-02 15:04:05"),
+		Duration:  now.Sub(start).String(),
+		Output:    run.Output,
+		Error:     run.Error,
+		Anomalies: anomalies,
+		Alert:     alert,
+		Task:      task,
+		Run:       run,
+		Statistics: &entity.TaskStatistics{
+			TaskID:               task.ID,
+			TaskName:             task.Name,
+			TotalExecutions:      120,
+			SuccessExecutions:    114,
+			FailureExecutions:    6,
+			SuccessRate:          95,
+			AverageExecutionTime: 12.5,
+			LastExecutionTime:    &lastRun,
+			LastExecutionStatus:  true,
+		},
+	}
+	return message
+}
+
+// testNotificationMessage 测试通知使用的消息
+func testNotificationMessage(lang string) *entity.NotificationMessage {
+	message := sampleNotificationMessage(lang, true)
+	message.TaskName = "测试任务"
+	message.Output = "这是一条测试通知消息"
+	if normalizeLanguage(lang) == entity.LanguageEn {
+		message.TaskName = "Test task"
+		message.Output = "This is a test notification"
+	}
+	message.Task.Name = message.TaskName
+	message.Run.TaskName = message.TaskName
+	message.Run.Output = message.Output
+	message.Statistics.TaskName = message.TaskName
+	message.Anomalies = nil
+	return message
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// defaultChannelTimeout 渠道未设置超时时间时的发送超时
+const defaultChannelTimeout = 10 * time.Second
+
+// channelTimeout 获取渠道单次发送的超时时间
+func channelTimeout(channel *entity.NotificationChannel) time.Duration {
+	if channel.Timeout <= 0 {
+		return defaultChannelTimeout
+	}
+	return time.Duration(channel.Timeout) * time.Second
+}
+
+// deliver 在渠道超时时间内发送已渲染的任务执行通知，返回渠道的响应摘要
+func (ns *NotificationService) deliver(channel *entity.NotificationChannel, message *entity.NotificationMessage) (string, error) {
+	notifier, ok := ns.registry.Get(channel.Type)
+	if !ok {
+		return "", fmt.Errorf("未知的通知类型: %s", channel.Type)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), channelTimeout(channel))
+	defer cancel()
+	ctx, recorder := withResponseRecorder(ctx)
+
+	err := notifier.Send(ctx, json.RawMessage(channel.Config), message)
+	observer.ObserveNotification(channel.Type, err)
+	return recorder.response, err
+}
+
+// SendDigestToChannels 向通知渠道发送报表摘要，返回发送失败的渠道及原因
+func (ns *NotificationService) SendDigestToChannels(channels []*entity.NotificationChannel, report *entity.DigestReport) error {
+	var failures []string
+	for _, channel := range channels {
+		notifier, ok := ns.registry.Get(channel.Type)
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s: 未知的通知类型: %s", channel.Name, channel.Type))
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), channelTimeout(channel))
+		err := notifier.SendDigest(ctx, json.RawMessage(channel.Config), report)
+		cancel()
+		observer.ObserveNotification(channel.Type, err)
+		if err != nil {
+			log.Printf("Failed to send digest %s via channel %s: %v", report.Name, channel.Name, err)
+			failures = append(failures, fmt.Sprintf("%s: %v", channel.Name, err))
+		} else {
+			log.Printf("Digest %s sent successfully via channel %s", report.Name, channel.Name)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("发送报表摘要失败: %s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// HasNotifier 通知类型是否已注册
+func (ns *NotificationService) HasNotifier(notifierType string) bool {
+	_, ok := ns.registry.Get(notifierType)
+	return ok
+}
+
+// ValidateChannel 校验通知渠道的类型和配置
+func (ns *NotificationService) ValidateChannel(channel *entity.NotificationChannel) error {
+	notifier, ok := ns.registry.Get(channel.Type)
+	if !ok {
+		return fmt.Errorf("未知的通知类型: %s", channel.Type)
+	}
+	return notifier.Validate(json.RawMessage(channel.Config))
+}
+
+// TestChannel 向通知渠道发送一条测试通知
+func (ns *NotificationService) TestChannel(channel *entity.NotificationChannel) error {
+	if err := ns.ValidateChannel(channel); err != nil {
+		return err
+	}
+	if err := ns.ValidateMessageTemplate(channel.MessageTemplate); err != nil {
+		return err
+	}
+
+	notifier, _ := ns.registry.Get(channel.Type)
+	message := testNotificationMessage(channel.Language)
+	rendered, err := renderMessage(notifier, channel.Language, message, ns.messageTemplates(channel, message)...)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), channelTimeout(channel))
+	defer cancel()
+	err = notifier.Send(ctx, json.RawMessage(channel.Config), rendered)
+	observer.ObserveNotification(channel.Type, err)
+	return err
+}
+
+// ValidateMessageTemplate 校验 JSON 格式存储的消息模板，使用示例数据渲染以检查字段引用
+func (ns *NotificationService) ValidateMessageTemplate(value string) error {
+	tmpl, err := entity.ParseMessageTemplate(value)
+	if err != nil {
+		return fmt.Errorf("消息模板格式错误: %v", err)
+	}
+	return validateMessageTemplate(tmpl)
+}
+
+// PreviewMessage 使用示例数据渲染指定渠道类型的通知，templates 按优先级从高到低排列，未设置的部分使用默认模板
+func (ns *NotificationService) PreviewMessage(channelType, lang string, success bool, templates ...entity.MessageTemplate) (*entity.MessagePreview, error) {
+	notifier, ok := ns.registry.Get(channelType)
+	if !ok {
+		return nil, fmt.Errorf("未知的通知类型: %s", channelType)
+	}
+
+	rendered, err := renderMessage(notifier, lang, sampleNotificationMessage(lang, success), templates...)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.MessagePreview{
+		Format:  notifier.Format(),
+		Title:   rendered.Title,
+		Content: rendered.Content,
+	}, nil
+}
+
+// renderForChannel 按任务模板、渠道模板和默认模板渲染通知，自定义模板渲染失败时回退到默认模板，避免通知丢失
+func (ns *NotificationService) renderForChannel(notifier Notifier, channel *entity.NotificationChannel, message *entity.NotificationMessage) (*entity.NotificationMessage, error) {
+	templates := ns.messageTemplates(channel, message)
+	rendered, err := renderMessage(notifier, channel.Language, message, templates...)
+	if err != nil && len(templates) > 0 {
+		log.Printf("Failed to render message template of channel %s for task %s, using default template: %v", channel.Name, message.TaskName, err)
+		rendered, err = renderMessage(notifier, channel.Language, message)
+	}
+	return rendered, err
+}
+
+// messageTemplates 按优先级获取任务和渠道的消息模板，格式错误的模板会被忽略
+func (ns *NotificationService) messageTemplates(channel *entity.NotificationChannel, message *entity.NotificationMessage) []entity.MessageTemplate {
+	var templates []entity.MessageTemplate
+	if message.Task != nil {
+		if tmpl, err := entity.ParseMessageTemplate(message.Task.NotificationTemplate); err != nil {
+			log.Printf("Invalid notification template of task %s: %v", message.TaskName, err)
+		} else {
+			templates = append(templates, tmpl)
+		}
+	}
+	if tmpl, err := entity.ParseMessageTemplate(channel.MessageTemplate); err != nil {
+		log.Printf("Invalid message template of channel %s: %v", channel.Name, err)
+	} else {
+		templates = append(templates, tmpl)
+	}
+	return templates
+}
+
+// MaskConfig 将渠道配置中的敏感字段替换为掩码，用于接口响应
+func (ns *NotificationService) MaskConfig(channelType, config string) string {
+	values, ok := decodeConfigValues(config)
+	if !ok {
+		return config
+	}
+
+	for _, field := range ns.secretFields(channelType) {
+		switch value := values[field].(type) {
+		case string:
+			if value != "" {
+				values[field] = maskSecret(value)
+			}
+		case map[string]interface{}:
+			// 键值对类型的配置项（如请求头）只掩码值
+			for key, item := range value {
+				if itemValue, ok := item.(string); ok && itemValue != "" {
+					value[key] = maskSecret(itemValue)
+				}
+			}
+		}
+	}
+	return encodeConfigValues(values, config)
+}
+
+// ErrSecretsMustBeReentered 渠道类型或连接配置已修改，但敏感字段仍为掩码
+var ErrSecretsMustBeReentered = errors.New("渠道类型或连接配置已修改，请重新填写密钥等敏感信息")
+
+// MergeMaskedConfig 更新渠道时，将仍为掩码的敏感字段还原为原有配置中的值，
+// 使客户端可以原样提交获取到的配置而不会覆盖密钥。
+// 只有渠道类型和所有连接配置（如服务器地址、Webhook 地址）都未修改时才还原，
+// 否则返回 ErrSecretsMustBeReentered，避免将原有密钥发送到新的地址
+func (ns *NotificationService) MergeMaskedConfig(channelType, config, previousType, previous string) (string, error) {
+	values, ok := decodeConfigValues(config)
+	if !ok {
+		return config, nil
+	}
+	previousValues, ok := decodeConfigValues(previous)
+	if !ok {
+		return config, nil
+	}
+
+	masked := false
+	for _, field := range ns.secretFields(channelType) {
+		switch value := values[field].(type) {
+		case string:
+			if previousValue, ok := previousValues[field].(string); ok && isMaskOf(value, previousValue) {
+				values[field] = previousValue
+				masked = true
+			}
+		case map[string]interface{}:
+			previousMap, _ := previousValues[field].(map[string]interface{})
+			for key, item := range value {
+				itemValue, _ := item.(string)
+				if previousValue, ok := previousMap[key].(string); ok && isMaskOf(itemValue, previousValue) {
+					value[key] = previousValue
+					masked = true
+				}
+			}
+		}
+	}
+	if !masked {
+		return config, nil
+	}
+	if channelType != previousType || ns.connectionChanged(channelType, values, previousValues) {
+		return "", ErrSecretsMustBeReentered
+	}
+	return encodeConfigValues(values, config), nil
+}
+
+// connectionChanged 连接配置是否与原有配置不同，values 中的敏感字段需已还原。未填写的配置项按默认值比较
+func (ns *NotificationService) connectionChanged(channelType string, values, previousValues map[string]interface{}) bool {
+	notifier, ok := ns.registry.Get(channelType)
+	if !ok {
+		return true
+	}
+	for _, field := range notifier.Schema() {
+		if field.Connection && configFieldValue(field, values) != configFieldValue(field, previousValues) {
+			return true
+		}
+	}
+	return false
+}
+
+// configFieldValue 配置项的值，未填写时为默认值
+func configFieldValue(field entity.NotifierField, values map[string]interface{}) string {
+	value := values[field.Name]
+	if value == nil || value == "" {
+		value = field.Default
+	}
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// isMaskOf 判断 value 是否为 previous 的掩码
+func isMaskOf(value, previous string) bool {
+	return value == entity.MaskedValue || value == maskSecret(previous)
+}
+
+// secretFields 渠道类型中标记为敏感信息的配置项
+func (ns *NotificationService) secretFields(channelType string) []string {
+	notifier, ok := ns.registry.Get(channelType)
+	if !ok {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range notifier.Schema() {
+		if field.Secret {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+// maskSecret 掩码敏感值。URL 保留协议和主机，便于识别渠道，路径和参数中可能包含 token，一并掩码
+func maskSecret(value string) string {
+	if parsed, err := url.Parse(value); err == nil && parsed.Scheme != "" && parsed.Host != "" {
+		return parsed.Scheme + "://" + parsed.Host + "/" + entity.MaskedValue
+	}
+	return entity.MaskedValue
+}
+
+// decodeConfigValues 解析 JSON 对象格式的渠道配置，数字保持原样
+func decodeConfigValues(config string) (map[string]interface{}, bool) {
+	decoder := json.NewDecoder(strings.NewReader(config))
+	decoder.UseNumber()
+
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil || values == nil {
+		return nil, false
+	}
+	return values, true
+}
+
+// encodeConfigValues 序列化渠道配置，失败时返回原配置
+func encodeConfigValues(values map[string]interface{}, fallback string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(values); err != nil {
+		return fallback
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+const (
+	deliveryPollInterval    = 5 * time.Second  // 轮询待发送记录的间隔
+	deliveryRetryBaseDelay  = 30 * time.Second // 首次重试的等待时间，之后每次翻倍
+	deliveryRetryMaxDelay   = 30 * time.Minute // 重试等待时间上限
+	deliveryCleanupInterval = time.Hour        // 清理发送记录的间隔
+)
+
+// NotificationDispatcher 通知分发器，从发送队列中取出到期的记录交给多个 worker 发送，失败时按指数退避重试
+type NotificationDispatcher struct {
+	deliveryRepo        repository.NotificationDeliveryRepository
+	channelRepo         repository.NotificationChannelRepository
+	notificationService *NotificationService
+	workers             int
+	retention           time.Duration // 已结束的发送记录保留时间
+	jobs                chan *entity.NotificationDelivery
+	stop                chan struct{}
+	wg                  sync.WaitGroup
+}
+
+func NewNotificationDispatcher(deliveryRepo repository.NotificationDeliveryRepository, channelRepo repository.NotificationChannelRepository, workers int, retention time.Duration) *NotificationDispatcher {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &NotificationDispatcher{
+		deliveryRepo:        deliveryRepo,
+		channelRepo:         channelRepo,
+		notificationService: NewNotificationService(),
+		workers:             workers,
+		retention:           retention,
+		jobs:                make(chan *entity.NotificationDelivery),
+		stop:                make(chan struct{}),
+	}
+}
+
+// Start 启动分发器。上次退出时仍在发送中的记录会重新发送
+func (d *NotificationDispatcher) Start() {
+	if err := d.deliveryRepo.ResetSending(); err != nil {
+		log.Printf("Failed to reset sending notification deliveries: %v", err)
+	}
+
+	for i := 0; i < d.workers; i++ {
+		d.wg.Add(1)
+		go d.work()
+	}
+	d.wg.Add(1)
+	go d.loop()
+	log.Printf("Notification dispatcher started with %d workers", d.workers)
+}
+
+// Stop 停止分发器，等待正在发送的通知完成
+func (d *NotificationDispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// loop 定期或被唤醒时分发到期的记录，并定期清理旧记录
+func (d *NotificationDispatcher) loop() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(deliveryPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Now()
+
+	for {
+		d.dispatch()
+
+		if time.Since(lastCleanup) >= deliveryCleanupInterval {
+			d.cleanup()
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		case <-deliveryWake:
+		}
+	}
+}
+
+// dispatch 取出所有到期的记录交给 worker，worker 繁忙时等待
+func (d *NotificationDispatcher) dispatch() {
+	for {
+		deliveries, err := d.deliveryRepo.ClaimDue(time.Now(), d.workers)
+		if err != nil {
+			log.Printf("Failed to load due notification deliveries: %v", err)
+			return
+		}
+
+		for _, delivery := range deliveries {
+			select {
+			case d.jobs <- delivery:
+			case <-d.stop:
+				// 已标记为发送中的记录在下次启动时恢复
+				return
+			}
+		}
+
+		if len(deliveries) < d.workers {
+			return
+		}
+	}
+}
+
+// work 发送 worker
+func (d *NotificationDispatcher) work() {
+	defer d.wg.Done()
+	for {
+		select {
+		case delivery := <-d.jobs:
+			d.attempt(delivery)
+		case <-d.stop:
+			return
+		}
+	}
+}
+
+// attempt 发送一次通知并记录结果，失败且未达到最多尝试次数时安排重试
+func (d *NotificationDispatcher) attempt(delivery *entity.NotificationDelivery) {
+	response, err := d.send(delivery)
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.Response = response
+	if err == nil {
+		delivery.Status = entity.DeliverySuccess
+		delivery.LastError = ""
+		delivery.SentAt = &now
+		log.Printf("Notification %d sent successfully via channel %s", delivery.ID, delivery.ChannelName)
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= delivery.MaxAttempts {
+			delivery.Status = entity.DeliveryFailed
+			log.Printf("Notification %d via channel %s failed after %d attempts: %v", delivery.ID, delivery.ChannelName, delivery.Attempts, err)
+		} else {
+			delivery.Status = entity.DeliveryPending
+			delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+			log.Printf("Notification %d via channel %s failed (attempt %d/%d), retry at %s: %v",
+				delivery.ID, delivery.ChannelName, delivery.Attempts, delivery.MaxAttempts, delivery.NextAttemptAt.Format("15:04:05"), err)
+		}
+	}
+
+	if err := d.deliveryRepo.Update(delivery); err != nil {
+		log.Printf("Failed to update notification delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send 使用渠道的最新配置发送已渲染的通知
+func (d *NotificationDispatcher) send(delivery *entity.NotificationDelivery) (string, error) {
+	channel, err := d.channelRepo.FindByID(delivery.ChannelID)
+	if err != nil {
+		return "", err
+	}
+
+	var message entity.NotificationMessage
+	if err := json.Unmarshal([]byte(delivery.Message), &message); err != nil {
+		return "", err
+	}
+	message.Title, message.Content = delivery.Title, delivery.Content
+
+	return d.notificationService.deliver(channel, &message)
+}
+
+// cleanup 删除超过保留时间的已结束记录
+func (d *NotificationDispatcher) cleanup() {
+	deleted, err := d.deliveryRepo.DeleteFinishedBefore(time.Now().Add(-d.retention))
+	if err != nil {
+		log.Printf("Failed to clean old notification deliveries: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Cleaned %d old notification deliveries", deleted)
+	}
+}
+
+// retryDelay 第 attempts 次失败后的重试等待时间：30s、1m、2m…，最长 30 分钟
+func retryDelay(attempts int) time.Duration {
+	delay := deliveryRetryBaseDelay
+	for i := 1; i < attempts && delay < deliveryRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > deliveryRetryMaxDelay {
+		delay = deliveryRetryMaxDelay
+	}
+	return delay
+}
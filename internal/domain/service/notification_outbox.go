@@ -0,0 +1,123 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+)
+
+// defaultMaxAttempts 渠道未设置最多尝试次数时的默认值
+const defaultMaxAttempts = 4
+
+// deliveryWake 有新的待发送记录时唤醒同一进程中的 NotificationDispatcher，避免等待下一次轮询
+var deliveryWake = make(chan struct{}, 1)
+
+// wakeDispatcher 非阻塞地唤醒分发器
+func wakeDispatcher() {
+	select {
+	case deliveryWake <- struct{}{}:
+	default:
+	}
+}
+
+// NotificationOutbox 通知发送队列。通知按渠道渲染后写入 notification_deliveries，由 NotificationDispatcher 异步发送
+type NotificationOutbox struct {
+	deliveryRepo        repository.NotificationDeliveryRepository
+	notificationService *NotificationService
+}
+
+func NewNotificationOutbox(deliveryRepo repository.NotificationDeliveryRepository) *NotificationOutbox {
+	return &NotificationOutbox{
+		deliveryRepo:        deliveryRepo,
+		notificationService: NewNotificationService(),
+	}
+}
+
+// Enqueue 为每个渠道渲染任务执行通知并写入发送队列。渲染在入队时完成，重试时发送相同的内容
+func (o *NotificationOutbox) Enqueue(channels []*entity.NotificationChannel, message *entity.NotificationMessage) {
+	for _, channel := range channels {
+		delivery := &entity.NotificationDelivery{
+			ChannelID:     channel.ID,
+			ChannelName:   channel.Name,
+			ChannelType:   channel.Type,
+			Status:        entity.DeliveryPending,
+			MaxAttempts:   channelMaxAttempts(channel),
+			NextAttemptAt: time.Now(),
+		}
+		if message.Task != nil {
+			delivery.TaskID = message.Task.ID
+		}
+		if message.Run != nil {
+			delivery.TaskLogID = message.Run.ID
+		}
+
+		if err := o.render(channel, message, delivery); err != nil {
+			// 无法渲染的通知直接记为失败，便于在发送记录中查看原因
+			delivery.Status = entity.DeliveryFailed
+			delivery.LastError = err.Error()
+		}
+
+		if err := o.deliveryRepo.Create(delivery); err != nil {
+			log.Printf("Failed to enqueue notification via channel %s for task %s: %v", channel.Name, message.TaskName, err)
+		}
+	}
+	wakeDispatcher()
+}
+
+// Retry 手动重试发送失败的通知，重新发送一次
+func (o *NotificationOutbox) Retry(id uint) (*entity.NotificationDelivery, error) {
+	delivery, err := o.deliveryRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if delivery.Status != entity.DeliveryFailed {
+		return nil, errors.New("只能重试发送失败的通知")
+	}
+	if delivery.Message == "" {
+		return nil, fmt.Errorf("通知未能渲染，无法重试: %s", delivery.LastError)
+	}
+
+	delivery.Status = entity.DeliveryPending
+	delivery.MaxAttempts = delivery.Attempts + 1
+	delivery.NextAttemptAt = time.Now()
+	if err := o.deliveryRepo.Update(delivery); err != nil {
+		return nil, err
+	}
+	wakeDispatcher()
+	return delivery, nil
+}
+
+// render 渲染通知内容并保存到发送记录
+func (o *NotificationOutbox) render(channel *entity.NotificationChannel, message *entity.NotificationMessage, delivery *entity.NotificationDelivery) error {
+	notifier, ok := o.notificationService.registry.Get(channel.Type)
+	if !ok {
+		return fmt.Errorf("未知的通知类型: %s", channel.Type)
+	}
+
+	rendered, err := o.notificationService.renderForChannel(notifier, channel, message)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return err
+	}
+
+	delivery.Title = rendered.Title
+	delivery.Content = rendered.Content
+	delivery.Message = string(data)
+	return nil
+}
+
+// channelMaxAttempts 获取渠道的最多尝试次数
+func channelMaxAttempts(channel *entity.NotificationChannel) int {
+	if channel.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+	return channel.MaxAttempts
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// errMissingConfig 通知类型缺少对应配置
+var errMissingConfig = errors.New("notification config is missing")
+
+// Notifier 通知渠道。新增渠道只需实现该接口并注册到 NotifierRegistry
+type Notifier interface {
+	// Type 渠道类型，对应任务 notification_types 中的取值和 notification_config 中的键
+	Type() string
+	// Name 渠道显示名称
+	Name() string
+	// Schema 渠道配置项说明
+	Schema() []entity.NotifierField
+	// Validate 校验渠道配置
+	Validate(config json.RawMessage) error
+	// Format 消息内容格式，决定正文模板按 Markdown 还是 HTML 渲染
+	Format() string
+	// DefaultTemplate 指定语言的默认消息模板
+	DefaultTemplate(lang string) entity.MessageTemplate
+	// Send 发送任务执行通知，message 的 Title 和 Content 已按渠道模板渲染。ctx 控制发送超时
+	Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error
+	// SendDigest 发送报表摘要
+	SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error
+}
+
+// NotifierRegistry 通知渠道注册表
+type NotifierRegistry struct {
+	mu        sync.RWMutex
+	notifiers map[string]Notifier
+}
+
+func NewNotifierRegistry() *NotifierRegistry {
+	return &NotifierRegistry{notifiers: make(map[string]Notifier)}
+}
+
+// defaultNotifierRegistry 内置通知渠道
+var defaultNotifierRegistry = func() *NotifierRegistry {
+	registry := NewNotifierRegistry()
+	registry.Register(&EmailNotifier{})
+	registry.Register(&DingTalkNotifier{})
+	registry.Register(&WeChatNotifier{})
+	registry.Register(&WebhookNotifier{})
+	registry.Register(&SlackNotifier{})
+	registry.Register(&FeishuNotifier{})
+	registry.Register(&TelegramNotifier{})
+	return registry
+}()
+
+// DefaultNotifierRegistry 获取默认注册表，包含所有内置通知渠道
+func DefaultNotifierRegistry() *NotifierRegistry {
+	return defaultNotifierRegistry
+}
+
+// Register 注册通知渠道，同类型的渠道会被替换
+func (r *NotifierRegistry) Register(notifier Notifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifiers[notifier.Type()] = notifier
+}
+
+// Get 获取通知渠道
+func (r *NotifierRegistry) Get(notifierType string) (Notifier, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	notifier, ok := r.notifiers[notifierType]
+	return notifier, ok
+}
+
+// List 按类型排序列出所有通知渠道
+func (r *NotifierRegistry) List() []Notifier {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	notifiers := make([]Notifier, 0, len(r.notifiers))
+	for _, notifier := range r.notifiers {
+		notifiers = append(notifiers, notifier)
+	}
+	sort.Slice(notifiers, func(i, j int) bool {
+		return notifiers[i].Type() < notifiers[j].Type()
+	})
+	return notifiers
+}
+
+// decodeNotifierConfig 解析渠道配置
+func decodeNotifierConfig(config json.RawMessage, v interface{}) error {
+	if len(bytes.TrimSpace(config)) == 0 || string(bytes.TrimSpace(config)) == "null" {
+		return errMissingConfig
+	}
+	if err := json.Unmarshal(config, v); err != nil {
+		return fmt.Errorf("配置格式错误: %v", err)
+	}
+	return nil
+}
+
+// validateWebhookURL 校验 webhook 地址
+func validateWebhookURL(webhookURL string) error {
+	return validateURL("webhook_url", webhookURL)
+}
+
+// validateURL 校验配置项中的 http(s) 地址
+func validateURL(field, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s 不能为空", field)
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("无效的 %s: %s", field, value)
+	}
+	return nil
+}
+
+// notifierHTTPClient 通知渠道使用的 HTTP 客户端，未设置超时的 ctx 也不会无限等待
+var notifierHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// maxRecordedResponse 发送记录中保存的响应内容最大长度
+const maxRecordedResponse = 2048
+
+// responseRecorderKey 用于在 ctx 中传递 responseRecorder
+type responseRecorderKey struct{}
+
+// responseRecorder 记录通知渠道的响应摘要，用于发送记录
+type responseRecorder struct {
+	response string
+}
+
+// withResponseRecorder 返回带响应记录的 ctx
+func withResponseRecorder(ctx context.Context) (context.Context, *responseRecorder) {
+	recorder := &responseRecorder{}
+	return context.WithValue(ctx, responseRecorderKey{}, recorder), recorder
+}
+
+// recordResponse 记录响应摘要，ctx 中没有 responseRecorder 时忽略
+func recordResponse(ctx context.Context, response string) {
+	if recorder, ok := ctx.Value(responseRecorderKey{}).(*responseRecorder); ok {
+		recorder.response = truncateRunes(response, maxRecordedResponse)
+	}
+}
+
+// postWebhook 以 JSON 格式提交 webhook 请求，非2xx响应视为失败
+func postWebhook(ctx context.Context, webhookURL string, payload map[string]interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	_, err = sendJSON(ctx, http.MethodPost, webhookURL, nil, jsonData)
+	return err
+}
+
+// sendJSON 发送 JSON 请求并返回响应内容，非2xx响应视为失败
+func sendJSON(ctx context.Context, method, requestURL string, headers map[string]string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := notifierHTTPClient.Do(req)
+	if err != nil {
+		// 请求地址中可能包含 token，错误信息中不返回地址
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, fmt.Errorf("%s request failed: %w", method, urlErr.Err)
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// 响应内容只用于判断结果和错误提示，限制读取大小
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	recordResponse(ctx, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, respBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return respBody, nil
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// DingTalkNotifier 钉钉机器人通知
+type DingTalkNotifier struct{}
+
+func (n *DingTalkNotifier) Type() string {
+	return "dingtalk"
+}
+
+func (n *DingTalkNotifier) Name() string {
+	return "钉钉"
+}
+
+func (n *DingTalkNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "webhook_url", Label: "Webhook地址", Type: entity.NotifierFieldString, Required: true, Secret: true, Connection: true},
+		{Name: "secret", Label: "加签密钥", Type: entity.NotifierFieldPassword, Secret: true, Description: "机器人安全设置为加签时填写"},
+		{Name: "at_mobiles", Label: "@手机号", Type: entity.NotifierFieldStringList},
+		{Name: "at_all", Label: "@所有人", Type: entity.NotifierFieldBool},
+	}
+}
+
+func (n *DingTalkNotifier) Validate(config json.RawMessage) error {
+	_, err := n.decode(config)
+	return err
+}
+
+func (n *DingTalkNotifier) Format() string {
+	return entity.MessageFormatMarkdown
+}
+
+func (n *DingTalkNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_markdown", lang)
+}
+
+func (n *DingTalkNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	dingTalkConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"title": message.Title,
+			"text":  message.Content,
+		},
+	}
+
+	return n.post(ctx, dingTalkConfig, payload)
+}
+
+func (n *DingTalkNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	dingTalkConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	text, err := renderDigestMarkdown(report)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"title": digestTitle(report),
+			"text":  text,
+		},
+	}
+	return n.post(ctx, dingTalkConfig, payload)
+}
+
+// decode 解析并校验钉钉配置
+func (n *DingTalkNotifier) decode(config json.RawMessage) (*entity.DingTalkConfig, error) {
+	var dingTalkConfig entity.DingTalkConfig
+	if err := decodeNotifierConfig(config, &dingTalkConfig); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL(dingTalkConfig.WebhookURL); err != nil {
+		return nil, err
+	}
+	return &dingTalkConfig, nil
+}
+
+// post 添加@信息后发送消息
+func (n *DingTalkNotifier) post(ctx context.Context, config *entity.DingTalkConfig, payload map[string]interface{}) error {
+	// 添加@功能
+	if len(config.AtMobiles) > 0 || config.AtAll {
+		payload["at"] = map[string]interface{}{
+			"atMobiles": config.AtMobiles,
+			"isAtAll":   config.AtAll,
+		}
+	}
+
+	return postWebhook(ctx, n.webhookURL(config), payload)
+}
+
+// webhookURL 获取钉钉 webhook 地址，配置了签名密钥时附加时间戳和签名
+func (n *DingTalkNotifier) webhookURL(config *entity.DingTalkConfig) string {
+	if config.Secret == "" {
+		return config.WebhookURL
+	}
+
+	timestamp := time.Now().UnixNano() / 1e6
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, config.Secret)
+	h := hmac.New(sha256.New, []byte(config.Secret))
+	h.Write([]byte(stringToSign))
+	signature := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	return fmt.Sprintf("%s&timestamp=%d&sign=%s", config.WebhookURL, timestamp, signature)
+}
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// EmailNotifier 邮件通知
+type EmailNotifier struct{}
+
+func (n *EmailNotifier) Type() string {
+	return "email"
+}
+
+func (n *EmailNotifier) Name() string {
+	return "邮件"
+}
+
+func (n *EmailNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "smtp_host", Label: "SMTP服务器", Type: entity.NotifierFieldString, Required: true, Connection: true},
+		{Name: "smtp_port", Label: "SMTP端口", Type: entity.NotifierFieldNumber, Required: true, Default: 587, Connection: true},
+		{Name: "security", Label: "加密方式", Type: entity.NotifierFieldSelect, Default: entity.EmailSecuritySTARTTLS,
+			Options:     []string{entity.EmailSecuritySTARTTLS, entity.EmailSecurityTLS, entity.EmailSecurityNone},
+			Description: "starttls 通常使用 587 端口，tls（SMTPS）通常使用 465 端口", Connection: true},
+		{Name: "username", Label: "用户名", Type: entity.NotifierFieldString, Connection: true},
+		{Name: "password", Label: "密码", Type: entity.NotifierFieldPassword, Secret: true},
+		{Name: "from", Label: "发件人", Type: entity.NotifierFieldString, Required: true, Description: "如 ops@example.com 或 Crontab <ops@example.com>"},
+		{Name: "to", Label: "收件人", Type: entity.NotifierFieldStringList, Required: true},
+		{Name: "cc", Label: "抄送", Type: entity.NotifierFieldStringList},
+		{Name: "bcc", Label: "密送", Type: entity.NotifierFieldStringList},
+		{Name: "subject", Label: "邮件主题", Type: entity.NotifierFieldString, Description: "为空时使用消息模板的标题"},
+		{Name: "attach_output", Label: "附加任务输出", Type: entity.NotifierFieldBool, Description: "将任务输出和错误信息作为 .log 附件发送"},
+	}
+}
+
+func (n *EmailNotifier) Validate(config json.RawMessage) error {
+	_, err := n.decode(config)
+	return err
+}
+
+func (n *EmailNotifier) Format() string {
+	return entity.MessageFormatHTML
+}
+
+func (n *EmailNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_html", lang)
+}
+
+func (n *EmailNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	emailConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	subject := emailConfig.Subject
+	if subject == "" {
+		subject = message.Title
+	}
+
+	var attachments []emailAttachment
+	if emailConfig.AttachOutput {
+		if attachment := outputAttachment(message); attachment != nil {
+			attachments = append(attachments, *attachment)
+		}
+	}
+
+	return n.sendEmail(ctx, emailConfig, subject, message.Content, attachments)
+}
+
+func (n *EmailNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	emailConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	body, err := renderDigestEmail(report)
+	if err != nil {
+		return err
+	}
+	return n.sendEmail(ctx, emailConfig, digestTitle(report), body, nil)
+}
+
+// outputAttachment 将任务输出和错误信息生成日志附件，两者都为空时返回 nil
+func outputAttachment(message *entity.NotificationMessage) *emailAttachment {
+	if message.Output == "" && message.Error == "" {
+		return nil
+	}
+
+	var content strings.Builder
+	content.WriteString(message.Output)
+	if message.Error != "" {
+		if message.Output != "" && !strings.HasSuffix(message.Output, "\n") {
+			content.WriteString("\n")
+		}
+		content.WriteString("--- error ---\n")
+		content.WriteString(message.Error)
+	}
+
+	filename := "output.log"
+	if message.Run != nil {
+		filename = fmt.Sprintf("%s-%s.log", message.TaskName, message.Run.StartTime.Format("20060102-150405"))
+	}
+	return &emailAttachment{
+		Filename:    strings.NewReplacer("/", "_", "\\", "_").Replace(filename),
+		ContentType: "text/plain; charset=UTF-8",
+		Data:        []byte(content.String()),
+	}
+}
+
+// emailRecipients 解析后的邮件地址
+type emailRecipients struct {
+	From *mail.Address
+	To   []*mail.Address
+	Cc   []*mail.Address
+	Bcc  []*mail.Address
+}
+
+// All 所有需要投递的收件人，包括密送
+func (r *emailRecipients) All() []*mail.Address {
+	all := append([]*mail.Address{}, r.To...)
+	all = append(all, r.Cc...)
+	return append(all, r.Bcc...)
+}
+
+// decode 解析并校验邮件配置，未设置加密方式时按旧配置推断：465 端口使用隐式 TLS，
+// enable_tls 为 true 时使用 STARTTLS，否则不加密
+func (n *EmailNotifier) decode(config json.RawMessage) (*entity.EmailConfig, error) {
+	var emailConfig entity.EmailConfig
+	if err := decodeNotifierConfig(config, &emailConfig); err != nil {
+		return nil, err
+	}
+
+	if emailConfig.SMTPHost == "" {
+		return nil, errors.New("smtp_host 不能为空")
+	}
+	if emailConfig.SMTPPort <= 0 || emailConfig.SMTPPort > 65535 {
+		return nil, fmt.Errorf("无效的 smtp_port: %d", emailConfig.SMTPPort)
+	}
+	if emailConfig.From == "" {
+		return nil, errors.New("from 不能为空")
+	}
+	if len(emailConfig.To) == 0 {
+		return nil, errors.New("至少需要一个收件人")
+	}
+
+	switch emailConfig.Security {
+	case "":
+		switch {
+		case emailConfig.SMTPPort == 465:
+			emailConfig.Security = entity.EmailSecurityTLS
+		case emailConfig.EnableTLS:
+			emailConfig.Security = entity.EmailSecuritySTARTTLS
+		default:
+			emailConfig.Security = entity.EmailSecurityNone
+		}
+	case entity.EmailSecuritySTARTTLS, entity.EmailSecurityTLS, entity.EmailSecurityNone:
+	default:
+		return nil, fmt.Errorf("不支持的加密方式: %s", emailConfig.Security)
+	}
+
+	if _, err := parseEmailRecipients(&emailConfig); err != nil {
+		return nil, err
+	}
+	return &emailConfig, nil
+}
+
+// parseEmailRecipients 解析发件人、收件人、抄送和密送地址
+func parseEmailRecipients(config *entity.EmailConfig) (*emailRecipients, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("无效的发件人地址 %q: %v", config.From, err)
+	}
+
+	recipients := &emailRecipients{From: from}
+	for _, list := range []struct {
+		values []string
+		target *[]*mail.Address
+	}{
+		{config.To, &recipients.To},
+		{config.Cc, &recipients.Cc},
+		{config.Bcc, &recipients.Bcc},
+	} {
+		for _, value := range list.values {
+			address, err := mail.ParseAddress(value)
+			if err != nil {
+				return nil, fmt.Errorf("无效的邮件地址 %q: %v", value, err)
+			}
+			*list.target = append(*list.target, address)
+		}
+	}
+	return recipients, nil
+}
+
+// sendEmail 发送邮件，ctx 的截止时间同时作为连接超时和 SMTP 会话的读写超时
+func (n *EmailNotifier) sendEmail(ctx context.Context, config *entity.EmailConfig, subject, body string, attachments []emailAttachment) error {
+	recipients, err := parseEmailRecipients(config)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	msg, err := (&emailMessage{
+		From:        recipients.From,
+		To:          recipients.To,
+		Cc:          recipients.Cc,
+		Subject:     subject,
+		HTML:        body,
+		Attachments: attachments,
+		Date:        now,
+		MessageID:   newEmailMessageID(recipients.From, now),
+	}).Bytes()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultChannelTimeout)
+		defer cancel()
+	}
+
+	client, err := n.dial(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// 与 smtp.SendMail 一致，服务器支持认证时才进行认证；未加密的连接上 PlainAuth 会拒绝发送密码
+	if ok, _ := client.Extension("AUTH"); ok && config.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(recipients.From.Address); err != nil {
+		return err
+	}
+	all := recipients.All()
+	for _, address := range all {
+		if err = client.Rcpt(address.Address); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	recordResponse(ctx, fmt.Sprintf("已发送给 %d 个收件人", len(all)))
+	return client.Quit()
+}
+
+// emailRootCAs 校验 SMTP 服务器证书使用的根证书，为空时使用系统根证书
+var emailRootCAs *x509.CertPool
+
+// dial 按加密方式建立 SMTP 连接
+func (n *EmailNotifier) dial(ctx context.Context, config *entity.EmailConfig) (*smtp.Client, error) {
+	addr := net.JoinHostPort(config.SMTPHost, strconv.Itoa(config.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: config.SMTPHost, RootCAs: emailRootCAs}
+
+	var conn net.Conn
+	var err error
+	if config.Security == entity.EmailSecurityTLS {
+		dialer := &tls.Dialer{Config: tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if config.Security == entity.EmailSecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP 服务器不支持 STARTTLS，请将加密方式改为 tls 或 none")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// smtpSession SMTP 测试服务器记录的一次投递
+type smtpSession struct {
+	TLS  bool   // 发送 MAIL 命令时连接是否已加密
+	Auth string // AUTH 命令的参数
+	From string
+	Rcpt []string
+	Data []byte
+}
+
+// smtpStandInOptions SMTP 测试服务器的行为
+type smtpStandInOptions struct {
+	ImplicitTLS bool   // 连接建立后立即进行 TLS 握手（SMTPS）
+	StartTLS    bool   // 是否在 EHLO 响应中声明 STARTTLS
+	RejectRcpt  string // 拒绝投递的收件人地址
+}
+
+// smtpStandIn 代替 SMTP 服务器的本地服务，只实现发送邮件需要的命令
+type smtpStandIn struct {
+	Host string
+	Port int
+
+	options   smtpStandInOptions
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	sessions []smtpSession
+}
+
+// newSMTPStandIn 在 127.0.0.1 上启动 SMTP 测试服务器，并让邮件通知信任其自签名证书
+func newSMTPStandIn(t *testing.T, options smtpStandInOptions) *smtpStandIn {
+	t.Helper()
+
+	certificate, roots := selfSignedCertificate(t)
+	previousRoots := emailRootCAs
+	emailRootCAs = roots
+	t.Cleanup(func() { emailRootCAs = previousRoots })
+
+	s := &smtpStandIn{
+		Host:      "127.0.0.1",
+		options:   options,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{certificate}},
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if options.ImplicitTLS {
+		listener = tls.NewListener(listener, s.tlsConfig)
+	}
+	t.Cleanup(func() { listener.Close() })
+	s.Port = listener.Addr().(*net.TCPAddr).Port
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// serve 处理一个 SMTP 连接
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	_, isTLS := conn.(*tls.Conn)
+	var session smtpSession
+	text.PrintfLine("220 stand-in ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			extensions := []string{"stand-in"}
+			if s.options.StartTLS && !isTLS {
+				extensions = append(extensions, "STARTTLS")
+			}
+			extensions = append(extensions, "AUTH PLAIN")
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				text.PrintfLine("250%s%s", separator, extension)
+			}
+		case "STARTTLS":
+			text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+			isTLS = true
+		case "AUTH":
+			session.Auth = arg
+			text.PrintfLine("235 authenticated")
+		case "MAIL":
+			session.TLS = isTLS
+			session.From = smtpPathAddress(arg)
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			address := smtpPathAddress(arg)
+			if address == s.options.RejectRcpt {
+				text.PrintfLine("550 5.1.1 mailbox unavailable")
+				continue
+			}
+			session.Rcpt = append(session.Rcpt, address)
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 end data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			session.Data = data
+			s.mu.Lock()
+			s.sessions = append(s.sessions, session)
+			s.mu.Unlock()
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 command not implemented")
+		}
+	}
+}
+
+// delivered 返回已完成的投递
+func (s *smtpStandIn) delivered() []smtpSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpSession(nil), s.sessions...)
+}
+
+// config 生成连接到测试服务器的邮件配置
+func (s *smtpStandIn) config(security string) entity.EmailConfig {
+	return entity.EmailConfig{
+		SMTPHost: s.Host,
+		SMTPPort: s.Port,
+		Security: security,
+		Username: "ops",
+		Password: "p@ss",
+		From:     "Crontab <crontab@example.com>",
+		To:       []string{"alice@example.com"},
+		Cc:       []string{"Bob <bob@example.com>"},
+		Bcc:      []string{"audit@example.com"},
+	}
+}
+
+// smtpPathAddress 从 "FROM:<addr>" 或 "TO:<addr>" 中取出地址
+func smtpPathAddress(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return arg
+	}
+	return arg[start+1 : end]
+}
+
+// selfSignedCertificate 生成 127.0.0.1 的自签名证书及信任它的根证书池
+func selfSignedCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "smtp stand-in"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+// sendTestEmail 通过 EmailNotifier 发送一封测试邮件
+func sendTestEmail(t *testing.T, config entity.EmailConfig, message *entity.NotificationMessage) error {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return (&EmailNotifier{}).Send(ctx, notifierConfig(t, config), message)
+}
+
+// htmlTaskMessage 测试使用的 HTML 格式任务通知
+func htmlTaskMessage() *entity.NotificationMessage {
+	message := failedTaskMessage()
+	message.Content = "<p><b>backup</b> 执行失败</p>"
+	return message
+}
+
+func TestEmailNotifierSendSecurityModes(t *testing.T) {
+	tests := []struct {
+		security string
+		options  smtpStandInOptions
+		wantTLS  bool
+	}{
+		{entity.EmailSecurityTLS, smtpStandInOptions{ImplicitTLS: true}, true},
+		{entity.EmailSecuritySTARTTLS, smtpStandInOptions{StartTLS: true}, true},
+		{entity.EmailSecurityNone, smtpStandInOptions{StartTLS: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.security, func(t *testing.T) {
+			server := newSMTPStandIn(t, tt.options)
+
+			ctx, recorder := withResponseRecorder(context.Background())
+			config := server.config(tt.security)
+			if err := (&EmailNotifier{}).Send(ctx, notifierConfig(t, config), htmlTaskMessage()); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+
+			sessions := server.delivered()
+			if len(sessions) != 1 {
+				t.Fatalf("delivered %d messages, want 1", len(sessions))
+			}
+			session := sessions[0]
+			if session.TLS != tt.wantTLS {
+				t.Errorf("TLS = %v, want %v", session.TLS, tt.wantTLS)
+			}
+			wantAuth := "PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00ops\x00p@ss"))
+			if session.Auth != wantAuth {
+				t.Errorf("AUTH = %q, want %q", session.Auth, wantAuth)
+			}
+			if session.From != "crontab@example.com" {
+				t.Errorf("MAIL FROM = %q", session.From)
+			}
+			if got := strings.Join(session.Rcpt, ","); got != "alice@example.com,bob@example.com,audit@example.com" {
+				t.Errorf("RCPT TO = %s", got)
+			}
+			if recorder.response != "已发送给 3 个收件人" {
+				t.Errorf("recorded response = %q", recorder.response)
+			}
+		})
+	}
+}
+
+func TestEmailNotifierSendStartTLSUnsupported(t *testing.T) {
+	server := newSMTPStandIn(t, smtpStandInOptions{StartTLS: false})
+
+	err := sendTestEmail(t, server.config(entity.EmailSecuritySTARTTLS), htmlTaskMessage())
+	if err == nil || !strings.Contains(err.Error(), "不支持 STARTTLS") {
+		t.Fatalf("Send error = %v, want STARTTLS unsupported", err)
+	}
+	// 不能回退到明文连接发送密码和邮件
+	if sessions := server.delivered(); len(sessions) != 0 {
+		t.Errorf("delivered %d messages over plaintext", len(sessions))
+	}
+}
+
+func TestEmailNotifierSendRejectedRecipient(t *testing.T) {
+	server := newSMTPStandIn(t, smtpStandInOptions{StartTLS: true, RejectRcpt: "bob@example.com"})
+
+	err := sendTestEmail(t, server.config(entity.EmailSecuritySTARTTLS), htmlTaskMessage())
+	if err == nil || !strings.Contains(err.Error(), "mailbox unavailable") {
+		t.Fatalf("Send error = %v, want rejected recipient", err)
+	}
+	if sessions := server.delivered(); len(sessions) != 0 {
+		t.Errorf("delivered %d messages after a rejected recipient", len(sessions))
+	}
+}
+
+func TestEmailNotifierSendHeaders(t *testing.T) {
+	server := newSMTPStandIn(t, smtpStandInOptions{StartTLS: true})
+
+	config := server.config(entity.EmailSecuritySTARTTLS)
+	config.Subject = "定时任务告警: backup"
+	if err := sendTestEmail(t, config, htmlTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	data := server.delivered()[0].Data
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+
+	if got := message.Header.Get("To"); got != "<alice@example.com>" {
+		t.Errorf("To = %q", got)
+	}
+	if got := message.Header.Get("Cc"); got != `"Bob" <bob@example.com>` {
+		t.Errorf("Cc = %q", got)
+	}
+	// 密送地址只出现在 RCPT 中
+	if got := message.Header.Get("Bcc"); got != "" {
+		t.Errorf("Bcc header = %q, want none", got)
+	}
+	if bytes.Contains(data, []byte("audit@example.com")) {
+		t.Error("message content exposes the BCC address")
+	}
+
+	rawSubject := message.Header.Get("Subject")
+	if !strings.HasPrefix(rawSubject, "=?UTF-8?q?") {
+		t.Errorf("Subject = %q, want RFC 2047 encoded word", rawSubject)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+	if err != nil || subject != "定时任务告警: backup" {
+		t.Errorf("decoded Subject = %q (%v)", subject, err)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Errorf("Content-Type = %q, want multipart/alternative without attachments", message.Header.Get("Content-Type"))
+	}
+}
+
+func TestEmailNotifierSendAttachment(t *testing.T) {
+	server := newSMTPStandIn(t, smtpStandInOptions{StartTLS: true})
+
+	config := server.config(entity.EmailSecuritySTARTTLS)
+	config.AttachOutput = true
+	message := htmlTaskMessage()
+	message.Output = "dumping database\n"
+	if err := sendTestEmail(t, config, message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	received, err := mail.ReadMessage(bytes.NewReader(server.delivered()[0].Data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(received.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q, want multipart/mixed", received.Header.Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(received.Body, params["boundary"])
+	body, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("read body part: %v", err)
+	}
+	if bodyType, _, _ := mime.ParseMediaType(body.Header.Get("Content-Type")); bodyType != "multipart/alternative" {
+		t.Errorf("first part Content-Type = %q, want multipart/alternative", body.Header.Get("Content-Type"))
+	}
+
+	attachment, err := reader.NextPart()
+	if err != nil {
+		t.Fatalf("read attachment part: %v", err)
+	}
+	if attachment.FileName() != "output.log" {
+		t.Errorf("attachment filename = %q, want output.log", attachment.FileName())
+	}
+	if got := attachment.Header.Get("Content-Transfer-Encoding"); got != "base64" {
+		t.Errorf("attachment Content-Transfer-Encoding = %q, want base64", got)
+	}
+	encoded, err := io.ReadAll(attachment)
+	if err != nil {
+		t.Fatalf("read attachment: %v", err)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.NewReplacer("\r", "", "\n", "").Replace(string(encoded)))
+	if err != nil {
+		t.Fatalf("decode attachment: %v", err)
+	}
+	if want := "dumping database\n--- error ---\nexit status 1"; string(decoded) != want {
+		t.Errorf("attachment = %q, want %q", decoded, want)
+	}
+
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("unexpected extra part: %v", err)
+	}
+}
+
+func TestEmailNotifierSendInferredSecurity(t *testing.T) {
+	server := newSMTPStandIn(t, smtpStandInOptions{})
+
+	// 未设置加密方式且未启用 enable_tls 时按旧配置推断为不加密
+	config := server.config("")
+	if err := sendTestEmail(t, config, htmlTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if sessions := server.delivered(); len(sessions) != 1 || sessions[0].TLS {
+		t.Errorf("sessions = %+v, want one plaintext delivery", sessions)
+	}
+}
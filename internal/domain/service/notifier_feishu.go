@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// FeishuNotifier 飞书/Lark 自定义机器人通知
+type FeishuNotifier struct{}
+
+func (n *FeishuNotifier) Type() string {
+	return "feishu"
+}
+
+func (n *FeishuNotifier) Name() string {
+	return "飞书/Lark"
+}
+
+func (n *FeishuNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "webhook_url", Label: "Webhook地址", Type: entity.NotifierFieldString, Required: true, Secret: true, Connection: true},
+		{Name: "secret", Label: "签名密钥", Type: entity.NotifierFieldPassword, Secret: true, Description: "机器人安全设置为签名校验时填写"},
+	}
+}
+
+func (n *FeishuNotifier) Validate(config json.RawMessage) error {
+	_, err := n.decode(config)
+	return err
+}
+
+func (n *FeishuNotifier) Format() string {
+	return entity.MessageFormatMarkdown
+}
+
+func (n *FeishuNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_markdown", lang)
+}
+
+func (n *FeishuNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	feishuConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	color := "green"
+	if !message.Success {
+		color = "red"
+	}
+	return n.post(ctx, feishuConfig, message.Title, color, message.Content)
+}
+
+func (n *FeishuNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	feishuConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	text, err := renderDigestMarkdown(report)
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, feishuConfig, digestTitle(report), "blue", text)
+}
+
+// decode 解析并校验飞书配置
+func (n *FeishuNotifier) decode(config json.RawMessage) (*entity.FeishuConfig, error) {
+	var feishuConfig entity.FeishuConfig
+	if err := decodeNotifierConfig(config, &feishuConfig); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL(feishuConfig.WebhookURL); err != nil {
+		return nil, err
+	}
+	return &feishuConfig, nil
+}
+
+// post 以消息卡片发送 Markdown 内容，配置了签名密钥时附加时间戳和签名
+func (n *FeishuNotifier) post(ctx context.Context, config *entity.FeishuConfig, title, color, text string) error {
+	payload := map[string]interface{}{
+		"msg_type": "interactive",
+		"card": map[string]interface{}{
+			"header": map[string]interface{}{
+				"title":    map[string]interface{}{"tag": "plain_text", "content": title},
+				"template": color,
+			},
+			"elements": []interface{}{
+				map[string]interface{}{
+					"tag":  "div",
+					"text": map[string]interface{}{"tag": "lark_md", "content": larkMarkdown(text)},
+				},
+			},
+		},
+	}
+
+	if config.Secret != "" {
+		timestamp := time.Now().Unix()
+		payload["timestamp"] = strconv.FormatInt(timestamp, 10)
+		payload["sign"] = n.sign(config.Secret, timestamp)
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+	respBody, err := sendJSON(ctx, http.MethodPost, config.WebhookURL, nil, jsonData)
+	if err != nil {
+		return err
+	}
+
+	// 飞书在 HTTP 200 响应中通过 code 返回业务错误，如签名校验失败
+	var result struct {
+		Code          int    `json:"code"`
+		Msg           string `json:"msg"`
+		StatusCode    int    `json:"StatusCode"`
+		StatusMessage string `json:"StatusMessage"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("飞书返回错误 %d: %s", result.Code, result.Msg)
+	}
+	if result.StatusCode != 0 {
+		return fmt.Errorf("飞书返回错误 %d: %s", result.StatusCode, result.StatusMessage)
+	}
+	return nil
+}
+
+// sign 计算飞书签名：以 "timestamp\nsecret" 为密钥对空字符串做 HmacSHA256 后 Base64 编码
+func (n *FeishuNotifier) sign(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	h := hmac.New(sha256.New, []byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
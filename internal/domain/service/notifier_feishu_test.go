@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+func TestFeishuNotifierSendCard(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{"code":0,"msg":"success"}`)
+	config := notifierConfig(t, entity.FeishuConfig{WebhookURL: server.URL + "/open-apis/bot/v2/hook/abc"})
+
+	if err := (&FeishuNotifier{}).Send(context.Background(), config, failedTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := server.lastRequest(t)
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if req.Path != "/open-apis/bot/v2/hook/abc" {
+		t.Errorf("path = %s", req.Path)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	payload := decodeJSONBody(t, req.Body)
+	if payload["msg_type"] != "interactive" {
+		t.Errorf("msg_type = %v, want interactive", payload["msg_type"])
+	}
+	if _, ok := payload["sign"]; ok {
+		t.Error("sign should be omitted without a secret")
+	}
+	if _, ok := payload["timestamp"]; ok {
+		t.Error("timestamp should be omitted without a secret")
+	}
+
+	card := payload["card"].(map[string]interface{})
+	header := card["header"].(map[string]interface{})
+	if header["template"] != "red" {
+		t.Errorf("header template = %v, want red for a failed task", header["template"])
+	}
+	title := header["title"].(map[string]interface{})
+	if title["content"] != "任务执行失败: backup" {
+		t.Errorf("header title = %v", title["content"])
+	}
+	element := card["elements"].([]interface{})[0].(map[string]interface{})
+	text := element["text"].(map[string]interface{})
+	if text["tag"] != "lark_md" {
+		t.Errorf("element tag = %v, want lark_md", text["tag"])
+	}
+	// lark_md 不支持标题，标题转换为加粗
+	if want := "**backup**\n**状态**: 失败 <exit 1>"; text["content"] != want {
+		t.Errorf("element content = %q, want %q", text["content"], want)
+	}
+}
+
+func TestFeishuNotifierSendSigned(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{"code":0}`)
+	config := notifierConfig(t, entity.FeishuConfig{WebhookURL: server.URL, Secret: "s3cr3t"})
+
+	message := failedTaskMessage()
+	message.Success = true
+	before := time.Now().Unix()
+	if err := (&FeishuNotifier{}).Send(context.Background(), config, message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	after := time.Now().Unix()
+
+	payload := decodeJSONBody(t, server.lastRequest(t).Body)
+	card := payload["card"].(map[string]interface{})
+	if template := card["header"].(map[string]interface{})["template"]; template != "green" {
+		t.Errorf("header template = %v, want green for a successful task", template)
+	}
+
+	// 飞书要求 timestamp 为字符串格式的秒级时间戳
+	timestampText, ok := payload["timestamp"].(string)
+	if !ok {
+		t.Fatalf("timestamp = %v, want string", payload["timestamp"])
+	}
+	timestamp, err := strconv.ParseInt(timestampText, 10, 64)
+	if err != nil || timestamp < before || timestamp > after {
+		t.Fatalf("timestamp = %q, want unix seconds in [%d, %d]", timestampText, before, after)
+	}
+
+	// 按飞书文档独立计算签名：以 "timestamp\nsecret" 为密钥对空字符串做 HmacSHA256
+	mac := hmac.New(sha256.New, []byte(timestampText+"\n"+"s3cr3t"))
+	want := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if payload["sign"] != want {
+		t.Errorf("sign = %v, want %s", payload["sign"], want)
+	}
+}
+
+func TestFeishuNotifierErrorPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+		wantErr  string
+	}{
+		{"business code", http.StatusOK, `{"code":19021,"msg":"sign match fail or timestamp is not within one hour from current time"}`, "飞书返回错误 19021: sign match fail"},
+		{"legacy status code", http.StatusOK, `{"StatusCode":9499,"StatusMessage":"Bad Request"}`, "飞书返回错误 9499: Bad Request"},
+		{"non-2xx", http.StatusInternalServerError, `internal error`, "status code: 500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStandInServer(t, tt.status, tt.response)
+			config := notifierConfig(t, entity.FeishuConfig{WebhookURL: server.URL, Secret: "s3cr3t"})
+
+			err := (&FeishuNotifier{}).Send(context.Background(), config, failedTaskMessage())
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Send error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFeishuNotifierNonJSONSuccess(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `ok`)
+	config := notifierConfig(t, entity.FeishuConfig{WebhookURL: server.URL})
+
+	if err := (&FeishuNotifier{}).Send(context.Background(), config, failedTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+}
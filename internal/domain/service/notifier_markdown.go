@@ -0,0 +1,42 @@
+package service
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	markdownHeading   = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+	markdownBold      = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownCodeBlock = regexp.MustCompile("(?s)```\\n?(.*?)\\n?```")
+)
+
+// slackMarkdown 将 Markdown 转换为 Slack mrkdwn 格式
+func slackMarkdown(text string) string {
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	text = markdownHeading.ReplaceAllString(text, "*$1*")
+	return markdownBold.ReplaceAllString(text, "*$1*")
+}
+
+// larkMarkdown 将 Markdown 转换为飞书 lark_md 格式，lark_md 不支持标题
+func larkMarkdown(text string) string {
+	return markdownHeading.ReplaceAllString(text, "**$1**")
+}
+
+// telegramHTML 将 Markdown 转换为 Telegram 支持的 HTML 格式
+func telegramHTML(text string) string {
+	text = html.EscapeString(text)
+	text = markdownCodeBlock.ReplaceAllString(text, "<pre>$1</pre>")
+	text = markdownHeading.ReplaceAllString(text, "<b>$1</b>")
+	return markdownBold.ReplaceAllString(text, "<b>$1</b>")
+}
+
+// truncateRunes 按字符数截断文本
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit]) + "…"
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// SlackNotifier Slack Incoming Webhook 通知
+type SlackNotifier struct{}
+
+func (n *SlackNotifier) Type() string {
+	return "slack"
+}
+
+func (n *SlackNotifier) Name() string {
+	return "Slack"
+}
+
+func (n *SlackNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "webhook_url", Label: "Webhook地址", Type: entity.NotifierFieldString, Required: true, Secret: true, Connection: true},
+		{Name: "channel", Label: "频道", Type: entity.NotifierFieldString, Description: "覆盖 Webhook 默认频道，如 #ops（仅旧版 Webhook 支持）"},
+		{Name: "username", Label: "显示名称", Type: entity.NotifierFieldString},
+		{Name: "icon_emoji", Label: "图标", Type: entity.NotifierFieldString, Description: "如 :alarm_clock:"},
+	}
+}
+
+func (n *SlackNotifier) Validate(config json.RawMessage) error {
+	_, err := n.decode(config)
+	return err
+}
+
+func (n *SlackNotifier) Format() string {
+	return entity.MessageFormatMarkdown
+}
+
+func (n *SlackNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_markdown", lang)
+}
+
+func (n *SlackNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	slackConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, slackConfig, message.Content)
+}
+
+func (n *SlackNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	slackConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	text, err := renderDigestMarkdown(report)
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, slackConfig, text)
+}
+
+// decode 解析并校验 Slack 配置
+func (n *SlackNotifier) decode(config json.RawMessage) (*entity.SlackConfig, error) {
+	var slackConfig entity.SlackConfig
+	if err := decodeNotifierConfig(config, &slackConfig); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL(slackConfig.WebhookURL); err != nil {
+		return nil, err
+	}
+	return &slackConfig, nil
+}
+
+// post 将 Markdown 内容转换为 mrkdwn 后发送
+func (n *SlackNotifier) post(ctx context.Context, config *entity.SlackConfig, text string) error {
+	payload := map[string]interface{}{
+		"text": slackMarkdown(text),
+	}
+	if config.Channel != "" {
+		payload["channel"] = config.Channel
+	}
+	if config.Username != "" {
+		payload["username"] = config.Username
+	}
+	if config.IconEmoji != "" {
+		payload["icon_emoji"] = config.IconEmoji
+	}
+	return postWebhook(ctx, config.WebhookURL, payload)
+}
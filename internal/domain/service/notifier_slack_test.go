@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"crontab_go/internal/domain/entity"
+)
+
+func TestSlackNotifierSend(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `ok`)
+	config := notifierConfig(t, entity.SlackConfig{
+		WebhookURL: server.URL + "/services/T000/B000/XXX",
+		Channel:    "#ops",
+		Username:   "crontab",
+		IconEmoji:  ":alarm_clock:",
+	})
+
+	if err := (&SlackNotifier{}).Send(context.Background(), config, failedTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := server.lastRequest(t)
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if req.Path != "/services/T000/B000/XXX" {
+		t.Errorf("path = %s", req.Path)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	payload := decodeJSONBody(t, req.Body)
+	// mrkdwn 使用单星号加粗，并转义 & < >
+	if want := "*backup*\n*状态*: 失败 &lt;exit 1&gt;"; payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+	if payload["channel"] != "#ops" {
+		t.Errorf("channel = %v, want #ops", payload["channel"])
+	}
+	if payload["username"] != "crontab" {
+		t.Errorf("username = %v, want crontab", payload["username"])
+	}
+	if payload["icon_emoji"] != ":alarm_clock:" {
+		t.Errorf("icon_emoji = %v, want :alarm_clock:", payload["icon_emoji"])
+	}
+}
+
+func TestSlackNotifierSendOmitsEmptyOverrides(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `ok`)
+	config := notifierConfig(t, entity.SlackConfig{WebhookURL: server.URL})
+
+	if err := (&SlackNotifier{}).Send(context.Background(), config, failedTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	payload := decodeJSONBody(t, server.lastRequest(t).Body)
+	for _, key := range []string{"channel", "username", "icon_emoji"} {
+		if _, ok := payload[key]; ok {
+			t.Errorf("payload contains %s although it is not configured", key)
+		}
+	}
+}
+
+func TestSlackNotifierSendNon2xx(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+	}{
+		{"invalid token", http.StatusForbidden, `invalid_token`},
+		{"channel not found", http.StatusNotFound, `channel_not_found`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStandInServer(t, tt.status, tt.response)
+			config := notifierConfig(t, entity.SlackConfig{WebhookURL: server.URL})
+
+			ctx, recorder := withResponseRecorder(context.Background())
+			err := (&SlackNotifier{}).Send(ctx, config, failedTaskMessage())
+			if err == nil || !strings.Contains(err.Error(), "status code:") {
+				t.Fatalf("Send error = %v, want status code error", err)
+			}
+			if !strings.Contains(recorder.response, tt.response) {
+				t.Errorf("recorded response = %q, want it to contain %q", recorder.response, tt.response)
+			}
+		})
+	}
+}
+
+func TestSlackNotifierValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config entity.SlackConfig
+	}{
+		{"missing url", entity.SlackConfig{}},
+		{"unsupported scheme", entity.SlackConfig{WebhookURL: "ftp://hooks.slack.com/services/x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (&SlackNotifier{}).Validate(notifierConfig(t, tt.config)); err == nil {
+				t.Fatal("Validate succeeded, want error")
+			}
+		})
+	}
+}
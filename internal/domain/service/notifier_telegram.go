@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"crontab_go/internal/domain/entity"
+)
+
+const (
+	// defaultTelegramAPIURL Telegram Bot API 默认地址
+	defaultTelegramAPIURL = "https://api.telegram.org"
+	// telegramTextLimit Telegram 单条消息最大长度为 4096 字符，转换为 HTML 前预留标签和转义的长度
+	telegramTextLimit = 3500
+)
+
+// TelegramNotifier Telegram 机器人通知
+type TelegramNotifier struct{}
+
+func (n *TelegramNotifier) Type() string {
+	return "telegram"
+}
+
+func (n *TelegramNotifier) Name() string {
+	return "Telegram"
+}
+
+func (n *TelegramNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "bot_token", Label: "Bot Token", Type: entity.NotifierFieldPassword, Required: true, Secret: true},
+		{Name: "chat_id", Label: "Chat ID", Type: entity.NotifierFieldString, Required: true, Description: "用户、群组或频道ID，如 -1001234567890 或 @channel_name"},
+		{Name: "api_url", Label: "API地址", Type: entity.NotifierFieldString, Default: defaultTelegramAPIURL, Connection: true, Description: "使用代理或自建 Bot API 服务时填写"},
+	}
+}
+
+func (n *TelegramNotifier) Validate(config json.RawMessage) error {
+	_, err := n.decode(config)
+	return err
+}
+
+func (n *TelegramNotifier) Format() string {
+	return entity.MessageFormatMarkdown
+}
+
+func (n *TelegramNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_markdown", lang)
+}
+
+func (n *TelegramNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	telegramConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, telegramConfig, message.Content)
+}
+
+func (n *TelegramNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	telegramConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	text, err := renderDigestMarkdown(report)
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, telegramConfig, text)
+}
+
+// decode 解析并校验 Telegram 配置
+func (n *TelegramNotifier) decode(config json.RawMessage) (*entity.TelegramConfig, error) {
+	var telegramConfig entity.TelegramConfig
+	if err := decodeNotifierConfig(config, &telegramConfig); err != nil {
+		return nil, err
+	}
+	if telegramConfig.BotToken == "" {
+		return nil, errors.New("bot_token 不能为空")
+	}
+	if telegramConfig.ChatID == "" {
+		return nil, errors.New("chat_id 不能为空")
+	}
+	if telegramConfig.APIURL == "" {
+		telegramConfig.APIURL = defaultTelegramAPIURL
+	}
+	if err := validateURL("api_url", telegramConfig.APIURL); err != nil {
+		return nil, err
+	}
+	return &telegramConfig, nil
+}
+
+// post 将 Markdown 内容转换为 HTML 后调用 sendMessage 发送
+func (n *TelegramNotifier) post(ctx context.Context, config *entity.TelegramConfig, text string) error {
+	payload := map[string]interface{}{
+		"chat_id":                  config.ChatID,
+		"text":                     telegramHTML(truncateRunes(text, telegramTextLimit)),
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	sendURL := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(config.APIURL, "/"), config.BotToken)
+	respBody, err := sendJSON(ctx, http.MethodPost, sendURL, nil, jsonData)
+	if err != nil {
+		// Bot API 在错误响应中通过 description 说明原因，如 chat not found
+		var result struct {
+			Description string `json:"description"`
+		}
+		if json.Unmarshal(respBody, &result) == nil && result.Description != "" {
+			return fmt.Errorf("%v: %s", err, result.Description)
+		}
+		return err
+	}
+	return nil
+}
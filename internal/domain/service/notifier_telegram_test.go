@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"crontab_go/internal/domain/entity"
+)
+
+func TestTelegramNotifierSend(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{"ok":true,"result":{"message_id":1}}`)
+	config := notifierConfig(t, entity.TelegramConfig{
+		BotToken: "123456:ABC-DEF",
+		ChatID:   "-1001234567890",
+		APIURL:   server.URL + "/",
+	})
+
+	if err := (&TelegramNotifier{}).Send(context.Background(), config, failedTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := server.lastRequest(t)
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if req.Path != "/bot123456:ABC-DEF/sendMessage" {
+		t.Errorf("path = %s, want /bot123456:ABC-DEF/sendMessage", req.Path)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	payload := decodeJSONBody(t, req.Body)
+	if payload["chat_id"] != "-1001234567890" {
+		t.Errorf("chat_id = %v", payload["chat_id"])
+	}
+	if payload["parse_mode"] != "HTML" {
+		t.Errorf("parse_mode = %v, want HTML", payload["parse_mode"])
+	}
+	if payload["disable_web_page_preview"] != true {
+		t.Errorf("disable_web_page_preview = %v, want true", payload["disable_web_page_preview"])
+	}
+	// 标题和加粗转换为 <b>，正文中的尖括号需要转义
+	if want := "<b>backup</b>\n<b>状态</b>: 失败 &lt;exit 1&gt;"; payload["text"] != want {
+		t.Errorf("text = %q, want %q", payload["text"], want)
+	}
+}
+
+func TestTelegramNotifierErrorDescription(t *testing.T) {
+	server := newStandInServer(t, http.StatusBadRequest, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+	config := notifierConfig(t, entity.TelegramConfig{BotToken: "123456:ABC-DEF", ChatID: "42", APIURL: server.URL})
+
+	err := (&TelegramNotifier{}).Send(context.Background(), config, failedTaskMessage())
+	if err == nil {
+		t.Fatal("Send succeeded, want error")
+	}
+	if want := "status code: 400: Bad Request: chat not found"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestTelegramNotifierErrorWithoutDescription(t *testing.T) {
+	server := newStandInServer(t, http.StatusBadGateway, `<html>bad gateway</html>`)
+	config := notifierConfig(t, entity.TelegramConfig{BotToken: "123456:ABC-DEF", ChatID: "42", APIURL: server.URL})
+
+	err := (&TelegramNotifier{}).Send(context.Background(), config, failedTaskMessage())
+	if err == nil || err.Error() != "status code: 502" {
+		t.Fatalf("Send error = %v, want status code: 502", err)
+	}
+}
+
+func TestTelegramNotifierConnectionErrorHidesToken(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{"ok":true}`)
+	apiURL := server.URL
+	server.Close()
+
+	config := notifierConfig(t, entity.TelegramConfig{BotToken: "123456:ABC-DEF", ChatID: "42", APIURL: apiURL})
+	err := (&TelegramNotifier{}).Send(context.Background(), config, failedTaskMessage())
+	if err == nil {
+		t.Fatal("Send succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), "ABC-DEF") {
+		t.Errorf("error leaks the bot token: %v", err)
+	}
+}
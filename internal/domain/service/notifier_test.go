@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// capturedRequest 测试服务器收到的请求
+type capturedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// standInServer 代替通知渠道的本地 HTTP 服务器，记录收到的请求并返回预设的响应
+type standInServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []capturedRequest
+}
+
+// newStandInServer 启动测试服务器，所有请求都返回 status 和 response
+func newStandInServer(t *testing.T, status int, response string) *standInServer {
+	t.Helper()
+
+	s := &standInServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, capturedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Header: r.Header.Clone(),
+			Body:   body,
+		})
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, response)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// lastRequest 返回最后一次收到的请求，没有请求时测试失败
+func (s *standInServer) lastRequest(t *testing.T) capturedRequest {
+	t.Helper()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("stand-in server received no request")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// requestCount 返回收到的请求数
+func (s *standInServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// notifierConfig 将配置序列化为通知渠道配置
+func notifierConfig(t *testing.T, config interface{}) json.RawMessage {
+	t.Helper()
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	return data
+}
+
+// decodeJSONBody 将请求体解析为 map
+func decodeJSONBody(t *testing.T, body []byte) map[string]interface{} {
+	t.Helper()
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatalf("request body is not JSON: %v\n%s", err, body)
+	}
+	return payload
+}
+
+// failedTaskMessage 测试使用的失败任务通知
+func failedTaskMessage() *entity.NotificationMessage {
+	return &entity.NotificationMessage{
+		TaskName: "backup",
+		Success:  false,
+		Error:    "exit status 1",
+		Title:    "任务执行失败: backup",
+		Content:  "## backup\n**状态**: 失败 <exit 1>",
+	}
+}
+
+func TestMergeMaskedConfigRequiresSameDestination(t *testing.T) {
+	const (
+		emailConfig    = `{"smtp_host":"smtp.example.com","smtp_port":587,"security":"starttls","username":"ops","password":"smtp-password","from":"ops@example.com","to":["a@example.com"]}`
+		telegramConfig = `{"bot_token":"123:bot-token","chat_id":"-100","api_url":"https://api.telegram.org"}`
+		webhookConfig  = `{"url":"https://hooks.example.com/notify?token=abc","headers":{"Authorization":"Bearer secret"}}`
+		dingtalkConfig = `{"webhook_url":"https://oapi.dingtalk.com/robot/send?access_token=abc","secret":"SEC-sign"}`
+	)
+
+	tests := []struct {
+		name         string
+		channelType  string
+		previous     string
+		update       func(values map[string]interface{})
+		previousType string
+		wantErr      bool
+		wantSecret   string // 合并后应保留的敏感信息
+	}{
+		{"email unchanged", "email", emailConfig, func(v map[string]interface{}) { v["to"] = []string{"b@example.com"} }, "", false, "smtp-password"},
+		{"email host changed", "email", emailConfig, func(v map[string]interface{}) { v["smtp_host"] = "smtp.attacker.com" }, "", true, ""},
+		{"email port changed", "email", emailConfig, func(v map[string]interface{}) { v["smtp_port"] = 2525 }, "", true, ""},
+		{"email username changed", "email", emailConfig, func(v map[string]interface{}) { v["username"] = "other" }, "", true, ""},
+		{"email security downgraded", "email", emailConfig, func(v map[string]interface{}) { v["security"] = "none" }, "", true, ""},
+		{"email host changed with new password", "email", emailConfig, func(v map[string]interface{}) {
+			v["smtp_host"] = "smtp2.example.com"
+			v["password"] = "new-password"
+		}, "", false, "new-password"},
+		{"telegram unchanged", "telegram", telegramConfig, func(v map[string]interface{}) { v["chat_id"] = "-200" }, "", false, "123:bot-token"},
+		{"telegram api_url changed", "telegram", telegramConfig, func(v map[string]interface{}) { v["api_url"] = "https://attacker.example.com" }, "", true, ""},
+		{"telegram api_url cleared to default", "telegram", telegramConfig, func(v map[string]interface{}) { delete(v, "api_url") }, "", false, "123:bot-token"},
+		{"webhook unchanged", "webhook", webhookConfig, func(v map[string]interface{}) { v["method"] = "PUT" }, "", false, "Bearer secret"},
+		{"webhook url changed", "webhook", webhookConfig, func(v map[string]interface{}) { v["url"] = "https://attacker.example.com/collect" }, "", true, ""},
+		{"dingtalk url changed", "dingtalk", dingtalkConfig, func(v map[string]interface{}) {
+			v["webhook_url"] = "https://attacker.example.com/robot"
+		}, "", true, ""},
+		{"type changed", "wechat", dingtalkConfig, func(map[string]interface{}) {}, "dingtalk", true, ""},
+	}
+
+	ns := NewNotificationService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previousType := tt.previousType
+			if previousType == "" {
+				previousType = tt.channelType
+			}
+
+			// 客户端提交获取到的掩码配置，再修改部分配置项
+			var values map[string]interface{}
+			if err := json.Unmarshal([]byte(ns.MaskConfig(previousType, tt.previous)), &values); err != nil {
+				t.Fatalf("masked config: %v", err)
+			}
+			tt.update(values)
+			config := string(notifierConfig(t, values))
+
+			merged, err := ns.MergeMaskedConfig(tt.channelType, config, previousType, tt.previous)
+			if tt.wantErr {
+				if !errors.Is(err, ErrSecretsMustBeReentered) {
+					t.Fatalf("MergeMaskedConfig error = %v, want ErrSecretsMustBeReentered", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeMaskedConfig: %v", err)
+			}
+			if !strings.Contains(merged, tt.wantSecret) {
+				t.Errorf("merged config %s does not contain %q", merged, tt.wantSecret)
+			}
+			if strings.Contains(merged, entity.MaskedValue) {
+				t.Errorf("merged config still contains masked values: %s", merged)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// webhookMethods 通用 Webhook 支持的请求方法
+var webhookMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// webhookTemplateFuncs 请求体模板中可用的函数
+var webhookTemplateFuncs = template.FuncMap{
+	// json 将值序列化为 JSON，用于在模板中安全地嵌入字符串
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// webhookTemplateData 请求体模板数据，未配置模板时直接序列化为请求体
+type webhookTemplateData struct {
+	Event   string                      `json:"event"` // task 或 digest
+	Title   string                      `json:"title"`
+	Text    string                      `json:"text"` // Markdown 格式的通知内容
+	Message *entity.NotificationMessage `json:"message,omitempty"`
+	Report  *entity.DigestReport        `json:"report,omitempty"`
+}
+
+// WebhookNotifier 通用 Webhook 通知，请求方法、请求头和请求体均可配置
+type WebhookNotifier struct{}
+
+func (n *WebhookNotifier) Type() string {
+	return "webhook"
+}
+
+func (n *WebhookNotifier) Name() string {
+	return "通用Webhook"
+}
+
+func (n *WebhookNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "url", Label: "请求地址", Type: entity.NotifierFieldString, Required: true, Secret: true, Connection: true},
+		{Name: "method", Label: "请求方法", Type: entity.NotifierFieldString, Default: http.MethodPost, Description: "GET、POST、PUT、PATCH 或 DELETE，GET 请求不发送请求体"},
+		{Name: "headers", Label: "请求头", Type: entity.NotifierFieldMap, Secret: true, Description: "如 Authorization，请求头的值在接口响应中会被掩码"},
+		{Name: "body_template", Label: "请求体模板", Type: entity.NotifierFieldText,
+			Description: "Go 模板语法，可用 .Event .Title .Text .Message .Report，字符串请使用 json 函数转义，如 {\"text\": {{json .Text}}}；留空时发送全部数据"},
+	}
+}
+
+func (n *WebhookNotifier) Validate(config json.RawMessage) error {
+	_, _, err := n.decode(config)
+	return err
+}
+
+func (n *WebhookNotifier) Format() string {
+	return entity.MessageFormatMarkdown
+}
+
+func (n *WebhookNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_markdown", lang)
+}
+
+func (n *WebhookNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	return n.send(ctx, config, &webhookTemplateData{
+		Event:   "task",
+		Title:   message.Title,
+		Text:    message.Content,
+		Message: message,
+	})
+}
+
+func (n *WebhookNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	text, err := renderDigestMarkdown(report)
+	if err != nil {
+		return err
+	}
+
+	return n.send(ctx, config, &webhookTemplateData{
+		Event:  "digest",
+		Title:  digestTitle(report),
+		Text:   text,
+		Report: report,
+	})
+}
+
+// decode 解析并校验 Webhook 配置，返回解析后的请求体模板
+func (n *WebhookNotifier) decode(config json.RawMessage) (*entity.WebhookConfig, *template.Template, error) {
+	var webhookConfig entity.WebhookConfig
+	if err := decodeNotifierConfig(config, &webhookConfig); err != nil {
+		return nil, nil, err
+	}
+	if err := validateURL("url", webhookConfig.URL); err != nil {
+		return nil, nil, err
+	}
+
+	webhookConfig.Method = strings.ToUpper(webhookConfig.Method)
+	if webhookConfig.Method == "" {
+		webhookConfig.Method = http.MethodPost
+	}
+	if !webhookMethods[webhookConfig.Method] {
+		return nil, nil, fmt.Errorf("不支持的请求方法: %s", webhookConfig.Method)
+	}
+
+	if strings.TrimSpace(webhookConfig.BodyTemplate) == "" {
+		return &webhookConfig, nil, nil
+	}
+	tmpl, err := template.New("body").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(webhookConfig.BodyTemplate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("请求体模板错误: %v", err)
+	}
+	return &webhookConfig, tmpl, nil
+}
+
+// send 渲染请求体并发送请求
+func (n *WebhookNotifier) send(ctx context.Context, config json.RawMessage, data *webhookTemplateData) error {
+	webhookConfig, tmpl, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	if webhookConfig.Method == http.MethodGet {
+		_, err = sendJSON(ctx, webhookConfig.Method, webhookConfig.URL, webhookConfig.Headers, nil)
+		return err
+	}
+
+	body, err := n.renderBody(tmpl, data)
+	if err != nil {
+		return err
+	}
+	_, err = sendJSON(ctx, webhookConfig.Method, webhookConfig.URL, webhookConfig.Headers, body)
+	return err
+}
+
+// renderBody 渲染请求体，未配置模板时序列化全部数据
+func (n *WebhookNotifier) renderBody(tmpl *template.Template, data *webhookTemplateData) ([]byte, error) {
+	if tmpl == nil {
+		return json.Marshal(data)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("渲染请求体模板失败: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("请求体模板渲染结果不是合法的 JSON")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"crontab_go/internal/domain/entity"
+)
+
+func TestWebhookNotifierSendDefaultBody(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{}`)
+	config := notifierConfig(t, entity.WebhookConfig{
+		URL:     server.URL + "/hook",
+		Headers: map[string]string{"Authorization": "Bearer secret-token", "X-Source": "crontab"},
+	})
+
+	message := failedTaskMessage()
+	if err := (&WebhookNotifier{}).Send(context.Background(), config, message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := server.lastRequest(t)
+	if req.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", req.Method)
+	}
+	if req.Path != "/hook" {
+		t.Errorf("path = %s, want /hook", req.Path)
+	}
+	if got := req.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer secret-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if got := req.Header.Get("X-Source"); got != "crontab" {
+		t.Errorf("X-Source = %q", got)
+	}
+
+	payload := decodeJSONBody(t, req.Body)
+	if payload["event"] != "task" {
+		t.Errorf("event = %v, want task", payload["event"])
+	}
+	if payload["title"] != message.Title {
+		t.Errorf("title = %v, want %q", payload["title"], message.Title)
+	}
+	if payload["text"] != message.Content {
+		t.Errorf("text = %v, want %q", payload["text"], message.Content)
+	}
+	embedded, ok := payload["message"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("message = %v, want object", payload["message"])
+	}
+	if embedded["task_name"] != "backup" {
+		t.Errorf("message.task_name = %v, want backup", embedded["task_name"])
+	}
+	if _, ok := payload["report"]; ok {
+		t.Error("task event should not carry a report")
+	}
+}
+
+func TestWebhookNotifierSendBodyTemplate(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{}`)
+	config := notifierConfig(t, entity.WebhookConfig{
+		URL:          server.URL,
+		Method:       "put",
+		BodyTemplate: `{"event": "{{.Event}}", "summary": {{json .Title}}, "body": {{json .Text}}, "ok": {{.Message.Success}}}`,
+	})
+
+	message := failedTaskMessage()
+	if err := (&WebhookNotifier{}).Send(context.Background(), config, message); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := server.lastRequest(t)
+	if req.Method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", req.Method)
+	}
+	payload := decodeJSONBody(t, req.Body)
+	if len(payload) != 4 {
+		t.Errorf("payload has %d keys, want 4: %v", len(payload), payload)
+	}
+	if payload["event"] != "task" {
+		t.Errorf("event = %v, want task", payload["event"])
+	}
+	if payload["summary"] != message.Title {
+		t.Errorf("summary = %v, want %q", payload["summary"], message.Title)
+	}
+	// json 函数需要正确转义换行、引号和尖括号
+	if payload["body"] != message.Content {
+		t.Errorf("body = %v, want %q", payload["body"], message.Content)
+	}
+	if payload["ok"] != false {
+		t.Errorf("ok = %v, want false", payload["ok"])
+	}
+}
+
+func TestWebhookNotifierSendGetWithoutBody(t *testing.T) {
+	server := newStandInServer(t, http.StatusNoContent, ``)
+	config := notifierConfig(t, entity.WebhookConfig{
+		URL:          server.URL + "/ping?source=crontab",
+		Method:       http.MethodGet,
+		BodyTemplate: `{"text": {{json .Text}}}`,
+	})
+
+	if err := (&WebhookNotifier{}).Send(context.Background(), config, failedTaskMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := server.lastRequest(t)
+	if req.Method != http.MethodGet {
+		t.Errorf("method = %s, want GET", req.Method)
+	}
+	if len(req.Body) != 0 {
+		t.Errorf("GET request carried a body: %s", req.Body)
+	}
+	if got := req.Header.Get("Content-Type"); got != "" {
+		t.Errorf("Content-Type = %q, want none", got)
+	}
+}
+
+func TestWebhookNotifierSendInvalidTemplateResult(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{}`)
+	config := notifierConfig(t, entity.WebhookConfig{
+		URL: server.URL,
+		// 未使用 json 函数转义，渲染结果不是合法的 JSON
+		BodyTemplate: `{"text": "{{.Text}}"}`,
+	})
+
+	err := (&WebhookNotifier{}).Send(context.Background(), config, failedTaskMessage())
+	if err == nil || !strings.Contains(err.Error(), "不是合法的 JSON") {
+		t.Fatalf("Send error = %v, want invalid JSON error", err)
+	}
+	if n := server.requestCount(); n != 0 {
+		t.Errorf("server received %d requests, want none", n)
+	}
+}
+
+func TestWebhookNotifierSendNon2xx(t *testing.T) {
+	server := newStandInServer(t, http.StatusBadGateway, `{"error":"upstream down"}`)
+	config := notifierConfig(t, entity.WebhookConfig{URL: server.URL})
+
+	ctx, recorder := withResponseRecorder(context.Background())
+	err := (&WebhookNotifier{}).Send(ctx, config, failedTaskMessage())
+	if err == nil || !strings.Contains(err.Error(), "status code: 502") {
+		t.Fatalf("Send error = %v, want status code: 502", err)
+	}
+	if want := `HTTP 502: {"error":"upstream down"}`; recorder.response != want {
+		t.Errorf("recorded response = %q, want %q", recorder.response, want)
+	}
+}
+
+func TestWebhookNotifierSendDigest(t *testing.T) {
+	server := newStandInServer(t, http.StatusOK, `{}`)
+	config := notifierConfig(t, entity.WebhookConfig{URL: server.URL})
+
+	report := &entity.DigestReport{Name: "运维日报", Period: entity.DigestPeriodDaily, TotalTasks: 3}
+	if err := (&WebhookNotifier{}).SendDigest(context.Background(), config, report); err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+
+	payload := decodeJSONBody(t, server.lastRequest(t).Body)
+	if payload["event"] != "digest" {
+		t.Errorf("event = %v, want digest", payload["event"])
+	}
+	if payload["title"] != digestTitle(report) {
+		t.Errorf("title = %v, want %q", payload["title"], digestTitle(report))
+	}
+	embedded, ok := payload["report"].(map[string]interface{})
+	if !ok || embedded["name"] != "运维日报" {
+		t.Errorf("report = %v, want report named 运维日报", payload["report"])
+	}
+	if _, ok := payload["message"]; ok {
+		t.Error("digest event should not carry a task message")
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// WeChatNotifier 企业微信机器人通知
+type WeChatNotifier struct{}
+
+func (n *WeChatNotifier) Type() string {
+	return "wechat"
+}
+
+func (n *WeChatNotifier) Name() string {
+	return "企业微信"
+}
+
+func (n *WeChatNotifier) Schema() []entity.NotifierField {
+	return []entity.NotifierField{
+		{Name: "webhook_url", Label: "Webhook地址", Type: entity.NotifierFieldString, Required: true, Secret: true, Connection: true},
+		{Name: "at_user_ids", Label: "@成员ID", Type: entity.NotifierFieldStringList},
+		{Name: "at_all", Label: "@所有人", Type: entity.NotifierFieldBool},
+	}
+}
+
+func (n *WeChatNotifier) Validate(config json.RawMessage) error {
+	_, err := n.decode(config)
+	return err
+}
+
+func (n *WeChatNotifier) Format() string {
+	return entity.MessageFormatMarkdown
+}
+
+func (n *WeChatNotifier) DefaultTemplate(lang string) entity.MessageTemplate {
+	return defaultMessageTemplate("task_wechat", lang)
+}
+
+func (n *WeChatNotifier) Send(ctx context.Context, config json.RawMessage, message *entity.NotificationMessage) error {
+	weChatConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	return n.post(ctx, weChatConfig, message.Content)
+}
+
+func (n *WeChatNotifier) SendDigest(ctx context.Context, config json.RawMessage, report *entity.DigestReport) error {
+	weChatConfig, err := n.decode(config)
+	if err != nil {
+		return err
+	}
+
+	content, err := renderDigestMarkdown(report)
+	if err != nil {
+		return err
+	}
+	return n.post(ctx, weChatConfig, content)
+}
+
+// decode 解析并校验企业微信配置
+func (n *WeChatNotifier) decode(config json.RawMessage) (*entity.WeChatConfig, error) {
+	var weChatConfig entity.WeChatConfig
+	if err := decodeNotifierConfig(config, &weChatConfig); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL(weChatConfig.WebhookURL); err != nil {
+		return nil, err
+	}
+	return &weChatConfig, nil
+}
+
+// post 添加@信息后发送 Markdown 消息
+func (n *WeChatNotifier) post(ctx context.Context, config *entity.WeChatConfig, content string) error {
+	// 添加@功能
+	if mentions := n.mentions(config); mentions != "" {
+		content = fmt.Sprintf("%s\n\n%s", content, mentions)
+	}
+
+	payload := map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]interface{}{
+			"content": content,
+		},
+	}
+	return postWebhook(ctx, config.WebhookURL, payload)
+}
+
+// mentions 构建企业微信消息中的@内容
+func (n *WeChatNotifier) mentions(config *entity.WeChatConfig) string {
+	if config.AtAll {
+		return "@all"
+	}
+
+	mentioned := make([]string, 0, len(config.AtUserIds))
+	for _, userId := range config.AtUserIds {
+		mentioned = append(mentioned, fmt.Sprintf("<@%s>", userId))
+	}
+	return strings.Join(mentioned, " ")
+}
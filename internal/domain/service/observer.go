@@ -0,0 +1,31 @@
+package service
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// Observer 接收任务执行和通知发送的观测数据，由基础设施层实现，如 Prometheus 指标
+type Observer interface {
+	// ObserveTaskExecution 记录一次任务执行
+	ObserveTaskExecution(taskLog *entity.TaskLog)
+	// ObserveQueueWait 记录任务从计划时间到实际开始执行的等待时间
+	ObserveQueueWait(task *entity.Task, wait time.Duration)
+	// ObserveNotification 记录一次通知发送结果，channel 为渠道类型
+	ObserveNotification(channel string, err error)
+}
+
+// noopObserver 未设置观测者时丢弃所有观测数据
+type noopObserver struct{}
+
+func (noopObserver) ObserveTaskExecution(*entity.TaskLog)         {}
+func (noopObserver) ObserveQueueWait(*entity.Task, time.Duration) {}
+func (noopObserver) ObserveNotification(string, error)            {}
+
+var observer Observer = noopObserver{}
+
+// SetObserver 设置观测者，需要在启动任务执行器和通知分发器之前调用
+func SetObserver(o Observer) {
+	observer = o
+}
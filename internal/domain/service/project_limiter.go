@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"crontab_go/internal/domain/entity"
+)
+
+// ErrProjectConcurrencyLimit 项目中同时执行的任务数已达上限
+var ErrProjectConcurrencyLimit = errors.New("项目中同时执行的任务数已达上限")
+
+// projectRuns 各项目正在执行的任务数，同一进程中的多个 TaskExecutor 共享
+var projectRuns = struct {
+	sync.Mutex
+	running map[uint]int
+}{running: make(map[uint]int)}
+
+// RunningProjectTasks 项目中正在执行的任务数
+func RunningProjectTasks(projectID uint) int {
+	projectRuns.Lock()
+	defer projectRuns.Unlock()
+	return projectRuns.running[projectID]
+}
+
+// acquireRun 为属于项目的任务占用一个执行名额，超出项目并发上限时返回 ErrProjectConcurrencyLimit。
+// 执行结束后需要调用返回的 release
+func (te *TaskExecutor) acquireRun(task *entity.Task) (release func(), err error) {
+	if task.ProjectID == nil || te.projectRepo == nil {
+		return func() {}, nil
+	}
+	projectID := *task.ProjectID
+
+	limit := 0
+	if project, err := te.projectRepo.FindByID(projectID); err != nil {
+		log.Printf("Failed to load project %d for task %s: %v", projectID, task.Name, err)
+	} else {
+		limit = project.MaxConcurrentRuns
+	}
+
+	projectRuns.Lock()
+	defer projectRuns.Unlock()
+	if limit > 0 && projectRuns.running[projectID] >= limit {
+		return nil, fmt.Errorf("%w (%d)", ErrProjectConcurrencyLimit, limit)
+	}
+	projectRuns.running[projectID]++
+
+	return func() {
+		projectRuns.Lock()
+		defer projectRuns.Unlock()
+		if projectRuns.running[projectID]--; projectRuns.running[projectID] <= 0 {
+			delete(projectRuns.running, projectID)
+		}
+	}, nil
+}
@@ -1,12 +1,14 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -18,18 +20,29 @@ import (
 type TaskExecutor struct {
 	taskRepo            repository.TaskRepository
 	taskLogRepo         repository.TaskLogRepository
+	channelRepo         repository.NotificationChannelRepository
 	cron                *cron.Cron
+	mu                  sync.Mutex // 保护 runningTasks
 	runningTasks        map[int]cron.EntryID
-	notificationService *NotificationService
+	outbox              *NotificationOutbox
+	anomalyDetector     *AnomalyDetector
+	alertEvaluator      *AlertEvaluator
+	policyRepo          repository.EscalationPolicyRepository
+	projectRepo         repository.ProjectRepository
 }
 
-func NewTaskExecutor(taskRepo repository.TaskRepository, taskLogRepo repository.TaskLogRepository) *TaskExecutor {
+func NewTaskExecutor(taskRepo repository.TaskRepository, taskLogRepo repository.TaskLogRepository, channelRepo repository.NotificationChannelRepository, deliveryRepo repository.NotificationDeliveryRepository, alertRepo repository.TaskAlertRepository, policyRepo repository.EscalationPolicyRepository, projectRepo repository.ProjectRepository) *TaskExecutor {
 	return &TaskExecutor{
 		taskRepo:            taskRepo,
 		taskLogRepo:         taskLogRepo,
+		channelRepo:         channelRepo,
 		cron:                cron.New(),
 		runningTasks:        make(map[int]cron.EntryID),
-		notificationService: NewNotificationService(),
+		outbox:              NewNotificationOutbox(deliveryRepo),
+		anomalyDetector:     NewAnomalyDetector(taskLogRepo, entity.NewAnomalyConfig()),
+		alertEvaluator:      NewAlertEvaluator(alertRepo),
+		policyRepo:          policyRepo,
+		projectRepo:         projectRepo,
 	}
 }
 
@@ -42,6 +55,10 @@ func (te *TaskExecutor) Start() {
 	}
 
 	for _, task := range tasks {
+		// 心跳任务不执行命令，由 HeartbeatMonitor 检查是否按时上报
+		if task.IsHeartbeat() {
+			continue
+		}
 		te.scheduleTask(task)
 	}
 
@@ -53,7 +70,14 @@ func (te *TaskExecutor) Stop() {
 }
 
 func (te *TaskExecutor) scheduleTask(task *entity.Task) {
+	// 持有锁直到记录调度ID，任务在此之前开始运行时会等待
+	te.mu.Lock()
+	defer te.mu.Unlock()
+
 	entryID, err := te.cron.AddFunc(task.Schedule, func() {
+		if scheduled := te.scheduledTime(task.ID); !scheduled.IsZero() {
+			observer.ObserveQueueWait(task, time.Since(scheduled))
+		}
 		te.executeTask(task)
 	})
 	if err != nil {
@@ -65,6 +89,22 @@ func (te *TaskExecutor) scheduleTask(task *entity.Task) {
 	log.Printf("Scheduled task %s with schedule %s", task.Name, task.Schedule)
 }
 
+// scheduledTime 任务本次运行的计划执行时间，运行前 cron 已将 Prev 设置为该时间
+func (te *TaskExecutor) scheduledTime(taskID int) time.Time {
+	te.mu.Lock()
+	entryID, ok := te.runningTasks[taskID]
+	te.mu.Unlock()
+	if !ok {
+		return time.Time{}
+	}
+	return te.cron.Entry(entryID).Prev
+}
+
+// EntryCount 调度器中的任务数量
+func (te *TaskExecutor) EntryCount() int {
+	return len(te.cron.Entries())
+}
+
 func (te *TaskExecutor) executeTask(task *entity.Task) {
 	log.Printf("Executing task: %s", task.Name)
 
@@ -74,17 +114,40 @@ func (te *TaskExecutor) executeTask(task *entity.Task) {
 		log.Printf("Failed to get latest task config for task %s: %v", task.Name, err)
 		latestTask = task // 使用原任务配置作为备用
 	}
+	if latestTask.IsHeartbeat() {
+		log.Printf("Task %s is a heartbeat task, skipping execution", task.Name)
+		return
+	}
+
+	if err := te.Execute(latestTask, entity.TriggerSchedule); err != nil {
+		log.Printf("Skipping task %s: %v", task.Name, err)
+	}
+}
+
+// Execute 执行任务，trigger 为触发来源。任务所属项目同时执行的任务数已达上限时不执行，返回 ErrProjectConcurrencyLimit。
+// 执行期间可以通过 CancelTaskRuns 取消
+func (te *TaskExecutor) Execute(task *entity.Task, trigger string) error {
+	release, err := te.acquireRun(task)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, done := trackRun(task.ID)
+	defer done()
 
 	// 检查是否为HTTP请求
-	if strings.HasPrefix(latestTask.Command, "http://") || strings.HasPrefix(latestTask.Command, "https://") {
-		te.ExecuteHTTPRequest(latestTask)
+	if strings.HasPrefix(task.Command, "http://") || strings.HasPrefix(task.Command, "https://") {
+		te.ExecuteHTTPRequest(ctx, task, trigger)
 	} else {
 		// 执行系统命令
-		te.ExecuteSystemCommand(latestTask)
+		te.ExecuteSystemCommand(ctx, task, trigger)
 	}
+	return nil
 }
 
-func (te *TaskExecutor) ExecuteSystemCommand(task *entity.Task) {
+// ExecuteSystemCommand 执行系统命令，ctx 取消时终止命令进程
+func (te *TaskExecutor) ExecuteSystemCommand(ctx context.Context, task *entity.Task, trigger string) {
 	// 记录开始时间
 	startTime := time.Now()
 	
@@ -96,23 +159,25 @@ func (te *TaskExecutor) ExecuteSystemCommand(task *entity.Task) {
 		endTime := time.Now()
 		// 记录日志到数据库
 		taskLog := &entity.TaskLog{
-			TaskID:    task.ID,
-			TaskName:  task.Name,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Success:   false,
-			Error:     "Invalid command",
+			TaskID:        task.ID,
+			TaskName:      task.Name,
+			TriggerSource: trigger,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Success:       false,
+			Error:         "Invalid command",
 		}
 		if err := te.taskLogRepo.Create(taskLog); err != nil {
 			log.Printf("Failed to save task log for task %s: %v", task.Name, err)
 		}
 		
-		// 发送通知
-		te.sendNotification(task, taskLog)
+		te.finishExecution(task, taskLog)
 		return
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd := exec.CommandContext(ctx, parts[0], parts[1:]...)
+	// 取消后子进程仍持有输出管道时不无限等待
+	cmd.WaitDelay = 5 * time.Second
 	output, err := cmd.CombinedOutput()
 	endTime := time.Now()
 	
@@ -123,13 +188,14 @@ func (te *TaskExecutor) ExecuteSystemCommand(task *entity.Task) {
 		
 		// 记录日志到数据库
 		taskLog = &entity.TaskLog{
-			TaskID:    task.ID,
-			TaskName:  task.Name,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Success:   false,
-			Output:    string(output),
-			Error:     err.Error(),
+			TaskID:        task.ID,
+			TaskName:      task.Name,
+			TriggerSource: trigger,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Success:       false,
+			Output:        string(output),
+			Error:         runError(ctx, err),
 		}
 		if err := te.taskLogRepo.Create(taskLog); err != nil {
 			log.Printf("Failed to save task log for task %s: %v", task.Name, err)
@@ -139,23 +205,24 @@ func (te *TaskExecutor) ExecuteSystemCommand(task *entity.Task) {
 		
 		// 记录日志到数据库
 		taskLog = &entity.TaskLog{
-			TaskID:    task.ID,
-			TaskName:  task.Name,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Success:   true,
-			Output:    string(output),
+			TaskID:        task.ID,
+			TaskName:      task.Name,
+			TriggerSource: trigger,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Success:       true,
+			Output:        string(output),
 		}
 		if err := te.taskLogRepo.Create(taskLog); err != nil {
 			log.Printf("Failed to save task log for task %s: %v", task.Name, err)
 		}
 	}
 	
-	// 发送通知
-	te.sendNotification(task, taskLog)
+	te.finishExecution(task, taskLog)
 }
 
-func (te *TaskExecutor) ExecuteHTTPRequest(task *entity.Task) {
+// ExecuteHTTPRequest 执行 HTTP 请求，ctx 取消时中断请求
+func (te *TaskExecutor) ExecuteHTTPRequest(ctx context.Context, task *entity.Task, trigger string) {
 	// 记录开始时间
 	startTime := time.Now()
 	
@@ -169,26 +236,26 @@ func (te *TaskExecutor) ExecuteHTTPRequest(task *entity.Task) {
 	}
 
 	// 创建请求
-	req, err := http.NewRequest(method, task.Command, nil)
+	req, err := http.NewRequestWithContext(ctx, method, task.Command, nil)
 	if err != nil {
 		log.Printf("Failed to create HTTP request for task %s: %v", task.Name, err)
 		
 		endTime := time.Now()
 		// 记录日志到数据库
 		taskLog := &entity.TaskLog{
-			TaskID:    task.ID,
-			TaskName:  task.Name,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Success:   false,
-			Error:     err.Error(),
+			TaskID:        task.ID,
+			TaskName:      task.Name,
+			TriggerSource: trigger,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Success:       false,
+			Error:         err.Error(),
 		}
 		if err := te.taskLogRepo.Create(taskLog); err != nil {
 			log.Printf("Failed to save task log for task %s: %v", task.Name, err)
 		}
 		
-		// 发送通知
-		te.sendNotification(task, taskLog)
+		te.finishExecution(task, taskLog)
 		return
 	}
 
@@ -215,12 +282,13 @@ func (te *TaskExecutor) ExecuteHTTPRequest(task *entity.Task) {
 		
 		// 记录日志到数据库
 		taskLog = &entity.TaskLog{
-			TaskID:    task.ID,
-			TaskName:  task.Name,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Success:   false,
-			Error:     err.Error(),
+			TaskID:        task.ID,
+			TaskName:      task.Name,
+			TriggerSource: trigger,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Success:       false,
+			Error:         runError(ctx, err),
 		}
 		if err := te.taskLogRepo.Create(taskLog); err != nil {
 			log.Printf("Failed to save task log for task %s: %v", task.Name, err)
@@ -240,12 +308,13 @@ func (te *TaskExecutor) ExecuteHTTPRequest(task *entity.Task) {
 		
 		// 记录日志到数据库
 		taskLog = &entity.TaskLog{
-			TaskID:    task.ID,
-			TaskName:  task.Name,
-			StartTime: startTime,
-			EndTime:   endTime,
-			Success:   success,
-			Output:    output,
+			TaskID:        task.ID,
+			TaskName:      task.Name,
+			TriggerSource: trigger,
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Success:       success,
+			Output:        output,
 		}
 		
 		if !success {
@@ -257,65 +326,145 @@ func (te *TaskExecutor) ExecuteHTTPRequest(task *entity.Task) {
 		}
 	}
 	
-	// 发送通知
+	te.finishExecution(task, taskLog)
+}
+
+// runError 执行失败时记录的错误信息，执行被取消时注明已取消
+func runError(ctx context.Context, err error) string {
+	if ctx.Err() != nil {
+		return runCancelledError + ": " + err.Error()
+	}
+	return err.Error()
+}
+
+// finishExecution 记录执行指标并发送通知
+func (te *TaskExecutor) finishExecution(task *entity.Task, taskLog *entity.TaskLog) {
+	observer.ObserveTaskExecution(taskLog)
 	te.sendNotification(task, taskLog)
 }
 
 // sendNotification 发送通知
 func (te *TaskExecutor) sendNotification(task *entity.Task, taskLog *entity.TaskLog) {
-	log.Printf("Checking notification for task %s: Success=%v, NotifyOnSuccess=%v, NotifyOnFailure=%v, NotificationTypes=%s", 
-		task.Name, taskLog.Success, task.NotifyOnSuccess, task.NotifyOnFailure, task.NotificationTypes)
+	log.Printf("Checking notification for task %s: Success=%v, NotifyOnSuccess=%v, NotifyOnFailure=%v, NotificationChannelIDs=%s", 
+		task.Name, taskLog.Success, task.NotifyOnSuccess, task.NotifyOnFailure, task.NotificationChannelIDs)
 	
-	// 检查是否需要发送通知
-	shouldNotify := (taskLog.Success && task.NotifyOnSuccess) || (!taskLog.Success && task.NotifyOnFailure)
-	if !shouldNotify {
-		log.Printf("Notification not needed for task %s: shouldNotify=%v", task.Name, shouldNotify)
+	// 异常检测
+	anomalies := te.detectAnomalies(task, taskLog)
+
+	// 按告警规则判定是否需要发送通知，检测到异常时总是通知
+	decision := te.alertEvaluator.Evaluate(task, taskLog, len(anomalies) > 0)
+	if !decision.Notify {
+		log.Printf("Notification not needed for task %s: %s", task.Name, decision.Reason)
 		return
 	}
-	
-	if task.NotificationTypes == "" {
-		log.Printf("No notification types configured for task %s", task.Name)
+
+	// 解析通知渠道
+	channelIDs, err := entity.ParseChannelIDs(task.NotificationChannelIDs)
+	if err != nil {
+		log.Printf("Failed to parse notification channels for task %s: %v", task.Name, err)
 		return
 	}
 
-	// 解析通知类型
-	var notificationTypes []string
-	if err := json.Unmarshal([]byte(task.NotificationTypes), &notificationTypes); err != nil {
-		log.Printf("Failed to parse notification types for task %s: %v", task.Name, err)
-		return
+	// 恢复通知同时发送给本轮告警已升级到的渠道
+	if decision.Alert != nil && decision.Alert.Resolved() {
+		channelIDs = mergeChannelIDs(channelIDs, escalatedChannelIDs(te.policyRepo, task, decision.EscalationLevel))
 	}
 
-	if len(notificationTypes) == 0 {
-		log.Printf("No notification types found for task %s", task.Name)
+	if len(channelIDs) == 0 {
+		log.Printf("No notification channels configured for task %s", task.Name)
 		return
 	}
-	
-	log.Printf("Sending notification for task %s with types: %v", task.Name, notificationTypes)
-
-	// 解析通知配置
-	var notificationConfig entity.NotificationConfig
-	if task.NotificationConfig != "" {
-		if err := json.Unmarshal([]byte(task.NotificationConfig), &notificationConfig); err != nil {
-			log.Printf("Failed to parse notification config for task %s: %v", task.Name, err)
-			return
-		}
-	} else {
-		log.Printf("No notification config found for task %s", task.Name)
+
+	channels, err := te.channelRepo.FindByIDs(channelIDs)
+	if err != nil {
+		log.Printf("Failed to load notification channels for task %s: %v", task.Name, err)
 		return
 	}
 
+	log.Printf("Sending notification for task %s to channels: %v", task.Name, channelIDs)
+
 	// 构建通知消息
+	message := newTaskNotificationMessage(te.taskLogRepo, task, taskLog)
+	message.Anomalies = anomalies
+	message.Alert = decision.Alert
+
+	// 写入发送队列，由通知分发器异步发送
+	te.outbox.Enqueue(channels, message)
+}
+
+// mergeChannelIDs 合并渠道ID并去重，保持原有顺序
+func mergeChannelIDs(channelIDs []int, extra []int) []int {
+	seen := make(map[int]bool, len(channelIDs))
+	for _, id := range channelIDs {
+		seen[id] = true
+	}
+	for _, id := range extra {
+		if !seen[id] {
+			seen[id] = true
+			channelIDs = append(channelIDs, id)
+		}
+	}
+	return channelIDs
+}
+
+// newTaskNotificationMessage 根据执行日志构建任务执行通知消息
+func newTaskNotificationMessage(taskLogRepo repository.TaskLogRepository, task *entity.Task, taskLog *entity.TaskLog) *entity.NotificationMessage {
 	duration := taskLog.EndTime.Sub(taskLog.StartTime)
-	message := &entity.NotificationMessage{
-		TaskName:  task.Name,
-		Success:   taskLog.Success,
-		StartTime: taskLog.StartTime.Format("2006-01-02 15:04:05"),
-		EndTime:   taskLog.EndTime.Format("2006-01-02 15:04:05"),
-		Duration:  duration.String(),
-		Output:    taskLog.Output,
-		Error:     taskLog.Error,
+	return &entity.NotificationMessage{
+		TaskName:   task.Name,
+		Success:    taskLog.Success,
+		StartTime:  taskLog.StartTime.Format("2006-01-02 15:04:05"),
+		EndTime:    taskLog.EndTime.Format("2006-01-02 15:04:05"),
+		Duration:   duration.String(),
+		Output:     taskLog.Output,
+		Error:      taskLog.Error,
+		Task:       task,
+		Run:        taskLog,
+		Statistics: taskStatistics(taskLogRepo, task),
+	}
+}
+
+// taskStatistics 统计任务的历史执行情况，供通知模板使用，统计失败时返回 nil
+func taskStatistics(taskLogRepo repository.TaskLogRepository, task *entity.Task) *entity.TaskStatistics {
+	taskID := task.ID
+	aggregates, err := taskLogRepo.AggregateByTask(&entity.TaskLogFilter{TaskID: &taskID})
+	if err != nil {
+		log.Printf("Failed to aggregate statistics for task %s: %v", task.Name, err)
+		return nil
+	}
+
+	statistics := &entity.TaskStatistics{TaskID: task.ID, TaskName: task.Name}
+	if len(aggregates) > 0 {
+		aggregate := aggregates[0]
+		statistics.TotalExecutions = aggregate.TotalExecutions
+		statistics.SuccessExecutions = aggregate.SuccessExecutions
+		statistics.FailureExecutions = aggregate.FailureExecutions
+		statistics.AverageExecutionTime = aggregate.AvgDuration
+		statistics.LastExecutionTime = aggregate.LastStartTime
+		statistics.LastExecutionStatus = aggregate.LastSuccess
+		if aggregate.TotalExecutions > 0 {
+			statistics.SuccessRate = float64(aggregate.SuccessExecutions) / float64(aggregate.TotalExecutions) * 100
+		}
+	}
+	return statistics
+}
+
+// detectAnomalies 检测本次执行是否异常，返回异常描述；任务未开启异常通知时不做检测
+func (te *TaskExecutor) detectAnomalies(task *entity.Task, taskLog *entity.TaskLog) []string {
+	if !task.NotifyOnAnomaly || taskLog.ID == 0 {
+		return nil
 	}
 
-	// 发送通知
-	te.notificationService.SendNotification(&notificationConfig, message, notificationTypes)
+	anomalies, err := te.anomalyDetector.DetectRun(task, taskLog)
+	if err != nil {
+		log.Printf("Failed to detect anomalies for task %s: %v", task.Name, err)
+		return nil
+	}
+
+	var messages []string
+	for _, anomaly := range anomalies {
+		log.Printf("Anomaly detected for task %s: %s", task.Name, anomaly.Message)
+		messages = append(messages, anomaly.Message)
+	}
+	return messages
 }
\ No newline at end of file
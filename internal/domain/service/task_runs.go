@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTaskNotRunning 任务当前没有正在执行的运行，无法取消
+var ErrTaskNotRunning = errors.New("任务当前没有正在执行")
+
+// runCancelledError 执行被取消时记录到执行日志中的错误信息
+const runCancelledError = "执行已被取消"
+
+// activeRuns 各任务正在执行的运行的取消函数，同一进程中的多个 TaskExecutor 共享，
+// 手动执行和计划执行都可以通过 CancelTaskRuns 取消
+var activeRuns = struct {
+	sync.Mutex
+	nextID uint64
+	runs   map[int]map[uint64]activeRun
+}{runs: make(map[int]map[uint64]activeRun)}
+
+// activeRun 正在执行的运行
+type activeRun struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// trackRun 登记任务的一次运行，返回运行使用的 context。执行结束后需要调用返回的 done
+func trackRun(taskID int) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	activeRuns.Lock()
+	defer activeRuns.Unlock()
+	activeRuns.nextID++
+	id := activeRuns.nextID
+	if activeRuns.runs[taskID] == nil {
+		activeRuns.runs[taskID] = make(map[uint64]activeRun)
+	}
+	activeRuns.runs[taskID][id] = activeRun{ctx: ctx, cancel: cancel}
+
+	return ctx, func() {
+		cancel()
+		activeRuns.Lock()
+		defer activeRuns.Unlock()
+		delete(activeRuns.runs[taskID], id)
+		if len(activeRuns.runs[taskID]) == 0 {
+			delete(activeRuns.runs, taskID)
+		}
+	}
+}
+
+// CancelTaskRuns 取消任务所有正在执行的运行：终止命令进程或中断 HTTP 请求，执行日志记录为失败。
+// 返回取消的运行数，没有正在执行（或都已取消）的运行时返回 ErrTaskNotRunning
+func CancelTaskRuns(taskID int) (int, error) {
+	activeRuns.Lock()
+	defer activeRuns.Unlock()
+	cancelled := 0
+	for _, run := range activeRuns.runs[taskID] {
+		if run.ctx.Err() == nil {
+			run.cancel()
+			cancelled++
+		}
+	}
+	if cancelled == 0 {
+		return 0, ErrTaskNotRunning
+	}
+	return cancelled, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/infrastructure/persistence"
+)
+
+// waitForRun 等待任务开始执行
+func waitForRun(t *testing.T, taskID int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		activeRuns.Lock()
+		running := len(activeRuns.runs[taskID]) > 0
+		activeRuns.Unlock()
+		if running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("task %d did not start", taskID)
+}
+
+func TestCancelTaskRuns(t *testing.T) {
+	// HTTP 任务请求的服务器在请求被中断前不返回
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{"command", "sleep 30"},
+		{"http request", server.URL},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, logRepo := newTestLogRepo(t)
+			executor := NewTaskExecutor(
+				persistence.NewTaskRepository(db),
+				logRepo,
+				persistence.NewNotificationChannelRepository(db),
+				persistence.NewNotificationDeliveryRepository(db),
+				persistence.NewTaskAlertRepository(db),
+				persistence.NewEscalationPolicyRepository(db),
+				persistence.NewProjectRepository(db),
+			)
+			task := &entity.Task{ID: 100 + i, Name: "task", Schedule: "0 0 * * *", Command: tt.command}
+
+			if _, err := CancelTaskRuns(task.ID); !errors.Is(err, ErrTaskNotRunning) {
+				t.Fatalf("CancelTaskRuns before start error = %v, want %v", err, ErrTaskNotRunning)
+			}
+
+			finished := make(chan error, 1)
+			go func() { finished <- executor.Execute(task, entity.TriggerManual) }()
+			waitForRun(t, task.ID)
+
+			cancelled, err := CancelTaskRuns(task.ID)
+			if err != nil || cancelled != 1 {
+				t.Fatalf("CancelTaskRuns = %d, %v, want 1", cancelled, err)
+			}
+			// 已取消的运行不再重复计数
+			if _, err := CancelTaskRuns(task.ID); !errors.Is(err, ErrTaskNotRunning) {
+				t.Errorf("second CancelTaskRuns error = %v, want %v", err, ErrTaskNotRunning)
+			}
+
+			select {
+			case err := <-finished:
+				if err != nil {
+					t.Fatalf("Execute: %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("Execute did not return after cancellation")
+			}
+
+			logs, err := logRepo.FindLogs(&entity.TaskLogFilter{TaskID: &task.ID})
+			if err != nil || len(logs) != 1 {
+				t.Fatalf("FindLogs = %v, %v", logs, err)
+			}
+			if logs[0].Success || !strings.HasPrefix(logs[0].Error, runCancelledError) {
+				t.Errorf("log success = %v, error = %q, want a cancelled failure", logs[0].Success, logs[0].Error)
+			}
+			if logs[0].EndTime.Sub(logs[0].StartTime) > 5*time.Second {
+				t.Errorf("cancelled run took %v", logs[0].EndTime.Sub(logs[0].StartTime))
+			}
+			if _, err := CancelTaskRuns(task.ID); !errors.Is(err, ErrTaskNotRunning) {
+				t.Errorf("CancelTaskRuns after finish error = %v, want %v", err, ErrTaskNotRunning)
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// Config 应用配置。加载顺序为默认值、配置文件、环境变量、命令行参数，后者覆盖前者
+type Config struct {
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	JWT       JWTConfig       `yaml:"jwt" toml:"jwt"`
+	Collector CollectorConfig `yaml:"collector" toml:"collector"`
+	Retention RetentionConfig `yaml:"retention" toml:"retention"`
+	Metrics   MetricsConfig   `yaml:"metrics" toml:"metrics"`
+	Notifier  NotifierConfig  `yaml:"notifier" toml:"notifier"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	Path string `yaml:"path" toml:"path"` // SQLite 数据库文件路径
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Listen    string    `yaml:"listen" toml:"listen"`         // 监听地址，如 :8080 或 127.0.0.1:8080
+	Mode      string    `yaml:"mode" toml:"mode"`             // gin 运行模式: debug, release, test
+	StaticDir string    `yaml:"static_dir" toml:"static_dir"` // 前端构建产物目录
+	TLS       TLSConfig `yaml:"tls" toml:"tls"`
+	// TrustedProxies 可信反向代理的 IP 或 CIDR，只有来自这些地址的请求才从 X-Forwarded-For 获取客户端 IP；
+	// 默认不信任任何代理，客户端 IP 取 TCP 连接的对端地址
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"`
+}
+
+// TLSConfig HTTPS 配置，证书和私钥都设置时启用
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file"`
+}
+
+// Enabled 是否启用 HTTPS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+// JWTConfig 登录令牌配置
+type JWTConfig struct {
+	Secret            string   `yaml:"secret" toml:"secret"`                         // 签名密钥，为空时每次启动随机生成，重启后需要重新登录
+	Expiration        Duration `yaml:"expiration" toml:"expiration"`                 // 访问令牌有效期
+	RefreshExpiration Duration `yaml:"refresh_expiration" toml:"refresh_expiration"` // 刷新令牌有效期，超过该时间未刷新需要重新登录
+}
+
+// CollectorConfig 后台任务的执行间隔
+type CollectorConfig struct {
+	SystemStatsInterval Duration `yaml:"system_stats_interval" toml:"system_stats_interval"` // 系统监控数据采集间隔
+	CleanupInterval     Duration `yaml:"cleanup_interval" toml:"cleanup_interval"`           // 清理旧监控数据的间隔
+	DigestInterval      Duration `yaml:"digest_interval" toml:"digest_interval"`             // 检查到期报表摘要的间隔
+}
+
+// RetentionConfig 数据保留配置
+type RetentionConfig struct {
+	SystemStats int      `yaml:"system_stats" toml:"system_stats"` // 保留的系统监控记录条数
+	Deliveries  Duration `yaml:"deliveries" toml:"deliveries"`     // 已结束的通知发送记录保留时间
+	AuditLogs   Duration `yaml:"audit_logs" toml:"audit_logs"`     // 审计日志保留时间
+}
+
+// MetricsConfig Prometheus 指标配置
+type MetricsConfig struct {
+	Token string `yaml:"token" toml:"token"` // 访问 /metrics 所需的 Bearer token，为空时不认证
+}
+
+// NotifierConfig 通知发送配置
+type NotifierConfig struct {
+	Workers int `yaml:"workers" toml:"workers"` // 并发发送通知的 worker 数量
+}
+
+// AuthConfig 账号配置
+type AuthConfig struct {
+	Registration      string           `yaml:"registration" toml:"registration"`               // 注册方式: open 开放注册, approval 管理员审核, invite 仅邀请码, disabled 关闭注册
+	DisableLocalLogin bool             `yaml:"disable_local_login" toml:"disable_local_login"` // 禁用本地账号密码登录和注册，只允许单点登录和 LDAP 登录
+	OIDC              OIDCConfig       `yaml:"oidc" toml:"oidc"`
+	LDAP              LDAPConfig       `yaml:"ldap" toml:"ldap"`
+	LoginLimit        LoginLimitConfig `yaml:"login_limit" toml:"login_limit"`
+}
+
+// LoginLimitConfig 登录失败限制，窗口期内失败次数达到上限后临时锁定 IP 或账号
+type LoginLimitConfig struct {
+	MaxPerIP      int      `yaml:"max_per_ip" toml:"max_per_ip"`           // 同一 IP 的失败次数上限，0 表示不限制
+	MaxPerAccount int      `yaml:"max_per_account" toml:"max_per_account"` // 同一账号的失败次数上限，0 表示不限制
+	Window        Duration `yaml:"window" toml:"window"`                   // 统计失败次数的时间窗口
+	Lockout       Duration `yaml:"lockout" toml:"lockout"`                 // 锁定时长
+}
+
+// OIDCConfig OpenID Connect 单点登录配置，设置 issuer 后启用
+type OIDCConfig struct {
+	Issuer        string            `yaml:"issuer" toml:"issuer"`                 // 身份提供方地址，通过 {issuer}/.well-known/openid-configuration 发现端点
+	ClientID      string            `yaml:"client_id" toml:"client_id"`           // 客户端 ID
+	ClientSecret  string            `yaml:"client_secret" toml:"client_secret"`   // 客户端密钥，公共客户端可以为空，只使用 PKCE
+	RedirectURL   string            `yaml:"redirect_url" toml:"redirect_url"`     // 回调地址，如 https://cron.example.com/api/v1/auth/oidc/callback
+	Scopes        []string          `yaml:"scopes" toml:"scopes"`                 // 申请的 scope，openid 会自动加入
+	DisplayName   string            `yaml:"display_name" toml:"display_name"`     // 登录页按钮显示的名称
+	UsernameClaim string            `yaml:"username_claim" toml:"username_claim"` // 作为用户名的声明
+	GroupsClaim   string            `yaml:"groups_claim" toml:"groups_claim"`     // 用户组声明，用于映射角色
+	RoleMapping   map[string]string `yaml:"role_mapping" toml:"role_mapping"`     // 用户组到角色的映射，用户属于多个组时取最高的角色
+	DefaultRole   string            `yaml:"default_role" toml:"default_role"`     // 没有匹配的用户组时的角色
+	AutoCreate    bool              `yaml:"auto_create" toml:"auto_create"`       // 首次登录时自动创建用户
+	LinkByEmail   bool              `yaml:"link_by_email" toml:"link_by_email"`   // 首次登录时按已验证的邮箱关联管理员已切换为单点登录的账号
+}
+
+// Enabled 是否启用 OIDC 单点登录
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != ""
+}
+
+// LDAPConfig LDAP / Active Directory 账号密码登录配置，设置 url 后启用。
+// 登录时先用服务账号按 user_filter 搜索用户，再用用户的 DN 和密码绑定验证
+type LDAPConfig struct {
+	URL                string            `yaml:"url" toml:"url"`                                   // 服务器地址，如 ldap://dc.example.com:389 或 ldaps://dc.example.com:636
+	StartTLS           bool              `yaml:"start_tls" toml:"start_tls"`                       // 连接 ldap:// 后使用 StartTLS 升级为加密连接
+	InsecureSkipVerify bool              `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"` // 不校验服务器证书，仅用于测试
+	BindDN             string            `yaml:"bind_dn" toml:"bind_dn"`                           // 搜索用户的服务账号，为空时匿名搜索
+	BindPassword       string            `yaml:"bind_password" toml:"bind_password"`               // 服务账号密码
+	BaseDN             string            `yaml:"base_dn" toml:"base_dn"`                           // 搜索用户的起点，如 dc=example,dc=com
+	UserFilter         string            `yaml:"user_filter" toml:"user_filter"`                   // 搜索用户的过滤器，%s 替换为登录的用户名
+	UsernameAttribute  string            `yaml:"username_attribute" toml:"username_attribute"`     // 作为用户名的属性
+	EmailAttribute     string            `yaml:"email_attribute" toml:"email_attribute"`           // 邮箱属性
+	GroupAttribute     string            `yaml:"group_attribute" toml:"group_attribute"`           // 用户条目上记录所属组 DN 的属性，如 memberOf
+	GroupBaseDN        string            `yaml:"group_base_dn" toml:"group_base_dn"`               // 设置 group_filter 时搜索用户组的起点，为空时使用 base_dn
+	GroupFilter        string            `yaml:"group_filter" toml:"group_filter"`                 // 搜索用户所属组的过滤器，%s 替换为用户 DN，设置后不再读取 group_attribute
+	DisplayName        string            `yaml:"display_name" toml:"display_name"`                 // 登录页显示的名称
+	RoleMapping        map[string]string `yaml:"role_mapping" toml:"role_mapping"`                 // 用户组名称（组 DN 的 cn）到角色的映射，用户属于多个组时取最高的角色
+	DefaultRole        string            `yaml:"default_role" toml:"default_role"`                 // 没有匹配的用户组时的角色
+	AutoCreate         bool              `yaml:"auto_create" toml:"auto_create"`                   // 首次登录时自动创建用户
+	LinkByEmail        bool              `yaml:"link_by_email" toml:"link_by_email"`               // 首次登录时关联邮箱相同的本地账号（管理员除外），仅在目录中的邮箱不能由用户自行修改时开启
+	SyncInterval       Duration          `yaml:"sync_interval" toml:"sync_interval"`               // 从目录同步用户启用状态和角色的间隔
+	Timeout            Duration          `yaml:"timeout" toml:"timeout"`                           // 连接和请求超时时间
+}
+
+// Enabled 是否启用 LDAP 登录
+func (c LDAPConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// Default 默认配置
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{Path: "crontab.db"},
+		Server: ServerConfig{
+			Listen:    ":8080",
+			Mode:      "debug",
+			StaticDir: "./web/dist",
+		},
+		JWT: JWTConfig{
+			Expiration:        Duration(15 * time.Minute),
+			RefreshExpiration: Duration(7 * 24 * time.Hour),
+		},
+		Collector: CollectorConfig{
+			SystemStatsInterval: Duration(10 * time.Second),
+			CleanupInterval:     Duration(time.Minute),
+			DigestInterval:      Duration(time.Minute),
+		},
+		Retention: RetentionConfig{
+			SystemStats: 100,
+			Deliveries:  Duration(30 * 24 * time.Hour),
+			AuditLogs:   Duration(180 * 24 * time.Hour),
+		},
+		Notifier: NotifierConfig{Workers: 4},
+		Auth: AuthConfig{
+			Registration: "open",
+			OIDC: OIDCConfig{
+				Scopes:        []string{"openid", "profile", "email"},
+				DisplayName:   "SSO",
+				UsernameClaim: "preferred_username",
+				GroupsClaim:   "groups",
+				DefaultRole:   "viewer",
+				AutoCreate:    true,
+			},
+			LDAP: LDAPConfig{
+				UserFilter:        "(&(objectClass=person)(sAMAccountName=%s))",
+				UsernameAttribute: "sAMAccountName",
+				EmailAttribute:    "mail",
+				GroupAttribute:    "memberOf",
+				DisplayName:       "LDAP",
+				DefaultRole:       "viewer",
+				AutoCreate:        true,
+				SyncInterval:      Duration(15 * time.Minute),
+				Timeout:           Duration(10 * time.Second),
+			},
+			LoginLimit: LoginLimitConfig{
+				MaxPerIP:      20,
+				MaxPerAccount: 5,
+				Window:        Duration(15 * time.Minute),
+				Lockout:       Duration(15 * time.Minute),
+			},
+		},
+	}
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	if c.Database.Path == "" {
+		return errors.New("database.path 不能为空")
+	}
+	if c.Server.Listen == "" {
+		return errors.New("server.listen 不能为空")
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		return fmt.Errorf("不支持的 server.mode: %s", c.Server.Mode)
+	}
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		return errors.New("server.tls.cert_file 和 server.tls.key_file 需要同时设置")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("server.trusted_proxies 中的地址无效: %s", proxy)
+			}
+		}
+	}
+	if c.JWT.Expiration <= 0 {
+		return errors.New("jwt.expiration 必须大于 0")
+	}
+	if c.JWT.RefreshExpiration < c.JWT.Expiration {
+		return errors.New("jwt.refresh_expiration 不能小于 jwt.expiration")
+	}
+	for name, interval := range map[string]Duration{
+		"collector.system_stats_interval": c.Collector.SystemStatsInterval,
+		"collector.cleanup_interval":      c.Collector.CleanupInterval,
+		"collector.digest_interval":       c.Collector.DigestInterval,
+		"retention.deliveries":            c.Retention.Deliveries,
+		"retention.audit_logs":            c.Retention.AuditLogs,
+		"auth.login_limit.window":         c.Auth.LoginLimit.Window,
+		"auth.login_limit.lockout":        c.Auth.LoginLimit.Lockout,
+	} {
+		if interval <= 0 {
+			return fmt.Errorf("%s 必须大于 0", name)
+		}
+	}
+	if c.Retention.SystemStats <= 0 {
+		return errors.New("retention.system_stats 必须大于 0")
+	}
+	if c.Auth.LoginLimit.MaxPerIP < 0 || c.Auth.LoginLimit.MaxPerAccount < 0 {
+		return errors.New("auth.login_limit 的失败次数上限不能小于 0")
+	}
+	if c.Notifier.Workers <= 0 {
+		return errors.New("notifier.workers 必须大于 0")
+	}
+	switch c.Auth.Registration {
+	case "open", "approval", "invite", "disabled":
+	default:
+		return fmt.Errorf("不支持的 auth.registration: %s", c.Auth.Registration)
+	}
+	if err := c.Auth.OIDC.validate(); err != nil {
+		return err
+	}
+	if err := c.Auth.LDAP.validate(); err != nil {
+		return err
+	}
+	if c.Auth.DisableLocalLogin && !c.Auth.OIDC.Enabled() && !c.Auth.LDAP.Enabled() {
+		return errors.New("auth.disable_local_login 需要配置单点登录 auth.oidc 或 auth.ldap")
+	}
+	return nil
+}
+
+func (c OIDCConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	if c.ClientID == "" || c.RedirectURL == "" {
+		return errors.New("启用 auth.oidc 时需要设置 client_id 和 redirect_url")
+	}
+	if c.UsernameClaim == "" {
+		return errors.New("auth.oidc.username_claim 不能为空")
+	}
+	if !validRole(c.DefaultRole) {
+		return fmt.Errorf("不支持的 auth.oidc.default_role: %s", c.DefaultRole)
+	}
+	for group, role := range c.RoleMapping {
+		if !validRole(role) {
+			return fmt.Errorf("auth.oidc.role_mapping 中用户组 %s 的角色无效: %s", group, role)
+		}
+	}
+	return nil
+}
+
+func (c LDAPConfig) validate() error {
+	if !c.Enabled() {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(c.URL, "ldaps://"):
+		if c.StartTLS {
+			return errors.New("auth.ldap.start_tls 不能用于 ldaps:// 地址")
+		}
+	case strings.HasPrefix(c.URL, "ldap://"):
+	default:
+		return fmt.Errorf("auth.ldap.url 需要以 ldap:// 或 ldaps:// 开头: %s", c.URL)
+	}
+	if c.BaseDN == "" {
+		return errors.New("启用 auth.ldap 时需要设置 base_dn")
+	}
+	if !strings.Contains(c.UserFilter, "%s") {
+		return errors.New("auth.ldap.user_filter 需要包含 %s")
+	}
+	if c.GroupFilter != "" && !strings.Contains(c.GroupFilter, "%s") {
+		return errors.New("auth.ldap.group_filter 需要包含 %s")
+	}
+	if c.UsernameAttribute == "" {
+		return errors.New("auth.ldap.username_attribute 不能为空")
+	}
+	if !validRole(c.DefaultRole) {
+		return fmt.Errorf("不支持的 auth.ldap.default_role: %s", c.DefaultRole)
+	}
+	for group, role := range c.RoleMapping {
+		if !validRole(role) {
+			return fmt.Errorf("auth.ldap.role_mapping 中用户组 %s 的角色无效: %s", group, role)
+		}
+	}
+	if c.SyncInterval <= 0 || c.Timeout <= 0 {
+		return errors.New("auth.ldap.sync_interval 和 auth.ldap.timeout 必须大于 0")
+	}
+	return nil
+}
+
+func validRole(role string) bool {
+	switch role {
+	case "admin", "operator", "viewer":
+		return true
+	}
+	return false
+}
+
+// Duration 可从 "10s"、"1h30m" 这样的字符串解析的时间间隔，用于配置文件、环境变量和命令行参数
+type Duration time.Duration
+
+// Std 转换为 time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set 实现 flag.Value
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
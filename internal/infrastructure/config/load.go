@@ -0,0 +1,202 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// envVars 环境变量与配置项的对应关系
+var envVars = []struct {
+	name  string
+	apply func(cfg *Config, value string) error
+}{
+	{"DB_PATH", func(cfg *Config, v string) error { cfg.Database.Path = v; return nil }},
+	{"PORT", func(cfg *Config, v string) error { cfg.Server.Listen = ":" + v; return nil }},
+	{"LISTEN_ADDR", func(cfg *Config, v string) error { cfg.Server.Listen = v; return nil }},
+	{"GIN_MODE", func(cfg *Config, v string) error { cfg.Server.Mode = v; return nil }},
+	{"STATIC_DIR", func(cfg *Config, v string) error { cfg.Server.StaticDir = v; return nil }},
+	{"TLS_CERT_FILE", func(cfg *Config, v string) error { cfg.Server.TLS.CertFile = v; return nil }},
+	{"TLS_KEY_FILE", func(cfg *Config, v string) error { cfg.Server.TLS.KeyFile = v; return nil }},
+	{"TRUSTED_PROXIES", func(cfg *Config, v string) error { cfg.Server.TrustedProxies = splitList(v); return nil }},
+	{"JWT_SECRET", func(cfg *Config, v string) error { cfg.JWT.Secret = v; return nil }},
+	{"JWT_EXPIRATION", func(cfg *Config, v string) error { return cfg.JWT.Expiration.Set(v) }},
+	{"JWT_REFRESH_EXPIRATION", func(cfg *Config, v string) error { return cfg.JWT.RefreshExpiration.Set(v) }},
+	{"SYSTEM_STATS_INTERVAL", func(cfg *Config, v string) error { return cfg.Collector.SystemStatsInterval.Set(v) }},
+	{"CLEANUP_INTERVAL", func(cfg *Config, v string) error { return cfg.Collector.CleanupInterval.Set(v) }},
+	{"DIGEST_INTERVAL", func(cfg *Config, v string) error { return cfg.Collector.DigestInterval.Set(v) }},
+	{"SYSTEM_STATS_RETENTION", func(cfg *Config, v string) error { return setInt(&cfg.Retention.SystemStats, v) }},
+	{"DELIVERY_RETENTION", func(cfg *Config, v string) error { return cfg.Retention.Deliveries.Set(v) }},
+	{"AUDIT_LOG_RETENTION", func(cfg *Config, v string) error { return cfg.Retention.AuditLogs.Set(v) }},
+	{"NOTIFIER_WORKERS", func(cfg *Config, v string) error { return setInt(&cfg.Notifier.Workers, v) }},
+	{"METRICS_TOKEN", func(cfg *Config, v string) error { cfg.Metrics.Token = v; return nil }},
+	{"REGISTRATION_MODE", func(cfg *Config, v string) error { cfg.Auth.Registration = v; return nil }},
+	{"DISABLE_LOCAL_LOGIN", func(cfg *Config, v string) error { return setBool(&cfg.Auth.DisableLocalLogin, v) }},
+	{"OIDC_ISSUER", func(cfg *Config, v string) error { cfg.Auth.OIDC.Issuer = v; return nil }},
+	{"OIDC_CLIENT_ID", func(cfg *Config, v string) error { cfg.Auth.OIDC.ClientID = v; return nil }},
+	{"OIDC_CLIENT_SECRET", func(cfg *Config, v string) error { cfg.Auth.OIDC.ClientSecret = v; return nil }},
+	{"OIDC_REDIRECT_URL", func(cfg *Config, v string) error { cfg.Auth.OIDC.RedirectURL = v; return nil }},
+	{"LOGIN_MAX_PER_IP", func(cfg *Config, v string) error { return setInt(&cfg.Auth.LoginLimit.MaxPerIP, v) }},
+	{"LOGIN_MAX_PER_ACCOUNT", func(cfg *Config, v string) error { return setInt(&cfg.Auth.LoginLimit.MaxPerAccount, v) }},
+	{"LOGIN_LOCKOUT", func(cfg *Config, v string) error { return cfg.Auth.LoginLimit.Lockout.Set(v) }},
+	{"LDAP_URL", func(cfg *Config, v string) error { cfg.Auth.LDAP.URL = v; return nil }},
+	{"LDAP_BIND_DN", func(cfg *Config, v string) error { cfg.Auth.LDAP.BindDN = v; return nil }},
+	{"LDAP_BIND_PASSWORD", func(cfg *Config, v string) error { cfg.Auth.LDAP.BindPassword = v; return nil }},
+	{"LDAP_BASE_DN", func(cfg *Config, v string) error { cfg.Auth.LDAP.BaseDN = v; return nil }},
+}
+
+// Load 加载配置。优先级从低到高依次为：默认值、配置文件、环境变量、命令行参数。
+// 配置文件通过 -config 参数或 CONFIG_FILE 环境变量指定，根据扩展名按 YAML 或 TOML 解析。
+// args 为 -h 时返回 flag.ErrHelp
+func Load(args []string) (*Config, error) {
+	var (
+		configFile = os.Getenv("CONFIG_FILE")
+		flags      = newFlagSet()
+		overrides  Config
+	)
+	flags.StringVar(&configFile, "config", configFile, "配置文件路径，支持 .yaml/.yml/.toml")
+	flags.StringVar(&overrides.Database.Path, "db", "", "数据库文件路径")
+	flags.StringVar(&overrides.Server.Listen, "listen", "", "监听地址，如 :8080")
+	flags.StringVar(&overrides.Server.Mode, "mode", "", "运行模式 (debug/release/test)")
+	flags.StringVar(&overrides.Server.StaticDir, "static-dir", "", "前端静态文件目录")
+	flags.StringVar(&overrides.Server.TLS.CertFile, "tls-cert", "", "HTTPS 证书文件")
+	flags.StringVar(&overrides.Server.TLS.KeyFile, "tls-key", "", "HTTPS 私钥文件")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if configFile != "" {
+		if err := loadFile(cfg, configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, env := range envVars {
+		value, ok := os.LookupEnv(env.name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := env.apply(cfg, value); err != nil {
+			return nil, fmt.Errorf("环境变量 %s 无效: %w", env.name, err)
+		}
+	}
+
+	// 只覆盖命令行中显式指定的参数
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "db":
+			cfg.Database.Path = overrides.Database.Path
+		case "listen":
+			cfg.Server.Listen = overrides.Server.Listen
+		case "mode":
+			cfg.Server.Mode = overrides.Server.Mode
+		case "static-dir":
+			cfg.Server.StaticDir = overrides.Server.StaticDir
+		case "tls-cert":
+			cfg.Server.TLS.CertFile = overrides.Server.TLS.CertFile
+		case "tls-key":
+			cfg.Server.TLS.KeyFile = overrides.Server.TLS.KeyFile
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.JWT.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("生成 JWT 密钥失败: %w", err)
+		}
+		cfg.JWT.Secret = hex.EncodeToString(secret)
+		log.Println("Warning: JWT secret is not configured, using a random secret; users must log in again after restart")
+	}
+	return cfg, nil
+}
+
+// PrintUsage 输出命令行参数和环境变量说明
+func PrintUsage(w io.Writer) {
+	flags := newFlagSet()
+	flags.String("config", "", "配置文件路径，支持 .yaml/.yml/.toml (环境变量 CONFIG_FILE)")
+	flags.String("db", "crontab.db", "数据库文件路径 (环境变量 DB_PATH)")
+	flags.String("listen", ":8080", "监听地址 (环境变量 LISTEN_ADDR，或 PORT 只指定端口)")
+	flags.String("mode", "debug", "运行模式 debug/release/test (环境变量 GIN_MODE)")
+	flags.String("static-dir", "./web/dist", "前端静态文件目录 (环境变量 STATIC_DIR)")
+	flags.String("tls-cert", "", "HTTPS 证书文件 (环境变量 TLS_CERT_FILE)")
+	flags.String("tls-key", "", "HTTPS 私钥文件 (环境变量 TLS_KEY_FILE)")
+	flags.SetOutput(w)
+	flags.PrintDefaults()
+
+	names := make([]string, 0, len(envVars))
+	for _, env := range envVars {
+		names = append(names, env.name)
+	}
+	fmt.Fprintf(w, "\n支持的环境变量:\n  %s\n", strings.Join(names, ", "))
+}
+
+func newFlagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet("crontab_go", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	return flags
+}
+
+// loadFile 从配置文件加载配置，文件中未出现的配置项保持原值
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s，请使用 .yaml、.yml 或 .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+func setBool(target *bool, value string) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
+
+// splitList 解析逗号分隔的列表，忽略空白项
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func setInt(target *int, value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*target = parsed
+	return nil
+}
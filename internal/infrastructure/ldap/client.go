@@ -0,0 +1,201 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+
+	ldapv3 "github.com/go-ldap/ldap/v3"
+)
+
+// accountDisable Active Directory userAccountControl 中表示账号已禁用的标志位
+const accountDisable = 0x2
+
+// Options LDAP 连接和搜索配置
+type Options struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	BindDN             string
+	BindPassword       string
+	BaseDN             string
+	UserFilter         string // %s 替换为转义后的用户名
+	UsernameAttribute  string
+	EmailAttribute     string
+	GroupAttribute     string
+	GroupBaseDN        string
+	GroupFilter        string // %s 替换为转义后的用户 DN
+	Timeout            time.Duration
+}
+
+// Client LDAP / Active Directory 客户端，每次请求建立新连接，使用服务账号搜索用户
+type Client struct {
+	opts Options
+}
+
+func NewClient(opts Options) *Client {
+	if opts.GroupBaseDN == "" {
+		opts.GroupBaseDN = opts.BaseDN
+	}
+	return &Client{opts: opts}
+}
+
+// Authenticate 使用用户的 DN 和密码绑定验证，返回目录中的用户。用户不存在或密码错误时返回 nil
+func (c *Client) Authenticate(username, password string) (*entity.DirectoryEntry, error) {
+	// 空密码的简单绑定会被服务器当作匿名绑定而成功，必须拒绝
+	if username == "" || password == "" {
+		return nil, nil
+	}
+
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := c.findUser(conn, username)
+	if entry == nil || err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldapv3.IsErrorWithCode(err, ldapv3.LDAPResultInvalidCredentials) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("LDAP 绑定 %s 失败: %w", entry.DN, err)
+	}
+	return entry, nil
+}
+
+// Lookup 使用服务账号查找用户，用户不存在时返回 nil
+func (c *Client) Lookup(username string) (*entity.DirectoryEntry, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return c.findUser(conn, username)
+}
+
+// connect 连接服务器并用服务账号绑定
+func (c *Client) connect() (*ldapv3.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.opts.InsecureSkipVerify}
+	conn, err := ldapv3.DialURL(c.opts.URL,
+		ldapv3.DialWithDialer(&net.Dialer{Timeout: c.opts.Timeout}),
+		ldapv3.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("连接 LDAP 服务器失败: %w", err)
+	}
+	conn.SetTimeout(c.opts.Timeout)
+
+	if c.opts.StartTLS {
+		// tls.Client 不会从连接地址推断服务器名称，需要手动设置用于校验证书
+		if parsed, err := url.Parse(c.opts.URL); err == nil {
+			tlsConfig.ServerName = parsed.Hostname()
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("LDAP StartTLS 失败: %w", err)
+		}
+	}
+
+	if c.opts.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(c.opts.BindDN, c.opts.BindPassword)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("LDAP 服务账号绑定失败: %w", err)
+	}
+	return conn, nil
+}
+
+// findUser 按 user_filter 搜索用户，找到多个条目时视为配置错误
+func (c *Client) findUser(conn *ldapv3.Conn, username string) (*entity.DirectoryEntry, error) {
+	attributes := []string{c.opts.UsernameAttribute, "userAccountControl"}
+	if c.opts.EmailAttribute != "" {
+		attributes = append(attributes, c.opts.EmailAttribute)
+	}
+	if c.opts.GroupFilter == "" && c.opts.GroupAttribute != "" {
+		attributes = append(attributes, c.opts.GroupAttribute)
+	}
+
+	result, err := conn.Search(ldapv3.NewSearchRequest(
+		c.opts.BaseDN, ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, int(c.opts.Timeout/time.Second), false,
+		strings.ReplaceAll(c.opts.UserFilter, "%s", ldapv3.EscapeFilter(username)),
+		attributes, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("LDAP 搜索用户失败: %w", err)
+	}
+	switch len(result.Entries) {
+	case 0:
+		return nil, nil
+	case 1:
+	default:
+		return nil, fmt.Errorf("LDAP 中有 %d 个用户匹配 %s，请检查 user_filter", len(result.Entries), username)
+	}
+
+	found := result.Entries[0]
+	entry := &entity.DirectoryEntry{
+		DN:       found.DN,
+		Username: found.GetAttributeValue(c.opts.UsernameAttribute),
+		Email:    found.GetAttributeValue(c.opts.EmailAttribute),
+	}
+	if entry.Username == "" {
+		return nil, fmt.Errorf("LDAP 用户 %s 没有属性 %s", found.DN, c.opts.UsernameAttribute)
+	}
+	if flags, err := strconv.ParseInt(found.GetAttributeValue("userAccountControl"), 10, 64); err == nil {
+		entry.Disabled = flags&accountDisable != 0
+	}
+
+	if c.opts.GroupFilter != "" {
+		if entry.Groups, err = c.searchGroups(conn, found.DN); err != nil {
+			return nil, err
+		}
+	} else if c.opts.GroupAttribute != "" {
+		for _, dn := range found.GetAttributeValues(c.opts.GroupAttribute) {
+			entry.Groups = append(entry.Groups, groupName(dn))
+		}
+	}
+	return entry, nil
+}
+
+// searchGroups 按 group_filter 搜索用户所属的组，返回组的 cn
+func (c *Client) searchGroups(conn *ldapv3.Conn, userDN string) ([]string, error) {
+	result, err := conn.Search(ldapv3.NewSearchRequest(
+		c.opts.GroupBaseDN, ldapv3.ScopeWholeSubtree, ldapv3.NeverDerefAliases, 0, int(c.opts.Timeout/time.Second), false,
+		strings.ReplaceAll(c.opts.GroupFilter, "%s", ldapv3.EscapeFilter(userDN)),
+		[]string{"cn"}, nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("LDAP 搜索用户组失败: %w", err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, group := range result.Entries {
+		name := group.GetAttributeValue("cn")
+		if name == "" {
+			name = groupName(group.DN)
+		}
+		groups = append(groups, name)
+	}
+	return groups, nil
+}
+
+// groupName 返回组 DN 第一个 RDN 的值，如 CN=cron-admins,OU=Groups,DC=example,DC=com 返回 cron-admins。
+// 不是合法的 DN 时原样返回
+func groupName(dn string) string {
+	parsed, err := ldapv3.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"crontab_go/internal/domain/entity"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "crontab"
+
+// 任务执行状态标签值
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+var (
+	taskExecutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "task_executions_total",
+		Help:      "Total number of task executions.",
+	}, []string{"task_id", "task", "status", "trigger"})
+
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_execution_duration_seconds",
+		Help:      "Task execution duration in seconds.",
+		Buckets:   []float64{0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 1800, 3600},
+	}, []string{"task_id", "task", "status", "trigger"})
+
+	taskQueueWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_queue_wait_seconds",
+		Help:      "Delay between the scheduled time and the actual start of a task run.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60},
+	}, []string{"task_id", "task"})
+
+	notificationsSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_sent_total",
+		Help:      "Total number of notifications sent, by channel and result.",
+	}, []string{"channel", "result"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP API request latency in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(taskExecutions, taskDuration, taskQueueWait, notificationsSent, httpRequestDuration)
+}
+
+// Observer 将任务执行和通知发送记录为 Prometheus 指标，在启动时通过 service.SetObserver 注入
+type Observer struct{}
+
+// ObserveTaskExecution 记录一次任务执行
+func (Observer) ObserveTaskExecution(taskLog *entity.TaskLog) {
+	status := StatusSuccess
+	if !taskLog.Success {
+		status = StatusFailure
+	}
+	labels := prometheus.Labels{
+		"task_id": strconv.Itoa(taskLog.TaskID),
+		"task":    taskLog.TaskName,
+		"status":  status,
+		"trigger": taskLog.TriggerSource,
+	}
+
+	taskExecutions.With(labels).Inc()
+	taskDuration.With(labels).Observe(taskLog.EndTime.Sub(taskLog.StartTime).Seconds())
+}
+
+// ObserveQueueWait 记录任务从计划时间到实际开始执行的等待时间
+func (Observer) ObserveQueueWait(task *entity.Task, wait time.Duration) {
+	if wait < 0 {
+		wait = 0
+	}
+	taskQueueWait.WithLabelValues(strconv.Itoa(task.ID), task.Name).Observe(wait.Seconds())
+}
+
+// ObserveNotification 记录一次通知发送结果
+func (Observer) ObserveNotification(channel string, err error) {
+	result := StatusSuccess
+	if err != nil {
+		result = StatusFailure
+	}
+	notificationsSent.WithLabelValues(channel, result).Inc()
+}
+
+// ObserveHTTPRequest 记录一次 HTTP 请求的耗时，route 为路由模板而非实际路径，以控制标签数量
+func ObserveHTTPRequest(method, route string, status int, duration time.Duration) {
+	httpRequestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// RegisterSchedulerEntries 注册调度器中的任务数量指标
+func RegisterSchedulerEntries(count func() int) {
+	prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_entries",
+		Help:      "Number of entries registered in the cron scheduler.",
+	}, func() float64 {
+		return float64(count())
+	}))
+}
+
+// RegisterSystemStats 注册主机监控指标，抓取时读取最近一次采集的系统统计信息
+func RegisterSystemStats(latest func() (*entity.SystemStats, error)) {
+	prometheus.MustRegister(newSystemStatsCollector(latest))
+}
+
+// Handler 返回指标抓取处理器
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
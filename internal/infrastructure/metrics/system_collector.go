@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"crontab_go/internal/domain/entity"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// systemStatsMetric 主机监控指标及其取值方法
+type systemStatsMetric struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	value     func(stats *entity.SystemStats) float64
+}
+
+// systemStatsCollector 将 system.Service 采集的主机统计信息导出为指标
+type systemStatsCollector struct {
+	latest  func() (*entity.SystemStats, error)
+	metrics []systemStatsMetric
+}
+
+func newSystemStatsCollector(latest func() (*entity.SystemStats, error)) *systemStatsCollector {
+	gauge := func(name, help string, value func(stats *entity.SystemStats) float64) systemStatsMetric {
+		return systemStatsMetric{
+			desc:      prometheus.NewDesc(prometheus.BuildFQName(namespace, "host", name), help, nil, nil),
+			valueType: prometheus.GaugeValue,
+			value:     value,
+		}
+	}
+	counter := func(name, help string, value func(stats *entity.SystemStats) float64) systemStatsMetric {
+		metric := gauge(name, help, value)
+		metric.valueType = prometheus.CounterValue
+		return metric
+	}
+
+	const mb = 1024 * 1024
+	const gb = 1024 * 1024 * 1024
+
+	return &systemStatsCollector{
+		latest: latest,
+		metrics: []systemStatsMetric{
+			gauge("cpu_usage_percent", "Host CPU usage in percent.",
+				func(s *entity.SystemStats) float64 { return s.CPUUsage }),
+			gauge("memory_usage_percent", "Host memory usage in percent.",
+				func(s *entity.SystemStats) float64 { return s.MemoryUsage }),
+			gauge("memory_total_bytes", "Host total memory in bytes.",
+				func(s *entity.SystemStats) float64 { return float64(s.MemoryTotal) * mb }),
+			gauge("memory_used_bytes", "Host used memory in bytes.",
+				func(s *entity.SystemStats) float64 { return float64(s.MemoryUsed) * mb }),
+			gauge("memory_free_bytes", "Host free memory in bytes.",
+				func(s *entity.SystemStats) float64 { return float64(s.MemoryFree) * mb }),
+			gauge("disk_usage_percent", "Host disk usage in percent.",
+				func(s *entity.SystemStats) float64 { return s.DiskUsage }),
+			gauge("disk_total_bytes", "Host total disk space in bytes.",
+				func(s *entity.SystemStats) float64 { return float64(s.DiskTotal) * gb }),
+			gauge("disk_used_bytes", "Host used disk space in bytes.",
+				func(s *entity.SystemStats) float64 { return float64(s.DiskUsed) * gb }),
+			gauge("disk_free_bytes", "Host free disk space in bytes.",
+				func(s *entity.SystemStats) float64 { return float64(s.DiskFree) * gb }),
+			gauge("load1", "Host 1-minute load average.",
+				func(s *entity.SystemStats) float64 { return s.SystemLoad }),
+			counter("network_receive_bytes_total", "Host network bytes received.",
+				func(s *entity.SystemStats) float64 { return float64(s.NetworkRxBytes) }),
+			counter("network_transmit_bytes_total", "Host network bytes transmitted.",
+				func(s *entity.SystemStats) float64 { return float64(s.NetworkTxBytes) }),
+			gauge("processes", "Number of host processes.",
+				func(s *entity.SystemStats) float64 { return float64(s.ProcessCount) }),
+			gauge("uptime_seconds", "Host uptime in seconds.",
+				func(s *entity.SystemStats) float64 { return float64(s.Uptime) }),
+			gauge("stats_timestamp_seconds", "Unix time when the host stats were collected.",
+				func(s *entity.SystemStats) float64 { return float64(s.Timestamp.UnixNano()) / 1e9 }),
+		},
+	}
+}
+
+func (c *systemStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, metric := range c.metrics {
+		ch <- metric.desc
+	}
+}
+
+func (c *systemStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats, err := c.latest()
+	if err != nil || stats == nil {
+		// 尚未采集到数据时不导出主机指标
+		return
+	}
+
+	for _, metric := range c.metrics {
+		ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, metric.value(stats))
+	}
+}
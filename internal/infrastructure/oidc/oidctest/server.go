@@ -0,0 +1,313 @@
+// Package oidctest 提供用于测试的 OpenID Connect 身份提供方：发现文档、JWKS、授权端点、
+// 令牌端点（校验 PKCE S256）和 userinfo 端点
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// ClientID 测试客户端 ID
+	ClientID = "crontab-test"
+	// ClientSecret 测试客户端密钥
+	ClientSecret = "crontab-secret"
+	// RedirectURL 测试回调地址
+	RedirectURL = "https://cron.example.com/api/v1/auth/oidc/callback"
+)
+
+// authRequest 授权端点收到的请求，在令牌端点兑换授权码时使用
+type authRequest struct {
+	redirectURI   string
+	challenge     string
+	method        string
+	nonce         string
+	subject       string
+	claims        map[string]interface{}
+	userInfo      map[string]interface{}
+	tokenModifier func(jwt.MapClaims)
+}
+
+// Server 测试用身份提供方
+type Server struct {
+	*httptest.Server
+	Issuer string
+
+	// Subject 下一次授权签发的 sub
+	Subject string
+	// Claims 下一次授权签发的 ID Token 中附加的声明，如 email、groups
+	Claims map[string]interface{}
+	// UserInfo userinfo 端点返回的声明，为空时 metadata 中不包含 userinfo 端点
+	UserInfo map[string]interface{}
+	// TokenModifier 签名前修改 ID Token 的声明，用于构造错误的 iss、aud 等
+	TokenModifier func(jwt.MapClaims)
+
+	mu          sync.Mutex
+	keys        map[string]*rsa.PrivateKey
+	signingKid  string
+	keyVersion  int
+	codes       map[string]*authRequest
+	accessInfo  map[string]map[string]interface{}
+	jwksFetches int
+}
+
+// NewServer 启动测试用身份提供方，issuerSuffix 附加在服务地址后作为 issuer，如 "/" 模拟以 / 结尾的 issuer
+func NewServer(t *testing.T, issuerSuffix string) *Server {
+	t.Helper()
+
+	s := &Server{
+		Subject:    "user-1",
+		keys:       make(map[string]*rsa.PrivateKey),
+		codes:      make(map[string]*authRequest),
+		accessInfo: make(map[string]map[string]interface{}),
+	}
+	s.RotateKey(t, false)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("/jwks", s.handleJWKS)
+	mux.HandleFunc("/token", s.handleToken)
+	mux.HandleFunc("/userinfo", s.handleUserInfo)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	s.Issuer = s.URL + issuerSuffix
+	return s
+}
+
+// RotateKey 生成新的签名密钥并用于之后签发的 ID Token，keepOld 为 false 时旧公钥从 JWKS 中移除。返回新的 kid
+func (s *Server) RotateKey(t *testing.T, keepOld bool) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !keepOld {
+		s.keys = make(map[string]*rsa.PrivateKey)
+	}
+	s.keyVersion++
+	s.signingKid = fmt.Sprintf("key-%d", s.keyVersion)
+	s.keys[s.signingKid] = key
+	return s.signingKid
+}
+
+// JWKSFetches 返回 JWKS 端点被请求的次数
+func (s *Server) JWKSFetches() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksFetches
+}
+
+// Authorize 模拟用户在身份提供方登录后跳转回客户端：校验授权地址的参数，返回授权码和 state
+func (s *Server) Authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse authorization URL: %v", err)
+	}
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != s.URL+"/authorize" {
+		t.Fatalf("authorization endpoint = %s, want %s/authorize", got, s.URL)
+	}
+	query := parsed.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             ClientID,
+		"redirect_uri":          RedirectURL,
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(name); got != want {
+			t.Fatalf("authorization %s = %q, want %q", name, got, want)
+		}
+	}
+	if !strings.Contains(" "+query.Get("scope")+" ", " openid ") {
+		t.Fatalf("authorization scope %q does not include openid", query.Get("scope"))
+	}
+	for _, name := range []string{"state", "nonce", "code_challenge"} {
+		if query.Get(name) == "" {
+			t.Fatalf("authorization request has no %s", name)
+		}
+	}
+
+	code = randomString(t)
+	s.mu.Lock()
+	s.codes[code] = &authRequest{
+		redirectURI:   query.Get("redirect_uri"),
+		challenge:     query.Get("code_challenge"),
+		method:        query.Get("code_challenge_method"),
+		nonce:         query.Get("nonce"),
+		subject:       s.Subject,
+		claims:        s.Claims,
+		userInfo:      s.UserInfo,
+		tokenModifier: s.TokenModifier,
+	}
+	s.mu.Unlock()
+	return code, query.Get("state")
+}
+
+// SignIDToken 使用当前签名密钥签发 ID Token
+func (s *Server) SignIDToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+
+	s.mu.Lock()
+	kid, key := s.signingKid, s.keys[s.signingKid]
+	s.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign ID token: %v", err)
+	}
+	return signed
+}
+
+// StandardClaims 返回 sub、iss、aud、exp、iat 和 nonce 均有效的 ID Token 声明
+func (s *Server) StandardClaims(subject, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":   s.Issuer,
+		"sub":   subject,
+		"aud":   ClientID,
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+	}
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	metadata := map[string]string{
+		"issuer":                 s.Issuer,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	}
+	s.mu.Lock()
+	if s.UserInfo != nil {
+		metadata["userinfo_endpoint"] = s.URL + "/userinfo"
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+func (s *Server) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwksFetches++
+
+	keys := make([]map[string]string, 0, len(s.keys))
+	for kid, key := range s.keys {
+		keys = append(keys, map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+}
+
+// handleToken 兑换授权码，校验客户端认证、redirect_uri 和 PKCE code_verifier
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != ClientID || clientSecret != ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	request, found := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+	if r.PostForm.Get("grant_type") != "authorization_code" || !found || r.PostForm.Get("redirect_uri") != request.redirectURI {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if request.method != "S256" || base64.RawURLEncoding.EncodeToString(challenge[:]) != request.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	claims := s.StandardClaims(request.subject, request.nonce)
+	for name, value := range request.claims {
+		claims[name] = value
+	}
+	if request.tokenModifier != nil {
+		request.tokenModifier(claims)
+	}
+
+	accessToken := fmt.Sprintf("access-%s", r.PostForm.Get("code"))
+	s.mu.Lock()
+	s.accessInfo[accessToken] = request.userInfo
+	kid, key := s.signingKid, s.keys[s.signingKid]
+	s.mu.Unlock()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	idToken, err := token.SignedString(key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	info, ok := s.accessInfo[accessToken]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func randomString(t *testing.T) string {
+	t.Helper()
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		t.Fatalf("random: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
@@ -0,0 +1,232 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"crontab_go/internal/infrastructure/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestProvider 创建连接到测试身份提供方的客户端
+func newTestProvider(idp *oidctest.Server, issuer string) *Provider {
+	return NewProvider(issuer, oidctest.ClientID, oidctest.ClientSecret, oidctest.RedirectURL, []string{"profile", "email"})
+}
+
+func TestProviderAuthCodeURLUsesPKCES256(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	provider := newTestProvider(idp, idp.Issuer)
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	query := parsed.Query()
+
+	challenge := sha256.Sum256([]byte("verifier-1"))
+	if got, want := query.Get("code_challenge"), base64.RawURLEncoding.EncodeToString(challenge[:]); got != want {
+		t.Errorf("code_challenge = %q, want %q", got, want)
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", query.Get("code_challenge_method"))
+	}
+	if query.Get("state") != "state-1" || query.Get("nonce") != "nonce-1" {
+		t.Errorf("state = %q, nonce = %q", query.Get("state"), query.Get("nonce"))
+	}
+	// openid 自动加入 scope
+	if query.Get("scope") != "openid profile email" {
+		t.Errorf("scope = %q, want openid profile email", query.Get("scope"))
+	}
+	if query.Get("code_verifier") != "" {
+		t.Error("authorization URL leaks the code verifier")
+	}
+}
+
+func TestProviderExchangeVerifiesPKCE(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	provider := newTestProvider(idp, idp.Issuer)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	code, _ := idp.Authorize(t, authURL)
+	if _, _, err := provider.Exchange(ctx, code, "another-verifier"); err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Fatalf("Exchange with wrong verifier error = %v, want HTTP 400", err)
+	}
+
+	code, _ = idp.Authorize(t, authURL)
+	accessToken, idToken, err := provider.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if accessToken == "" || idToken == "" {
+		t.Fatalf("access token = %q, id token = %q", accessToken, idToken)
+	}
+	claims, err := provider.VerifyIDToken(ctx, idToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if claims["sub"] != "user-1" {
+		t.Errorf("sub = %v, want user-1", claims["sub"])
+	}
+}
+
+func TestProviderVerifyIDToken(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	provider := newTestProvider(idp, idp.Issuer)
+
+	tests := []struct {
+		name    string
+		modify  func(jwt.MapClaims)
+		nonce   string
+		wantErr string
+	}{
+		{"valid", func(jwt.MapClaims) {}, "nonce-1", ""},
+		{"audience list", func(c jwt.MapClaims) { c["aud"] = []string{"other", oidctest.ClientID} }, "nonce-1", ""},
+		{"nonce mismatch", func(jwt.MapClaims) {}, "nonce-2", "nonce 不匹配"},
+		{"missing nonce", func(c jwt.MapClaims) { delete(c, "nonce") }, "nonce-1", "nonce 不匹配"},
+		{"wrong audience", func(c jwt.MapClaims) { c["aud"] = "someone-else" }, "nonce-1", "audience"},
+		{"wrong issuer", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, "nonce-1", "issuer"},
+		{"issuer with trailing slash", func(c jwt.MapClaims) { c["iss"] = idp.Issuer + "/" }, "nonce-1", "issuer"},
+		{"expired", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, "nonce-1", "expired"},
+		{"missing exp", func(c jwt.MapClaims) { delete(c, "exp") }, "nonce-1", "exp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.StandardClaims("user-1", "nonce-1")
+			tt.modify(claims)
+
+			_, err := provider.VerifyIDToken(context.Background(), idp.SignIDToken(t, claims), tt.nonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("VerifyIDToken: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("VerifyIDToken error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProviderVerifyIDTokenRejectsHMAC(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	provider := newTestProvider(idp, idp.Issuer)
+
+	// 用客户端密钥签名的 HS256 令牌不能通过校验
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, idp.StandardClaims("user-1", "nonce-1"))
+	signed, err := token.SignedString([]byte(oidctest.ClientSecret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(context.Background(), signed, "nonce-1"); err == nil {
+		t.Fatal("VerifyIDToken accepted an HS256 token")
+	}
+}
+
+func TestProviderIssuerMatchesExactly(t *testing.T) {
+	// 与 Auth0 一样以 / 结尾的 issuer
+	idp := oidctest.NewServer(t, "/")
+	ctx := context.Background()
+
+	provider := newTestProvider(idp, idp.Issuer)
+	claims := idp.StandardClaims("user-1", "nonce-1")
+	if _, err := provider.VerifyIDToken(ctx, idp.SignIDToken(t, claims), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken with trailing slash issuer: %v", err)
+	}
+
+	// 配置的 issuer 与发现文档不完全一致时拒绝
+	mismatched := newTestProvider(idp, strings.TrimSuffix(idp.Issuer, "/"))
+	if _, err := mismatched.AuthCodeURL(ctx, "state", "nonce", "verifier"); err == nil || !strings.Contains(err.Error(), "不一致") {
+		t.Fatalf("AuthCodeURL error = %v, want issuer mismatch", err)
+	}
+}
+
+func TestProviderKeyRotation(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	provider := newTestProvider(idp, idp.Issuer)
+	ctx := context.Background()
+
+	claims := idp.StandardClaims("user-1", "nonce-1")
+	if _, err := provider.VerifyIDToken(ctx, idp.SignIDToken(t, claims), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+	if fetches := idp.JWKSFetches(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", fetches)
+	}
+
+	newKid := idp.RotateKey(t, false)
+	rotated := idp.SignIDToken(t, claims)
+
+	// 刚获取过公钥时不为未知的 kid 重新请求，避免伪造的令牌频繁触发请求
+	if _, err := provider.VerifyIDToken(ctx, rotated, "nonce-1"); err == nil || !strings.Contains(err.Error(), newKid) {
+		t.Fatalf("VerifyIDToken error = %v, want unknown key %s", err, newKid)
+	}
+	if fetches := idp.JWKSFetches(); fetches != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want 1", fetches)
+	}
+
+	provider.mu.Lock()
+	provider.keysFetchedAt = time.Now().Add(-2 * keyRefreshInterval)
+	provider.mu.Unlock()
+	if _, err := provider.VerifyIDToken(ctx, rotated, "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken after rotation: %v", err)
+	}
+	if fetches := idp.JWKSFetches(); fetches != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", fetches)
+	}
+
+	// 旧密钥已从 JWKS 中移除，重新获取后之前签发的令牌不再有效
+	idp.RotateKey(t, false)
+	provider.mu.Lock()
+	provider.keysFetchedAt = time.Now().Add(-2 * keyRefreshInterval)
+	provider.mu.Unlock()
+	if _, err := provider.VerifyIDToken(ctx, idp.SignIDToken(t, claims), "nonce-1"); err != nil {
+		t.Fatalf("VerifyIDToken after second rotation: %v", err)
+	}
+	if _, err := provider.VerifyIDToken(ctx, rotated, "nonce-1"); err == nil {
+		t.Fatal("VerifyIDToken accepted a token signed by a removed key")
+	}
+}
+
+func TestProviderUserInfo(t *testing.T) {
+	idp := oidctest.NewServer(t, "")
+	idp.UserInfo = map[string]interface{}{"sub": "user-1", "groups": []string{"ops"}}
+	provider := newTestProvider(idp, idp.Issuer)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _ := idp.Authorize(t, authURL)
+	accessToken, _, err := provider.Exchange(ctx, code, "verifier")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	info, err := provider.UserInfo(ctx, accessToken)
+	if err != nil {
+		t.Fatalf("UserInfo: %v", err)
+	}
+	if info["sub"] != "user-1" {
+		t.Errorf("userinfo sub = %v", info["sub"])
+	}
+	if _, err := provider.UserInfo(ctx, "forged"); err == nil {
+		t.Error("UserInfo accepted an unknown access token")
+	}
+}
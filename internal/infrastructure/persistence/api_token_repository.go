@@ -0,0 +1,69 @@
+package persistence
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteAPITokenRepository struct {
+	DB *gorm.DB
+}
+
+func NewAPITokenRepository(db *gorm.DB) repository.APITokenRepository {
+	return &SQLiteAPITokenRepository{DB: db}
+}
+
+func (r *SQLiteAPITokenRepository) Create(token *entity.APIToken) error {
+	return r.DB.Create(token).Error
+}
+
+func (r *SQLiteAPITokenRepository) FindByID(id uint) (*entity.APIToken, error) {
+	var token entity.APIToken
+	if err := r.DB.First(&token, id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *SQLiteAPITokenRepository) FindByHash(hash string) (*entity.APIToken, error) {
+	var token entity.APIToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *SQLiteAPITokenRepository) FindByUser(userID uint) ([]*entity.APIToken, error) {
+	var tokens []*entity.APIToken
+	if err := r.DB.Where("user_id = ?", userID).Order("id DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *SQLiteAPITokenRepository) FindAll() ([]*entity.APIToken, error) {
+	var tokens []*entity.APIToken
+	if err := r.DB.Order("id DESC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (r *SQLiteAPITokenRepository) Revoke(id uint, at time.Time) error {
+	return r.DB.Model(&entity.APIToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+func (r *SQLiteAPITokenRepository) Touch(id uint, at time.Time, ip string) error {
+	return r.DB.Model(&entity.APIToken{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"last_used_at": at, "last_used_ip": ip}).Error
+}
+
+func (r *SQLiteAPITokenRepository) DeleteByUser(userID uint) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&entity.APIToken{}).Error
+}
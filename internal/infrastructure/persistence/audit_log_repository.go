@@ -0,0 +1,65 @@
+package persistence
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteAuditLogRepository struct {
+	DB *gorm.DB
+}
+
+func NewAuditLogRepository(db *gorm.DB) repository.AuditLogRepository {
+	return &SQLiteAuditLogRepository{DB: db}
+}
+
+func (r *SQLiteAuditLogRepository) Create(log *entity.AuditLog) error {
+	return r.DB.Create(log).Error
+}
+
+func (r *SQLiteAuditLogRepository) Find(filter *entity.AuditLogFilter, offset, limit int) ([]*entity.AuditLog, int64, error) {
+	query := r.DB.Model(&entity.AuditLog{})
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	// created_at 以本地时区的文本保存并按字符串比较，查询时间需要转换为本地时区
+	if filter.StartTime != nil {
+		query = query.Where("created_at >= ?", filter.StartTime.In(time.Local))
+	}
+	if filter.EndTime != nil {
+		query = query.Where("created_at <= ?", filter.EndTime.In(time.Local))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []*entity.AuditLog
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(limit).Find(&logs).Error; err != nil {
+		return nil, 0, err
+	}
+	return logs, total, nil
+}
+
+func (r *SQLiteAuditLogRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.DB.Where("created_at < ?", before).Delete(&entity.AuditLog{})
+	return result.RowsAffected, result.Error
+}
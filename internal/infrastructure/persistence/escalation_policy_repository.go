@@ -0,0 +1,49 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteEscalationPolicyRepository struct {
+	DB *gorm.DB
+}
+
+func NewEscalationPolicyRepository(db *gorm.DB) repository.EscalationPolicyRepository {
+	return &SQLiteEscalationPolicyRepository{DB: db}
+}
+
+func (r *SQLiteEscalationPolicyRepository) Create(policy *entity.EscalationPolicy) error {
+	return r.DB.Create(policy).Error
+}
+
+func (r *SQLiteEscalationPolicyRepository) Update(policy *entity.EscalationPolicy) error {
+	return r.DB.Save(policy).Error
+}
+
+func (r *SQLiteEscalationPolicyRepository) Delete(id int) error {
+	return r.DB.Delete(&entity.EscalationPolicy{}, id).Error
+}
+
+func (r *SQLiteEscalationPolicyRepository) FindByID(id int) (*entity.EscalationPolicy, error) {
+	var policy entity.EscalationPolicy
+	if err := r.DB.First(&policy, id).Error; err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *SQLiteEscalationPolicyRepository) FindAll() ([]*entity.EscalationPolicy, error) {
+	var policies []*entity.EscalationPolicy
+	if err := r.DB.Order("id").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *SQLiteEscalationPolicyRepository) CountReferences(id int) (int64, error) {
+	var count int64
+	err := r.DB.Model(&entity.Task{}).Where("escalation_policy_id = ?", id).Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,51 @@
+package persistence
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteInviteCodeRepository struct {
+	DB *gorm.DB
+}
+
+func NewInviteCodeRepository(db *gorm.DB) repository.InviteCodeRepository {
+	return &SQLiteInviteCodeRepository{DB: db}
+}
+
+func (r *SQLiteInviteCodeRepository) Create(code *entity.InviteCode) error {
+	return r.DB.Create(code).Error
+}
+
+func (r *SQLiteInviteCodeRepository) Delete(id uint) error {
+	return r.DB.Delete(&entity.InviteCode{}, id).Error
+}
+
+func (r *SQLiteInviteCodeRepository) FindByCode(code string) (*entity.InviteCode, error) {
+	var invite entity.InviteCode
+	if err := r.DB.Where("code = ?", code).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *SQLiteInviteCodeRepository) FindAll() ([]*entity.InviteCode, error) {
+	var invites []*entity.InviteCode
+	if err := r.DB.Order("id DESC").Find(&invites).Error; err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (r *SQLiteInviteCodeRepository) MarkUsed(id, userID uint) (bool, error) {
+	result := r.DB.Model(&entity.InviteCode{}).
+		Where("id = ? AND used_by IS NULL", id).
+		Updates(map[string]interface{}{"used_by": userID, "used_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
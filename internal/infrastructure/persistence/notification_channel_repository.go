@@ -0,0 +1,87 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteNotificationChannelRepository struct {
+	DB *gorm.DB
+}
+
+func NewNotificationChannelRepository(db *gorm.DB) repository.NotificationChannelRepository {
+	return &SQLiteNotificationChannelRepository{DB: db}
+}
+
+func (r *SQLiteNotificationChannelRepository) Create(channel *entity.NotificationChannel) error {
+	return r.DB.Create(channel).Error
+}
+
+func (r *SQLiteNotificationChannelRepository) Update(channel *entity.NotificationChannel) error {
+	return r.DB.Save(channel).Error
+}
+
+func (r *SQLiteNotificationChannelRepository) Delete(id int) error {
+	return r.DB.Delete(&entity.NotificationChannel{}, id).Error
+}
+
+func (r *SQLiteNotificationChannelRepository) FindByID(id int) (*entity.NotificationChannel, error) {
+	var channel entity.NotificationChannel
+	if err := r.DB.First(&channel, id).Error; err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r *SQLiteNotificationChannelRepository) FindByIDs(ids []int) ([]*entity.NotificationChannel, error) {
+	var channels []*entity.NotificationChannel
+	if len(ids) == 0 {
+		return channels, nil
+	}
+	if err := r.DB.Where("id IN ?", ids).Order("id").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (r *SQLiteNotificationChannelRepository) FindByName(name string) (*entity.NotificationChannel, error) {
+	var channel entity.NotificationChannel
+	if err := r.DB.Where("name = ?", name).First(&channel).Error; err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+func (r *SQLiteNotificationChannelRepository) FindAll() ([]*entity.NotificationChannel, error) {
+	var channels []*entity.NotificationChannel
+	if err := r.DB.Order("id").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+func (r *SQLiteNotificationChannelRepository) CountReferences(id int) (int64, error) {
+	var total int64
+	for _, table := range []string{"tasks", "task_templates", "report_digests"} {
+		var count int64
+		// notification_channel_ids 以 JSON 数组存储，使用 json_each 展开后匹配
+		err := r.DB.Table(table).
+			Where("notification_channel_ids <> '' AND EXISTS (SELECT 1 FROM json_each("+table+".notification_channel_ids) WHERE json_each.value = ?)", id).
+			Count(&count).Error
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+
+	// 升级策略的每个步骤包含一组渠道ID
+	var count int64
+	err := r.DB.Table("escalation_policies").
+		Where("steps <> '' AND EXISTS (SELECT 1 FROM json_each(escalation_policies.steps) AS step, json_each(step.value, '$.channel_ids') AS channel WHERE channel.value = ?)", id).
+		Count(&count).Error
+	if err != nil {
+		return 0, err
+	}
+	return total + count, nil
+}
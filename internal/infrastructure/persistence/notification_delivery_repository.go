@@ -0,0 +1,91 @@
+package persistence
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteNotificationDeliveryRepository struct {
+	DB *gorm.DB
+}
+
+func NewNotificationDeliveryRepository(db *gorm.DB) repository.NotificationDeliveryRepository {
+	return &SQLiteNotificationDeliveryRepository{DB: db}
+}
+
+func (r *SQLiteNotificationDeliveryRepository) Create(delivery *entity.NotificationDelivery) error {
+	return r.DB.Create(delivery).Error
+}
+
+func (r *SQLiteNotificationDeliveryRepository) Update(delivery *entity.NotificationDelivery) error {
+	return r.DB.Save(delivery).Error
+}
+
+func (r *SQLiteNotificationDeliveryRepository) FindByID(id uint) (*entity.NotificationDelivery, error) {
+	var delivery entity.NotificationDelivery
+	if err := r.DB.First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *SQLiteNotificationDeliveryRepository) FindByTaskLogID(taskLogID uint) ([]*entity.NotificationDelivery, error) {
+	var deliveries []*entity.NotificationDelivery
+	if err := r.DB.Where("task_log_id = ?", taskLogID).Order("id").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *SQLiteNotificationDeliveryRepository) FindByTaskID(taskID int, limit int) ([]*entity.NotificationDelivery, error) {
+	var deliveries []*entity.NotificationDelivery
+	query := r.DB.Where("task_id = ?", taskID).Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *SQLiteNotificationDeliveryRepository) ClaimDue(now time.Time, limit int) ([]*entity.NotificationDelivery, error) {
+	var deliveries []*entity.NotificationDelivery
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ? AND next_attempt_at <= ?", entity.DeliveryPending, now).
+			Order("next_attempt_at, id").Limit(limit).Find(&deliveries).Error; err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, 0, len(deliveries))
+		for _, delivery := range deliveries {
+			ids = append(ids, delivery.ID)
+			delivery.Status = entity.DeliverySending
+		}
+		return tx.Model(&entity.NotificationDelivery{}).
+			Where("id IN ? AND status = ?", ids, entity.DeliveryPending).
+			Update("status", entity.DeliverySending).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *SQLiteNotificationDeliveryRepository) ResetSending() error {
+	return r.DB.Model(&entity.NotificationDelivery{}).
+		Where("status = ?", entity.DeliverySending).
+		Update("status", entity.DeliveryPending).Error
+}
+
+func (r *SQLiteNotificationDeliveryRepository) DeleteFinishedBefore(before time.Time) (int64, error) {
+	result := r.DB.Where("created_at < ? AND status IN ?", before, []string{entity.DeliverySuccess, entity.DeliveryFailed}).
+		Delete(&entity.NotificationDelivery{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,91 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SQLiteProjectRepository struct {
+	DB *gorm.DB
+}
+
+func NewProjectRepository(db *gorm.DB) repository.ProjectRepository {
+	return &SQLiteProjectRepository{DB: db}
+}
+
+func (r *SQLiteProjectRepository) Create(project *entity.Project) error {
+	return r.DB.Create(project).Error
+}
+
+func (r *SQLiteProjectRepository) Update(project *entity.Project) error {
+	return r.DB.Save(project).Error
+}
+
+func (r *SQLiteProjectRepository) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("project_id = ?", id).Delete(&entity.ProjectMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.Project{}, id).Error
+	})
+}
+
+func (r *SQLiteProjectRepository) FindByID(id uint) (*entity.Project, error) {
+	var project entity.Project
+	if err := r.DB.First(&project, id).Error; err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (r *SQLiteProjectRepository) FindAll() ([]*entity.Project, error) {
+	var projects []*entity.Project
+	if err := r.DB.Order("name").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (r *SQLiteProjectRepository) FindByIDs(ids []uint) ([]*entity.Project, error) {
+	projects := []*entity.Project{}
+	if len(ids) == 0 {
+		return projects, nil
+	}
+	if err := r.DB.Where("id IN ?", ids).Order("name").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (r *SQLiteProjectRepository) SaveMember(member *entity.ProjectMember) error {
+	return r.DB.Omit("User").Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "project_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "updated_at"}),
+	}).Create(member).Error
+}
+
+func (r *SQLiteProjectRepository) RemoveMember(projectID, userID uint) error {
+	return r.DB.Where("project_id = ? AND user_id = ?", projectID, userID).Delete(&entity.ProjectMember{}).Error
+}
+
+func (r *SQLiteProjectRepository) FindMembers(projectID uint) ([]*entity.ProjectMember, error) {
+	var members []*entity.ProjectMember
+	if err := r.DB.Preload("User").Where("project_id = ?", projectID).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *SQLiteProjectRepository) FindMembershipsByUser(userID uint) ([]*entity.ProjectMember, error) {
+	var members []*entity.ProjectMember
+	if err := r.DB.Where("user_id = ?", userID).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *SQLiteProjectRepository) DeleteMembersByUser(userID uint) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&entity.ProjectMember{}).Error
+}
@@ -0,0 +1,51 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteReportDigestRepository struct {
+	DB *gorm.DB
+}
+
+func NewReportDigestRepository(db *gorm.DB) repository.ReportDigestRepository {
+	return &SQLiteReportDigestRepository{DB: db}
+}
+
+func (r *SQLiteReportDigestRepository) Create(digest *entity.ReportDigest) error {
+	return r.DB.Create(digest).Error
+}
+
+func (r *SQLiteReportDigestRepository) Update(digest *entity.ReportDigest) error {
+	return r.DB.Save(digest).Error
+}
+
+func (r *SQLiteReportDigestRepository) Delete(id int) error {
+	return r.DB.Delete(&entity.ReportDigest{}, id).Error
+}
+
+func (r *SQLiteReportDigestRepository) FindByID(id int) (*entity.ReportDigest, error) {
+	var digest entity.ReportDigest
+	if err := r.DB.First(&digest, id).Error; err != nil {
+		return nil, err
+	}
+	return &digest, nil
+}
+
+func (r *SQLiteReportDigestRepository) FindAll() ([]*entity.ReportDigest, error) {
+	var digests []*entity.ReportDigest
+	if err := r.DB.Order("id").Find(&digests).Error; err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
+
+func (r *SQLiteReportDigestRepository) FindEnabled() ([]*entity.ReportDigest, error) {
+	var digests []*entity.ReportDigest
+	if err := r.DB.Where("enabled = ?", true).Order("id").Find(&digests).Error; err != nil {
+		return nil, err
+	}
+	return digests, nil
+}
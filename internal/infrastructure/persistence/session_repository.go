@@ -0,0 +1,89 @@
+package persistence
+
+import (
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteSessionRepository struct {
+	DB *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) repository.SessionRepository {
+	return &SQLiteSessionRepository{DB: db}
+}
+
+func (r *SQLiteSessionRepository) Create(session *entity.Session) error {
+	return r.DB.Create(session).Error
+}
+
+func (r *SQLiteSessionRepository) FindByID(id uint) (*entity.Session, error) {
+	var session entity.Session
+	if err := r.DB.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SQLiteSessionRepository) FindByRefreshHash(hash string) (*entity.Session, error) {
+	var session entity.Session
+	if err := r.DB.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SQLiteSessionRepository) FindByPreviousHash(hash string) (*entity.Session, error) {
+	var session entity.Session
+	if err := r.DB.Where("previous_token_hash = ?", hash).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *SQLiteSessionRepository) FindActiveByUser(userID uint, now time.Time) ([]*entity.Session, error) {
+	var sessions []*entity.Session
+	err := r.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *SQLiteSessionRepository) Rotate(id uint, oldHash, newHash string, expiresAt, usedAt time.Time, ip string) (bool, error) {
+	result := r.DB.Model(&entity.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", id, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  newHash,
+			"previous_token_hash": oldHash,
+			"expires_at":          expiresAt,
+			"last_used_at":        usedAt,
+			"ip":                  ip,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *SQLiteSessionRepository) Revoke(id uint, at time.Time) error {
+	return r.DB.Model(&entity.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at).Error
+}
+
+func (r *SQLiteSessionRepository) RevokeByUser(userID uint, exceptID uint, at time.Time) error {
+	return r.DB.Model(&entity.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, exceptID).
+		Update("revoked_at", at).Error
+}
+
+func (r *SQLiteSessionRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.DB.Where("expires_at <= ?", now).Delete(&entity.Session{})
+	return result.RowsAffected, result.Error
+}
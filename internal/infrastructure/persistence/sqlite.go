@@ -5,6 +5,7 @@ import (
 	"log"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -26,23 +27,54 @@ func NewSQLiteDB(dsn string) (*SQLiteDB, error) {
 		&entity.User{},
 		&entity.TaskTemplate{},
 		&entity.TaskTemplateCategory{},
+		&entity.ReportDigest{},
+		&entity.NotificationChannel{},
+		&entity.NotificationDelivery{},
+		&entity.TaskAlertState{},
+		&entity.EscalationPolicy{},
+		&entity.TaskHeartbeat{},
+		&entity.TaskGrant{},
+		&entity.UserGroup{},
+		&entity.Project{},
+		&entity.ProjectMember{},
+		&entity.InviteCode{},
+		&entity.APIToken{},
+		&entity.Session{},
+		&entity.AuditLog{},
 	); err != nil {
 		return nil, err
 	}
+
+	// 旧版本的普通用户角色 user 迁移为操作员
+	if err := db.Model(&entity.User{}).Where("role = ? OR role = ''", "user").Update("role", entity.RoleOperator).Error; err != nil {
+		return nil, err
+	}
 	
+	// 创建日志全文索引
+	setupTaskLogSearch(db)
+
 	// 创建默认管理员用户
 	if err := createDefaultAdmin(db); err != nil {
 		return nil, err
 	}
+	if err := flagDefaultAdminPassword(db); err != nil {
+		return nil, err
+	}
 
 	return &SQLiteDB{Client: db}, nil
 }
 
+// 默认管理员账户
+const (
+	defaultAdminUsername = "admin"
+	defaultAdminPassword = "admin123"
+)
+
 // createDefaultAdmin 创建默认管理员用户
 func createDefaultAdmin(db *gorm.DB) error {
 	// 检查是否已存在管理员用户
 	var count int64
-	if err := db.Model(&entity.User{}).Where("role = ?", "admin").Count(&count).Error; err != nil {
+	if err := db.Model(&entity.User{}).Where("role = ?", entity.RoleAdmin).Count(&count).Error; err != nil {
 		return err
 	}
 	
@@ -52,23 +84,100 @@ func createDefaultAdmin(db *gorm.DB) error {
 	}
 	
 	// 创建默认管理员用户
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(defaultAdminPassword), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
 	
 	admin := &entity.User{
-		Username: "admin",
-		Password: string(hashedPassword),
-		Email:    "admin@example.com",
-		Role:     "admin",
-		IsActive: true,
+		Username:           defaultAdminUsername,
+		Password:           string(hashedPassword),
+		Email:              "admin@example.com",
+		Role:               entity.RoleAdmin,
+		IsActive:           true,
+		MustChangePassword: true,
 	}
 	
 	if err := db.Create(admin).Error; err != nil {
 		return err
 	}
 	
-	log.Println("默认管理员用户已创建: username=admin, password=admin123")
+	log.Println("默认管理员用户已创建: username=admin, password=admin123，首次登录后需要修改密码")
 	return nil
+}
+
+// flagDefaultAdminPassword 旧版本创建的默认管理员仍在使用默认密码时，要求其登录后修改密码
+func flagDefaultAdminPassword(db *gorm.DB) error {
+	var admin entity.User
+	if err := db.Where("username = ? AND must_change_password = ?", defaultAdminUsername, false).Limit(1).Find(&admin).Error; err != nil || admin.ID == 0 {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(defaultAdminPassword)) != nil {
+		return nil
+	}
+	log.Println("默认管理员仍在使用默认密码，登录后需要修改密码")
+	return db.Model(&admin).Update("must_change_password", true).Error
+}
+
+// taskLogFTSTable 任务日志全文索引表
+const taskLogFTSTable = "task_logs_fts"
+
+// setupTaskLogSearch 创建任务日志的 FTS5 全文索引（trigram 分词，支持中文子串匹配）。
+// FTS5 需要使用 sqlite_fts5 构建标签编译，不可用时删除同步触发器并退回 LIKE 搜索，
+// 以免触发器引用不存在的模块导致日志写入失败
+func setupTaskLogSearch(db *gorm.DB) {
+	probe := db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	if err := probe.Exec("CREATE VIRTUAL TABLE temp.fts5_probe USING fts5(content)").Error; err != nil {
+		log.Printf("FTS5 不可用，日志搜索将使用 LIKE 匹配: %v", err)
+		for _, trigger := range []string{"task_logs_ai", "task_logs_ad", "task_logs_au"} {
+			if err := db.Exec("DROP TRIGGER IF EXISTS " + trigger).Error; err != nil {
+				log.Printf("Failed to drop trigger %s: %v", trigger, err)
+			}
+		}
+		return
+	}
+	db.Exec("DROP TABLE temp.fts5_probe")
+
+	if err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS ` + taskLogFTSTable + ` USING fts5(
+		output, error, content='task_logs', content_rowid='id', tokenize='trigram')`).Error; err != nil {
+		log.Printf("Failed to create task log search index: %v", err)
+		return
+	}
+
+	// 触发器缺失说明索引是新建的或曾在没有 FTS5 的情况下运行过，需要重建索引
+	var count int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'task_logs_ai'").Scan(&count)
+	if count > 0 {
+		return
+	}
+
+	statements := []string{
+		`CREATE TRIGGER IF NOT EXISTS task_logs_ai AFTER INSERT ON task_logs BEGIN
+			INSERT INTO ` + taskLogFTSTable + `(rowid, output, error) VALUES (new.id, new.output, new.error);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS task_logs_ad AFTER DELETE ON task_logs BEGIN
+			INSERT INTO ` + taskLogFTSTable + `(` + taskLogFTSTable + `, rowid, output, error) VALUES ('delete', old.id, old.output, old.error);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS task_logs_au AFTER UPDATE ON task_logs BEGIN
+			INSERT INTO ` + taskLogFTSTable + `(` + taskLogFTSTable + `, rowid, output, error) VALUES ('delete', old.id, old.output, old.error);
+			INSERT INTO ` + taskLogFTSTable + `(rowid, output, error) VALUES (new.id, new.output, new.error);
+		END`,
+		`INSERT INTO ` + taskLogFTSTable + `(` + taskLogFTSTable + `) VALUES ('rebuild')`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Printf("Failed to set up task log search index: %v", err)
+			return
+		}
+	}
+	log.Println("日志全文索引已创建")
+}
+
+// taskLogSearchEnabled 检查日志全文索引是否可用
+func taskLogSearchEnabled(db *gorm.DB) bool {
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'task_logs_ai'").Scan(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
 }
\ No newline at end of file
@@ -0,0 +1,42 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteTaskAlertRepository struct {
+	DB *gorm.DB
+}
+
+func NewTaskAlertRepository(db *gorm.DB) repository.TaskAlertRepository {
+	return &SQLiteTaskAlertRepository{DB: db}
+}
+
+func (r *SQLiteTaskAlertRepository) FindByTaskID(taskID int) (*entity.TaskAlertState, error) {
+	var states []*entity.TaskAlertState
+	if err := r.DB.Where("task_id = ?", taskID).Limit(1).Find(&states).Error; err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+	return states[0], nil
+}
+
+func (r *SQLiteTaskAlertRepository) FindFiring() ([]*entity.TaskAlertState, error) {
+	var states []*entity.TaskAlertState
+	if err := r.DB.Where("status = ?", entity.AlertStatusFiring).Order("task_id").Find(&states).Error; err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (r *SQLiteTaskAlertRepository) Save(state *entity.TaskAlertState) error {
+	return r.DB.Save(state).Error
+}
+
+func (r *SQLiteTaskAlertRepository) Delete(taskID int) error {
+	return r.DB.Delete(&entity.TaskAlertState{}, taskID).Error
+}
@@ -0,0 +1,63 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteTaskGrantRepository struct {
+	DB *gorm.DB
+}
+
+func NewTaskGrantRepository(db *gorm.DB) repository.TaskGrantRepository {
+	return &SQLiteTaskGrantRepository{DB: db}
+}
+
+func (r *SQLiteTaskGrantRepository) Create(grant *entity.TaskGrant) error {
+	return r.DB.Create(grant).Error
+}
+
+func (r *SQLiteTaskGrantRepository) Delete(id uint) error {
+	return r.DB.Delete(&entity.TaskGrant{}, id).Error
+}
+
+func (r *SQLiteTaskGrantRepository) FindByID(id uint) (*entity.TaskGrant, error) {
+	var grant entity.TaskGrant
+	if err := r.DB.First(&grant, id).Error; err != nil {
+		return nil, err
+	}
+	return &grant, nil
+}
+
+func (r *SQLiteTaskGrantRepository) FindByTaskID(taskID int) ([]*entity.TaskGrant, error) {
+	var grants []*entity.TaskGrant
+	if err := r.DB.Where("task_id = ?", taskID).Order("id").Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *SQLiteTaskGrantRepository) FindBySubject(userID uint, groupIDs []uint) ([]*entity.TaskGrant, error) {
+	var grants []*entity.TaskGrant
+	query := r.DB.Where("user_id = ?", userID)
+	if len(groupIDs) > 0 {
+		query = query.Or("group_id IN ?", groupIDs)
+	}
+	if err := query.Find(&grants).Error; err != nil {
+		return nil, err
+	}
+	return grants, nil
+}
+
+func (r *SQLiteTaskGrantRepository) DeleteByTaskID(taskID int) error {
+	return r.DB.Where("task_id = ?", taskID).Delete(&entity.TaskGrant{}).Error
+}
+
+func (r *SQLiteTaskGrantRepository) DeleteByGroupID(groupID uint) error {
+	return r.DB.Where("group_id = ?", groupID).Delete(&entity.TaskGrant{}).Error
+}
+
+func (r *SQLiteTaskGrantRepository) DeleteByUserID(userID uint) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&entity.TaskGrant{}).Error
+}
@@ -0,0 +1,34 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteTaskHeartbeatRepository struct {
+	DB *gorm.DB
+}
+
+func NewTaskHeartbeatRepository(db *gorm.DB) repository.TaskHeartbeatRepository {
+	return &SQLiteTaskHeartbeatRepository{DB: db}
+}
+
+func (r *SQLiteTaskHeartbeatRepository) FindByTaskID(taskID int) (*entity.TaskHeartbeat, error) {
+	var heartbeats []*entity.TaskHeartbeat
+	if err := r.DB.Where("task_id = ?", taskID).Limit(1).Find(&heartbeats).Error; err != nil {
+		return nil, err
+	}
+	if len(heartbeats) == 0 {
+		return nil, nil
+	}
+	return heartbeats[0], nil
+}
+
+func (r *SQLiteTaskHeartbeatRepository) Save(heartbeat *entity.TaskHeartbeat) error {
+	return r.DB.Save(heartbeat).Error
+}
+
+func (r *SQLiteTaskHeartbeatRepository) Delete(taskID int) error {
+	return r.DB.Delete(&entity.TaskHeartbeat{}, taskID).Error
+}
@@ -3,17 +3,22 @@ package persistence
 import (
 	"crontab_go/internal/domain/entity"
 	"crontab_go/internal/domain/repository"
+	"math"
+	"strings"
+	"unicode/utf8"
+
 	"gorm.io/gorm"
 )
 
 // SQLiteTaskLogRepository SQLite任务日志仓库实现
 type SQLiteTaskLogRepository struct {
-	DB *gorm.DB
+	DB         *gorm.DB
+	ftsEnabled bool // 是否可以使用 FTS5 全文索引
 }
 
 // NewTaskLogRepository 创建任务日志仓库实例
 func NewTaskLogRepository(db *gorm.DB) repository.TaskLogRepository {
-	return &SQLiteTaskLogRepository{DB: db}
+	return &SQLiteTaskLogRepository{DB: db, ftsEnabled: taskLogSearchEnabled(db)}
 }
 
 // Create 创建任务日志
@@ -30,6 +35,18 @@ func (r *SQLiteTaskLogRepository) GetLogsByTaskID(taskID int) ([]entity.TaskLog,
 	return logs, nil
 }
 
+// GetLatestLog 获取任务最近一次执行日志，没有日志时返回 nil
+func (r *SQLiteTaskLogRepository) GetLatestLog(taskID int) (*entity.TaskLog, error) {
+	var logs []entity.TaskLog
+	if err := r.DB.Where("task_id = ?", taskID).Order("start_time DESC, id DESC").Limit(1).Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, nil
+	}
+	return &logs[0], nil
+}
+
 // GetLogsByTaskIDWithPagination 根据任务ID分页获取任务日志
 func (r *SQLiteTaskLogRepository) GetLogsByTaskIDWithPagination(taskID int, req *entity.PaginationRequest) ([]entity.TaskLog, int64, error) {
 	var logs []entity.TaskLog
@@ -52,10 +69,15 @@ func (r *SQLiteTaskLogRepository) GetLogsByTaskIDWithPagination(taskID int, req
 	return logs, total, nil
 }
 
-// GetAllLogs 获取所有任务日志
-func (r *SQLiteTaskLogRepository) GetAllLogs() ([]entity.TaskLog, error) {
+// GetAllLogs 获取最近的 limit 条任务日志，taskIDs 不为 nil 时只返回这些任务的日志
+func (r *SQLiteTaskLogRepository) GetAllLogs(taskIDs []int, limit int) ([]entity.TaskLog, error) {
 	var logs []entity.TaskLog
-	if err := r.DB.Order("start_time DESC").Find(&logs).Error; err != nil {
+	query := r.DB.Order("start_time DESC").Limit(limit)
+	if taskIDs != nil {
+		// 空列表表示没有可访问的任务，不匹配任何日志
+		query = query.Where("task_id IN ?", taskIDs)
+	}
+	if err := query.Find(&logs).Error; err != nil {
 		return nil, err
 	}
 	return logs, nil
@@ -78,4 +100,276 @@ func (r *SQLiteTaskLogRepository) GetAllLogsWithPagination(page, pageSize int) (
 	}
 
 	return logs, total, nil
-}
\ No newline at end of file
+}
+
+// durationExpr 计算执行时间（秒）的SQL表达式
+const durationExpr = "(julianday(end_time) - julianday(start_time)) * 86400.0"
+
+// sortDurationExpr 按执行时间排序和分页的SQL表达式，没有结束时间（未结束）的执行记录执行时间为 NULL，
+// 按 -1 处理，使排序、游标比较和游标中记录的值保持一致
+const sortDurationExpr = "COALESCE(" + durationExpr + ", -1)"
+
+// applyFilter 将查询条件应用到查询上
+func (r *SQLiteTaskLogRepository) applyFilter(query *gorm.DB, filter *entity.TaskLogFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+	if filter.TaskID != nil {
+		query = query.Where("task_id = ?", *filter.TaskID)
+	}
+	if filter.TaskIDs != nil {
+		// 空列表表示没有可访问的任务，不匹配任何日志
+		query = query.Where("task_id IN ?", filter.TaskIDs)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+	if filter.TriggerSource != "" {
+		query = query.Where("trigger_source = ?", filter.TriggerSource)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("start_time >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("start_time <= ?", *filter.EndTime)
+	}
+	if filter.MinDuration != nil {
+		query = query.Where(durationExpr+" >= ?", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		query = query.Where(durationExpr+" <= ?", *filter.MaxDuration)
+	}
+	if filter.Keyword != "" {
+		// trigram 分词要求关键字至少3个字符，更短的关键字使用 LIKE 匹配
+		if r.ftsEnabled && utf8.RuneCountInString(filter.Keyword) >= 3 {
+			query = query.Where("task_logs.id IN (SELECT rowid FROM "+taskLogFTSTable+" WHERE "+taskLogFTSTable+" MATCH ?)",
+				`"`+strings.ReplaceAll(filter.Keyword, `"`, `""`)+`"`)
+		} else {
+			keyword := "%" + filter.Keyword + "%"
+			query = query.Where("(output LIKE ? OR error LIKE ?)", keyword, keyword)
+		}
+	}
+	return query
+}
+
+// FindLogs 根据条件获取任务日志
+func (r *SQLiteTaskLogRepository) FindLogs(filter *entity.TaskLogFilter) ([]entity.TaskLog, error) {
+	var logs []entity.TaskLog
+	query := r.applyFilter(r.DB, filter).Order("start_time DESC")
+	if filter != nil && filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// CountLogs 根据条件统计任务日志数量
+func (r *SQLiteTaskLogRepository) CountLogs(filter *entity.TaskLogFilter) (int64, error) {
+	var total int64
+	if err := r.applyFilter(r.DB.Model(&entity.TaskLog{}), filter).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// AggregateByTask 按任务聚合执行次数、成功率和执行时间
+func (r *SQLiteTaskLogRepository) AggregateByTask(filter *entity.TaskLogFilter) ([]entity.TaskLogAggregate, error) {
+	var aggregates []entity.TaskLogAggregate
+	query := r.applyFilter(r.DB.Model(&entity.TaskLog{}), filter).
+		Select(`task_id,
+				COUNT(*) AS total_executions,
+				SUM(CASE WHEN success THEN 1 ELSE 0 END) AS success_executions,
+				SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failure_executions,
+				AVG(` + durationExpr + `) AS avg_duration,
+				MIN(` + durationExpr + `) AS min_duration,
+				MAX(` + durationExpr + `) AS max_duration,
+				AVG(` + durationExpr + ` * ` + durationExpr + `) AS avg_squared_duration`).
+		Group("task_id")
+	if err := query.Scan(&aggregates).Error; err != nil {
+		return nil, err
+	}
+
+	// 获取每个任务最后一次执行的日志
+	latest := r.applyFilter(r.DB.Model(&entity.TaskLog{}), filter).
+		Select("task_id, MAX(start_time) AS max_start_time").
+		Group("task_id")
+	var lastLogs []entity.TaskLog
+	if err := r.DB.Model(&entity.TaskLog{}).
+		Select("task_logs.id, task_logs.task_id, task_logs.start_time, task_logs.success").
+		Joins("JOIN (?) latest ON task_logs.task_id = latest.task_id AND task_logs.start_time = latest.max_start_time", latest).
+		Find(&lastLogs).Error; err != nil {
+		return nil, err
+	}
+
+	lastByTask := make(map[int]entity.TaskLog, len(lastLogs))
+	for _, log := range lastLogs {
+		lastByTask[log.TaskID] = log
+	}
+	for i := range aggregates {
+		if log, ok := lastByTask[aggregates[i].TaskID]; ok {
+			startTime := log.StartTime
+			aggregates[i].LastStartTime = &startTime
+			aggregates[i].LastSuccess = log.Success
+		}
+	}
+
+	return aggregates, nil
+}
+
+// AggregateByDay 按天聚合执行次数
+func (r *SQLiteTaskLogRepository) AggregateByDay(filter *entity.TaskLogFilter) ([]entity.TaskLogBucket, error) {
+	return r.aggregateByBucket(filter, "strftime('%Y-%m-%d', start_time, 'localtime')")
+}
+
+// AggregateByHour 按小时聚合执行次数
+func (r *SQLiteTaskLogRepository) AggregateByHour(filter *entity.TaskLogFilter) ([]entity.TaskLogBucket, error) {
+	return r.aggregateByBucket(filter, "strftime('%H', start_time, 'localtime')")
+}
+
+// aggregateByBucket 按指定的时间段表达式聚合执行次数
+func (r *SQLiteTaskLogRepository) aggregateByBucket(filter *entity.TaskLogFilter, bucketExpr string) ([]entity.TaskLogBucket, error) {
+	var buckets []entity.TaskLogBucket
+	query := r.applyFilter(r.DB.Model(&entity.TaskLog{}), filter).
+		Select(bucketExpr + ` AS bucket,
+				COUNT(*) AS total_executions,
+				SUM(CASE WHEN success THEN 1 ELSE 0 END) AS success_count,
+				SUM(CASE WHEN success THEN 0 ELSE 1 END) AS failure_count`).
+		Group("bucket").
+		Order("bucket")
+	if err := query.Scan(&buckets).Error; err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// GetDurationPercentilesByTask 按任务获取执行时间的百分位数 (秒)，相邻排名之间线性插值。
+// 在一次查询中用窗口函数为每个任务的执行时间排序，只取出插值需要的排名
+func (r *SQLiteTaskLogRepository) GetDurationPercentilesByTask(filter *entity.TaskLogFilter, percentiles []float64) (map[int][]float64, error) {
+	clamped := make([]float64, len(percentiles))
+	conditions := make([]string, len(percentiles))
+	args := make([]interface{}, len(percentiles))
+	for i, p := range percentiles {
+		clamped[i] = math.Min(math.Max(p, 0), 1)
+		conditions[i] = "row_index - CAST(? * (row_total - 1) AS INTEGER) IN (0, 1)"
+		args[i] = clamped[i]
+	}
+
+	ranked := r.applyFilter(r.DB.Model(&entity.TaskLog{}), filter).
+		Select("task_id, " + durationExpr + " AS duration, " +
+			"ROW_NUMBER() OVER (PARTITION BY task_id ORDER BY " + durationExpr + ") - 1 AS row_index, " +
+			"COUNT(*) OVER (PARTITION BY task_id) AS row_total")
+	var rows []struct {
+		TaskID   int
+		Duration float64
+		RowIndex int64
+		RowTotal int64
+	}
+	if err := r.DB.Table("(?) AS ranked", ranked).
+		Select("task_id, duration, row_index, row_total").
+		Where(strings.Join(conditions, " OR "), args...).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	durations := make(map[int]map[int64]float64)
+	totals := make(map[int]int64)
+	for _, row := range rows {
+		if durations[row.TaskID] == nil {
+			durations[row.TaskID] = make(map[int64]float64)
+		}
+		durations[row.TaskID][row.RowIndex] = row.Duration
+		totals[row.TaskID] = row.RowTotal
+	}
+
+	results := make(map[int][]float64, len(totals))
+	for taskID, total := range totals {
+		values := make([]float64, len(clamped))
+		for i, p := range clamped {
+			rank := p * float64(total-1)
+			lower := int64(rank)
+			values[i] = durations[taskID][lower]
+			if upper, ok := durations[taskID][lower+1]; ok {
+				values[i] += (upper - values[i]) * (rank - float64(lower))
+			}
+		}
+		results[taskID] = values
+	}
+	return results, nil
+}
+
+// SearchLogs 按条件搜索日志，返回当前页的日志和下一页的游标（没有更多数据时为空）
+func (r *SQLiteTaskLogRepository) SearchLogs(req *entity.TaskLogSearchRequest) ([]entity.TaskLog, *entity.TaskLogCursor, error) {
+	sortExpr := "start_time"
+	switch req.SortBy {
+	case entity.LogSortByDuration:
+		sortExpr = sortDurationExpr
+	case entity.LogSortByID:
+		sortExpr = ""
+	}
+
+	direction, compare := "ASC", ">"
+	if req.SortDesc {
+		direction, compare = "DESC", "<"
+	}
+
+	query := r.applyFilter(r.DB.Model(&entity.TaskLog{}), &req.TaskLogFilter).
+		Select("task_logs.*, " + sortDurationExpr + " AS sort_duration")
+
+	if cursor := req.Cursor; cursor != nil {
+		switch {
+		case sortExpr == "":
+			query = query.Where("task_logs.id "+compare+" ?", cursor.ID)
+		case cursor.StartTime != nil:
+			query = query.Where("(start_time "+compare+" ? OR (start_time = ? AND task_logs.id "+compare+" ?))",
+				*cursor.StartTime, *cursor.StartTime, cursor.ID)
+		case cursor.Duration != nil:
+			query = query.Where("("+sortDurationExpr+" "+compare+" ? OR ("+sortDurationExpr+" = ? AND task_logs.id "+compare+" ?))",
+				*cursor.Duration, *cursor.Duration, cursor.ID)
+		}
+	} else if req.Page > 1 {
+		query = query.Offset((req.Page - 1) * req.PageSize)
+	}
+
+	if sortExpr != "" {
+		query = query.Order(sortExpr + " " + direction)
+	}
+	query = query.Order("task_logs.id " + direction)
+
+	// 多取一条用于判断是否还有下一页
+	var rows []struct {
+		entity.TaskLog
+		SortDuration float64
+	}
+	if err := query.Limit(req.PageSize + 1).Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	hasMore := len(rows) > req.PageSize
+	if hasMore {
+		rows = rows[:req.PageSize]
+	}
+
+	logs := make([]entity.TaskLog, 0, len(rows))
+	for _, row := range rows {
+		logs = append(logs, row.TaskLog)
+	}
+
+	if !hasMore {
+		return logs, nil, nil
+	}
+
+	last := rows[len(rows)-1]
+	next := &entity.TaskLogCursor{SortBy: req.SortBy, SortDesc: req.SortDesc, ID: last.ID}
+	switch req.SortBy {
+	case entity.LogSortByStartTime:
+		startTime := last.StartTime
+		next.StartTime = &startTime
+	case entity.LogSortByDuration:
+		duration := last.SortDuration
+		next.Duration = &duration
+	}
+
+	return logs, next, nil
+}
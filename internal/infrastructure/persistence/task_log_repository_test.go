@@ -0,0 +1,76 @@
+package persistence
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+)
+
+func TestSearchLogsDurationCursor(t *testing.T) {
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "crontab.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	repo := NewTaskLogRepository(db.Client)
+
+	// 执行时间 (秒)，-1 表示未结束的执行记录
+	durations := []float64{5, -1, 3, 5, 10, -1, 5}
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	ids := make([]uint, len(durations))
+	for i, duration := range durations {
+		startTime := start.Add(time.Duration(i) * time.Minute)
+		log := &entity.TaskLog{
+			TaskID:    1,
+			TaskName:  "task",
+			StartTime: startTime,
+			EndTime:   startTime.Add(time.Duration(duration) * time.Second),
+			Success:   true,
+		}
+		if err := repo.Create(log); err != nil {
+			t.Fatalf("create log: %v", err)
+		}
+		if duration < 0 {
+			// 未结束的执行记录没有结束时间，执行时间为 NULL
+			if err := db.Client.Model(log).Update("end_time", "").Error; err != nil {
+				t.Fatalf("clear end_time: %v", err)
+			}
+		}
+		ids[i] = log.ID
+	}
+
+	tests := []struct {
+		name string
+		desc bool
+		want []uint
+	}{
+		{"ascending", false, []uint{ids[1], ids[5], ids[2], ids[0], ids[3], ids[6], ids[4]}},
+		{"descending", true, []uint{ids[4], ids[6], ids[3], ids[0], ids[2], ids[5], ids[1]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, pageSize := range []int{1, 2, 3, len(durations)} {
+				var got []uint
+				req := &entity.TaskLogSearchRequest{SortBy: entity.LogSortByDuration, SortDesc: tt.desc, PageSize: pageSize}
+				for page := 0; page <= len(durations); page++ {
+					logs, next, err := repo.SearchLogs(req)
+					if err != nil {
+						t.Fatalf("page size %d: SearchLogs: %v", pageSize, err)
+					}
+					for _, log := range logs {
+						got = append(got, log.ID)
+					}
+					if next == nil {
+						break
+					}
+					req.Cursor = next
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("page size %d: ids = %v, want %v", pageSize, got, tt.want)
+				}
+			}
+		})
+	}
+}
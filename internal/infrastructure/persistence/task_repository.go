@@ -50,17 +50,78 @@ func (r *SQLiteTaskRepository) FindEnabled() ([]*entity.Task, error) {
 	return tasks, nil
 }
 
-func (r *SQLiteTaskRepository) FindWithPagination(req *entity.PaginationRequest) ([]*entity.Task, int64, error) {
+func (r *SQLiteTaskRepository) FindByPingToken(token string) (*entity.Task, error) {
+	var task entity.Task
+	if err := r.DB.Where("type = ? AND ping_token = ?", entity.TaskTypeHeartbeat, token).First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (r *SQLiteTaskRepository) FindHeartbeats() ([]*entity.Task, error) {
+	var tasks []*entity.Task
+	if err := r.DB.Where("type = ?", entity.TaskTypeHeartbeat).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *SQLiteTaskRepository) FindByScope(scope *entity.TaskScope) ([]*entity.Task, error) {
+	var tasks []*entity.Task
+	if err := r.scoped(scope).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// scoped 限定查询范围为用户拥有的任务、被授权的任务和所属项目中的任务，并按选择的项目筛选
+func (r *SQLiteTaskRepository) scoped(scope *entity.TaskScope) *gorm.DB {
+	query := r.DB.Model(&entity.Task{})
+	if scope == nil {
+		return query
+	}
+	if !scope.Unrestricted {
+		conditions := "owner_id = ?"
+		args := []interface{}{scope.OwnerID}
+		if len(scope.TaskIDs) > 0 {
+			conditions += " OR id IN ?"
+			args = append(args, scope.TaskIDs)
+		}
+		if len(scope.ProjectIDs) > 0 {
+			conditions += " OR project_id IN ?"
+			args = append(args, scope.ProjectIDs)
+		}
+		query = query.Where("("+conditions+")", args...)
+	}
+	if scope.ProjectID != nil {
+		query = query.Where("project_id = ?", *scope.ProjectID)
+	}
+	return query
+}
+
+func (r *SQLiteTaskRepository) ClearOwner(userID uint) error {
+	return r.DB.Model(&entity.Task{}).Where("owner_id = ?", userID).Update("owner_id", nil).Error
+}
+
+func (r *SQLiteTaskRepository) CountByProject(projectID uint) (int64, error) {
+	var count int64
+	if err := r.DB.Model(&entity.Task{}).Where("project_id = ?", projectID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SQLiteTaskRepository) FindWithPagination(scope *entity.TaskScope, req *entity.PaginationRequest) ([]*entity.Task, int64, error) {
 	var tasks []*entity.Task
 	var total int64
 	
 	// 获取总数
-	if err := r.DB.Model(&entity.Task{}).Count(&total).Error; err != nil {
+	if err := r.scoped(scope).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 	
 	// 分页查询
-	if err := r.DB.Offset(req.GetOffset()).Limit(req.PageSize).Find(&tasks).Error; err != nil {
+	if err := r.scoped(scope).Offset(req.GetOffset()).Limit(req.PageSize).Find(&tasks).Error; err != nil {
 		return nil, 0, err
 	}
 	
@@ -92,6 +92,17 @@ func (r *taskTemplateRepository) Search(req *entity.TaskTemplateSearchRequest) (
 		query = query.Where("task_templates.tags LIKE ?", "%"+req.Tags+"%")
 	}
 
+	if req.VisibleTo != nil {
+		if len(req.VisibleProjects) > 0 {
+			query = query.Where("(task_templates.is_public = ? OR task_templates.created_by = ? OR task_templates.project_id IN ?)", true, *req.VisibleTo, req.VisibleProjects)
+		} else {
+			query = query.Where("(task_templates.is_public = ? OR task_templates.created_by = ?)", true, *req.VisibleTo)
+		}
+	}
+	if req.ProjectID != nil {
+		query = query.Where("task_templates.project_id = ?", *req.ProjectID)
+	}
+
 	// 获取总数
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
@@ -0,0 +1,68 @@
+package persistence
+
+import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
+	"gorm.io/gorm"
+)
+
+type SQLiteUserGroupRepository struct {
+	DB *gorm.DB
+}
+
+func NewUserGroupRepository(db *gorm.DB) repository.UserGroupRepository {
+	return &SQLiteUserGroupRepository{DB: db}
+}
+
+func (r *SQLiteUserGroupRepository) Create(group *entity.UserGroup) error {
+	return r.DB.Omit("Members").Create(group).Error
+}
+
+func (r *SQLiteUserGroupRepository) Update(group *entity.UserGroup) error {
+	return r.DB.Omit("Members").Save(group).Error
+}
+
+func (r *SQLiteUserGroupRepository) Delete(id uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM user_group_members WHERE user_group_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&entity.UserGroup{}, id).Error
+	})
+}
+
+func (r *SQLiteUserGroupRepository) FindByID(id uint) (*entity.UserGroup, error) {
+	var group entity.UserGroup
+	if err := r.DB.Preload("Members").First(&group, id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (r *SQLiteUserGroupRepository) FindAll() ([]*entity.UserGroup, error) {
+	var groups []*entity.UserGroup
+	if err := r.DB.Preload("Members").Order("name").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+func (r *SQLiteUserGroupRepository) AddMember(groupID, userID uint) error {
+	return r.DB.Exec("INSERT OR IGNORE INTO user_group_members (user_group_id, user_id) VALUES (?, ?)", groupID, userID).Error
+}
+
+func (r *SQLiteUserGroupRepository) RemoveMember(groupID, userID uint) error {
+	return r.DB.Exec("DELETE FROM user_group_members WHERE user_group_id = ? AND user_id = ?", groupID, userID).Error
+}
+
+func (r *SQLiteUserGroupRepository) DeleteMembersByUser(userID uint) error {
+	return r.DB.Exec("DELETE FROM user_group_members WHERE user_id = ?", userID).Error
+}
+
+func (r *SQLiteUserGroupRepository) FindGroupIDsByUser(userID uint) ([]uint, error) {
+	var groupIDs []uint
+	if err := r.DB.Table("user_group_members").Where("user_id = ?", userID).Pluck("user_group_id", &groupIDs).Error; err != nil {
+		return nil, err
+	}
+	return groupIDs, nil
+}
@@ -15,7 +15,15 @@ func NewUserRepository(db *gorm.DB) repository.UserRepository {
 }
 
 func (r *SQLiteUserRepository) Create(user *entity.User) error {
-	return r.DB.Create(user).Error
+	// is_active 有默认值 true，gorm 创建时会忽略零值并回填默认值，未启用的用户需要单独更新
+	active := user.IsActive
+	if err := r.DB.Create(user).Error; err != nil {
+		return err
+	}
+	if !active {
+		return r.DB.Model(user).Update("is_active", false).Error
+	}
+	return nil
 }
 
 func (r *SQLiteUserRepository) FindByUsername(username string) (*entity.User, error) {
@@ -48,4 +56,56 @@ func (r *SQLiteUserRepository) FindAll() ([]*entity.User, error) {
 		return nil, err
 	}
 	return users, nil
-}
\ No newline at end of file
+}
+
+func (r *SQLiteUserRepository) CountActiveAdmins() (int64, error) {
+	var count int64
+	if err := r.DB.Model(&entity.User{}).Where("role = ? AND is_active = ?", entity.RoleAdmin, true).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func (r *SQLiteUserRepository) FindByExternalID(provider, externalID string) (*entity.User, error) {
+	var user entity.User
+	if err := r.DB.Where("auth_provider = ? AND external_id = ?", provider, externalID).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *SQLiteUserRepository) FindByEmail(email string) (*entity.User, error) {
+	var user entity.User
+	if err := r.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *SQLiteUserRepository) FindByAuthProvider(provider string) ([]*entity.User, error) {
+	var users []*entity.User
+	if err := r.DB.Where("auth_provider = ?", provider).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *SQLiteUserRepository) UseTOTPStep(id uint, step int64) (bool, error) {
+	result := r.DB.Model(&entity.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *SQLiteUserRepository) ReplaceRecoveryCodes(id uint, previous, remaining string) (bool, error) {
+	result := r.DB.Model(&entity.User{}).
+		Where("id = ? AND recovery_codes = ?", id, previous).
+		Update("recovery_codes", remaining)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
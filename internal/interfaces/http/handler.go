@@ -1,16 +1,37 @@
 package http
 
 import (
+	"crontab_go/internal/application/access"
+	"crontab_go/internal/application/account"
+	"crontab_go/internal/application/apitoken"
+	"crontab_go/internal/application/audit"
 	"crontab_go/internal/application/auth"
+	"crontab_go/internal/application/channel"
+	"crontab_go/internal/application/digest"
+	"crontab_go/internal/application/directory"
+	"crontab_go/internal/application/escalation"
+	"crontab_go/internal/application/export"
+	"crontab_go/internal/application/project"
+	"crontab_go/internal/application/sso"
 	"crontab_go/internal/application/statistics"
 	"crontab_go/internal/application/system"
 	"crontab_go/internal/application/task"
 	"crontab_go/internal/application/template"
 	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/domain/repository"
 	"crontab_go/internal/domain/service"
+	"crontab_go/internal/infrastructure/config"
+	"crontab_go/internal/infrastructure/ldap"
+	"crontab_go/internal/infrastructure/oidc"
 	"crontab_go/internal/infrastructure/persistence"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,37 +55,294 @@ func CORSMiddleware() gin.HandlerFunc {
 }
 
 type Handler struct {
-	taskService       *task.Service
-	systemService     *system.Service
-	authService       *auth.Service
-	statisticsService *statistics.Service
-	templateService   *template.Service
+	taskService         *task.Service
+	systemService       *system.Service
+	authService         *auth.Service
+	statisticsService   *statistics.Service
+	templateService     *template.Service
+	exportService       *export.Service
+	digestService       *digest.Service
+	escalationService   *escalation.Service
+	channelService      *channel.Service
+	notificationService *service.NotificationService
+	accessService       *access.Service
+	projectService      *project.Service
+	accountService      *account.Service
+	tokenService        *apitoken.Service
+	auditService        *audit.Service
+	ssoService          *sso.Service // 未配置 OIDC 时为 nil
+	ssoName             string
+	ldapName            string
 }
 
-func NewHandler(db *gorm.DB) *Handler {
+func NewHandler(db *gorm.DB, cfg *config.Config) *Handler {
 	taskRepo := persistence.NewTaskRepository(db)
 	taskLogRepo := persistence.NewTaskLogRepository(db)
-	taskService := task.NewService(taskRepo, taskLogRepo)
+	channelRepo := persistence.NewNotificationChannelRepository(db)
+	deliveryRepo := persistence.NewNotificationDeliveryRepository(db)
+	alertRepo := persistence.NewTaskAlertRepository(db)
+	policyRepo := persistence.NewEscalationPolicyRepository(db)
+	heartbeatRepo := persistence.NewTaskHeartbeatRepository(db)
+	grantRepo := persistence.NewTaskGrantRepository(db)
+	projectRepo := persistence.NewProjectRepository(db)
+	taskService := task.NewService(taskRepo, taskLogRepo, channelRepo, deliveryRepo, alertRepo, policyRepo, heartbeatRepo, grantRepo, projectRepo)
 
 	systemRepo := persistence.NewSystemRepository(db)
 	systemService := system.NewService(systemRepo)
 
 	userRepo := persistence.NewUserRepository(db)
-	authService := auth.NewService(userRepo)
+	inviteRepo := persistence.NewInviteCodeRepository(db)
+	sessionRepo := persistence.NewSessionRepository(db)
+	var authenticator auth.PasswordAuthenticator
+	if cfg.Auth.LDAP.Enabled() {
+		authenticator = NewDirectoryService(cfg.Auth.LDAP, userRepo, sessionRepo)
+	}
+	auditService := audit.NewService(persistence.NewAuditLogRepository(db))
+	loginLimit := cfg.Auth.LoginLimit
+	limiter := auth.NewLoginLimiter(loginLimit.MaxPerIP, loginLimit.MaxPerAccount, loginLimit.Window.Std(), loginLimit.Lockout.Std())
+	authService := auth.NewService(userRepo, inviteRepo, sessionRepo, cfg.JWT.Secret, cfg.JWT.Expiration.Std(), cfg.JWT.RefreshExpiration.Std(), cfg.Auth.Registration, !cfg.Auth.DisableLocalLogin, authenticator, limiter, auditService)
 
 	statisticsService := statistics.NewService(taskRepo, taskLogRepo)
 
 	templateRepo := persistence.NewTaskTemplateRepository(db)
 	categoryRepo := persistence.NewTaskTemplateCategoryRepository(db)
-	templateService := template.NewService(templateRepo, categoryRepo, taskRepo)
+	templateService := template.NewService(templateRepo, categoryRepo, taskRepo, channelRepo)
+
+	exportService := export.NewService(taskLogRepo, statisticsService)
+
+	digestRepo := persistence.NewReportDigestRepository(db)
+	digestService := digest.NewService(digestRepo, statisticsService, channelRepo)
+
+	groupRepo := persistence.NewUserGroupRepository(db)
+	tokenRepo := persistence.NewAPITokenRepository(db)
+
+	var ssoService *sso.Service
+	if oidcCfg := cfg.Auth.OIDC; oidcCfg.Enabled() {
+		provider := oidc.NewProvider(oidcCfg.Issuer, oidcCfg.ClientID, oidcCfg.ClientSecret, oidcCfg.RedirectURL, oidcCfg.Scopes)
+		ssoService = sso.NewService(provider, authService, sso.ClaimMapping{
+			UsernameClaim: oidcCfg.UsernameClaim,
+			GroupsClaim:   oidcCfg.GroupsClaim,
+			RoleMapping:   oidcCfg.RoleMapping,
+			DefaultRole:   oidcCfg.DefaultRole,
+			AutoCreate:    oidcCfg.AutoCreate,
+			LinkByEmail:   oidcCfg.LinkByEmail,
+		})
+	}
 
 	return &Handler{
-		taskService:       taskService,
-		systemService:     systemService,
-		authService:       authService,
-		statisticsService: statisticsService,
-		templateService:   templateService,
+		taskService:         taskService,
+		systemService:       systemService,
+		authService:         authService,
+		statisticsService:   statisticsService,
+		templateService:     templateService,
+		exportService:       exportService,
+		digestService:       digestService,
+		escalationService:   escalation.NewService(policyRepo, channelRepo),
+		channelService:      channel.NewService(channelRepo),
+		notificationService: service.NewNotificationService(),
+		accessService:       access.NewService(taskRepo, grantRepo, groupRepo, userRepo, projectRepo),
+		projectService:      project.NewService(projectRepo, taskRepo, userRepo),
+		accountService:      account.NewService(userRepo, inviteRepo, taskRepo, grantRepo, groupRepo, projectRepo, tokenRepo, sessionRepo),
+		tokenService:        apitoken.NewService(tokenRepo, userRepo),
+		auditService:        auditService,
+		ssoService:          ssoService,
+		ssoName:             cfg.Auth.OIDC.DisplayName,
+		ldapName:            cfg.Auth.LDAP.DisplayName,
+	}
+}
+
+// NewDirectoryService 根据配置创建 LDAP 认证和同步服务
+func NewDirectoryService(ldapCfg config.LDAPConfig, userRepo repository.UserRepository, sessionRepo repository.SessionRepository) *directory.Service {
+	client := ldap.NewClient(ldap.Options{
+		URL:                ldapCfg.URL,
+		StartTLS:           ldapCfg.StartTLS,
+		InsecureSkipVerify: ldapCfg.InsecureSkipVerify,
+		BindDN:             ldapCfg.BindDN,
+		BindPassword:       ldapCfg.BindPassword,
+		BaseDN:             ldapCfg.BaseDN,
+		UserFilter:         ldapCfg.UserFilter,
+		UsernameAttribute:  ldapCfg.UsernameAttribute,
+		EmailAttribute:     ldapCfg.EmailAttribute,
+		GroupAttribute:     ldapCfg.GroupAttribute,
+		GroupBaseDN:        ldapCfg.GroupBaseDN,
+		GroupFilter:        ldapCfg.GroupFilter,
+		Timeout:            ldapCfg.Timeout.Std(),
+	})
+	return directory.NewService(client, userRepo, sessionRepo, directory.Mapping{
+		RoleMapping: ldapCfg.RoleMapping,
+		DefaultRole: ldapCfg.DefaultRole,
+		AutoCreate:  ldapCfg.AutoCreate,
+		LinkByEmail: ldapCfg.LinkByEmail,
+	})
+}
+
+// currentUser 获取当前登录用户
+func currentUser(c *gin.Context) *entity.User {
+	value, _ := c.Get("user")
+	user, _ := value.(*entity.User)
+	return user
+}
+
+// currentSessionID 获取当前请求的登录会话 ID，使用个人访问令牌时为 0
+func currentSessionID(c *gin.Context) uint {
+	return c.GetUint("session_id")
+}
+
+// recordAudit 记录当前用户的操作，before 和 after 为修改前后的对象，创建时 before 为 nil，删除时 after 为 nil
+func (h *Handler) recordAudit(c *gin.Context, action, targetType string, targetID interface{}, detail string, before, after interface{}) {
+	entry := &entity.AuditLog{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		Detail:     detail,
+		Changes:    audit.Diff(before, after),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if user := currentUser(c); user != nil {
+		entry.UserID = user.ID
+		entry.Username = user.Username
+	}
+	h.auditService.Record(entry)
+}
+
+// authorizeTask 检查当前用户对任务是否拥有指定权限，没有权限时写入错误响应并返回 nil
+func (h *Handler) authorizeTask(c *gin.Context, taskID int, permission string) *entity.Task {
+	task, err := h.accessService.AuthorizeTask(currentUser(c), taskID, permission)
+	if err != nil {
+		writeAccessError(c, err)
+		return nil
+	}
+	return task
+}
+
+// writeAccessError 将权限服务返回的错误写入响应
+func writeAccessError(c *gin.Context, err error) {
+	switch err {
+	case access.ErrTaskNotFound, access.ErrProjectNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case access.ErrForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// authorizeProject 检查当前用户在项目中的角色是否不低于 role，不满足时写入错误响应并返回 nil
+func (h *Handler) authorizeProject(c *gin.Context, projectID uint, role string) *entity.Project {
+	project, err := h.accessService.AuthorizeProject(currentUser(c), projectID, role)
+	if err != nil {
+		writeAccessError(c, err)
+		return nil
+	}
+	return project
+}
+
+// authorizeCreate 检查当前用户是否可以在项目中创建资源（projectID 为空表示不属于项目），不满足时写入错误响应并返回 false
+func (h *Handler) authorizeCreate(c *gin.Context, projectID *uint) bool {
+	if err := h.accessService.AuthorizeCreate(currentUser(c), projectID); err != nil {
+		if err == access.ErrForbidden && projectID == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "需要操作员权限"})
+			return false
+		}
+		writeAccessError(c, err)
+		return false
+	}
+	return true
+}
+
+// projectSelector 读取查询参数 project_id 选择的项目，当前用户需为项目成员。
+// 未选择项目时返回 nil；参数无效或无权访问时写入错误响应并返回 false
+func (h *Handler) projectSelector(c *gin.Context) (*uint, bool) {
+	value := c.Query("project_id")
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return nil, false
+	}
+	if h.authorizeProject(c, uint(id), entity.RoleViewer) == nil {
+		return nil, false
+	}
+	projectID := uint(id)
+	return &projectID, true
+}
+
+// projectMemberships 获取当前用户在各项目中的角色，出错时写入错误响应并返回 false
+func (h *Handler) projectMemberships(c *gin.Context) (map[uint]string, bool) {
+	projects, err := h.accessService.Memberships(currentUser(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return projects, true
+}
+
+// taskScope 获取当前用户可访问的任务范围，按 project_id 参数筛选项目；出错时写入错误响应并返回 false
+func (h *Handler) taskScope(c *gin.Context) (*entity.TaskScope, bool) {
+	projectID, ok := h.projectSelector(c)
+	if !ok {
+		return nil, false
+	}
+	scope, err := h.accessService.Scope(currentUser(c), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return scope, true
+}
+
+// visibleTaskIDs 获取当前用户可以查看的任务ID，按 project_id 参数筛选项目，为 nil 时不限制；
+// 出错时写入错误响应并返回 false
+func (h *Handler) visibleTaskIDs(c *gin.Context) ([]int, bool) {
+	projectID, ok := h.projectSelector(c)
+	if !ok {
+		return nil, false
+	}
+	ids, err := h.accessService.VisibleTaskIDs(currentUser(c), projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return ids, true
+}
+
+// restrictTaskIDs 将请求的任务ID限制在可以查看的范围内。visible 为 nil 时不限制；
+// 未指定任务时返回全部可查看的任务
+func restrictTaskIDs(requested, visible []int) []int {
+	if visible == nil {
+		return requested
+	}
+	if len(requested) == 0 {
+		return visible
+	}
+
+	allowed := make(map[int]bool, len(visible))
+	for _, id := range visible {
+		allowed[id] = true
+	}
+	restricted := []int{}
+	for _, id := range requested {
+		if allowed[id] {
+			restricted = append(restricted, id)
+		}
+	}
+	return restricted
+}
+
+// scopeStatistics 将统计请求限定在当前用户可以查看的任务范围内，没有权限时写入错误响应并返回 false
+func (h *Handler) scopeStatistics(c *gin.Context, req *entity.StatisticsRequest) bool {
+	if req.TaskID != nil && h.authorizeTask(c, *req.TaskID, entity.PermissionView) == nil {
+		return false
 	}
+	ids, ok := h.visibleTaskIDs(c)
+	if !ok {
+		return false
+	}
+	req.TaskIDs = ids
+	return true
 }
 
 func (h *Handler) CreateTask(c *gin.Context) {
@@ -74,12 +352,61 @@ func (h *Handler) CreateTask(c *gin.Context) {
 		return
 	}
 
+	// 创建者成为任务所有者，管理员可以指定其他所有者
+	user := currentUser(c)
+	if task.OwnerID == nil || !user.IsAdmin() {
+		task.OwnerID = &user.ID
+	}
+
+	task.ProjectID = normalizeProjectID(task.ProjectID)
+	if !h.authorizeCreate(c, task.ProjectID) || !h.checkTaskQuota(c, task.ProjectID) {
+		return
+	}
+
 	if err := h.taskService.CreateTask(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	h.recordAudit(c, entity.AuditTaskCreate, entity.AuditTargetTask, task.ID, task.Name, nil, &task)
 
-	c.JSON(http.StatusOK, task)
+	task.Permission = entity.PermissionManage
+	c.JSON(http.StatusOK, taskWithPingToken{Task: &task, PingToken: task.PingToken})
+}
+
+// taskWithPingToken 附带心跳上报令牌的任务。令牌只在创建任务和重新生成时返回给有编辑权限的用户，
+// 任务列表和详情中不包含令牌，只读用户和被授权查看的用户无法获取
+type taskWithPingToken struct {
+	*entity.Task
+	PingToken string `json:"ping_token,omitempty"`
+}
+
+// normalizeProjectID 将请求中的项目ID 0 转换为不属于任何项目
+func normalizeProjectID(projectID *uint) *uint {
+	if projectID != nil && *projectID == 0 {
+		return nil
+	}
+	return projectID
+}
+
+// sameProject 两个项目ID是否指向同一个项目
+func sameProject(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// checkTaskQuota 检查项目的任务数配额，超出时写入错误响应并返回 false
+func (h *Handler) checkTaskQuota(c *gin.Context, projectID *uint) bool {
+	if err := h.projectService.CheckTaskQuota(projectID); err != nil {
+		if errors.Is(err, project.ErrTaskQuotaExceeded) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return false
+	}
+	return true
 }
 
 func (h *Handler) GetTask(c *gin.Context) {
@@ -89,9 +416,8 @@ func (h *Handler) GetTask(c *gin.Context) {
 		return
 	}
 
-	task, err := h.taskService.GetTask(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	task := h.authorizeTask(c, id, entity.PermissionView)
+	if task == nil {
 		return
 	}
 
@@ -99,7 +425,15 @@ func (h *Handler) GetTask(c *gin.Context) {
 }
 
 func (h *Handler) ListTasks(c *gin.Context) {
-	tasks, err := h.taskService.ListTasks()
+	scope, ok := h.taskScope(c)
+	if !ok {
+		return
+	}
+
+	tasks, err := h.taskService.ListTasks(scope)
+	if err == nil {
+		err = h.accessService.AnnotateTasks(currentUser(c), tasks)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -119,7 +453,15 @@ func (h *Handler) ListTasksWithPagination(c *gin.Context) {
 	// 设置默认值
 	paginationReq := entity.NewPaginationRequest(req.Page, req.PageSize)
 
-	response, err := h.taskService.ListTasksWithPagination(paginationReq)
+	scope, ok := h.taskScope(c)
+	if !ok {
+		return
+	}
+
+	tasks, response, err := h.taskService.ListTasksWithPagination(scope, paginationReq)
+	if err == nil {
+		err = h.accessService.AnnotateTasks(currentUser(c), tasks)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -141,11 +483,39 @@ func (h *Handler) UpdateTask(c *gin.Context) {
 		return
 	}
 
+	existing := h.authorizeTask(c, id, entity.PermissionEdit)
+	if existing == nil {
+		return
+	}
+
+	// 只有管理员可以转移任务所有者
+	if task.OwnerID == nil || !currentUser(c).IsAdmin() {
+		task.OwnerID = existing.OwnerID
+	}
+
+	// 未指定项目时保持原项目，project_id 为 0 时移出项目。移动任务需要任务的管理权限和目标项目的操作员角色
+	if task.ProjectID == nil {
+		task.ProjectID = existing.ProjectID
+	} else {
+		task.ProjectID = normalizeProjectID(task.ProjectID)
+		if !sameProject(task.ProjectID, existing.ProjectID) {
+			if !entity.PermissionAllows(existing.Permission, entity.PermissionManage) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "移动任务到其他项目需要任务的管理权限"})
+				return
+			}
+			if !h.authorizeCreate(c, task.ProjectID) || !h.checkTaskQuota(c, task.ProjectID) {
+				return
+			}
+		}
+	}
+
 	task.ID = id
+	task.Permission = existing.Permission
 	if err := h.taskService.UpdateTask(&task); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	h.recordAudit(c, entity.AuditTaskUpdate, entity.AuditTargetTask, id, task.Name, existing, &task)
 
 	c.JSON(http.StatusOK, task)
 }
@@ -157,10 +527,16 @@ func (h *Handler) DeleteTask(c *gin.Context) {
 		return
 	}
 
+	existing := h.authorizeTask(c, id, entity.PermissionManage)
+	if existing == nil {
+		return
+	}
+
 	if err := h.taskService.DeleteTask(id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.recordAudit(c, entity.AuditTaskDelete, entity.AuditTargetTask, id, existing.Name, existing, nil)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
 }
@@ -173,6 +549,10 @@ func (h *Handler) GetTaskLogs(c *gin.Context) {
 		return
 	}
 
+	if h.authorizeTask(c, taskID, entity.PermissionView) == nil {
+		return
+	}
+
 	logs, err := h.taskService.GetTaskLogs(taskID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -182,590 +562,2866 @@ func (h *Handler) GetTaskLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, logs)
 }
 
-// GetTaskLogsWithPagination 分页获取任务执行日志
-func (h *Handler) GetTaskLogsWithPagination(c *gin.Context) {
+// GetTaskAlertState 获取任务的告警状态
+func (h *Handler) GetTaskAlertState(c *gin.Context) {
 	taskID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	var req entity.PaginationRequest
-	if err := c.ShouldBindQuery(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if h.authorizeTask(c, taskID, entity.PermissionView) == nil {
 		return
 	}
 
-	// 设置默认值
-	paginationReq := entity.NewPaginationRequest(req.Page, req.PageSize)
-
-	response, err := h.taskService.GetTaskLogsWithPagination(taskID, paginationReq)
+	state, err := h.taskService.GetAlertState(taskID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, state)
 }
 
-func (h *Handler) GetSystemStats(c *gin.Context) {
-	// 返回实时系统统计数据
-	stats, err := h.systemService.GetRealTimeStats()
+// AcknowledgeTaskAlert 确认任务的告警，停止升级
+func (h *Handler) AcknowledgeTaskAlert(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	// 对百分比字段保留两位小数
-	stats.CPUUsage = float64(int(stats.CPUUsage*100)) / 100
-	stats.MemoryUsage = float64(int(stats.MemoryUsage*100)) / 100
-	stats.DiskUsage = float64(int(stats.DiskUsage*100)) / 100
-
-	c.JSON(http.StatusOK, stats)
-}
-
-// Login 用户登录
-func (h *Handler) Login(c *gin.Context) {
-	var req entity.LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if h.authorizeTask(c, taskID, entity.PermissionExecute) == nil {
 		return
 	}
 
-	response, err := h.authService.Login(&req)
+	state, err := h.taskService.AcknowledgeAlert(taskID, currentUser(c).Username)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		if err == service.ErrAlertNotFiring {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	c.JSON(http.StatusOK, state)
 }
 
-// Register 用户注册
-func (h *Handler) Register(c *gin.Context) {
-	var req entity.RegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// GetTaskHeartbeat 获取心跳任务的监控状态
+func (h *Handler) GetTaskHeartbeat(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	user, err := h.authService.Register(&req)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if h.authorizeTask(c, taskID, entity.PermissionView) == nil {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "注册成功", "user": user})
-}
-
-// GetCurrentUser 获取当前用户信息
-func (h *Handler) GetCurrentUser(c *gin.Context) {
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+	heartbeat, err := h.taskService.GetHeartbeat(taskID)
+	if err != nil {
+		if err == service.ErrNotHeartbeatTask {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusOK, heartbeat)
 }
 
-// ExecuteTask 立即执行任务
-func (h *Handler) ExecuteTask(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// RegeneratePingToken 重新生成心跳任务的上报令牌
+func (h *Handler) RegeneratePingToken(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
 		return
 	}
 
-	if err := h.taskService.ExecuteTask(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if h.authorizeTask(c, taskID, entity.PermissionEdit) == nil {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task executed successfully"})
-}
-
-// GetAllLogs 获取所有任务执行日志
-func (h *Handler) GetAllLogs(c *gin.Context) {
-	logs, err := h.taskService.GetAllLogs()
+	task, err := h.taskService.RegeneratePingToken(taskID)
 	if err != nil {
+		if err == service.ErrNotHeartbeatTask {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, logs)
+	c.JSON(http.StatusOK, taskWithPingToken{Task: task, PingToken: task.PingToken})
 }
 
-// GetAllLogsWithPagination 分页获取所有任务执行日志
-func (h *Handler) GetAllLogsWithPagination(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "10"))
+// maxPingOutput 心跳上报输出的最大长度，超出部分截断
+const maxPingOutput = 64 * 1024
 
-	// 确保页码和每页大小有效
-	if page <= 0 {
-		page = 1
-	}
-	if pageSize <= 0 {
-		pageSize = 10
+// Ping 接收心跳任务的上报，state 查询参数为 start、success（默认）或 fail，请求体作为任务输出
+func (h *Handler) Ping(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPingOutput))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	logs, total, err := h.taskService.GetAllLogsWithPagination(page, pageSize)
+	taskLog, err := h.taskService.Ping(c.Param("token"), c.Query("state"), string(body))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch err {
+		case task.ErrPingTokenNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case service.ErrInvalidHeartbeatState:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"logs":     logs,
-		"total":    total,
-		"page":     page,
-		"pageSize": pageSize,
-	})
+	response := gin.H{"message": "ok"}
+	if taskLog != nil {
+		response["log_id"] = taskLog.ID
+	}
+	c.JSON(http.StatusOK, response)
 }
 
-// TestNotification 测试通知配置
-func (h *Handler) TestNotification(c *gin.Context) {
-	var req struct {
-		NotificationTypes  []string                  `json:"notification_types"`
-		NotificationConfig entity.NotificationConfig `json:"notification_config"`
+// ResetTaskAlertState 清除任务的告警状态
+func (h *Handler) ResetTaskAlertState(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if h.authorizeTask(c, taskID, entity.PermissionExecute) == nil {
 		return
 	}
 
-	// 创建测试消息
-	message := &entity.NotificationMessage{
-		TaskName:  "测试任务",
-		Success:   true,
-		StartTime: time.Now().Add(-time.Minute).Format("2006-01-02 15:04:05"),
-		EndTime:   time.Now().Format("2006-01-02 15:04:05"),
-		Duration:  "1m0s",
-		Output:    "这是一条测试通知消息",
+	if err := h.taskService.ResetAlertState(taskID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	// 发送测试通知
-	notificationService := service.NewNotificationService()
-	notificationService.SendNotification(&req.NotificationConfig, message, req.NotificationTypes)
-
-	c.JSON(http.StatusOK, gin.H{"message": "测试通知已发送"})
+	c.JSON(http.StatusOK, gin.H{"message": "告警状态已重置"})
 }
 
-// GetTaskStatistics 获取任务统计信息
-func (h *Handler) GetTaskStatistics(c *gin.Context) {
-	req := entity.NewStatisticsRequest()
+// GetTaskDeliveries 获取任务最近的通知发送记录
+func (h *Handler) GetTaskDeliveries(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
 
-	// 解析查询参数
-	if days := c.Query("days"); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			req.Days = d
-		}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须在 1-500 之间"})
+		return
 	}
 
-	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
-		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
-			req.TaskID = &taskID
-		}
+	if h.authorizeTask(c, taskID, entity.PermissionView) == nil {
+		return
 	}
 
-	statistics, err := h.statisticsService.GetTaskStatistics(req)
+	deliveries, err := h.taskService.GetTaskDeliveries(taskID, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, statistics)
+	c.JSON(http.StatusOK, deliveries)
 }
 
-// GetTaskStatisticsByID 获取特定任务的统计信息
-func (h *Handler) GetTaskStatisticsByID(c *gin.Context) {
-	taskID, err := strconv.Atoi(c.Param("id"))
+// GetLogDeliveries 获取一次任务执行的通知发送记录
+func (h *Handler) GetLogDeliveries(c *gin.Context) {
+	logID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid log ID"})
 		return
 	}
 
-	req := entity.NewStatisticsRequest()
-	if days := c.Query("days"); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			req.Days = d
-		}
-	}
-
-	statistics, err := h.statisticsService.GetTaskStatisticsByID(taskID, req)
+	deliveries, err := h.taskService.GetLogDeliveries(uint(logID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if len(deliveries) > 0 && h.authorizeTask(c, deliveries[0].TaskID, entity.PermissionView) == nil {
+		return
+	}
 
-	c.JSON(http.StatusOK, statistics)
+	c.JSON(http.StatusOK, deliveries)
 }
 
-// GetExecutionTrends 获取执行趋势数据
-func (h *Handler) GetExecutionTrends(c *gin.Context) {
-	req := entity.NewStatisticsRequest()
+// RetryDelivery 重新发送失败的通知
+func (h *Handler) RetryDelivery(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
 
-	// 解析查询参数
-	if days := c.Query("days"); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+	// 任务通知需要任务的执行权限，报表摘要等非任务通知只有管理员可以重发
+	existing, err := h.taskService.GetDelivery(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if existing.TaskID == 0 && !currentUser(c).IsAdmin() {
+		c.JSON(http.StatusForbidden, gin.H{"error": access.ErrForbidden.Error()})
+		return
+	}
+	if existing.TaskID != 0 && h.authorizeTask(c, existing.TaskID, entity.PermissionExecute) == nil {
+		return
+	}
+
+	delivery, err := h.taskService.RetryDelivery(uint(id))
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// GetTaskLogsWithPagination 分页获取任务执行日志
+func (h *Handler) GetTaskLogsWithPagination(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var req entity.PaginationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 设置默认值
+	paginationReq := entity.NewPaginationRequest(req.Page, req.PageSize)
+
+	if h.authorizeTask(c, taskID, entity.PermissionView) == nil {
+		return
+	}
+
+	response, err := h.taskService.GetTaskLogsWithPagination(taskID, paginationReq)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *Handler) GetSystemStats(c *gin.Context) {
+	// 返回实时系统统计数据
+	stats, err := h.systemService.GetRealTimeStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 对百分比字段保留两位小数
+	stats.CPUUsage = float64(int(stats.CPUUsage*100)) / 100
+	stats.MemoryUsage = float64(int(stats.MemoryUsage*100)) / 100
+	stats.DiskUsage = float64(int(stats.DiskUsage*100)) / 100
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// Login 用户登录
+func (h *Handler) Login(c *gin.Context) {
+	var req entity.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.Login(&req, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		var locked *auth.LockedError
+		if errors.As(err, &locked) {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(locked.Until).Seconds())+1))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		switch err {
+		case auth.ErrTwoFactorRequired, auth.ErrInvalidTwoFactorCode:
+			// 前端据此显示验证码输入框
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "two_factor_required": true})
+		case auth.ErrLocalLoginDisabled:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case directory.ErrUnavailable:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RefreshToken 使用刷新令牌获取新的访问令牌和刷新令牌
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req entity.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.Refresh(req.RefreshToken, c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout 注销当前会话
+func (h *Handler) Logout(c *gin.Context) {
+	sessionID := currentSessionID(c)
+	if sessionID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "个人访问令牌请通过撤销令牌停用"})
+		return
+	}
+
+	if err := h.authService.Logout(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// LogoutAll 注销当前用户的所有会话
+func (h *Handler) LogoutAll(c *gin.Context) {
+	if err := h.authService.LogoutAll(currentUser(c).ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出所有会话"})
+}
+
+// ListSessions 获取当前用户的登录会话
+func (h *Handler) ListSessions(c *gin.Context) {
+	sessions, err := h.authService.ListSessions(currentUser(c).ID, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession 注销当前用户的某个会话
+func (h *Handler) RevokeSession(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := h.authService.RevokeSession(currentUser(c).ID, uint(id)); err != nil {
+		if err == auth.ErrSessionNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "会话已注销"})
+}
+
+// RevokeUserSessions 注销用户的所有会话，强制其重新登录
+func (h *Handler) RevokeUserSessions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	if err := h.authService.LogoutAll(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditUserRevokeSessions, entity.AuditTargetUser, id, user.Username, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "已注销该用户的所有会话"})
+}
+
+// Register 用户注册
+func (h *Handler) Register(c *gin.Context) {
+	var req entity.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.authService.Register(&req)
+	if err != nil {
+		if err == auth.ErrRegistrationDisabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "注册成功"
+	if user.PendingApproval {
+		message = "注册成功，请等待管理员审核"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "user": user})
+}
+
+// GetAuthProviders 获取可用的登录方式，登录页据此显示账号密码表单和单点登录按钮。
+// 禁用本地登录但配置了 LDAP 时仍显示账号密码表单
+func (h *Handler) GetAuthProviders(c *gin.Context) {
+	oidcInfo := gin.H{"enabled": h.ssoService != nil}
+	if h.ssoService != nil {
+		oidcInfo["name"] = h.ssoName
+		oidcInfo["login_url"] = "/api/v1/auth/oidc/login"
+	}
+	ldapEnabled := h.authService.PasswordProvider() == entity.AuthProviderLDAP
+	ldapInfo := gin.H{"enabled": ldapEnabled}
+	if ldapEnabled {
+		ldapInfo["name"] = h.ldapName
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"local_login":  h.authService.LocalLoginEnabled(),
+		"registration": h.authService.RegistrationMode(),
+		"oidc":         oidcInfo,
+		"ldap":         ldapInfo,
+	})
+}
+
+// OIDCLogin 跳转到身份提供方登录，redirect 参数为登录后返回的页面
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	if h.ssoService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未配置单点登录"})
+		return
+	}
+
+	redirect := c.Query("redirect")
+	// 只允许站内地址，避免被用作开放重定向
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		redirect = "/"
+	}
+
+	authURL, err := h.ssoService.Begin(c.Request.Context(), redirect)
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "连接身份提供方失败"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 身份提供方登录后的回调，完成登录后带一次性登录码跳转回前端登录页
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	if h.ssoService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未配置单点登录"})
+		return
+	}
+
+	query := url.Values{}
+	if idpError := c.Query("error"); idpError != "" {
+		description := c.Query("error_description")
+		if description == "" {
+			description = idpError
+		}
+		query.Set("sso_error", description)
+		c.Redirect(http.StatusFound, "/login?"+query.Encode())
+		return
+	}
+
+	code, redirect, err := h.ssoService.Callback(c.Request.Context(), c.Query("code"), c.Query("state"), c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		query.Set("sso_error", err.Error())
+	} else {
+		query.Set("sso_code", code)
+		query.Set("redirect", redirect)
+	}
+	c.Redirect(http.StatusFound, "/login?"+query.Encode())
+}
+
+// OIDCExchange 用一次性登录码换取访问令牌和刷新令牌
+func (h *Handler) OIDCExchange(c *gin.Context) {
+	if h.ssoService == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "未配置单点登录"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.ssoService.Redeem(req.Code)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetRegistrationMode 获取注册方式
+func (h *Handler) GetRegistrationMode(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"mode": h.authService.RegistrationMode()})
+}
+
+// GetCurrentUser 获取当前用户信息
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword 修改当前用户的密码
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req entity.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.ChangePassword(currentUser(c), currentSessionID(c), req.OldPassword, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已修改"})
+}
+
+// GetTwoFactorStatus 获取当前用户的两步验证状态
+func (h *Handler) GetTwoFactorStatus(c *gin.Context) {
+	user := currentUser(c)
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TOTPEnabled,
+		"recovery_codes_remaining": auth.RecoveryCodesRemaining(user),
+	})
+}
+
+// SetupTwoFactor 生成两步验证密钥，返回用于验证器应用的密钥和 otpauth 地址
+func (h *Handler) SetupTwoFactor(c *gin.Context) {
+	if !requireInteractiveLogin(c) {
+		return
+	}
+
+	setup, err := h.authService.SetupTOTP(currentUser(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// EnableTwoFactor 输入验证器应用生成的验证码启用两步验证，返回恢复码
+func (h *Handler) EnableTwoFactor(c *gin.Context) {
+	if !requireInteractiveLogin(c) {
+		return
+	}
+
+	var req entity.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.authService.EnableTOTP(currentUser(c), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已启用两步验证，请妥善保存恢复码", "recovery_codes": codes})
+}
+
+// DisableTwoFactor 使用验证码或恢复码确认后停用两步验证
+func (h *Handler) DisableTwoFactor(c *gin.Context) {
+	if !requireInteractiveLogin(c) {
+		return
+	}
+
+	var req entity.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.DisableTOTP(currentUser(c), req.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已停用两步验证"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，之前的恢复码失效
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	if !requireInteractiveLogin(c) {
+		return
+	}
+
+	var req entity.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(currentUser(c), req.Code)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// requireInteractiveLogin 两步验证设置只能在登录后操作，不能使用个人访问令牌，否则写入 403 响应并返回 false
+func requireInteractiveLogin(c *gin.Context) bool {
+	if _, ok := c.Get("api_token"); ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "请登录后设置两步验证"})
+		return false
+	}
+	return true
+}
+
+// ExecuteTask 立即执行任务
+func (h *Handler) ExecuteTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	existing := h.authorizeTask(c, id, entity.PermissionExecute)
+	if existing == nil {
+		return
+	}
+
+	if err := h.taskService.ExecuteTask(id); err != nil {
+		if err == task.ErrHeartbeatNotExecutable {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrProjectConcurrencyLimit) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditTaskExecute, entity.AuditTargetTask, id, existing.Name, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task executed successfully"})
+}
+
+// CancelTask 取消任务正在执行的运行，需要任务的 execute 权限
+func (h *Handler) CancelTask(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	existing := h.authorizeTask(c, id, entity.PermissionExecute)
+	if existing == nil {
+		return
+	}
+
+	cancelled, err := h.taskService.CancelTask(id)
+	if err != nil {
+		if err == service.ErrTaskNotRunning {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditTaskCancel, entity.AuditTargetTask, id, existing.Name, nil, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task cancelled", "cancelled_runs": cancelled})
+}
+
+// GetAllLogs 获取最近的执行日志（默认 100 条，最多 1000 条），只返回当前用户可以查看的任务的日志。完整历史请使用分页搜索
+func (h *Handler) GetAllLogs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须在 1-1000 之间"})
+		return
+	}
+
+	visible, ok := h.visibleTaskIDs(c)
+	if !ok {
+		return
+	}
+
+	logs, err := h.taskService.GetAllLogs(visible, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, logs)
+}
+
+// GetAllLogsWithPagination 分页搜索任务执行日志
+func (h *Handler) GetAllLogsWithPagination(c *gin.Context) {
+	req, err := parseTaskLogSearchRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visible, ok := h.visibleTaskIDs(c)
+	if !ok {
+		return
+	}
+	req.TaskIDs = restrictTaskIDs(req.TaskIDs, visible)
+
+	result, err := h.taskService.SearchLogs(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportLogs 导出任务执行日志，筛选条件与日志搜索相同
+func (h *Handler) ExportLogs(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.ValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return
+	}
+
+	req, err := parseTaskLogSearchRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	visible, ok := h.visibleTaskIDs(c)
+	if !ok {
+		return
+	}
+	req.TaskIDs = restrictTaskIDs(req.TaskIDs, visible)
+
+	setExportHeaders(c, "task_logs", format)
+	if err := h.exportService.ExportLogs(c.Writer, format, req); err != nil {
+		handleExportError(c, err)
+	}
+}
+
+// ExportStatistics 导出统计数据，type 为 tasks（任务统计，默认）或 report（执行报表）
+func (h *Handler) ExportStatistics(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if !export.ValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出格式: " + format})
+		return
+	}
+
+	req := entity.NewStatisticsRequest()
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
+			req.TaskID = &taskID
+		}
+	}
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	var exportFunc func() error
+	switch exportType := c.DefaultQuery("type", "tasks"); exportType {
+	case "tasks":
+		setExportHeaders(c, "task_statistics", format)
+		exportFunc = func() error { return h.exportService.ExportTaskStatistics(c.Writer, format, req) }
+	case "report":
+		setExportHeaders(c, "task_report", format)
+		exportFunc = func() error { return h.exportService.ExportReport(c.Writer, format, req) }
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的导出类型: " + exportType})
+		return
+	}
+
+	if err := exportFunc(); err != nil {
+		handleExportError(c, err)
+	}
+}
+
+// setExportHeaders 设置导出文件的响应头
+func setExportHeaders(c *gin.Context, name, format string) {
+	filename := fmt.Sprintf("%s_%s.%s", name, time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Type", export.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+}
+
+// handleExportError 处理导出错误：尚未写出数据时返回错误信息，否则只能中断响应
+func handleExportError(c *gin.Context, err error) {
+	log.Printf("Failed to export data: %v", err)
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Abort()
+}
+
+// parseTaskLogSearchRequest 解析日志搜索的查询参数
+func parseTaskLogSearchRequest(c *gin.Context) (*entity.TaskLogSearchRequest, error) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", c.DefaultQuery("page_size", "10")))
+
+	// 确保页码和每页大小有效
+	paginationReq := entity.NewPaginationRequest(page, pageSize)
+
+	req := &entity.TaskLogSearchRequest{
+		SortBy:   entity.LogSortByStartTime,
+		SortDesc: true,
+		Page:     paginationReq.Page,
+		PageSize: paginationReq.PageSize,
+	}
+
+	// 任务ID，支持 task_id=1&task_id=2 或 task_ids=1,2
+	var taskIDs []string
+	taskIDs = append(taskIDs, c.QueryArray("task_id")...)
+	if ids := c.Query("task_ids"); ids != "" {
+		taskIDs = append(taskIDs, strings.Split(ids, ",")...)
+	}
+	for _, idStr := range taskIDs {
+		id, err := strconv.Atoi(strings.TrimSpace(idStr))
+		if err != nil {
+			return nil, fmt.Errorf("无效的任务ID: %s", idStr)
+		}
+		req.TaskIDs = append(req.TaskIDs, id)
+	}
+
+	switch c.Query("status") {
+	case "":
+	case "success":
+		success := true
+		req.Success = &success
+	case "failure", "failed":
+		success := false
+		req.Success = &success
+	default:
+		return nil, fmt.Errorf("无效的状态: %s", c.Query("status"))
+	}
+
+	req.TriggerSource = c.Query("trigger")
+	req.Keyword = strings.TrimSpace(c.Query("q"))
+
+	for _, param := range []struct {
+		name  string
+		value **time.Time
+	}{{"start_time", &req.StartTime}, {"end_time", &req.EndTime}} {
+		if value := c.Query(param.name); value != "" {
+			t, err := parseQueryTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("无效的时间 %s: %s", param.name, value)
+			}
+			*param.value = &t
+		}
+	}
+
+	for _, param := range []struct {
+		name  string
+		value **float64
+	}{{"min_duration", &req.MinDuration}, {"max_duration", &req.MaxDuration}} {
+		if value := c.Query(param.name); value != "" {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("无效的执行时间 %s: %s", param.name, value)
+			}
+			*param.value = &v
+		}
+	}
+
+	switch sortBy := c.Query("sort_by"); sortBy {
+	case "":
+	case entity.LogSortByStartTime, entity.LogSortByDuration, entity.LogSortByID:
+		req.SortBy = sortBy
+	default:
+		return nil, fmt.Errorf("无效的排序字段: %s", sortBy)
+	}
+
+	switch order := c.Query("sort_order"); order {
+	case "", "desc":
+	case "asc":
+		req.SortDesc = false
+	default:
+		return nil, fmt.Errorf("无效的排序方向: %s", order)
+	}
+
+	if cursorStr := c.Query("cursor"); cursorStr != "" {
+		cursor, err := entity.DecodeTaskLogCursor(cursorStr)
+		if err != nil {
+			return nil, err
+		}
+		// 游标决定排序方式，保证翻页过程中顺序一致
+		req.SortBy = cursor.SortBy
+		req.SortDesc = cursor.SortDesc
+		req.Cursor = cursor
+	}
+
+	return req, nil
+}
+
+// parseQueryTime 解析查询参数中的时间，支持 RFC3339、"2006-01-02 15:04:05" 和 "2006-01-02"。
+// 返回本地时区的时间：SQLite 以文本保存本地时区的时间并按字符串比较，带其他时区的时间需要先转换
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(time.Local), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", value)
+}
+
+// TestNotification 测试通知配置
+func (h *Handler) TestNotification(c *gin.Context) {
+	var req struct {
+		NotificationTypes  []string                  `json:"notification_types"`
+		NotificationConfig entity.NotificationConfig `json:"notification_config"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(req.NotificationTypes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择通知类型"})
+		return
+	}
+
+	// 发送测试通知，返回每种通知类型的结果
+	results := make(map[string]string)
+	failed := false
+	for notificationType, err := range h.notificationService.TestNotification(req.NotificationConfig, req.NotificationTypes) {
+		if err != nil {
+			results[notificationType] = err.Error()
+			failed = true
+		} else {
+			results[notificationType] = "ok"
+		}
+	}
+
+	if failed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "测试通知发送失败", "results": results})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "测试通知已发送", "results": results})
+}
+
+// PreviewNotification 使用示例数据预览通知消息模板
+func (h *Handler) PreviewNotification(c *gin.Context) {
+	var req struct {
+		ChannelID   int                    `json:"channel_id"`   // 指定时使用该渠道的类型、语言和模板
+		ChannelType string                 `json:"channel_type"` // 未指定渠道时的渠道类型
+		Language    string                 `json:"language"`
+		Template    entity.MessageTemplate `json:"template"` // 待预览的模板，优先于渠道模板
+		Success     *bool                  `json:"success"`  // 预览成功或失败的执行，默认失败
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ChannelID != 0 && h.authorizeChannel(c, req.ChannelID, false) == nil {
+		return
+	}
+
+	success := req.Success != nil && *req.Success
+	preview, err := h.channelService.PreviewMessage(req.ChannelID, req.ChannelType, req.Language, req.Template, success)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// ListNotifiers 获取可用的通知渠道及其配置项
+func (h *Handler) ListNotifiers(c *gin.Context) {
+	c.JSON(http.StatusOK, h.notificationService.Notifiers())
+}
+
+// GetTaskStatistics 获取任务统计信息
+func (h *Handler) GetTaskStatistics(c *gin.Context) {
+	req := entity.NewStatisticsRequest()
+
+	// 解析查询参数
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
+			req.TaskID = &taskID
+		}
+	}
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	statistics, err := h.statisticsService.GetTaskStatistics(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, statistics)
+}
+
+// GetTaskStatisticsByID 获取特定任务的统计信息
+func (h *Handler) GetTaskStatisticsByID(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	req := entity.NewStatisticsRequest()
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if h.authorizeTask(c, taskID, entity.PermissionView) == nil {
+		return
+	}
+
+	statistics, err := h.statisticsService.GetTaskStatisticsByID(taskID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, statistics)
+}
+
+// GetExecutionTrends 获取执行趋势数据
+func (h *Handler) GetExecutionTrends(c *gin.Context) {
+	req := entity.NewStatisticsRequest()
+
+	// 解析查询参数
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
+			req.TaskID = &taskID
+		}
+	}
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	trends, err := h.statisticsService.GetExecutionTrends(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trends)
+}
+
+// GetTaskExecutionReport 获取任务执行报表
+func (h *Handler) GetTaskExecutionReport(c *gin.Context) {
+	req := entity.NewStatisticsRequest()
+
+	// 解析查询参数
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	report, err := h.statisticsService.GetTaskExecutionReport(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetTaskPerformanceMetrics 获取任务性能指标
+func (h *Handler) GetTaskPerformanceMetrics(c *gin.Context) {
+	req := entity.NewStatisticsRequest()
+
+	// 解析查询参数
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
+			req.TaskID = &taskID
+		}
+	}
+
+	parseAnomalyConfig(c, req.Anomaly)
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	metrics, err := h.statisticsService.GetTaskPerformanceMetrics(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// GetAnomalies 获取异常执行
+func (h *Handler) GetAnomalies(c *gin.Context) {
+	req := entity.NewStatisticsRequest()
+
+	// 解析查询参数
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
+			req.Days = d
+		}
+	}
+
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
+			req.TaskID = &taskID
+		}
+	}
+
+	parseAnomalyConfig(c, req.Anomaly)
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	anomalies, err := h.statisticsService.GetAnomalies(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, anomalies)
+}
+
+// parseAnomalyConfig 解析异常检测相关的查询参数
+func parseAnomalyConfig(c *gin.Context, config *entity.AnomalyConfig) {
+	if sigma := c.Query("sigma"); sigma != "" {
+		if v, err := strconv.ParseFloat(sigma, 64); err == nil && v > 0 {
+			config.Sigma = v
+		}
+	}
+
+	if baselineDays := c.Query("baseline_days"); baselineDays != "" {
+		if v, err := strconv.Atoi(baselineDays); err == nil && v > 0 {
+			config.BaselineDays = v
+		}
+	}
+
+	if minSamples := c.Query("min_samples"); minSamples != "" {
+		if v, err := strconv.ParseInt(minSamples, 10, 64); err == nil && v > 0 {
+			config.MinSamples = v
+		}
+	}
+}
+
+// GetHourlyExecutionStats 获取小时执行统计
+func (h *Handler) GetHourlyExecutionStats(c *gin.Context) {
+	req := entity.NewStatisticsRequest()
+
+	// 解析查询参数
+	if days := c.Query("days"); days != "" {
+		if d, err := strconv.Atoi(days); err == nil && d > 0 {
 			req.Days = d
 		}
 	}
 
-	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
-		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
-			req.TaskID = &taskID
+	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
+		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
+			req.TaskID = &taskID
+		}
+	}
+
+	if !h.scopeStatistics(c, req) {
+		return
+	}
+
+	stats, err := h.statisticsService.GetHourlyExecutionStats(req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// 任务模板相关接口
+
+// CreateTemplate 创建任务模板
+func (h *Handler) CreateTemplate(c *gin.Context) {
+	var template entity.TaskTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template.CreatedBy = int(currentUser(c).ID)
+	template.ProjectID = normalizeProjectID(template.ProjectID)
+	if !h.authorizeCreate(c, template.ProjectID) {
+		return
+	}
+
+	if err := h.templateService.CreateTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditTemplateCreate, entity.AuditTargetTemplate, template.ID, template.Name, nil, &template)
+
+	c.JSON(http.StatusOK, template)
+}
+
+// GetTemplate 获取任务模板
+func (h *Handler) GetTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return
+	}
+
+	template, err := h.templateService.GetTemplate(id)
+	if err != nil || !access.CanViewTemplate(currentUser(c), projects, template) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// ListTemplates 获取模板列表
+func (h *Handler) ListTemplates(c *gin.Context) {
+	templates, err := h.templateService.ListTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	projectID, ok := h.projectSelector(c)
+	if !ok {
+		return
+	}
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return
+	}
+
+	// 只返回公共模板、自己创建的模板和所属项目中的模板，可按项目筛选
+	user := currentUser(c)
+	visible := []*entity.TaskTemplate{}
+	for _, template := range templates {
+		if projectID != nil && !sameProject(template.ProjectID, projectID) {
+			continue
+		}
+		if access.CanViewTemplate(user, projects, template) {
+			visible = append(visible, template)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// ListPublicTemplates 获取公共模板列表
+func (h *Handler) ListPublicTemplates(c *gin.Context) {
+	templates, err := h.templateService.ListPublicTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// ListMyTemplates 获取我的模板列表
+func (h *Handler) ListMyTemplates(c *gin.Context) {
+	templates, err := h.templateService.ListMyTemplates(int(currentUser(c).ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// SearchTemplates 搜索模板
+func (h *Handler) SearchTemplates(c *gin.Context) {
+	var req entity.TaskTemplateSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 设置默认值
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 10
+	}
+
+	projectID, ok := h.projectSelector(c)
+	if !ok {
+		return
+	}
+	req.ProjectID = projectID
+
+	// 非管理员只能搜索到公共模板、自己创建的模板和所属项目中的模板
+	if user := currentUser(c); !user.IsAdmin() {
+		projects, ok := h.projectMemberships(c)
+		if !ok {
+			return
+		}
+		userID := int(user.ID)
+		req.VisibleTo = &userID
+		req.VisibleProjects = []uint{}
+		for id := range projects {
+			req.VisibleProjects = append(req.VisibleProjects, id)
+		}
+	}
+
+	templates, total, err := h.templateService.SearchTemplates(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":      templates,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	})
+}
+
+// UpdateTemplate 更新任务模板
+func (h *Handler) UpdateTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	var template entity.TaskTemplate
+	if err := c.ShouldBindJSON(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing := h.authorizeTemplate(c, id)
+	if existing == nil {
+		return
+	}
+
+	template.ID = id
+	if err := h.templateService.UpdateTemplate(&template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditTemplateUpdate, entity.AuditTargetTemplate, id, template.Name, existing, &template)
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate 删除任务模板
+func (h *Handler) DeleteTemplate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return
+	}
+
+	existing := h.authorizeTemplate(c, id)
+	if existing == nil {
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditTemplateDelete, entity.AuditTargetTemplate, id, existing.Name, existing, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// authorizeTemplate 检查当前用户是否可以修改或删除模板，不可以时写入错误响应并返回 nil
+func (h *Handler) authorizeTemplate(c *gin.Context, id int) *entity.TaskTemplate {
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return nil
+	}
+
+	user := currentUser(c)
+	template, err := h.templateService.GetTemplate(id)
+	if err != nil || !access.CanViewTemplate(user, projects, template) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return nil
+	}
+	if !access.CanManageTemplate(user, projects, template) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有模板创建者、项目管理员和管理员可以修改模板"})
+		return nil
+	}
+	return template
+}
+
+// GetPopularTemplates 获取热门模板
+func (h *Handler) GetPopularTemplates(c *gin.Context) {
+	limit := 10
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	templates, err := h.templateService.GetPopularTemplates(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// CreateTaskFromTemplate 从模板创建任务
+func (h *Handler) CreateTaskFromTemplate(c *gin.Context) {
+	var req entity.CreateTaskFromTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return
+	}
+	template, err := h.templateService.GetTemplate(req.TemplateID)
+	if err != nil || !access.CanViewTemplate(currentUser(c), projects, template) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	// 未指定项目时创建到模板所属的项目
+	if req.ProjectID == nil {
+		req.ProjectID = template.ProjectID
+	}
+	req.ProjectID = normalizeProjectID(req.ProjectID)
+	if !h.authorizeCreate(c, req.ProjectID) || !h.checkTaskQuota(c, req.ProjectID) {
+		return
+	}
+
+	task, err := h.templateService.CreateTaskFromTemplate(&req, int(currentUser(c).ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditTaskCreate, entity.AuditTargetTask, task.ID, fmt.Sprintf("%s（从模板 %s 创建）", task.Name, template.Name), nil, task)
+
+	c.JSON(http.StatusOK, task)
+}
+
+// GetTemplateStats 获取模板统计
+func (h *Handler) GetTemplateStats(c *gin.Context) {
+	stats, err := h.templateService.GetTemplateStats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// 模板分类相关接口
+
+// CreateCategory 创建模板分类
+func (h *Handler) CreateCategory(c *gin.Context) {
+	var category entity.TaskTemplateCategory
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.templateService.CreateCategory(&category); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditCategoryCreate, entity.AuditTargetCategory, category.ID, category.Name, nil, &category)
+
+	c.JSON(http.StatusOK, category)
+}
+
+// ListCategories 获取分类列表
+func (h *Handler) ListCategories(c *gin.Context) {
+	categories, err := h.templateService.ListCategories()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// UpdateCategory 更新分类
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	var category entity.TaskTemplateCategory
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := h.templateService.GetCategory(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	category.ID = id
+	if err := h.templateService.UpdateCategory(&category); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditCategoryUpdate, entity.AuditTargetCategory, id, category.Name, existing, &category)
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory 删除分类
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		return
+	}
+
+	existing, err := h.templateService.GetCategory(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+		return
+	}
+
+	if err := h.templateService.DeleteCategory(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditCategoryDelete, entity.AuditTargetCategory, id, existing.Name, existing, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+}
+
+// CreateDigest 创建报表摘要
+func (h *Handler) CreateDigest(c *gin.Context) {
+	var digest entity.ReportDigest
+	if err := c.ShouldBindJSON(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.digestService.CreateDigest(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// ListDigests 获取报表摘要列表
+func (h *Handler) ListDigests(c *gin.Context) {
+	digests, err := h.digestService.ListDigests()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, digests)
+}
+
+// GetDigest 获取报表摘要
+func (h *Handler) GetDigest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	digest, err := h.digestService.GetDigest(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Digest not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// UpdateDigest 更新报表摘要
+func (h *Handler) UpdateDigest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	var digest entity.ReportDigest
+	if err := c.ShouldBindJSON(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	digest.ID = id
+	if err := h.digestService.UpdateDigest(&digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// DeleteDigest 删除报表摘要
+func (h *Handler) DeleteDigest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	if err := h.digestService.DeleteDigest(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Digest deleted successfully"})
+}
+
+// PreviewDigest 预览截至当前的报表摘要内容
+func (h *Handler) PreviewDigest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	report, err := h.digestService.PreviewDigest(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// SendDigest 立即发送报表摘要
+func (h *Handler) SendDigest(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest ID"})
+		return
+	}
+
+	report, err := h.digestService.SendDigest(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "报表摘要已发送", "report": report})
+}
+
+// CreateChannel 创建通知渠道
+func (h *Handler) CreateChannel(c *gin.Context) {
+	var channel entity.NotificationChannel
+	if err := c.ShouldBindJSON(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel.ID = 0
+	channel.ProjectID = normalizeProjectID(channel.ProjectID)
+	if !h.authorizeCreate(c, channel.ProjectID) {
+		return
+	}
+
+	if err := h.channelService.CreateChannel(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditChannelCreate, entity.AuditTargetChannel, channel.ID, channel.Name, nil, &channel)
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// ListChannels 获取通知渠道列表，只返回全局渠道和所属项目的渠道，可按项目筛选
+func (h *Handler) ListChannels(c *gin.Context) {
+	projectID, ok := h.projectSelector(c)
+	if !ok {
+		return
+	}
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return
+	}
+
+	channels, err := h.channelService.ListChannels()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	visible := []*entity.NotificationChannel{}
+	for _, ch := range channels {
+		if projectID != nil && !sameProject(ch.ProjectID, projectID) {
+			continue
+		}
+		if access.CanViewChannel(user, projects, ch) {
+			visible = append(visible, ch)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// authorizeChannel 检查当前用户是否可以查看通知渠道，manage 为 true 时检查是否可以修改；
+// 可以时返回敏感字段已掩码的渠道，不可以时写入错误响应并返回 nil
+func (h *Handler) authorizeChannel(c *gin.Context, id int, manage bool) *entity.NotificationChannel {
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return nil
+	}
+
+	user := currentUser(c)
+	ch, err := h.channelService.GetChannel(id)
+	if err != nil || !access.CanViewChannel(user, projects, ch) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Channel not found"})
+		return nil
+	}
+	if manage && !access.CanManageChannel(user, projects, ch) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有修改该通知渠道的权限"})
+		return nil
+	}
+	return ch
+}
+
+// GetChannel 获取通知渠道
+func (h *Handler) GetChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	channel := h.authorizeChannel(c, id, false)
+	if channel == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// UpdateChannel 更新通知渠道，配置中仍为掩码的敏感字段保留原值
+func (h *Handler) UpdateChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	var channel entity.NotificationChannel
+	if err := c.ShouldBindJSON(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing := h.authorizeChannel(c, id, true)
+	if existing == nil {
+		return
+	}
+
+	channel.ID = id
+	if err := h.channelService.UpdateChannel(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditChannelUpdate, entity.AuditTargetChannel, id, channel.Name, existing, &channel)
+
+	c.JSON(http.StatusOK, channel)
+}
+
+// DeleteChannel 删除通知渠道
+func (h *Handler) DeleteChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	existing := h.authorizeChannel(c, id, true)
+	if existing == nil {
+		return
+	}
+
+	if err := h.channelService.DeleteChannel(id); err != nil {
+		if err == channel.ErrChannelInUse {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, entity.AuditChannelDelete, entity.AuditTargetChannel, id, existing.Name, existing, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Channel deleted successfully"})
+}
+
+// TestChannel 向通知渠道发送测试通知
+func (h *Handler) TestChannel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel ID"})
+		return
+	}
+
+	if h.authorizeChannel(c, id, true) == nil {
+		return
+	}
+
+	if err := h.channelService.TestChannel(id); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "测试通知已发送"})
+}
+
+// CreateEscalationPolicy 创建告警升级策略
+func (h *Handler) CreateEscalationPolicy(c *gin.Context) {
+	var policy entity.EscalationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy.ID = 0
+	policy.ProjectID = normalizeProjectID(policy.ProjectID)
+	if !h.authorizeCreate(c, policy.ProjectID) {
+		return
+	}
+
+	if err := h.escalationService.CreatePolicy(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// ListEscalationPolicies 获取告警升级策略列表，只返回全局策略和所属项目的策略，可按项目筛选
+func (h *Handler) ListEscalationPolicies(c *gin.Context) {
+	projectID, ok := h.projectSelector(c)
+	if !ok {
+		return
+	}
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return
+	}
+
+	policies, err := h.escalationService.ListPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	visible := []*entity.EscalationPolicy{}
+	for _, policy := range policies {
+		if projectID != nil && policy.ProjectID != nil && *policy.ProjectID != *projectID {
+			continue
+		}
+		if access.CanViewEscalationPolicy(user, projects, policy) {
+			visible = append(visible, policy)
+		}
+	}
+
+	c.JSON(http.StatusOK, visible)
+}
+
+// authorizeEscalationPolicy 检查当前用户是否可以查看升级策略，manage 为 true 时检查是否可以修改；
+// 可以时返回策略，不可以时写入错误响应并返回 nil
+func (h *Handler) authorizeEscalationPolicy(c *gin.Context, id int, manage bool) *entity.EscalationPolicy {
+	projects, ok := h.projectMemberships(c)
+	if !ok {
+		return nil
+	}
+
+	user := currentUser(c)
+	policy, err := h.escalationService.GetPolicy(id)
+	if err != nil || !access.CanViewEscalationPolicy(user, projects, policy) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Policy not found"})
+		return nil
+	}
+	if manage && !access.CanManageEscalationPolicy(user, projects, policy) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "没有修改该升级策略的权限"})
+		return nil
+	}
+	return policy
+}
+
+// GetEscalationPolicy 获取告警升级策略
+func (h *Handler) GetEscalationPolicy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return
+	}
+
+	policy := h.authorizeEscalationPolicy(c, id, false)
+	if policy == nil {
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateEscalationPolicy 更新告警升级策略
+func (h *Handler) UpdateEscalationPolicy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return
+	}
+
+	var policy entity.EscalationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.authorizeEscalationPolicy(c, id, true) == nil {
+		return
+	}
+
+	policy.ID = id
+	if err := h.escalationService.UpdatePolicy(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteEscalationPolicy 删除告警升级策略
+func (h *Handler) DeleteEscalationPolicy(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid policy ID"})
+		return
+	}
+
+	if h.authorizeEscalationPolicy(c, id, true) == nil {
+		return
+	}
+
+	if err := h.escalationService.DeletePolicy(id); err != nil {
+		if err == escalation.ErrPolicyInUse {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Policy deleted successfully"})
+}
+
+// ListTaskGrants 获取任务的授权
+func (h *Handler) ListTaskGrants(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	if h.authorizeTask(c, taskID, entity.PermissionManage) == nil {
+		return
+	}
+
+	grants, err := h.accessService.ListTaskGrants(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grants)
+}
+
+// CreateTaskGrant 授予用户或用户组对任务的权限
+func (h *Handler) CreateTaskGrant(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var grant entity.TaskGrant
+	if err := c.ShouldBindJSON(&grant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.authorizeTask(c, taskID, entity.PermissionManage) == nil {
+		return
+	}
+
+	grant.TaskID = taskID
+	if err := h.accessService.GrantTask(&grant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, grant)
+}
+
+// DeleteTaskGrant 撤销任务的授权
+func (h *Handler) DeleteTaskGrant(c *gin.Context) {
+	taskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	grantID, err := strconv.ParseUint(c.Param("grantId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid grant ID"})
+		return
+	}
+
+	if h.authorizeTask(c, taskID, entity.PermissionManage) == nil {
+		return
+	}
+
+	if err := h.accessService.RevokeTaskGrant(taskID, uint(grantID)); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Grant not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	trends, err := h.statisticsService.GetExecutionTrends(req)
+	c.JSON(http.StatusOK, gin.H{"message": "Grant deleted successfully"})
+}
+
+// ListUsers 获取用户列表，用于选择授权对象
+func (h *Handler) ListUsers(c *gin.Context) {
+	users, err := h.accountService.ListUsers()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, trends)
+	c.JSON(http.StatusOK, users)
 }
 
-// GetTaskExecutionReport 获取任务执行报表
-func (h *Handler) GetTaskExecutionReport(c *gin.Context) {
-	req := entity.NewStatisticsRequest()
+// writeAccountError 将用户管理服务的错误转换为响应
+func writeAccountError(c *gin.Context, err error) {
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case err == account.ErrModifySelf || err == account.ErrLastAdmin || err == account.ErrExternalAdmin || err == account.ErrExternalLinked:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
 
-	// 解析查询参数
-	if days := c.Query("days"); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			req.Days = d
+// GetUser 获取用户
+func (h *Handler) GetUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUser 管理员创建用户
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req entity.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.accountService.CreateUser(&req)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserCreate, entity.AuditTargetUser, user.ID, user.Username, nil, user)
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// UpdateUserRole 修改用户角色
+func (h *Handler) UpdateUserRole(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	user, err := h.accountService.SetRole(currentUser(c), uint(id), req.Role)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserUpdate, entity.AuditTargetUser, id, user.Username, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUserAuthProvider 修改用户的认证来源，将本地账号切换为单点登录或 LDAP 后，用户首次登录时按邮箱绑定
+func (h *Handler) UpdateUserAuthProvider(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		AuthProvider string `json:"auth_provider" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if (req.AuthProvider == entity.AuthProviderOIDC && h.ssoService == nil) ||
+		(req.AuthProvider == entity.AuthProviderLDAP && h.authService.PasswordProvider() != entity.AuthProviderLDAP) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未启用该认证方式"})
+		return
+	}
+
+	before, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	user, err := h.accountService.SetAuthProvider(currentUser(c), uint(id), req.AuthProvider)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserUpdate, entity.AuditTargetUser, id, user.Username, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUserStatus 启用或禁用用户
+func (h *Handler) UpdateUserStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		IsActive *bool `json:"is_active" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	before, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	user, err := h.accountService.SetActive(currentUser(c), uint(id), *req.IsActive)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserUpdate, entity.AuditTargetUser, id, user.Username, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ApproveUser 审核通过注册的用户
+func (h *Handler) ApproveUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	before, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	user, err := h.accountService.Approve(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserUpdate, entity.AuditTargetUser, id, user.Username, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetUserPassword 重置用户密码，未指定密码时生成临时密码并在响应中返回
+func (h *Handler) ResetUserPassword(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	user, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	password, err := h.accountService.ResetPassword(uint(id), req.Password)
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserResetPassword, entity.AuditTargetUser, id, user.Username, nil, nil)
+
+	response := gin.H{"message": "密码已重置，用户登录后需要修改密码"}
+	if req.Password == "" {
+		response["password"] = password
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ResetUserTwoFactor 停用用户的两步验证
+func (h *Handler) ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	before, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	user, err := h.accountService.ResetTwoFactor(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserResetTwoFactor, entity.AuditTargetUser, id, user.Username, before, user)
+
+	c.JSON(http.StatusOK, user)
+}
+
+// ListAuditLogs 分页查询审计日志，可按操作、操作人、操作对象、IP 和时间范围筛选
+func (h *Handler) ListAuditLogs(c *gin.Context) {
+	var req entity.PaginationRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := &entity.AuditLogFilter{
+		Action:     c.Query("action"),
+		Username:   c.Query("username"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+		IP:         c.Query("ip"),
+	}
+	if value := c.Query("user_id"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID := uint(id)
+		filter.UserID = &userID
+	}
+	for _, param := range []struct {
+		name  string
+		value **time.Time
+	}{{"start_time", &filter.StartTime}, {"end_time", &filter.EndTime}} {
+		if value := c.Query(param.name); value != "" {
+			t, err := parseQueryTime(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("无效的时间 %s: %s", param.name, value)})
+				return
+			}
+			*param.value = &t
 		}
 	}
 
-	report, err := h.statisticsService.GetTaskExecutionReport(req)
+	response, err := h.auditService.List(filter, entity.NewPaginationRequest(req.Page, req.PageSize))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteUser 删除用户
+func (h *Handler) DeleteUser(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.accountService.GetUser(uint(id))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	if err := h.accountService.DeleteUser(currentUser(c), uint(id)); err != nil {
+		writeAccountError(c, err)
+		return
+	}
+	h.recordAudit(c, entity.AuditUserDelete, entity.AuditTargetUser, id, user.Username, user, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// ListAPITokens 获取当前用户的个人访问令牌
+func (h *Handler) ListAPITokens(c *gin.Context) {
+	tokens, err := h.tokenService.List(currentUser(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, report)
+	c.JSON(http.StatusOK, tokens)
 }
 
-// GetTaskPerformanceMetrics 获取任务性能指标
-func (h *Handler) GetTaskPerformanceMetrics(c *gin.Context) {
-	req := entity.NewStatisticsRequest()
+// CreateAPIToken 创建个人访问令牌，令牌明文只在响应中返回一次
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	// 令牌不能用来创建新的令牌，避免泄露的令牌被延长有效期或提升权限范围
+	if _, ok := c.Get("api_token"); ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "请登录后创建访问令牌"})
+		return
+	}
 
-	// 解析查询参数
-	if days := c.Query("days"); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			req.Days = d
-		}
+	var req entity.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	metrics, err := h.statisticsService.GetTaskPerformanceMetrics(req)
+	response, err := h.tokenService.Create(currentUser(c), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, metrics)
+	c.JSON(http.StatusCreated, response)
 }
 
-// GetHourlyExecutionStats 获取小时执行统计
-func (h *Handler) GetHourlyExecutionStats(c *gin.Context) {
-	req := entity.NewStatisticsRequest()
-
-	// 解析查询参数
-	if days := c.Query("days"); days != "" {
-		if d, err := strconv.Atoi(days); err == nil && d > 0 {
-			req.Days = d
-		}
+// RevokeAPIToken 撤销个人访问令牌，管理员可以撤销任何用户的令牌
+func (h *Handler) RevokeAPIToken(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
 	}
 
-	if taskIDStr := c.Query("task_id"); taskIDStr != "" {
-		if taskID, err := strconv.Atoi(taskIDStr); err == nil {
-			req.TaskID = &taskID
+	if err := h.tokenService.Revoke(currentUser(c), uint(id)); err != nil {
+		if err == apitoken.ErrTokenNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
 
-	stats, err := h.statisticsService.GetHourlyExecutionStats(req)
+	c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+}
+
+// ListAllAPITokens 获取所有用户的个人访问令牌
+func (h *Handler) ListAllAPITokens(c *gin.Context) {
+	tokens, err := h.tokenService.ListAll()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, tokens)
 }
 
-// 任务模板相关接口
-
-// CreateTemplate 创建任务模板
-func (h *Handler) CreateTemplate(c *gin.Context) {
-	var template entity.TaskTemplate
-	if err := c.ShouldBindJSON(&template); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// ListInviteCodes 获取邀请码列表
+func (h *Handler) ListInviteCodes(c *gin.Context) {
+	invites, err := h.accountService.ListInvites()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 获取当前用户ID
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateInviteCode 创建邀请码，expires_in 为有效小时数，0 表示不过期
+func (h *Handler) CreateInviteCode(c *gin.Context) {
+	var req struct {
+		Role      string `json:"role"`
+		Note      string `json:"note"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	userEntity := user.(*entity.User)
-	template.CreatedBy = int(userEntity.ID)
 
-	if err := h.templateService.CreateTemplate(&template); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	invite, err := h.accountService.CreateInvite(currentUser(c), req.Role, req.Note, time.Duration(req.ExpiresIn)*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, template)
+	c.JSON(http.StatusCreated, invite)
 }
 
-// GetTemplate 获取任务模板
-func (h *Handler) GetTemplate(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// DeleteInviteCode 删除邀请码
+func (h *Handler) DeleteInviteCode(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invite code ID"})
 		return
 	}
 
-	template, err := h.templateService.GetTemplate(id)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+	if err := h.accountService.DeleteInvite(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, template)
+	c.JSON(http.StatusOK, gin.H{"message": "Invite code deleted successfully"})
 }
 
-// ListTemplates 获取模板列表
-func (h *Handler) ListTemplates(c *gin.Context) {
-	templates, err := h.templateService.ListTemplates()
+// ListUserGroups 获取用户组列表
+func (h *Handler) ListUserGroups(c *gin.Context) {
+	groups, err := h.accessService.ListGroups()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, templates)
+	c.JSON(http.StatusOK, groups)
 }
 
-// ListPublicTemplates 获取公共模板列表
-func (h *Handler) ListPublicTemplates(c *gin.Context) {
-	templates, err := h.templateService.ListPublicTemplates()
+// GetUserGroup 获取用户组及其成员
+func (h *Handler) GetUserGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
 		return
 	}
 
-	c.JSON(http.StatusOK, templates)
+	group, err := h.accessService.GetGroup(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
 }
 
-// ListMyTemplates 获取我的模板列表
-func (h *Handler) ListMyTemplates(c *gin.Context) {
-	// 获取当前用户ID
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+// CreateUserGroup 创建用户组
+func (h *Handler) CreateUserGroup(c *gin.Context) {
+	var group entity.UserGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	userEntity := user.(*entity.User)
 
-	templates, err := h.templateService.ListMyTemplates(int(userEntity.ID))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.accessService.CreateGroup(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, templates)
+	c.JSON(http.StatusOK, group)
 }
 
-// SearchTemplates 搜索模板
-func (h *Handler) SearchTemplates(c *gin.Context) {
-	var req entity.TaskTemplateSearchRequest
-	if err := c.ShouldBindQuery(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// UpdateUserGroup 更新用户组
+func (h *Handler) UpdateUserGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
 		return
 	}
 
-	// 设置默认值
-	if req.Page <= 0 {
-		req.Page = 1
+	var group entity.UserGroup
+	if err := c.ShouldBindJSON(&group); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if req.PageSize <= 0 {
-		req.PageSize = 10
+
+	group.ID = uint(id)
+	if err := h.accessService.UpdateGroup(&group); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	templates, total, err := h.templateService.SearchTemplates(&req)
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteUserGroup 删除用户组，同时撤销授予该组的权限
+func (h *Handler) DeleteUserGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
+		return
+	}
+
+	if err := h.accessService.DeleteGroup(uint(id)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data":      templates,
-		"total":     total,
-		"page":      req.Page,
-		"page_size": req.PageSize,
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "Group deleted successfully"})
 }
 
-// UpdateTemplate 更新任务模板
-func (h *Handler) UpdateTemplate(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// AddUserGroupMember 将用户加入用户组
+func (h *Handler) AddUserGroupMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
 		return
 	}
 
-	var template entity.TaskTemplate
-	if err := c.ShouldBindJSON(&template); err != nil {
+	var req struct {
+		UserID uint `json:"user_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	template.ID = id
-	if err := h.templateService.UpdateTemplate(&template); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.accessService.AddGroupMember(uint(id), req.UserID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Group not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, template)
+	c.JSON(http.StatusOK, gin.H{"message": "成员已添加"})
 }
 
-// DeleteTemplate 删除任务模板
-func (h *Handler) DeleteTemplate(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// RemoveUserGroupMember 将用户移出用户组
+func (h *Handler) RemoveUserGroupMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid group ID"})
 		return
 	}
 
-	if err := h.templateService.DeleteTemplate(id); err != nil {
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if err := h.accessService.RemoveGroupMember(uint(id), uint(userID)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "成员已移除"})
 }
 
-// GetPopularTemplates 获取热门模板
-func (h *Handler) GetPopularTemplates(c *gin.Context) {
-	limit := 10
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
-	}
-
-	templates, err := h.templateService.GetPopularTemplates(limit)
+// ListProjects 获取当前用户可见的项目，包含当前用户在项目中的角色和项目用量
+func (h *Handler) ListProjects(c *gin.Context) {
+	projects, err := h.projectService.ListProjects(currentUser(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, templates)
+	c.JSON(http.StatusOK, projects)
 }
 
-// CreateTaskFromTemplate 从模板创建任务
-func (h *Handler) CreateTaskFromTemplate(c *gin.Context) {
-	var req entity.CreateTaskFromTemplateRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// GetProject 获取项目，需要是项目成员
+func (h *Handler) GetProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
 		return
 	}
 
-	// 获取当前用户ID
-	user, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+	authorized := h.authorizeProject(c, uint(id), entity.RoleViewer)
+	if authorized == nil {
 		return
 	}
-	userEntity := user.(*entity.User)
 
-	task, err := h.templateService.CreateTaskFromTemplate(&req, int(userEntity.ID))
+	proj, err := h.projectService.GetProject(uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
 	}
+	proj.Role = authorized.Role
 
-	c.JSON(http.StatusOK, task)
+	c.JSON(http.StatusOK, proj)
 }
 
-// GetTemplateStats 获取模板统计
-func (h *Handler) GetTemplateStats(c *gin.Context) {
-	stats, err := h.templateService.GetTemplateStats()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// CreateProject 创建项目
+func (h *Handler) CreateProject(c *gin.Context) {
+	var proj entity.Project
+	if err := c.ShouldBindJSON(&proj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	if err := h.projectService.CreateProject(&proj); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, proj)
 }
 
-// 模板分类相关接口
+// UpdateProject 更新项目名称、描述和配额
+func (h *Handler) UpdateProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
 
-// CreateCategory 创建模板分类
-func (h *Handler) CreateCategory(c *gin.Context) {
-	var category entity.TaskTemplateCategory
-	if err := c.ShouldBindJSON(&category); err != nil {
+	var proj entity.Project
+	if err := c.ShouldBindJSON(&proj); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.templateService.CreateCategory(&category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	proj.ID = uint(id)
+	if err := h.projectService.UpdateProject(&proj); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	c.JSON(http.StatusOK, proj)
 }
 
-// ListCategories 获取分类列表
-func (h *Handler) ListCategories(c *gin.Context) {
-	categories, err := h.templateService.ListCategories()
+// DeleteProject 删除项目，项目中还有任务时返回 409
+func (h *Handler) DeleteProject(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	if err := h.projectService.DeleteProject(uint(id)); err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		case project.ErrProjectNotEmpty:
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
+}
+
+// ListProjectMembers 获取项目成员，需要是项目成员
+func (h *Handler) ListProjectMembers(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	if h.authorizeProject(c, uint(id), entity.RoleViewer) == nil {
+		return
+	}
+
+	members, err := h.projectService.ListMembers(uint(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, categories)
+	c.JSON(http.StatusOK, members)
 }
 
-// UpdateCategory 更新分类
-func (h *Handler) UpdateCategory(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// SaveProjectMember 添加项目成员或修改成员的项目角色，需要项目管理员角色
+func (h *Handler) SaveProjectMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
 		return
 	}
 
-	var category entity.TaskTemplateCategory
-	if err := c.ShouldBindJSON(&category); err != nil {
+	var req struct {
+		UserID uint   `json:"user_id" binding:"required"`
+		Role   string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	category.ID = id
-	if err := h.templateService.UpdateCategory(&category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if h.authorizeProject(c, uint(id), entity.RoleAdmin) == nil {
 		return
 	}
 
-	c.JSON(http.StatusOK, category)
+	member, err := h.projectService.SaveMember(uint(id), req.UserID, req.Role)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, member)
 }
 
-// DeleteCategory 删除分类
-func (h *Handler) DeleteCategory(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("id"))
+// RemoveProjectMember 将用户移出项目，需要项目管理员角色
+func (h *Handler) RemoveProjectMember(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
 		return
 	}
 
-	if err := h.templateService.DeleteCategory(id); err != nil {
+	userID, err := strconv.ParseUint(c.Param("userId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	if h.authorizeProject(c, uint(id), entity.RoleAdmin) == nil {
+		return
+	}
+
+	if err := h.projectService.RemoveMember(uint(id), uint(userID)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "成员已移除"})
 }
@@ -1,16 +1,20 @@
 package http
 
 import (
+	"crontab_go/internal/application/apitoken"
 	"crontab_go/internal/application/auth"
 	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/infrastructure/metrics"
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware JWT认证中间件
-func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
+// AuthMiddleware 认证中间件，支持登录获取的 JWT 和个人访问令牌
+func AuthMiddleware(authService *auth.Service, tokenService *apitoken.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -28,22 +32,70 @@ func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
 			return
 		}
 
+		// 个人访问令牌按权限范围限制可以调用的接口
+		if strings.HasPrefix(tokenParts[1], entity.APITokenPrefix) {
+			user, token, err := tokenService.Authenticate(tokenParts[1], c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+			if !tokenScopeAllows(token.Scope, c.Request.Method, c.FullPath()) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "访问令牌的权限范围不允许该操作", "scope": token.Scope})
+				c.Abort()
+				return
+			}
+			c.Set("user", user)
+			c.Set("api_token", token)
+			c.Next()
+			return
+		}
+
 		// 验证token
-		user, err := authService.ValidateToken(tokenParts[1])
+		user, sessionID, err := authService.ValidateToken(tokenParts[1])
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token"})
 			c.Abort()
 			return
 		}
 
-		// 将用户信息存储到上下文中
+		// 将用户信息和会话存储到上下文中
 		c.Set("user", user)
+		c.Set("session_id", sessionID)
 		c.Next()
 	}
 }
 
+// executeScopeRoutes execute 权限范围的令牌除查询接口外可以调用的接口
+var executeScopeRoutes = map[string]bool{
+	"POST /api/v1/tasks/:id/execute":   true,
+	"POST /api/v1/tasks/:id/cancel":    true,
+	"POST /api/v1/tasks/:id/alert/ack": true,
+}
+
+// tokenScopeAllows 个人访问令牌的权限范围是否允许调用接口
+func tokenScopeAllows(scope, method, route string) bool {
+	switch scope {
+	case entity.TokenScopeAdmin:
+		return true
+	case entity.TokenScopeExecute:
+		if executeScopeRoutes[method+" "+route] {
+			return true
+		}
+		fallthrough
+	case entity.TokenScopeRead:
+		return method == http.MethodGet || method == http.MethodHead
+	}
+	return false
+}
+
 // AdminMiddleware 管理员权限中间件
 func AdminMiddleware() gin.HandlerFunc {
+	return RoleMiddleware(entity.RoleAdmin)
+}
+
+// RoleMiddleware 角色权限中间件，要求用户角色不低于 role
+func RoleMiddleware(role string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
@@ -52,12 +104,74 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if user.(*entity.User).Role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+		if !user.(*entity.User).HasRole(role) {
+			message := "需要操作员权限"
+			if role == entity.RoleAdmin {
+				message = "需要管理员权限"
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// PasswordChangeMiddleware 用户被要求修改密码时（例如默认管理员账号或被管理员重置密码），
+// 只允许查看当前用户、修改密码和退出登录，其余接口返回 403
+func PasswordChangeMiddleware() gin.HandlerFunc {
+	allowed := map[string]bool{
+		"GET /api/v1/user":          true,
+		"PUT /api/v1/user/password": true,
+		"POST /api/v1/auth/logout":  true,
+	}
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if exists && user.(*entity.User).MustChangePassword && !allowed[c.Request.Method+" "+c.FullPath()] {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":                "请先修改密码",
+				"must_change_password": true,
+			})
 			c.Abort()
 			return
 		}
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// MetricsMiddleware 记录 HTTP 请求耗时指标
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		// 使用路由模板作为标签，未匹配的路由统一归为 unmatched，避免标签数量失控
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// MetricsAuthMiddleware 指标接口认证中间件，token 为空时不做认证
+func MetricsAuthMiddleware(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		tokenParts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" ||
+			subtle.ConstantTimeCompare([]byte(tokenParts[1]), []byte(token)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,288 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/infrastructure/config"
+	"crontab_go/internal/infrastructure/persistence"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestTokenScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope  string
+		method string
+		route  string
+		want   bool
+	}{
+		{entity.TokenScopeRead, http.MethodGet, "/api/v1/tasks", true},
+		{entity.TokenScopeRead, http.MethodHead, "/api/v1/tasks", true},
+		{entity.TokenScopeRead, http.MethodPost, "/api/v1/tasks", false},
+		{entity.TokenScopeRead, http.MethodPost, "/api/v1/tasks/:id/execute", false},
+		{entity.TokenScopeRead, http.MethodPost, "/api/v1/tasks/:id/alert/ack", false},
+		{entity.TokenScopeRead, http.MethodPost, "/api/v1/tasks/:id/cancel", false},
+		{entity.TokenScopeRead, http.MethodPut, "/api/v1/tasks/:id", false},
+		{entity.TokenScopeRead, http.MethodDelete, "/api/v1/tasks/:id", false},
+
+		{entity.TokenScopeExecute, http.MethodGet, "/api/v1/tasks", true},
+		{entity.TokenScopeExecute, http.MethodPost, "/api/v1/tasks/:id/execute", true},
+		{entity.TokenScopeExecute, http.MethodPost, "/api/v1/tasks/:id/alert/ack", true},
+		{entity.TokenScopeExecute, http.MethodPost, "/api/v1/tasks/:id/cancel", true},
+		{entity.TokenScopeExecute, http.MethodPost, "/api/v1/tasks", false},
+		{entity.TokenScopeExecute, http.MethodPut, "/api/v1/tasks/:id", false},
+		{entity.TokenScopeExecute, http.MethodDelete, "/api/v1/tasks/:id/alert", false},
+		{entity.TokenScopeExecute, http.MethodDelete, "/api/v1/tasks/:id/execute", false},
+
+		{entity.TokenScopeAdmin, http.MethodGet, "/api/v1/tasks", true},
+		{entity.TokenScopeAdmin, http.MethodPost, "/api/v1/tasks", true},
+		{entity.TokenScopeAdmin, http.MethodDelete, "/api/v1/users/:id", true},
+
+		{"", http.MethodGet, "/api/v1/tasks", false},
+		{"write", http.MethodGet, "/api/v1/tasks", false},
+	}
+	for _, tt := range tests {
+		if got := tokenScopeAllows(tt.scope, tt.method, tt.route); got != tt.want {
+			t.Errorf("tokenScopeAllows(%q, %s, %s) = %v, want %v", tt.scope, tt.method, tt.route, got, tt.want)
+		}
+	}
+}
+
+// tokenTestServer 使用临时数据库的完整路由，用于测试个人访问令牌经过中间件和处理函数后的实际权限
+type tokenTestServer struct {
+	db     *gorm.DB
+	server *Server
+}
+
+func newTokenTestServer(t *testing.T) *tokenTestServer {
+	t.Helper()
+
+	db, err := persistence.NewSQLiteDB(filepath.Join(t.TempDir(), "crontab.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	cfg := config.Default()
+	cfg.Server.Mode = gin.TestMode
+	cfg.JWT.Secret = "test-secret"
+	return &tokenTestServer{db: db.Client, server: NewServer(db.Client, cfg)}
+}
+
+// createUser 创建用户和该用户拥有的任务
+func (s *tokenTestServer) createUser(t *testing.T, username, role string) (*entity.User, *entity.Task) {
+	t.Helper()
+
+	user := &entity.User{Username: username, Email: username + "@example.com", Role: role, IsActive: true, AuthProvider: entity.AuthProviderLocal}
+	if err := s.db.Create(user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	task := &entity.Task{Name: username + "-task", Schedule: "0 0 * * *", Command: "true", OwnerID: &user.ID}
+	if err := s.db.Create(task).Error; err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	return user, task
+}
+
+// createToken 为用户创建个人访问令牌，返回令牌明文
+func (s *tokenTestServer) createToken(t *testing.T, user *entity.User, scope string) string {
+	t.Helper()
+
+	resp, err := s.server.handler.tokenService.Create(user, &entity.CreateAPITokenRequest{Name: scope, Scope: scope})
+	if err != nil {
+		t.Fatalf("create %s token: %v", scope, err)
+	}
+	return resp.Token
+}
+
+// do 使用令牌发送请求，返回状态码和响应
+func (s *tokenTestServer) do(t *testing.T, token, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	s.server.engine.ServeHTTP(rec, req)
+
+	var resp map[string]interface{}
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestAPITokenScopeAndRoleCap(t *testing.T) {
+	s := newTokenTestServer(t)
+
+	const (
+		allowed     = "allowed"      // 通过令牌权限范围和角色检查
+		scopeDenied = "scope denied" // 令牌权限范围不允许
+		roleDenied  = "role denied"  // 令牌权限范围允许，但用户角色或任务权限不足
+	)
+	scopeLevels := map[string]int{entity.TokenScopeRead: 1, entity.TokenScopeExecute: 2, entity.TokenScopeAdmin: 3}
+
+	// 每个接口需要的最低令牌权限范围和用户角色，任务接口的角色由任务权限的角色上限决定
+	routes := []struct {
+		name    string
+		method  string
+		path    string // %d 替换为用户拥有的任务ID
+		body    string
+		scope   string
+		minRole string
+	}{
+		{"view task", http.MethodGet, "/api/v1/tasks/%d", "", entity.TokenScopeRead, entity.RoleViewer},
+		{"acknowledge alert", http.MethodPost, "/api/v1/tasks/%d/alert/ack", "", entity.TokenScopeExecute, entity.RoleOperator},
+		{"cancel task", http.MethodPost, "/api/v1/tasks/%d/cancel", "", entity.TokenScopeExecute, entity.RoleOperator},
+		{"update task", http.MethodPut, "/api/v1/tasks/%d", `{"name":"renamed","schedule":"0 0 * * *","command":"true"}`, entity.TokenScopeAdmin, entity.RoleOperator},
+		{"create task", http.MethodPost, "/api/v1/tasks", `{"name":"created","schedule":"0 0 * * *","command":"true"}`, entity.TokenScopeAdmin, entity.RoleOperator},
+		{"list audit logs", http.MethodGet, "/api/v1/audit-logs", "", entity.TokenScopeRead, entity.RoleAdmin},
+		{"delete user", http.MethodDelete, "/api/v1/users/99999", "", entity.TokenScopeAdmin, entity.RoleAdmin},
+	}
+
+	for _, role := range []string{entity.RoleViewer, entity.RoleOperator, entity.RoleAdmin} {
+		user, task := s.createUser(t, role+"-user", role)
+		for _, scope := range []string{entity.TokenScopeRead, entity.TokenScopeExecute, entity.TokenScopeAdmin} {
+			token := s.createToken(t, user, scope)
+			for _, route := range routes {
+				t.Run(fmt.Sprintf("%s/%s/%s", role, scope, route.name), func(t *testing.T) {
+					want := allowed
+					switch {
+					case scopeLevels[scope] < scopeLevels[route.scope]:
+						want = scopeDenied
+					case !entity.RoleAtLeast(role, route.minRole):
+						want = roleDenied
+					}
+
+					path := route.path
+					if strings.Contains(path, "%d") {
+						path = fmt.Sprintf(path, task.ID)
+					}
+					status, resp := s.do(t, token, route.method, path, route.body)
+
+					got := allowed
+					switch {
+					case status == http.StatusUnauthorized:
+						t.Fatalf("status = 401: %v", resp)
+					case status == http.StatusForbidden && resp["scope"] != nil:
+						got = scopeDenied
+					case status == http.StatusForbidden:
+						got = roleDenied
+					}
+					if got != want {
+						t.Errorf("%s %s: %s (status %d, %v), want %s", route.method, path, got, status, resp, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	s := newTokenTestServer(t)
+	user, _ := s.createUser(t, "operator-user", entity.RoleOperator)
+
+	if _, err := s.server.handler.tokenService.Create(user, &entity.CreateAPITokenRequest{Name: "invalid", Scope: "write"}); err == nil {
+		t.Error("Create accepted an invalid scope")
+	}
+
+	valid := s.createToken(t, user, entity.TokenScopeAdmin)
+	if status, resp := s.do(t, valid, http.MethodGet, "/api/v1/user", ""); status != http.StatusOK {
+		t.Fatalf("valid token: status = %d, %v", status, resp)
+	}
+
+	revoked := s.createToken(t, user, entity.TokenScopeAdmin)
+	expired := s.createToken(t, user, entity.TokenScopeAdmin)
+	var tokens []*entity.APIToken
+	if err := s.db.Order("id").Find(&tokens).Error; err != nil || len(tokens) != 3 {
+		t.Fatalf("find tokens = %d, %v", len(tokens), err)
+	}
+	now := time.Now()
+	if err := s.db.Model(tokens[1]).Update("revoked_at", now).Error; err != nil {
+		t.Fatalf("revoke token: %v", err)
+	}
+	if err := s.db.Model(tokens[2]).Update("expires_at", now.Add(-time.Minute)).Error; err != nil {
+		t.Fatalf("expire token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"revoked", revoked},
+		{"expired", expired},
+		{"unknown", entity.APITokenPrefix + "unknown"},
+	}
+	for _, tt := range tests {
+		if status, resp := s.do(t, tt.token, http.MethodGet, "/api/v1/user", ""); status != http.StatusUnauthorized {
+			t.Errorf("%s token: status = %d, %v, want 401", tt.name, status, resp)
+		}
+	}
+
+	// 用户被禁用后令牌失效
+	if err := s.db.Model(user).Update("is_active", false).Error; err != nil {
+		t.Fatalf("disable user: %v", err)
+	}
+	if status, resp := s.do(t, valid, http.MethodGet, "/api/v1/user", ""); status != http.StatusUnauthorized {
+		t.Errorf("disabled user: status = %d, %v, want 401", status, resp)
+	}
+}
+
+func TestCancelTaskRecordsAudit(t *testing.T) {
+	s := newTokenTestServer(t)
+	user, task := s.createUser(t, "operator-user", entity.RoleOperator)
+	if err := s.db.Model(task).Update("command", "sleep 30").Error; err != nil {
+		t.Fatalf("update command: %v", err)
+	}
+	token := s.createToken(t, user, entity.TokenScopeExecute)
+	path := fmt.Sprintf("/api/v1/tasks/%d/cancel", task.ID)
+
+	if status, resp := s.do(t, token, http.MethodPost, path, ""); status != http.StatusBadRequest {
+		t.Fatalf("cancel idle task: status = %d, %v, want 400", status, resp)
+	}
+
+	finished := make(chan error, 1)
+	go func() { finished <- s.server.handler.taskService.ExecuteTask(task.ID) }()
+
+	// 任务开始执行前取消返回 400
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, resp := s.do(t, token, http.MethodPost, path, "")
+		if status == http.StatusOK {
+			if resp["cancelled_runs"] != float64(1) {
+				t.Errorf("cancelled_runs = %v, want 1", resp["cancelled_runs"])
+			}
+			break
+		}
+		if status != http.StatusBadRequest || time.Now().After(deadline) {
+			t.Fatalf("cancel running task: status = %d, %v", status, resp)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("ExecuteTask: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ExecuteTask did not return after cancellation")
+	}
+
+	var entries []entity.AuditLog
+	if err := s.db.Where("action = ?", entity.AuditTaskCancel).Find(&entries).Error; err != nil {
+		t.Fatalf("find audit logs: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("%d cancel audit logs, want 1", len(entries))
+	}
+	if entries[0].UserID != user.ID || entries[0].TargetType != entity.AuditTargetTask || entries[0].TargetID != fmt.Sprint(task.ID) {
+		t.Errorf("audit log = %+v", entries[0])
+	}
+}
@@ -1,7 +1,11 @@
 package http
 
 import (
+	"crontab_go/internal/domain/entity"
+	"crontab_go/internal/infrastructure/config"
+	"crontab_go/internal/infrastructure/metrics"
 	"log"
+	"path/filepath"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -10,26 +14,39 @@ import (
 type Server struct {
 	engine *gin.Engine
 	handler *Handler
+	config  *config.Config
 }
 
-func NewServer(db *gorm.DB) *Server {
+func NewServer(db *gorm.DB, cfg *config.Config) *Server {
+	gin.SetMode(cfg.Server.Mode)
 	engine := gin.Default()
+	// 只信任配置的反向代理转发的客户端 IP，否则客户端可以通过 X-Forwarded-For 伪造 IP
+	if err := engine.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid server.trusted_proxies: %v", err)
+	}
 	
 	// 应用CORS中间件
 	engine.Use(CORSMiddleware())
+
+	// 记录请求耗时指标
+	engine.Use(MetricsMiddleware())
 	
-	handler := NewHandler(db)
+	handler := NewHandler(db, cfg)
 
 	// 注册路由
-	registerRoutes(engine, handler)
+	registerRoutes(engine, handler, cfg)
 
 	return &Server{
 		engine:  engine,
 		handler: handler,
+		config:  cfg,
 	}
 }
 
-func registerRoutes(engine *gin.Engine, handler *Handler) {
+func registerRoutes(engine *gin.Engine, handler *Handler, cfg *config.Config) {
+	// Prometheus 指标，配置 metrics.token 后需要携带 Bearer token 访问
+	engine.GET("/metrics", MetricsAuthMiddleware(cfg.Metrics.Token), gin.WrapH(metrics.Handler()))
+
 	api := engine.Group("/api/v1")
 	
 	// 认证相关路由（无需认证）
@@ -37,8 +54,17 @@ func registerRoutes(engine *gin.Engine, handler *Handler) {
 	{
 		auth.POST("/login", handler.Login)
 		auth.POST("/register", handler.Register)
+		auth.POST("/refresh", handler.RefreshToken)          // 使用刷新令牌获取新的访问令牌
+		auth.GET("/registration", handler.GetRegistrationMode) // 注册方式，登录页用于显示邀请码输入框
+		auth.GET("/providers", handler.GetAuthProviders)       // 可用的登录方式
+		auth.GET("/oidc/login", handler.OIDCLogin)             // 跳转到身份提供方登录
+		auth.GET("/oidc/callback", handler.OIDCCallback)       // 身份提供方回调
+		auth.POST("/oidc/exchange", handler.OIDCExchange)      // 用一次性登录码换取令牌
 	}
 
+	// 心跳上报路由（无需认证，通过任务的上报令牌识别）
+	api.POST("/ping/:token", handler.Ping)
+
 	// 系统监控路由（无需认证，只读）
 	system := api.Group("/system")
 	{
@@ -46,17 +72,84 @@ func registerRoutes(engine *gin.Engine, handler *Handler) {
 	}
 
 	// 需要认证的路由
-	authMiddleware := AuthMiddleware(handler.authService)
+	authMiddleware := AuthMiddleware(handler.authService, handler.tokenService)
 	authenticated := api.Group("")
-	authenticated.Use(authMiddleware)
+	authenticated.Use(authMiddleware, PasswordChangeMiddleware())
 	{
+		// 按角色限制的操作：operator 可以管理用户组和升级策略，admin 管理系统级配置。
+		// 任务、模板和通知渠道可以属于项目，由处理函数按用户角色和项目角色检查权限
+		operatorOnly := RoleMiddleware(entity.RoleOperator)
+		adminOnly := AdminMiddleware()
+
 		// 用户信息
 		authenticated.GET("/user", handler.GetCurrentUser)
+		authenticated.PUT("/user/password", handler.ChangePassword) // 修改自己的密码
+		authenticated.POST("/auth/logout", handler.Logout)                 // 退出当前会话
+		authenticated.POST("/auth/logout-all", handler.LogoutAll)          // 退出所有会话
+		authenticated.GET("/user/sessions", handler.ListSessions)          // 登录会话
+		authenticated.DELETE("/user/sessions/:id", handler.RevokeSession)  // 注销某个会话
+		authenticated.GET("/user/tokens", handler.ListAPITokens)         // 个人访问令牌
+		authenticated.POST("/user/tokens", handler.CreateAPIToken)       // 创建个人访问令牌
+		authenticated.DELETE("/user/tokens/:id", handler.RevokeAPIToken) // 撤销个人访问令牌
+		authenticated.GET("/user/2fa", handler.GetTwoFactorStatus)                          // 两步验证状态
+		authenticated.POST("/user/2fa/setup", handler.SetupTwoFactor)                       // 生成两步验证密钥
+		authenticated.POST("/user/2fa/enable", handler.EnableTwoFactor)                     // 启用两步验证
+		authenticated.POST("/user/2fa/disable", handler.DisableTwoFactor)                   // 停用两步验证
+		authenticated.POST("/user/2fa/recovery-codes", handler.RegenerateRecoveryCodes)     // 重新生成恢复码
+
+		// 用户和用户组管理，操作员可以查看以选择授权对象
+		users := authenticated.Group("/users")
+		{
+			users.GET("", operatorOnly, handler.ListUsers)                         // 获取用户列表
+			users.GET("/:id", adminOnly, handler.GetUser)                          // 获取用户
+			users.POST("", adminOnly, handler.CreateUser)                          // 创建用户
+			users.PUT("/:id/role", adminOnly, handler.UpdateUserRole)              // 修改用户角色
+			users.PUT("/:id/status", adminOnly, handler.UpdateUserStatus)          // 启用或禁用用户
+			users.PUT("/:id/auth-provider", adminOnly, handler.UpdateUserAuthProvider) // 切换认证来源，用于关联单点登录或 LDAP 账号
+			users.POST("/:id/approve", adminOnly, handler.ApproveUser)             // 审核通过注册的用户
+			users.POST("/:id/reset-password", adminOnly, handler.ResetUserPassword) // 重置密码
+			users.DELETE("/:id", adminOnly, handler.DeleteUser)                    // 删除用户
+			users.DELETE("/:id/sessions", adminOnly, handler.RevokeUserSessions)   // 注销用户的所有会话
+			users.DELETE("/:id/2fa", adminOnly, handler.ResetUserTwoFactor)        // 停用用户的两步验证
+		}
+		authenticated.GET("/audit-logs", adminOnly, handler.ListAuditLogs) // 审计日志
+		apiTokens := authenticated.Group("/api-tokens", adminOnly)
+		{
+			apiTokens.GET("", handler.ListAllAPITokens)      // 所有用户的访问令牌
+			apiTokens.DELETE("/:id", handler.RevokeAPIToken) // 撤销任意用户的访问令牌
+		}
+		invites := authenticated.Group("/invite-codes", adminOnly)
+		{
+			invites.GET("", handler.ListInviteCodes)         // 获取邀请码
+			invites.POST("", handler.CreateInviteCode)       // 创建邀请码
+			invites.DELETE("/:id", handler.DeleteInviteCode) // 删除邀请码
+		}
+		projects := authenticated.Group("/projects")
+		{
+			projects.GET("", handler.ListProjects)                                           // 获取可见的项目
+			projects.GET("/:id", handler.GetProject)                                         // 获取项目（项目成员）
+			projects.POST("", adminOnly, handler.CreateProject)                              // 创建项目
+			projects.PUT("/:id", adminOnly, handler.UpdateProject)                           // 更新项目及配额
+			projects.DELETE("/:id", adminOnly, handler.DeleteProject)                        // 删除项目
+			projects.GET("/:id/members", handler.ListProjectMembers)                         // 获取项目成员（项目成员）
+			projects.POST("/:id/members", handler.SaveProjectMember)                         // 添加或修改成员（项目管理员）
+			projects.DELETE("/:id/members/:userId", handler.RemoveProjectMember)             // 移除成员（项目管理员）
+		}
+		groups := authenticated.Group("/user-groups")
+		{
+			groups.GET("", operatorOnly, handler.ListUserGroups)                              // 获取用户组列表
+			groups.GET("/:id", operatorOnly, handler.GetUserGroup)                            // 获取用户组及成员
+			groups.POST("", adminOnly, handler.CreateUserGroup)                               // 创建用户组
+			groups.PUT("/:id", adminOnly, handler.UpdateUserGroup)                            // 更新用户组
+			groups.DELETE("/:id", adminOnly, handler.DeleteUserGroup)                         // 删除用户组
+			groups.POST("/:id/members", adminOnly, handler.AddUserGroupMember)                // 添加成员
+			groups.DELETE("/:id/members/:userId", adminOnly, handler.RemoveUserGroupMember)   // 移除成员
+		}
 		
 		// 任务相关路由（需要认证）
 		tasks := authenticated.Group("/tasks")
 		{
-			tasks.POST("", handler.CreateTask)           // 创建任务需要认证
+			tasks.POST("", handler.CreateTask) // 创建任务需要操作员或项目操作员角色
 			tasks.GET("", handler.ListTasks)             // 查看任务需要认证
 			tasks.GET("/paginated", handler.ListTasksWithPagination)
 			tasks.GET(":id", handler.GetTask)
@@ -64,7 +157,17 @@ func registerRoutes(engine *gin.Engine, handler *Handler) {
 			tasks.DELETE(":id", handler.DeleteTask)      // 删除任务需要认证
 			tasks.GET(":id/logs", handler.GetTaskLogs)
 			tasks.GET(":id/logs/paginated", handler.GetTaskLogsWithPagination)
+			tasks.GET(":id/deliveries", handler.GetTaskDeliveries)    // 任务最近的通知发送记录
+			tasks.GET(":id/alert", handler.GetTaskAlertState)         // 任务告警状态
+			tasks.DELETE(":id/alert", handler.ResetTaskAlertState)    // 重置告警状态
+			tasks.POST(":id/alert/ack", handler.AcknowledgeTaskAlert) // 确认告警，停止升级
+			tasks.GET(":id/heartbeat", handler.GetTaskHeartbeat)      // 心跳任务的监控状态
+			tasks.POST(":id/ping-token", handler.RegeneratePingToken) // 重新生成心跳上报令牌
 			tasks.POST(":id/execute", handler.ExecuteTask) // 执行任务需要认证
+			tasks.POST(":id/cancel", handler.CancelTask)   // 取消正在执行的任务
+			tasks.GET(":id/grants", handler.ListTaskGrants)               // 任务授权列表
+			tasks.POST(":id/grants", handler.CreateTaskGrant)             // 授予用户或用户组权限
+			tasks.DELETE(":id/grants/:grantId", handler.DeleteTaskGrant)  // 撤销授权
 		}
 
 		// 日志相关路由（需要认证）
@@ -72,12 +175,17 @@ func registerRoutes(engine *gin.Engine, handler *Handler) {
 		{
 			logs.GET("", handler.GetAllLogs)             // 获取所有日志
 			logs.GET("/paginated", handler.GetAllLogsWithPagination) // 分页获取所有日志
+			logs.GET("/export", handler.ExportLogs)                  // 导出日志
+			logs.GET("/:id/deliveries", handler.GetLogDeliveries)    // 一次执行的通知发送记录
 		}
 
 		// 通知相关路由（需要认证）
 		notifications := authenticated.Group("/notifications")
 		{
-			notifications.POST("/test", handler.TestNotification) // 测试通知
+			notifications.POST("/test", operatorOnly, handler.TestNotification)       // 测试通知
+			notifications.POST("/preview", operatorOnly, handler.PreviewNotification) // 预览通知消息模板
+			notifications.GET("/channels", handler.ListNotifiers)              // 获取通知渠道及配置项
+			notifications.POST("/deliveries/:id/retry", handler.RetryDelivery) // 重新发送失败的通知
 		}
 
 		// 统计相关路由（需要认证）
@@ -89,12 +197,48 @@ func registerRoutes(engine *gin.Engine, handler *Handler) {
 			stats.GET("/report", handler.GetTaskExecutionReport)             // 获取执行报表
 			stats.GET("/performance", handler.GetTaskPerformanceMetrics)     // 获取性能指标
 			stats.GET("/hourly", handler.GetHourlyExecutionStats)            // 获取小时统计
+			stats.GET("/anomalies", handler.GetAnomalies)                    // 获取异常执行
+			stats.GET("/export", handler.ExportStatistics)                   // 导出统计数据
+		}
+
+		// 通知渠道相关路由（需要认证）
+		channels := authenticated.Group("/notification-channels")
+		{
+			channels.POST("", handler.CreateChannel)                        // 创建通知渠道（操作员或项目操作员）
+			channels.GET("", handler.ListChannels)                          // 获取通知渠道列表
+			channels.GET("/:id", handler.GetChannel)                        // 获取通知渠道
+			channels.PUT("/:id", handler.UpdateChannel)                     // 更新通知渠道
+			channels.DELETE("/:id", handler.DeleteChannel)                  // 删除通知渠道
+			channels.POST("/:id/test", handler.TestChannel)                 // 发送测试通知
+		}
+
+		// 告警升级策略相关路由（需要认证）
+		escalations := authenticated.Group("/escalation-policies")
+		{
+			escalations.POST("", handler.CreateEscalationPolicy)       // 创建升级策略（操作员或项目操作员）
+			escalations.GET("", handler.ListEscalationPolicies)        // 获取升级策略列表
+			escalations.GET("/:id", handler.GetEscalationPolicy)       // 获取升级策略
+			escalations.PUT("/:id", handler.UpdateEscalationPolicy)    // 更新升级策略
+			escalations.DELETE("/:id", handler.DeleteEscalationPolicy) // 删除升级策略
+		}
+
+		// 报表摘要相关路由（需要管理员权限，摘要包含所有任务的统计）
+		digests := authenticated.Group("/digests")
+		digests.Use(adminOnly)
+		{
+			digests.POST("", handler.CreateDigest)              // 创建报表摘要
+			digests.GET("", handler.ListDigests)                // 获取报表摘要列表
+			digests.GET("/:id", handler.GetDigest)              // 获取报表摘要
+			digests.PUT("/:id", handler.UpdateDigest)           // 更新报表摘要
+			digests.DELETE("/:id", handler.DeleteDigest)        // 删除报表摘要
+			digests.GET("/:id/preview", handler.PreviewDigest)  // 预览摘要内容
+			digests.POST("/:id/send", handler.SendDigest)       // 立即发送摘要
 		}
 
 		// 模板相关路由（需要认证）
 		templates := authenticated.Group("/templates")
 		{
-			templates.POST("", handler.CreateTemplate)                       // 创建模板
+			templates.POST("", handler.CreateTemplate)                       // 创建模板（操作员或项目操作员）
 			templates.GET("", handler.ListTemplates)                         // 获取模板列表
 			templates.GET("/public", handler.ListPublicTemplates)            // 获取公共模板
 			templates.GET("/my", handler.ListMyTemplates)                    // 获取我的模板
@@ -102,28 +246,28 @@ func registerRoutes(engine *gin.Engine, handler *Handler) {
 			templates.GET("/popular", handler.GetPopularTemplates)          // 获取热门模板
 			templates.GET("/stats", handler.GetTemplateStats)               // 获取模板统计
 			templates.GET("/:id", handler.GetTemplate)                      // 获取模板详情
-			templates.PUT("/:id", handler.UpdateTemplate)                   // 更新模板
-			templates.DELETE("/:id", handler.DeleteTemplate)                // 删除模板
+			templates.PUT("/:id", handler.UpdateTemplate)                   // 更新模板（创建者、项目管理员或管理员）
+			templates.DELETE("/:id", handler.DeleteTemplate)                // 删除模板（创建者、项目管理员或管理员）
 			templates.POST("/create-task", handler.CreateTaskFromTemplate)  // 从模板创建任务
 		}
 
-		// 模板分类相关路由（需要认证）
+		// 模板分类相关路由（查看需要认证，修改需要管理员权限）
 		categories := authenticated.Group("/template-categories")
 		{
-			categories.POST("", handler.CreateCategory)                      // 创建分类
+			categories.POST("", adminOnly, handler.CreateCategory)           // 创建分类
 			categories.GET("", handler.ListCategories)                       // 获取分类列表
-			categories.PUT("/:id", handler.UpdateCategory)                   // 更新分类
-			categories.DELETE("/:id", handler.DeleteCategory)                // 删除分类
+			categories.PUT("/:id", adminOnly, handler.UpdateCategory)        // 更新分类
+			categories.DELETE("/:id", adminOnly, handler.DeleteCategory)     // 删除分类
 		}
 	}
 }
 
 func (s *Server) Start() {
-	log.Println("Starting server on :8080")
+	staticDir := s.config.Server.StaticDir
 	
 	// 提供前端构建后的静态文件
-	s.engine.Static("/assets", "./web/dist/assets")
-	s.engine.StaticFile("/favicon.ico", "./web/dist/favicon.ico")
+	s.engine.Static("/assets", filepath.Join(staticDir, "assets"))
+	s.engine.StaticFile("/favicon.ico", filepath.Join(staticDir, "favicon.ico"))
 	
 	// 对于所有非API路由，返回 index.html (SPA路由)
 	s.engine.NoRoute(func(c *gin.Context) {
@@ -133,10 +277,20 @@ func (s *Server) Start() {
 			return
 		}
 		// 否则返回前端应用的入口文件
-		c.File("./web/dist/index.html")
+		c.File(filepath.Join(staticDir, "index.html"))
 	})
 
-	if err := s.engine.Run(":8080"); err != nil {
+	listen := s.config.Server.Listen
+	tls := s.config.Server.TLS
+	var err error
+	if tls.Enabled() {
+		log.Printf("Starting server on %s (HTTPS)", listen)
+		err = s.engine.RunTLS(listen, tls.CertFile, tls.KeyFile)
+	} else {
+		log.Printf("Starting server on %s", listen)
+		err = s.engine.Run(listen)
+	}
+	if err != nil {
 		log.Fatal("Failed to start server: ", err)
 	}
 }
\ No newline at end of file
@@ -54,7 +54,7 @@ build_frontend() {
 build_backend() {
     print_message "Building backend..."
     go mod tidy
-    go build -ldflags="-s -w" -o crontab_go ./cmd
+    go build -tags sqlite_fts5 -ldflags="-s -w" -o crontab_go ./cmd
 }
 
 # 主函数
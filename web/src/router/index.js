@@ -48,6 +48,18 @@ const routes = [
     component: () => import('../views/Templates.vue'),
     meta: { requiresAuth: true }
   },
+  {
+    path: '/notification-channels',
+    name: 'NotificationChannels',
+    component: () => import('../views/NotificationChannels.vue'),
+    meta: { requiresAuth: true }
+  },
+  {
+    path: '/escalation-policies',
+    name: 'EscalationPolicies',
+    component: () => import('../views/EscalationPolicies.vue'),
+    meta: { requiresAuth: true }
+  },
   {
     path: '/system',
     name: 'System',
@@ -12,6 +12,11 @@ api.interceptors.request.use(
     if (token) {
       config.headers.Authorization = `Bearer ${token}`
     }
+    // 列表和统计接口按当前选择的项目筛选
+    const projectId = localStorage.getItem('projectId')
+    if (projectId && config.method === 'get' && config.params?.project_id === undefined) {
+      config.params = { ...config.params, project_id: projectId }
+    }
     return config
   },
   (error) => {
@@ -24,13 +29,48 @@ api.interceptors.response.use(
   (response) => {
     return response
   },
-  (error) => {
-    if (error.response?.status === 401) {
+  async (error) => {
+    const original = error.config
+    if (error.response?.status === 401 && original && !original._retried && !original.url.startsWith('/auth/')) {
+      // 访问令牌过期后使用刷新令牌获取新的令牌并重试一次
+      original._retried = true
+      try {
+        const token = await refreshToken()
+        original.headers.Authorization = `Bearer ${token}`
+        return api(original)
+      } catch (refreshError) {
+        // 刷新失败时按未登录处理
+      }
+    }
+    if (error.response?.status === 401 && !original?.url?.startsWith('/auth/')) {
       localStorage.removeItem('token')
+      localStorage.removeItem('refreshToken')
       window.location.href = '/login'
     }
     return Promise.reject(error)
   }
 )
 
+// 同时有多个请求遇到 401 时只刷新一次
+let refreshing = null
+
+const refreshToken = () => {
+  if (!refreshing) {
+    const token = localStorage.getItem('refreshToken')
+    if (!token) {
+      return Promise.reject(new Error('no refresh token'))
+    }
+    refreshing = axios.post('/api/v1/auth/refresh', { refresh_token: token })
+      .then((response) => {
+        localStorage.setItem('token', response.data.token)
+        localStorage.setItem('refreshToken', response.data.refresh_token)
+        return response.data.token
+      })
+      .finally(() => {
+        refreshing = null
+      })
+  }
+  return refreshing
+}
+
 export default api
\ No newline at end of file
@@ -7,19 +7,49 @@ export const useUserStore = defineStore('user', () => {
   const token = ref(localStorage.getItem('token'))
 
   const isAuthenticated = computed(() => !!token.value)
+  const isAdmin = computed(() => user.value?.role === 'admin')
+  // 操作员及以上角色可以创建任务和模板
+  const canOperate = computed(() => ['admin', 'operator'].includes(user.value?.role))
+
+  const projects = ref([])
+  // 当前选择的项目，为空时显示所有可见的任务
+  const currentProjectId = ref(Number(localStorage.getItem('projectId')) || null)
+  // 是否可以在当前项目中创建任务：未选择项目时需要操作员角色，选择项目时需要项目操作员角色
+  const canCreate = computed(() => {
+    if (!currentProjectId.value) {
+      return canOperate.value
+    }
+    const project = projects.value.find(p => p.id === currentProjectId.value)
+    return ['admin', 'operator'].includes(project?.role)
+  })
 
   const login = async (credentials) => {
     try {
       const response = await api.post('/auth/login', credentials)
-      token.value = response.data.token
-      user.value = response.data.user
-      localStorage.setItem('token', token.value)
-      return response.data
+      return setSession(response)
     } catch (error) {
       throw error
     }
   }
 
+  // 单点登录完成后用一次性登录码换取令牌
+  const loginWithSSO = async (code) => {
+    const response = await api.post('/auth/oidc/exchange', { code })
+    return setSession(response)
+  }
+
+  // 保存登录响应中的令牌和用户信息
+  const setSession = (response) => {
+    token.value = response.data.token
+    user.value = response.data.user
+    localStorage.setItem('token', token.value)
+    localStorage.setItem('refreshToken', response.data.refresh_token)
+    if (!user.value.must_change_password) {
+      fetchProjects().catch(() => {})
+    }
+    return response.data
+  }
+
   const register = async (userData) => {
     try {
       const response = await api.post('/auth/register', userData)
@@ -29,10 +59,43 @@ export const useUserStore = defineStore('user', () => {
     }
   }
 
+  const changePassword = async (oldPassword, newPassword) => {
+    await api.put('/user/password', { old_password: oldPassword, new_password: newPassword })
+    user.value = { ...user.value, must_change_password: false }
+    fetchProjects().catch(() => {})
+  }
+
   const logout = () => {
+    // 注销服务端会话，失败时（如令牌已过期）不影响本地退出
+    if (token.value) {
+      api.post('/auth/logout', null, { headers: { Authorization: `Bearer ${token.value}` } }).catch(() => {})
+    }
     user.value = null
     token.value = null
+    projects.value = []
+    currentProjectId.value = null
     localStorage.removeItem('token')
+    localStorage.removeItem('refreshToken')
+    localStorage.removeItem('projectId')
+  }
+
+  const fetchProjects = async () => {
+    const response = await api.get('/projects', { params: { project_id: '' } })
+    projects.value = response.data
+    // 已不再是成员的项目不能继续选择
+    if (currentProjectId.value && !projects.value.some(p => p.id === currentProjectId.value)) {
+      setProject(null)
+    }
+    return projects.value
+  }
+
+  const setProject = (projectId) => {
+    currentProjectId.value = projectId || null
+    if (projectId) {
+      localStorage.setItem('projectId', projectId)
+    } else {
+      localStorage.removeItem('projectId')
+    }
   }
 
   const fetchUser = async () => {
@@ -48,7 +111,12 @@ export const useUserStore = defineStore('user', () => {
 
   // 初始化时获取用户信息
   if (token.value) {
-    fetchUser().catch(() => {
+    fetchUser().then((current) => {
+      // 需要修改密码时其他接口不可用，修改密码后再加载项目
+      if (!current.must_change_password) {
+        fetchProjects().catch(() => {})
+      }
+    }).catch(() => {
       logout()
     })
   }
@@ -57,8 +125,17 @@ export const useUserStore = defineStore('user', () => {
     user,
     token,
     isAuthenticated,
+    isAdmin,
+    canOperate,
+    projects,
+    currentProjectId,
+    canCreate,
+    fetchProjects,
+    setProject,
     login,
+    loginWithSSO,
     register,
+    changePassword,
     logout,
     fetchUser
   }